@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/audit"
+	"github.com/rislah/fakes/internal/errors"
+)
+
+type SuspendUserRequest struct {
+	Reason string `json:"reason"`
+}
+
+type AccountStatusResponse struct {
+	UserID    string            `json:"user_id"`
+	Status    app.AccountStatus `json:"status"`
+	Reason    string            `json:"reason,omitempty"`
+	ChangedAt time.Time         `json:"changed_at"`
+}
+
+func (s *Mux) SuspendUser(ctx context.Context, response *Response, req *http.Request) error {
+	var suspendReq SuspendUserRequest
+	if err := json.NewDecoder(req.Body).Decode(&suspendReq); err != nil {
+		return err
+	}
+
+	return s.changeUserStatus(ctx, response, req, app.StatusSuspended, suspendReq.Reason)
+}
+
+func (s *Mux) ReinstateUser(ctx context.Context, response *Response, req *http.Request) error {
+	return s.changeUserStatus(ctx, response, req, app.StatusActive, "")
+}
+
+// changeUserStatus changes the status of the user in the path.
+func (s *Mux) changeUserStatus(ctx context.Context, response *Response, req *http.Request, status app.AccountStatus, reason string) error {
+	userID := mux.Vars(req)["user_id"]
+	usr, err := s.applyUserStatus(ctx, req, userID, status, reason)
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	return response.WriteJSON(AccountStatusResponse{
+		UserID:    usr.UserID,
+		Status:    usr.Status,
+		Reason:    usr.StatusReason,
+		ChangedAt: usr.StatusChangedAt,
+	})
+}
+
+// applyUserStatus changes the status of the user and records it in the
+// session store, so that the tokens of accounts that are no longer active are
+// rejected right away.
+func (s *Mux) applyUserStatus(ctx context.Context, req *http.Request, userID string, status app.AccountStatus, reason string) (app.User, error) {
+	usr, err := s.userBackend.ChangeUserStatus(ctx, userID, status, reason)
+	if err != nil {
+		return app.User{}, err
+	}
+
+	if err := s.sessions.SetAccountStatus(ctx, userID, usr.Status); err != nil {
+		return app.User{}, err
+	}
+
+	s.auditEvent(req, audit.Event{
+		Action:   audit.StatusChanged,
+		TargetID: userID,
+		Details:  audit.Details{"to": usr.Status.String(), "reason": usr.StatusReason},
+	})
+
+	if usr.Status != app.StatusActive {
+		if err := s.revokeSessions(ctx, req, userID, "status_changed"); err != nil {
+			return app.User{}, err
+		}
+	}
+
+	return usr, nil
+}
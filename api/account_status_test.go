@@ -0,0 +1,12 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestLocalAccountStatus(t *testing.T) {
+	tests.TestAPIAccountStatus(t, local.MakeUserDB, local.MakeRedis)
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rislah/fakes/internal/audit"
+	"github.com/rislah/fakes/internal/errors"
+	"github.com/rislah/fakes/internal/jwt"
+	"github.com/rislah/fakes/internal/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// scimActor is the actor of events recorded for SCIM clients.
+const scimActor = "scim"
+
+// WithAuditLog records audit events in log on top of logging them.
+func (s *Mux) WithAuditLog(log *audit.Log) *Mux {
+	s.auditLog = log
+	return s
+}
+
+// auditEvent records a security-relevant action together with the caller
+// that performed it. Users are recorded by ID only, see audit.Event. An event
+// that can't be recorded is logged as an error rather than failing a request
+// whose action already took place.
+func (s *Mux) auditEvent(req *http.Request, event audit.Event) {
+	log := s.logger
+	if log == nil {
+		log = logger.SharedGlobalLogger
+	}
+
+	ctx := req.Context()
+	if event.ActorID == "" && event.Actor == "" {
+		if claims, ok := ctx.Value(jwtClaimsKey).(*jwt.UserClaims); ok {
+			if claims.RegisteredClaims != nil {
+				event.ActorID = claims.Subject
+			}
+		} else if ctx.Value(scimClientKey) != nil {
+			event.Actor = scimActor
+		}
+	}
+
+	fields := logrus.Fields{"audit": true, "action": event.Action}
+	for k, v := range event.Details {
+		fields[k] = v
+	}
+	for k, v := range map[string]string{"actor": event.Actor, "actor_id": event.ActorID, "target_id": event.TargetID} {
+		if v != "" {
+			fields[k] = v
+		}
+	}
+
+	if s.auditLog != nil {
+		recorded, err := s.auditLog.Record(ctx, event)
+		if err != nil {
+			log.LogRequestError(errors.Wrap(err, "auditLog"), req)
+		} else {
+			fields["seq"] = recorded.Seq
+		}
+	}
+
+	log.InfoWithFields("audit event", fields)
+}
+
+// revokeSessions revokes the sessions of the user and records why.
+func (s *Mux) revokeSessions(ctx context.Context, req *http.Request, userID string, reason string) error {
+	if err := s.sessions.RevokeUserSessions(ctx, userID); err != nil {
+		return err
+	}
+
+	s.auditEvent(req, audit.Event{Action: audit.SessionsRevoked, TargetID: userID, Details: audit.Details{"reason": reason}})
+	return nil
+}
+
+// AuditEventsResponse is a page of events, newest first. NextCursor, also
+// advertised in the Link header, is empty on the last page.
+type AuditEventsResponse struct {
+	Events     []audit.Event `json:"events"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+func (s *Mux) GetAuditEvents(ctx context.Context, response *Response, req *http.Request) error {
+	if s.auditLog == nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, ErrAuditLogDisabled)
+	}
+
+	params := req.URL.Query()
+	query := audit.SearchQuery{
+		ActorID:  params.Get("actor_id"),
+		TargetID: params.Get("target_id"),
+		Action:   audit.Action(params.Get("action")),
+		Cursor:   params.Get("cursor"),
+	}
+
+	for name, t := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if value := params.Get(name); value != "" {
+			var err error
+			if *t, err = time.Parse(time.RFC3339, value); err != nil {
+				return errors.IsWrappedErrorWriteErrorResponse(ctx, response, ErrAuditTimeInvalid)
+			}
+		}
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		var err error
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit == 0 {
+			return errors.IsWrappedErrorWriteErrorResponse(ctx, response, audit.ErrPageSizeInvalid)
+		}
+	}
+
+	page, err := s.auditLog.Search(ctx, query)
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	if page.NextCursor != "" {
+		next := *req.URL
+		params.Set("cursor", page.NextCursor)
+		next.RawQuery = params.Encode()
+		response.Header().Set("Link", "<"+next.RequestURI()+`>; rel="next"`)
+	}
+
+	return response.WriteJSON(AuditEventsResponse{
+		Events:     page.Events,
+		NextCursor: page.NextCursor,
+	})
+}
+
+var (
+	ErrAuditLogDisabled = &errors.WrappedError{
+		Code: errors.ErrNotFound,
+		Msg:  "Audit log is not enabled",
+	}
+	ErrAuditTimeInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "from and to must be RFC 3339 timestamps",
+	}
+)
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/jwt"
+	"github.com/rislah/fakes/internal/policy"
+)
+
+// Authorization is the outcome of evaluating a route's access rules for a
+// caller. authMiddleware only looks at Allowed, the explain endpoint returns
+// all of it.
+type Authorization struct {
+	Allowed              bool       `json:"allowed"`
+	Reasons              []string   `json:"reasons"`
+	Organization         string     `json:"organization,omitempty"`
+	Role                 app.Role   `json:"role"`
+	InheritedRoles       []app.Role `json:"inherited_roles"`
+	GrantedRoles         []app.Role `json:"granted_roles"`
+	GrantedPermissions   []string   `json:"granted_permissions"`
+	EffectivePermissions []string   `json:"effective_permissions"`
+	RequiredPermissions  []string   `json:"required_permissions"`
+	MissingPermissions   []string   `json:"missing_permissions"`
+	RequiredRole         app.Role   `json:"required_role,omitempty"`
+	Policy               string     `json:"policy,omitempty"`
+	PolicyError          string     `json:"policy_error,omitempty"`
+
+	policyErr error
+}
+
+func (a *Authorization) deny(format string, args ...interface{}) {
+	a.Allowed = false
+	a.Reasons = append(a.Reasons, fmt.Sprintf(format, args...))
+}
+
+// authorize evaluates the route's permissions, role, ownership and policies
+// against claims. Every check runs so that a denial lists all of its reasons.
+// With an active organization the caller's role within it is used,
+// otherwise the roles and permissions granted through groups are added.
+func (r *Route) authorize(ctx context.Context, req *http.Request, claims *jwt.UserClaims) (Authorization, error) {
+	rbac := r.module.rbac
+	role := app.Role(claims.EffectiveRole())
+	grants := claimsGrants(claims)
+
+	authz := Authorization{
+		Allowed:             true,
+		Reasons:             []string{},
+		Organization:        claims.Organization,
+		Role:                role,
+		RequiredPermissions: append([]string{}, r.permissions...),
+		MissingPermissions:  []string{},
+		RequiredRole:        r.role,
+		GrantedRoles:        grants.Roles,
+		GrantedPermissions:  grants.Permissions,
+	}
+
+	inherited, err := rbac.InheritedRoles(ctx, role)
+	if err != nil {
+		return Authorization{}, err
+	}
+	authz.InheritedRoles = inherited
+
+	permissions, err := rbac.GrantedPermissions(ctx, role, grants)
+	if err != nil {
+		return Authorization{}, err
+	}
+	authz.EffectivePermissions = permissions
+
+	for _, permission := range r.permissions {
+		if !containsPermission(permissions, permission) {
+			authz.MissingPermissions = append(authz.MissingPermissions, permission)
+			authz.deny("missing permission %s", permission)
+		}
+	}
+
+	if r.role != "" && role != r.role && !containsRole(grants.Roles, r.role) {
+		authz.deny("role %s does not match required role %s", role, r.role)
+	}
+
+	if r.organization != nil {
+		if org := r.organization(req); claims.Organization != org {
+			authz.deny("active organization %q is not %q", claims.Organization, org)
+		}
+	}
+
+	if r.owner != nil {
+		isOwner := claims.RegisteredClaims != nil && claims.Subject != "" && claims.Subject == r.owner.owner(req)
+		if !isOwner && !containsPermission(permissions, r.owner.overridePermission) {
+			authz.deny("not the owner of the resource and missing permission %s", r.owner.overridePermission)
+		}
+	}
+
+	if len(r.policies) != 0 {
+		decision, err := policy.Evaluate(r.policies, newPolicyRequest(ctx, req, r.path, claims, r.module.geoIP))
+		authz.Policy = decision.Policy
+		if err != nil {
+			authz.PolicyError = err.Error()
+			authz.policyErr = err
+		}
+
+		if !decision.Allowed {
+			if decision.Policy != "" {
+				authz.deny("denied by policy %s", decision.Policy)
+			} else {
+				authz.deny("no allow policy matched")
+			}
+		}
+	}
+
+	return authz, nil
+}
+
+// claimsGrants returns the group grants carried by claims. They don't apply
+// within an organization.
+func claimsGrants(claims *jwt.UserClaims) app.Grants {
+	grants := app.Grants{Roles: []app.Role{}, Permissions: []string{}}
+	if claims.Organization != "" {
+		return grants
+	}
+
+	for _, role := range claims.Roles {
+		grants.Roles = append(grants.Roles, app.Role(role))
+	}
+	grants.Permissions = append(grants.Permissions, claims.Permissions...)
+
+	return grants
+}
+
+func containsRole(roles []app.Role, role app.Role) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPermission(permissions []string, permission string) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
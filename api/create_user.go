@@ -5,13 +5,20 @@ import (
 	"encoding/json"
 	"net/http"
 
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/audit"
 	"github.com/rislah/fakes/internal/credentials"
 	"github.com/rislah/fakes/internal/errors"
+	"github.com/rislah/fakes/internal/pow"
 )
 
 type CreateUserRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	// InviteCode is required when registration is invite-only. Email must
+	// match the email the invite was issued for, if any.
+	InviteCode string `json:"invite_code,omitempty"`
+	Email      string `json:"email,omitempty"`
 }
 
 type CreateUserResponse struct {
@@ -24,13 +31,39 @@ func (s *Mux) CreateUser(ctx context.Context, response *Response, req *http.Requ
 		return err
 	}
 
+	if err := s.checkProofOfWork(ctx, req, pow.ScopeRegister); err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+	s.recordAbuseSignal(ctx, req, pow.ScopeRegister)
+
 	creds := credentials.New(createUserReq.Username, createUserReq.Password)
-	if err := s.userBackend.CreateUser(ctx, creds); err != nil {
+	usr, err := s.registerUser(ctx, creds, createUserReq.InviteCode, createUserReq.Email)
+	if err != nil {
 		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
 	}
 
+	event := audit.Event{Action: audit.UserRegistered, ActorID: usr.UserID, TargetID: usr.UserID}
+	if createUserReq.InviteCode != "" && s.inviteBackend != nil {
+		event.Details = audit.Details{"invite": "true", "role": usr.Role.String()}
+	}
+	s.auditEvent(req, event)
+
 	response.WriteHeader(http.StatusCreated)
 	return response.WriteJSON(CreateUserResponse{
 		Username: createUserReq.Username,
 	})
 }
+
+// registerUser redeems the invite code when one is given or required, and
+// creates the user directly otherwise.
+func (s *Mux) registerUser(ctx context.Context, creds credentials.Credentials, inviteCode string, email string) (app.User, error) {
+	if s.inviteBackend != nil && (s.inviteOnly || inviteCode != "") {
+		return s.inviteBackend.Register(ctx, inviteCode, email, creds)
+	}
+
+	if err := s.userBackend.CreateUser(ctx, creds); err != nil {
+		return app.User{}, err
+	}
+
+	return s.userBackend.GetUserByUsername(ctx, creds.Username.String())
+}
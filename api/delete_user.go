@@ -0,0 +1,26 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rislah/fakes/internal/audit"
+	"github.com/rislah/fakes/internal/errors"
+)
+
+func (s *Mux) DeleteUser(ctx context.Context, response *Response, req *http.Request) error {
+	userID := mux.Vars(req)["user_id"]
+	if err := s.userBackend.DeleteUser(ctx, userID); err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	s.auditEvent(req, audit.Event{Action: audit.UserDeleted, TargetID: userID})
+
+	if err := s.revokeSessions(ctx, req, userID, "deleted"); err != nil {
+		return err
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	jwtPkg "github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
+	"github.com/rislah/fakes/internal/errors"
+	"github.com/rislah/fakes/internal/jwt"
+)
+
+var (
+	ErrExplainSubjectRequired = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Exactly one of token or username is required",
+	}
+
+	ErrExplainRouteNotFound = &errors.WrappedError{
+		Code: errors.ErrNotFound,
+		Msg:  "Route not found",
+	}
+)
+
+// ExplainAuthorizationRequest describes the request to explain: the caller,
+// identified by a token or a username, and the route it wants to access.
+// OrgID evaluates a username within one of its organizations.
+type ExplainAuthorizationRequest struct {
+	Token    string `json:"token"`
+	Username string `json:"username"`
+	OrgID    string `json:"org_id"`
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+}
+
+type ExplainAuthorizationResponse struct {
+	Method     string `json:"method"`
+	Route      string `json:"route"`
+	Username   string `json:"username,omitempty"`
+	TokenError string `json:"token_error,omitempty"`
+	Authorization
+}
+
+func (s *Mux) ExplainAuthorization(ctx context.Context, response *Response, req *http.Request) error {
+	var explainReq ExplainAuthorizationRequest
+	if err := json.NewDecoder(req.Body).Decode(&explainReq); err != nil {
+		return err
+	}
+
+	if (explainReq.Token == "") == (explainReq.Username == "") {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, ErrExplainSubjectRequired)
+	}
+
+	method := strings.ToUpper(explainReq.Method)
+	route, vars, ok := s.routeModule.Match(method, explainReq.Path)
+	if !ok {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, ErrExplainRouteNotFound)
+	}
+
+	res := ExplainAuthorizationResponse{
+		Method: method,
+		Route:  route.path,
+	}
+
+	if !route.requiresAuth() {
+		res.Authorization = Authorization{Allowed: true, Reasons: []string{"route does not require authorization"}}
+		return response.WriteJSON(res)
+	}
+
+	var claims *jwt.UserClaims
+	if explainReq.Token != "" {
+		decoded, err := s.jwtWrapper.Decode(explainReq.Token, &jwt.UserClaims{})
+		if err != nil {
+			tokenErr, ok := explainTokenError(ctx, err)
+			if !ok {
+				return err
+			}
+
+			res.TokenError = tokenErr
+			res.Authorization = Authorization{Reasons: []string{"invalid token: " + tokenErr}}
+			return response.WriteJSON(res)
+		}
+
+		claims = decoded.Claims.(*jwt.UserClaims)
+
+		sessionErr, err := s.routeModule.sessionError(ctx, claims)
+		if err != nil {
+			return err
+		}
+
+		if sessionErr != nil {
+			res.TokenError = sessionErr.Msg
+			res.Authorization = Authorization{Reasons: []string{"invalid token: " + sessionErr.Msg}}
+			return response.WriteJSON(res)
+		}
+	} else {
+		usr, err := s.userBackend.GetUserByUsername(ctx, explainReq.Username)
+		if err != nil {
+			return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+		}
+
+		claims = &jwt.UserClaims{
+			RegisteredClaims: &jwtPkg.RegisteredClaims{Subject: usr.UserID},
+			Username:         usr.Username,
+			Role:             usr.Role.String(),
+		}
+
+		grants, err := s.groupBackend.GetUserGrants(ctx, usr.UserID)
+		if err != nil {
+			return err
+		}
+		for _, role := range grants.Roles {
+			claims.Roles = append(claims.Roles, role.String())
+		}
+		claims.Permissions = grants.Permissions
+
+		if explainReq.OrgID != "" {
+			membership, err := s.orgBackend.GetMembership(ctx, explainReq.OrgID, usr.UserID)
+			if err != nil {
+				return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+			}
+
+			claims.Organization = membership.OrgID
+			claims.OrganizationRole = membership.Role.String()
+		}
+	}
+	res.Username = claims.Username
+
+	routeReq, err := http.NewRequestWithContext(ctx, method, explainReq.Path, nil)
+	if err != nil {
+		return err
+	}
+	routeReq = mux.SetURLVars(routeReq, vars)
+
+	authz, err := route.authorize(ctx, routeReq, claims)
+	if err != nil {
+		return err
+	}
+	res.Authorization = authz
+
+	return response.WriteJSON(res)
+}
+
+// explainTokenError describes why a token was rejected. It returns false for
+// errors that aren't caused by the token itself.
+func explainTokenError(ctx context.Context, err error) (string, bool) {
+	if e, ok := errors.Unwrap(err).(*jwtPkg.ValidationError); ok {
+		return e.Error(), true
+	}
+
+	if e, ok := errors.IsWrappedError(ctx, err); ok {
+		return e.Msg, true
+	}
+
+	return "", false
+}
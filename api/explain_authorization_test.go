@@ -0,0 +1,12 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestLocalExplainAuthorization(t *testing.T) {
+	tests.TestAPIExplainAuthorization(t, local.MakeUserDB, local.MakeRedis)
+}
@@ -3,35 +3,87 @@ package api
 import (
 	"context"
 	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
 
 	app "github.com/rislah/fakes/internal"
 	"github.com/rislah/fakes/internal/errors"
 )
 
 type GetUsersResponse struct {
-	UserID   string   `json:"user_id"`
-	Username string   `json:"username"`
-	Role     app.Role `json:"role"`
+	UserID      string   `json:"user_id"`
+	Username    string   `json:"username"`
+	Role        app.Role `json:"role"`
+	DisplayName string   `json:"display_name,omitempty"`
+	AvatarURL   string   `json:"avatar_url,omitempty"`
+	Locale      string   `json:"locale,omitempty"`
+	Timezone    string   `json:"timezone,omitempty"`
+}
+
+func newGetUsersResponse(usr app.User) GetUsersResponse {
+	return GetUsersResponse{
+		UserID:      usr.UserID,
+		Username:    usr.Username,
+		Role:        usr.Role,
+		DisplayName: usr.DisplayName,
+		AvatarURL:   usr.AvatarURL,
+		Locale:      usr.Locale,
+		Timezone:    usr.Timezone,
+	}
+}
+
+// GetUsersPageResponse is a page of users. NextCursor, also advertised in the
+// Link header, is empty on the last page.
+type GetUsersPageResponse struct {
+	Users      []GetUsersResponse `json:"users"`
+	NextCursor string             `json:"next_cursor,omitempty"`
 }
 
 func (s *Mux) GetUsers(ctx context.Context, response *Response, request *http.Request) error {
-	users, err := s.userBackend.GetUsers(ctx)
-	if err != nil {
-		if e, ok := errors.IsWrappedError(ctx, err); ok {
-			response.WriteHeader(int(e.Code))
-			return response.WriteJSON(errors.NewErrorResponse(e.Msg, int(e.Code)))
+	params := request.URL.Query()
+	query := app.UserQuery{
+		Cursor:         params.Get("cursor"),
+		Role:           app.Role(params.Get("role")),
+		UsernamePrefix: params.Get("username_prefix"),
+		Sort:           app.UserSort(params.Get("sort")),
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		var err error
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit == 0 {
+			return errors.IsWrappedErrorWriteErrorResponse(ctx, response, app.ErrInvalidPageSize)
 		}
-		return err
 	}
 
-	var res []GetUsersResponse
-	for _, usr := range users {
-		res = append(res, GetUsersResponse{
-			UserID:   usr.UserID,
-			Username: usr.Username,
-			Role:     usr.Role,
-		})
+	page, err := s.userBackend.ListUsers(ctx, query)
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	res := GetUsersPageResponse{
+		Users:      []GetUsersResponse{},
+		NextCursor: page.NextCursor,
+	}
+	for _, usr := range page.Users {
+		res.Users = append(res.Users, newGetUsersResponse(usr))
+	}
+
+	if page.NextCursor != "" {
+		next := *request.URL
+		params.Set("cursor", page.NextCursor)
+		next.RawQuery = params.Encode()
+		response.Header().Set("Link", "<"+next.RequestURI()+`>; rel="next"`)
 	}
 
 	return response.WriteJSON(res)
 }
+
+func (s *Mux) GetUser(ctx context.Context, response *Response, request *http.Request) error {
+	usr, err := s.userBackend.GetUser(ctx, mux.Vars(request)["user_id"])
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	return response.WriteJSON(newGetUsersResponse(usr))
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/audit"
+	"github.com/rislah/fakes/internal/errors"
+)
+
+type GroupRequest struct {
+	Name        string     `json:"name"`
+	Roles       []app.Role `json:"roles"`
+	Permissions []string   `json:"permissions"`
+}
+
+type GroupResponse struct {
+	GroupID     string     `json:"group_id"`
+	Name        string     `json:"name"`
+	Roles       []app.Role `json:"roles"`
+	Permissions []string   `json:"permissions"`
+}
+
+type GroupMembersResponse struct {
+	GroupID string   `json:"group_id"`
+	UserIDs []string `json:"user_ids"`
+}
+
+func newGroupResponse(group app.Group) GroupResponse {
+	res := GroupResponse{
+		GroupID:     group.GroupID,
+		Name:        group.Name,
+		Roles:       group.Roles,
+		Permissions: group.Permissions,
+	}
+	if res.Roles == nil {
+		res.Roles = []app.Role{}
+	}
+	if res.Permissions == nil {
+		res.Permissions = []string{}
+	}
+	return res
+}
+
+func (s *Mux) GetGroups(ctx context.Context, response *Response, req *http.Request) error {
+	groups, err := s.groupBackend.GetGroups(ctx)
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	res := []GroupResponse{}
+	for _, group := range groups {
+		res = append(res, newGroupResponse(group))
+	}
+
+	return response.WriteJSON(res)
+}
+
+func (s *Mux) CreateGroup(ctx context.Context, response *Response, req *http.Request) error {
+	var groupReq GroupRequest
+	if err := json.NewDecoder(req.Body).Decode(&groupReq); err != nil {
+		return err
+	}
+
+	group, err := s.groupBackend.CreateGroup(ctx, app.Group{
+		Name:        groupReq.Name,
+		Roles:       groupReq.Roles,
+		Permissions: groupReq.Permissions,
+	})
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	s.auditEvent(req, audit.Event{Action: audit.GroupCreated, TargetID: group.GroupID, Details: audit.Details{"name": group.Name}})
+
+	response.WriteHeader(http.StatusCreated)
+	return response.WriteJSON(newGroupResponse(group))
+}
+
+func (s *Mux) UpdateGroup(ctx context.Context, response *Response, req *http.Request) error {
+	var groupReq GroupRequest
+	if err := json.NewDecoder(req.Body).Decode(&groupReq); err != nil {
+		return err
+	}
+
+	group := app.Group{
+		GroupID:     mux.Vars(req)["group_id"],
+		Name:        groupReq.Name,
+		Roles:       groupReq.Roles,
+		Permissions: groupReq.Permissions,
+	}
+
+	previous, err := s.groupBackend.GetGroup(ctx, group.GroupID)
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	if err := s.groupBackend.UpdateGroup(ctx, group); err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	s.auditEvent(req, audit.Event{Action: audit.GroupUpdated, TargetID: group.GroupID})
+
+	if grantsRemoved(previous, group) {
+		if err := s.revokeGroupSessions(ctx, req, group.GroupID, "group_grants_removed"); err != nil {
+			return err
+		}
+	}
+
+	return response.WriteJSON(newGroupResponse(group))
+}
+
+func (s *Mux) DeleteGroup(ctx context.Context, response *Response, req *http.Request) error {
+	if err := s.deleteGroup(ctx, req, mux.Vars(req)["group_id"]); err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// deleteGroup deletes the group and revokes the sessions of its members,
+// whose tokens carry the grants of the group.
+func (s *Mux) deleteGroup(ctx context.Context, req *http.Request, groupID string) error {
+	members, err := s.groupBackend.GetGroupMembers(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.groupBackend.DeleteGroup(ctx, groupID); err != nil {
+		return err
+	}
+
+	s.auditEvent(req, audit.Event{Action: audit.GroupDeleted, TargetID: groupID})
+
+	for _, userID := range members {
+		if err := s.revokeSessions(ctx, req, userID, "group_deleted"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// revokeGroupSessions revokes the sessions of the members of the group.
+func (s *Mux) revokeGroupSessions(ctx context.Context, req *http.Request, groupID string, reason string) error {
+	members, err := s.groupBackend.GetGroupMembers(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range members {
+		if err := s.revokeSessions(ctx, req, userID, reason); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// grantsRemoved reports whether group lacks a role or permission that
+// previous had.
+func grantsRemoved(previous app.Group, group app.Group) bool {
+	roles := map[app.Role]bool{}
+	for _, role := range group.Roles {
+		roles[role] = true
+	}
+	for _, role := range previous.Roles {
+		if !roles[role] {
+			return true
+		}
+	}
+
+	permissions := map[string]bool{}
+	for _, permission := range group.Permissions {
+		permissions[permission] = true
+	}
+	for _, permission := range previous.Permissions {
+		if !permissions[permission] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Mux) GetGroupMembers(ctx context.Context, response *Response, req *http.Request) error {
+	groupID := mux.Vars(req)["group_id"]
+	members, err := s.groupBackend.GetGroupMembers(ctx, groupID)
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	return response.WriteJSON(GroupMembersResponse{
+		GroupID: groupID,
+		UserIDs: members,
+	})
+}
+
+func (s *Mux) AddGroupMember(ctx context.Context, response *Response, req *http.Request) error {
+	vars := mux.Vars(req)
+	if err := s.groupBackend.AddGroupMember(ctx, vars["group_id"], vars["user_id"]); err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	s.auditEvent(req, audit.Event{Action: audit.GroupMemberAdded, TargetID: vars["user_id"], Details: audit.Details{"group_id": vars["group_id"]}})
+
+	response.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Mux) RemoveGroupMember(ctx context.Context, response *Response, req *http.Request) error {
+	vars := mux.Vars(req)
+	if err := s.groupBackend.RemoveGroupMember(ctx, vars["group_id"], vars["user_id"]); err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	s.auditEvent(req, audit.Event{Action: audit.GroupMemberRemoved, TargetID: vars["user_id"], Details: audit.Details{"group_id": vars["group_id"]}})
+
+	if err := s.revokeSessions(ctx, req, vars["user_id"], "group_member_removed"); err != nil {
+		return err
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+	return nil
+}
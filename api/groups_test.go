@@ -0,0 +1,12 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestLocalGroups(t *testing.T) {
+	tests.TestAPIGroups(t, local.MakeGroupDB, local.MakeRedis)
+}
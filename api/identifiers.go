@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/audit"
+	"github.com/rislah/fakes/internal/errors"
+	"github.com/rislah/fakes/internal/jwt"
+)
+
+type AddIdentifierRequest struct {
+	Value string `json:"value"`
+}
+
+type VerifyIdentifierRequest struct {
+	Code string `json:"code"`
+}
+
+type IdentifierResponse struct {
+	// IdentifierID is empty for the username.
+	IdentifierID string             `json:"identifier_id,omitempty"`
+	Type         app.IdentifierType `json:"type"`
+	Value        string             `json:"value"`
+	Verified     bool               `json:"verified"`
+}
+
+func newIdentifierResponse(identifier app.Identifier) IdentifierResponse {
+	return IdentifierResponse{
+		IdentifierID: identifier.IdentifierID,
+		Type:         identifier.Type,
+		Value:        identifier.Value,
+		Verified:     identifier.Verified,
+	}
+}
+
+// WithIdentifiers enables the endpoints users manage the emails and phone
+// numbers they can log in with through. Logging in with them works
+// regardless, once they're verified.
+func (s *Mux) WithIdentifiers(identifiers app.IdentifierBackend) *Mux {
+	s.identifierBackend = identifiers
+	return s
+}
+
+func (s *Mux) GetIdentifiers(ctx context.Context, response *Response, req *http.Request) error {
+	if s.identifierBackend == nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, ErrIdentifiersDisabled)
+	}
+
+	claims, _ := ctx.Value(jwtClaimsKey).(*jwt.UserClaims)
+	identifiers, err := s.identifierBackend.ListIdentifiers(ctx, claims.Subject)
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	res := []IdentifierResponse{}
+	for _, identifier := range identifiers {
+		res = append(res, newIdentifierResponse(identifier))
+	}
+
+	return response.WriteJSON(res)
+}
+
+func (s *Mux) AddIdentifier(ctx context.Context, response *Response, req *http.Request) error {
+	if s.identifierBackend == nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, ErrIdentifiersDisabled)
+	}
+
+	var identifierReq AddIdentifierRequest
+	if err := json.NewDecoder(req.Body).Decode(&identifierReq); err != nil {
+		return err
+	}
+
+	claims, _ := ctx.Value(jwtClaimsKey).(*jwt.UserClaims)
+	identifier, err := s.identifierBackend.AddIdentifier(ctx, claims.Subject, identifierReq.Value)
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	s.auditEvent(req, audit.Event{
+		Action:   audit.IdentifierAdded,
+		TargetID: claims.Subject,
+		Details:  audit.Details{"identifier_id": identifier.IdentifierID, "type": string(identifier.Type)},
+	})
+
+	response.WriteHeader(http.StatusCreated)
+	return response.WriteJSON(newIdentifierResponse(identifier))
+}
+
+func (s *Mux) VerifyIdentifier(ctx context.Context, response *Response, req *http.Request) error {
+	if s.identifierBackend == nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, ErrIdentifiersDisabled)
+	}
+
+	var verifyReq VerifyIdentifierRequest
+	if err := json.NewDecoder(req.Body).Decode(&verifyReq); err != nil {
+		return err
+	}
+
+	claims, _ := ctx.Value(jwtClaimsKey).(*jwt.UserClaims)
+	identifier, err := s.identifierBackend.VerifyIdentifier(ctx, claims.Subject, mux.Vars(req)["identifier_id"], verifyReq.Code)
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	s.auditEvent(req, audit.Event{
+		Action:   audit.IdentifierVerified,
+		TargetID: claims.Subject,
+		Details:  audit.Details{"identifier_id": identifier.IdentifierID, "type": string(identifier.Type)},
+	})
+
+	return response.WriteJSON(newIdentifierResponse(identifier))
+}
+
+func (s *Mux) RemoveIdentifier(ctx context.Context, response *Response, req *http.Request) error {
+	if s.identifierBackend == nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, ErrIdentifiersDisabled)
+	}
+
+	claims, _ := ctx.Value(jwtClaimsKey).(*jwt.UserClaims)
+	identifierID := mux.Vars(req)["identifier_id"]
+	if err := s.identifierBackend.RemoveIdentifier(ctx, claims.Subject, identifierID); err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	s.auditEvent(req, audit.Event{
+		Action:   audit.IdentifierRemoved,
+		TargetID: claims.Subject,
+		Details:  audit.Details{"identifier_id": identifierID},
+	})
+
+	response.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+var (
+	ErrIdentifiersDisabled = &errors.WrappedError{
+		Code: errors.ErrNotFound,
+		Msg:  "Login identifiers are not enabled",
+	}
+)
@@ -0,0 +1,12 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestLocalIdentifiers(t *testing.T) {
+	tests.TestAPIIdentifiers(t, local.MakeIdentifierDB, local.MakeRedis)
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/audit"
+	"github.com/rislah/fakes/internal/errors"
+	"github.com/rislah/fakes/internal/jwt"
+)
+
+type CreateInviteRequest struct {
+	Email     string    `json:"email,omitempty"`
+	Role      app.Role  `json:"role,omitempty"`
+	MaxUses   int       `json:"max_uses,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+type InviteResponse struct {
+	InviteID string `json:"invite_id"`
+	// Code is only returned when the invite is created.
+	Code      string    `json:"code,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	Role      app.Role  `json:"role"`
+	MaxUses   int       `json:"max_uses"`
+	Uses      int       `json:"uses"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newInviteResponse(invite app.Invite) InviteResponse {
+	return InviteResponse{
+		InviteID:  invite.InviteID,
+		Code:      invite.Code,
+		Email:     invite.Email,
+		Role:      invite.Role,
+		MaxUses:   invite.MaxUses,
+		Uses:      invite.Uses,
+		ExpiresAt: invite.ExpiresAt,
+		CreatedBy: invite.CreatedBy,
+		CreatedAt: invite.CreatedAt,
+	}
+}
+
+// WithInvites enables the invite endpoints. When inviteOnly is set,
+// registering requires an invite code; otherwise a code is optional and
+// grants the role of its invite.
+func (s *Mux) WithInvites(invites app.InviteBackend, inviteOnly bool) *Mux {
+	s.inviteBackend = invites
+	s.inviteOnly = invites != nil && inviteOnly
+	return s
+}
+
+func (s *Mux) GetInvites(ctx context.Context, response *Response, req *http.Request) error {
+	if s.inviteBackend == nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, ErrInvitesDisabled)
+	}
+
+	invites, err := s.inviteBackend.ListPendingInvites(ctx)
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	res := []InviteResponse{}
+	for _, invite := range invites {
+		res = append(res, newInviteResponse(invite))
+	}
+
+	return response.WriteJSON(res)
+}
+
+func (s *Mux) CreateInvite(ctx context.Context, response *Response, req *http.Request) error {
+	if s.inviteBackend == nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, ErrInvitesDisabled)
+	}
+
+	var inviteReq CreateInviteRequest
+	if err := json.NewDecoder(req.Body).Decode(&inviteReq); err != nil {
+		return err
+	}
+
+	claims, _ := ctx.Value(jwtClaimsKey).(*jwt.UserClaims)
+	invite, err := s.inviteBackend.CreateInvite(ctx, app.InviteSpec{
+		Email:     inviteReq.Email,
+		Role:      inviteReq.Role,
+		MaxUses:   inviteReq.MaxUses,
+		ExpiresAt: inviteReq.ExpiresAt,
+		CreatedBy: claims.Subject,
+	})
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	s.auditEvent(req, audit.Event{
+		Action:   audit.InviteCreated,
+		TargetID: invite.InviteID,
+		Details: audit.Details{
+			"email_bound": strconv.FormatBool(invite.Email != ""),
+			"role":        invite.Role.String(),
+			"max_uses":    strconv.Itoa(invite.MaxUses),
+			"expires_at":  invite.ExpiresAt.Format(time.RFC3339),
+		},
+	})
+
+	response.WriteHeader(http.StatusCreated)
+	return response.WriteJSON(newInviteResponse(invite))
+}
+
+func (s *Mux) RevokeInvite(ctx context.Context, response *Response, req *http.Request) error {
+	if s.inviteBackend == nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, ErrInvitesDisabled)
+	}
+
+	inviteID := mux.Vars(req)["invite_id"]
+	if err := s.inviteBackend.RevokeInvite(ctx, inviteID); err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	s.auditEvent(req, audit.Event{Action: audit.InviteRevoked, TargetID: inviteID})
+
+	response.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+var (
+	ErrInvitesDisabled = &errors.WrappedError{
+		Code: errors.ErrNotFound,
+		Msg:  "Invites are not enabled",
+	}
+)
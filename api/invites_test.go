@@ -0,0 +1,12 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestLocalInvites(t *testing.T) {
+	tests.TestAPIInvites(t, local.MakeUserDB, local.MakeRedis)
+}
@@ -6,9 +6,11 @@ import (
 	"net"
 	"net/http"
 
+	"github.com/rislah/fakes/internal/audit"
 	"github.com/rislah/fakes/internal/credentials"
 
 	"github.com/rislah/fakes/internal/errors"
+	"github.com/rislah/fakes/internal/pow"
 	"github.com/rislah/fakes/internal/ratelimiter"
 )
 
@@ -18,7 +20,10 @@ type LoginResponse struct {
 
 type LoginRequest struct {
 	Username string `json:"username"`
-	Password string `json:"password"`
+	// Identifier is a username or a verified email or phone number, used
+	// instead of Username when set.
+	Identifier string `json:"identifier,omitempty"`
+	Password   string `json:"password"`
 }
 
 func (s *Mux) Login(ctx context.Context, response *Response, req *http.Request) error {
@@ -27,17 +32,40 @@ func (s *Mux) Login(ctx context.Context, response *Response, req *http.Request)
 		return err
 	}
 
-	creds := credentials.New(loginReq.Username, loginReq.Password)
+	if err := s.checkProofOfWork(ctx, req, pow.ScopeLogin); err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	identifier := loginReq.Username
+	if loginReq.Identifier != "" {
+		identifier = loginReq.Identifier
+	}
+
+	creds := credentials.New(identifier, loginReq.Password)
 	usr, err := s.authenticator.AuthenticatePassword(ctx, creds)
 	if err != nil {
+		s.recordAbuseSignal(ctx, req, pow.ScopeLogin)
+		if wrapped, ok := errors.IsWrappedError(ctx, err); ok {
+			s.auditEvent(req, audit.Event{
+				Action:  audit.LoginFailed,
+				Details: audit.Details{"reason": wrapped.Msg},
+			})
+		}
 		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
 	}
 
-	token, err := s.authenticator.GenerateJWT(usr)
+	grants, err := s.groupBackend.GetUserGrants(ctx, usr.UserID)
 	if err != nil {
 		return err
 	}
 
+	token, err := s.authenticator.GenerateJWT(usr, grants)
+	if err != nil {
+		return err
+	}
+
+	s.auditEvent(req, audit.Event{Action: audit.LoginSucceeded, ActorID: usr.UserID, TargetID: usr.UserID})
+
 	return response.WriteJSON(LoginResponse{
 		Token: token,
 	})
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"net/http"
+)
+
+// RouteManifestEntry describes what a caller needs to access a route.
+type RouteManifestEntry struct {
+	Method       string `json:"method"`
+	Path         string `json:"path"`
+	AuthRequired bool   `json:"auth_required"`
+	// Role is the role required by the route. Callers must hold it themselves
+	// or through a group; roles inheriting from it don't satisfy it.
+	Role        string   `json:"role,omitempty"`
+	Permissions []string `json:"permissions"`
+	Policies    []string `json:"policies,omitempty"`
+	// Owned routes are limited to the owner of the resource and to callers
+	// holding OwnerOverridePermission.
+	Owned                   bool   `json:"owned,omitempty"`
+	OwnerOverridePermission string `json:"owner_override_permission,omitempty"`
+	OrganizationScoped      bool   `json:"organization_scoped,omitempty"`
+	// ProvisioningToken routes authenticate with the SCIM provisioning token
+	// instead of a user token.
+	ProvisioningToken bool `json:"provisioning_token,omitempty"`
+}
+
+// Manifest lists the registered routes in registration order.
+func (r *RouteModule) Manifest() []RouteManifestEntry {
+	manifest := make([]RouteManifestEntry, 0, len(r.routes))
+	for _, route := range r.routes {
+		entry := RouteManifestEntry{
+			Method:             route.method,
+			Path:               route.path,
+			AuthRequired:       route.requiresAuth(),
+			Role:               route.role.String(),
+			Permissions:        append([]string{}, route.permissions...),
+			OrganizationScoped: route.organization != nil,
+		}
+
+		for _, p := range route.policies {
+			entry.Policies = append(entry.Policies, p.Name)
+		}
+
+		if route.owner != nil {
+			entry.Owned = true
+			entry.OwnerOverridePermission = route.owner.overridePermission
+		}
+
+		manifest = append(manifest, entry)
+	}
+
+	return manifest
+}
+
+// RouteManifest returns the manifest of the routes served by the mux,
+// including the SCIM provisioning routes.
+func (s *Mux) RouteManifest() []RouteManifestEntry {
+	return append(s.routeModule.Manifest(), s.scimManifest...)
+}
+
+func (s *Mux) GetRouteManifest(ctx context.Context, response *Response, req *http.Request) error {
+	return response.WriteJSON(s.RouteManifest())
+}
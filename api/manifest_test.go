@@ -0,0 +1,201 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rislah/fakes/api"
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/geoip"
+	"github.com/rislah/fakes/internal/jwt"
+	"github.com/rislah/fakes/internal/local"
+	"github.com/stretchr/testify/assert"
+)
+
+func newManifestMux(t *testing.T, rbac app.RBAC) (*api.Mux, jwt.Wrapper) {
+	redis, teardown, err := local.MakeRedis()
+	assert.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, teardown()) })
+
+	db := local.NewUserDB()
+	jwtWrapper := jwt.NewHS256Wrapper("secret")
+	apiMux := api.NewMux(app.NewUserBackend(db, jwtWrapper), app.NewOrganizationBackend(local.NewUserDB()), app.NewGroupBackend(local.NewUserDB()), nil, app.NewAuthenticator(db, jwtWrapper), rbac, jwtWrapper, geoip.GeoIP{}, redis, nil)
+
+	return apiMux, jwtWrapper
+}
+
+func TestRouteManifestPermissionsAreGranted(t *testing.T) {
+	ctx := context.Background()
+	rbac := app.NewRBAC(local.NewRoleDB())
+	apiMux, _ := newManifestMux(t, rbac)
+
+	roles, err := rbac.GetRoles(ctx)
+	assert.NoError(t, err)
+
+	granted := map[string]bool{}
+	known := map[string]bool{}
+	for _, role := range roles {
+		known[role.Name.String()] = true
+		for _, permission := range role.Permissions {
+			granted[permission] = true
+		}
+	}
+
+	for _, entry := range apiMux.RouteManifest() {
+		for _, permission := range entry.Permissions {
+			assert.True(t, granted[permission], "%s %s requires %q which no role grants", entry.Method, entry.Path, permission)
+		}
+
+		if entry.OwnerOverridePermission != "" {
+			assert.True(t, granted[entry.OwnerOverridePermission], "%s %s is overridden by %q which no role grants", entry.Method, entry.Path, entry.OwnerOverridePermission)
+		}
+
+		if entry.Role != "" {
+			assert.True(t, known[entry.Role], "%s %s requires unknown role %q", entry.Method, entry.Path, entry.Role)
+		}
+	}
+}
+
+func TestRouteManifestListsServedRoutes(t *testing.T) {
+	apiMux, _ := newManifestMux(t, app.NewRBAC(local.NewRoleDB()))
+
+	listed := map[string]bool{}
+	for _, entry := range apiMux.RouteManifest() {
+		listed[entry.Method+" "+entry.Path] = true
+	}
+
+	served := map[string]bool{}
+	err := apiMux.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+
+		// Routes without methods are path prefixes of subrouters, or
+		// /metrics, which is not part of the API.
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+
+		for _, method := range methods {
+			served[method+" "+path] = true
+		}
+
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, served, listed)
+}
+
+var (
+	sqlStatementRe = regexp.MustCompile(`(?is)insert\s+into\s+(role|role_permission)\s.*?;`)
+	sqlStringRe    = regexp.MustCompile(`'([^']*)'`)
+)
+
+// migrationGrants returns the roles the Postgres migrations create and the
+// names quoted in their role_permission inserts. The Postgres schema is the
+// only one backing a role database.
+func migrationGrants(t *testing.T) (roles, granted map[string]bool) {
+	files, err := filepath.Glob("../migrations/*.up.sql")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, files)
+
+	roles = map[string]bool{}
+	granted = map[string]bool{}
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		assert.NoError(t, err)
+
+		for _, statement := range sqlStatementRe.FindAllStringSubmatch(string(b), -1) {
+			names := roles
+			if strings.EqualFold(statement[1], "role_permission") {
+				names = granted
+			}
+
+			for _, name := range sqlStringRe.FindAllStringSubmatch(statement[0], -1) {
+				names[name[1]] = true
+			}
+		}
+	}
+
+	return roles, granted
+}
+
+func TestRouteManifestPermissionsAreGrantedByMigrations(t *testing.T) {
+	apiMux, _ := newManifestMux(t, app.NewRBAC(local.NewRoleDB()))
+	roles, granted := migrationGrants(t)
+
+	for _, entry := range apiMux.RouteManifest() {
+		for _, permission := range entry.Permissions {
+			assert.True(t, granted[permission], "%s %s requires %q which no migration grants", entry.Method, entry.Path, permission)
+		}
+
+		if entry.OwnerOverridePermission != "" {
+			assert.True(t, granted[entry.OwnerOverridePermission], "%s %s is overridden by %q which no migration grants", entry.Method, entry.Path, entry.OwnerOverridePermission)
+		}
+
+		if entry.Role != "" {
+			assert.True(t, roles[entry.Role], "%s %s requires role %q which no migration creates", entry.Method, entry.Path, entry.Role)
+		}
+	}
+}
+
+func TestGetRouteManifest(t *testing.T) {
+	apiMux, jwtWrapper := newManifestMux(t, app.NewRBAC(local.NewRoleDB()))
+
+	get := func(role app.Role) *httptest.ResponseRecorder {
+		token, err := jwtWrapper.Encode(jwt.NewUserClaims("admin", role.String()))
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/routes", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		apiMux.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	rr := get(app.DeveloperRole)
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode)
+
+	rr = get(app.AdminRole)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	var manifest []api.RouteManifestEntry
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&manifest))
+	assert.Contains(t, manifest, api.RouteManifestEntry{
+		Method:       "PUT",
+		Path:         "/users/{user_id}/role",
+		AuthRequired: true,
+		Permissions:  []string{app.AssignRoles},
+	})
+	assert.Contains(t, manifest, api.RouteManifestEntry{
+		Method:       "POST",
+		Path:         "/login",
+		AuthRequired: false,
+		Permissions:  []string{},
+	})
+	assert.Contains(t, manifest, api.RouteManifestEntry{
+		Method:             "POST",
+		Path:               "/organizations/{org_id}/members",
+		AuthRequired:       true,
+		Permissions:        []string{app.ManageMembers},
+		OrganizationScoped: true,
+	})
+	assert.Contains(t, manifest, api.RouteManifestEntry{
+		Method:            "DELETE",
+		Path:              "/scim/v2/Users/{user_id}",
+		AuthRequired:      true,
+		Permissions:       []string{},
+		ProvisioningToken: true,
+	})
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/rislah/fakes/internal/errors"
 	"github.com/rislah/fakes/internal/geoip"
 	"github.com/rislah/fakes/internal/jwt"
+	"github.com/rislah/fakes/internal/policy"
 	"github.com/rislah/fakes/internal/ratelimiter"
 
 	"github.com/rislah/fakes/internal/logger"
@@ -191,11 +192,18 @@ var (
 		Msg:  "Insufficient privileges",
 		Code: http.StatusUnauthorized,
 	}
+
+	ErrAuthSessionRevoked = &errors.WrappedError{
+		Msg:  "Session has been revoked",
+		Code: http.StatusUnauthorized,
+	}
 )
 
 const jwtClaimsKey ContextKey = "jwt_claims"
 
-func (r *Route) authMiddleware(h http.Handler, jwtWrapper jwt.Wrapper) http.Handler {
+func (r *Route) authMiddleware(h http.Handler) http.Handler {
+	jwtWrapper := r.module.jwtWrapper
+
 	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		resp := &Response{ResponseWriter: rw}
 		ctx := req.Context()
@@ -230,6 +238,20 @@ func (r *Route) authMiddleware(h http.Handler, jwtWrapper jwt.Wrapper) http.Hand
 				return
 			}
 
+			sessionErr, err := r.module.sessionError(ctx, decoded.Claims.(*jwt.UserClaims))
+			if err != nil {
+				resp.WriteHeader(http.StatusInternalServerError)
+				resp.WriteJSON(errors.NewErrorResponse("Internal server error has occured", http.StatusInternalServerError))
+				logger.SharedGlobalLogger.LogRequestError(err, req)
+				return
+			}
+
+			if sessionErr != nil {
+				resp.WriteHeader(int(sessionErr.Code))
+				resp.WriteJSON(errors.NewErrorResponse(sessionErr.Msg, int(sessionErr.Code)))
+				return
+			}
+
 			jwtClaims = decoded.Claims
 			ctx = context.WithValue(ctx, jwtClaimsKey, decoded.Claims)
 		}
@@ -240,28 +262,71 @@ func (r *Route) authMiddleware(h http.Handler, jwtWrapper jwt.Wrapper) http.Hand
 			return
 		}
 
-		if len(r.permissions) != 0 {
-			for _, permission := range r.permissions {
-				if !app.DoesRoleHavePermission(app.Role(userClaims.Role), permission) {
-					resp.WriteHeader(int(ErrAuthInsufficientPrivileges.Code))
-					resp.WriteJSON(errors.NewErrorResponse(ErrAuthInsufficientPrivileges.Msg, int(ErrAuthInsufficientPrivileges.Code)))
-					return
-				}
-			}
+		authz, err := r.authorize(ctx, req, userClaims)
+		if err != nil {
+			resp.WriteHeader(http.StatusInternalServerError)
+			resp.WriteJSON(errors.NewErrorResponse("Internal server error has occured", http.StatusInternalServerError))
+			logger.SharedGlobalLogger.LogRequestError(err, req)
+			return
 		}
 
-		if r.role != "" {
-			if app.Role(userClaims.Role) != r.role {
-				resp.WriteHeader(int(ErrAuthInsufficientPrivileges.Code))
-				resp.WriteJSON(errors.NewErrorResponse(ErrAuthInsufficientPrivileges.Msg, int(ErrAuthInsufficientPrivileges.Code)))
-				return
-			}
+		if authz.policyErr != nil {
+			logger.SharedGlobalLogger.LogRequestError(authz.policyErr, req)
+		}
+
+		if !authz.Allowed {
+			resp.WriteHeader(int(ErrAuthInsufficientPrivileges.Code))
+			resp.WriteJSON(errors.NewErrorResponse(ErrAuthInsufficientPrivileges.Msg, int(ErrAuthInsufficientPrivileges.Code)))
+			return
+		}
+
+		if userClaims.Organization != "" {
+			ctx = app.WithOrganization(ctx, userClaims.Organization)
 		}
 
 		h.ServeHTTP(resp, req.WithContext(ctx))
 	})
 }
 
+func newPolicyRequest(ctx context.Context, req *http.Request, path string, claims *jwt.UserClaims, gip geoip.GeoIP) policy.Request {
+	subject := map[string]interface{}{
+		"username": claims.Username,
+		"role":     claims.EffectiveRole(),
+	}
+	if claims.RegisteredClaims != nil {
+		subject["id"] = claims.Subject
+	}
+	if claims.Organization != "" {
+		subject["organization"] = claims.Organization
+	}
+
+	resource := map[string]interface{}{}
+	for k, v := range mux.Vars(req) {
+		resource[k] = v
+	}
+	resource["path"] = path
+
+	now := time.Now().UTC()
+	environment := map[string]interface{}{
+		"time":    now.Format(time.RFC3339),
+		"hour":    now.Hour(),
+		"weekday": now.Weekday().String(),
+	}
+	if ip, ok := ctx.Value(RemoteIPContextKey).(net.IP); ok {
+		environment["ip"] = ip.String()
+		if country, err := gip.LookupCountryISO(ip); err == nil {
+			environment["country"] = country
+		}
+	}
+
+	return policy.Request{
+		Subject:     subject,
+		Resource:    resource,
+		Action:      req.Method,
+		Environment: environment,
+	}
+}
+
 func extractAuthorizationBearerToken(r *http.Request) (string, error) {
 	authorization := r.Header.Get("Authorization")
 	if authorization == "" {
@@ -1,15 +1,21 @@
 package api_test
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/gorilla/mux"
 	"github.com/rislah/fakes/api"
+	app "github.com/rislah/fakes/internal"
 	"github.com/rislah/fakes/internal/errors"
+	"github.com/rislah/fakes/internal/geoip"
 	"github.com/rislah/fakes/internal/jwt"
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/policy"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -83,16 +89,142 @@ func TestAuthenticationMiddleware(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.scenario, func(t *testing.T) {
 			jwtWrapper := jwt.NewHS256Wrapper("secret")
-			handler := api.AuthenticationMiddleware(testHandler(), jwtWrapper, test.rolesAllowed...)
-			srv := httptest.NewServer(handler)
+			router := mux.NewRouter()
+			routeModule := api.NewRouteModule(jwtWrapper, app.NewRBAC(local.NewRoleDB()), geoip.GeoIP{})
+			for _, role := range test.rolesAllowed {
+				routeModule.Get("/", testHandler()).Role(app.Role(role))
+			}
+			routeModule.InjectRoutes(router)
+
+			srv := httptest.NewServer(router)
 			defer srv.Close()
 			test.test(srv.URL, jwtWrapper)
 		})
 	}
 }
 
-func testHandler() http.HandlerFunc {
-	return func(rw http.ResponseWriter, r *http.Request) {
-		rw.Write([]byte("ok"))
+func testHandler() api.ApiFunc {
+	return func(ctx context.Context, response *api.Response, request *http.Request) error {
+		_, err := response.Write([]byte("ok"))
+		return err
+	}
+}
+
+func TestAuthenticationMiddlewarePolicies(t *testing.T) {
+	tests := []struct {
+		scenario string
+		path     string
+		status   int
+	}{
+		{
+			scenario: "allow policy matches",
+			path:     "/things/jaja",
+			status:   http.StatusOK,
+		},
+		{
+			scenario: "allow policy doesnt match",
+			path:     "/things/other",
+			status:   http.StatusUnauthorized,
+		},
+		{
+			scenario: "deny policy matches",
+			path:     "/things/blocked",
+			status:   http.StatusUnauthorized,
+		},
+	}
+
+	jwtWrapper := jwt.NewHS256Wrapper("secret")
+	router := mux.NewRouter()
+	routeModule := api.NewRouteModule(jwtWrapper, app.NewRBAC(local.NewRoleDB()), geoip.GeoIP{})
+	routeModule.Get("/things/{name}", testHandler()).Policies(
+		policy.MustNew("own_thing", policy.Allow, `subject.username == resource.name || subject.role == "developer"`),
+		policy.MustNew("blocked_thing", policy.Deny, `resource.name == "blocked"`),
+	)
+	routeModule.InjectRoutes(router)
+
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	tokenStr, err := jwtWrapper.Encode(jwt.NewUserClaims("jaja", "developer"))
+	assert.NoError(t, err)
+	guestTokenStr, err := jwtWrapper.Encode(jwt.NewUserClaims("jaja", "guest"))
+	assert.NoError(t, err)
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			token := guestTokenStr
+			if test.path == "/things/blocked" {
+				token = tokenStr
+			}
+
+			req, err := http.NewRequest("GET", srv.URL+test.path, nil)
+			assert.NoError(t, err)
+			req.Header.Add("Authorization", "Bearer "+token)
+
+			resp, err := http.DefaultClient.Do(req)
+			assert.NoError(t, err)
+			assert.Equal(t, test.status, resp.StatusCode)
+		})
+	}
+}
+
+func TestAuthenticationMiddlewareOwnership(t *testing.T) {
+	const ownerID = "11111111-1111-1111-1111-111111111111"
+
+	tests := []struct {
+		scenario string
+		subject  string
+		role     string
+		status   int
+	}{
+		{
+			scenario: "owner",
+			subject:  ownerID,
+			role:     app.GuestRole.String(),
+			status:   http.StatusOK,
+		},
+		{
+			scenario: "not owner",
+			subject:  "22222222-2222-2222-2222-222222222222",
+			role:     app.GuestRole.String(),
+			status:   http.StatusUnauthorized,
+		},
+		{
+			scenario: "missing subject",
+			role:     app.GuestRole.String(),
+			status:   http.StatusUnauthorized,
+		},
+		{
+			scenario: "not owner with override permission",
+			subject:  "22222222-2222-2222-2222-222222222222",
+			role:     app.DeveloperRole.String(),
+			status:   http.StatusOK,
+		},
+	}
+
+	jwtWrapper := jwt.NewHS256Wrapper("secret")
+	router := mux.NewRouter()
+	routeModule := api.NewRouteModule(jwtWrapper, app.NewRBAC(local.NewRoleDB()), geoip.GeoIP{})
+	routeModule.Get("/users/{user_id}", testHandler()).OwnedBy(api.PathVar("user_id"), app.ManageUsers)
+	routeModule.InjectRoutes(router)
+
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			claims := jwt.NewUserClaims("jaja", test.role)
+			claims.Subject = test.subject
+			tokenStr, err := jwtWrapper.Encode(claims)
+			assert.NoError(t, err)
+
+			req, err := http.NewRequest("GET", srv.URL+"/users/"+ownerID, nil)
+			assert.NoError(t, err)
+			req.Header.Add("Authorization", "Bearer "+tokenStr)
+
+			resp, err := http.DefaultClient.Do(req)
+			assert.NoError(t, err)
+			assert.Equal(t, test.status, resp.StatusCode)
+		})
 	}
 }
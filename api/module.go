@@ -1,37 +1,78 @@
 package api
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/gorilla/mux"
+	app "github.com/rislah/fakes/internal"
 	"github.com/rislah/fakes/internal/errors"
+	"github.com/rislah/fakes/internal/geoip"
 	"github.com/rislah/fakes/internal/jwt"
 	"github.com/rislah/fakes/internal/logger"
 )
 
 type RouteModule struct {
 	routes     []*Route
+	router     *mux.Router
+	muxRoutes  map[*mux.Route]*Route
 	jwtWrapper jwt.Wrapper
+	rbac       app.RBAC
+	sessions   app.SessionStore
+	geoIP      geoip.GeoIP
 	log        *logger.Logger
 }
 
-func NewRouteModule(jwtWrapper jwt.Wrapper) *RouteModule {
+func NewRouteModule(jwtWrapper jwt.Wrapper, rbac app.RBAC, gip geoip.GeoIP) *RouteModule {
 	return &RouteModule{
 		jwtWrapper: jwtWrapper,
+		rbac:       rbac,
+		geoIP:      gip,
 	}
 }
 
-func (r *RouteModule) InjectRoutes(mux *mux.Router) {
+// WithSessions rejects tokens whose sessions were revoked in sessions, or
+// whose accounts aren't active.
+func (r *RouteModule) WithSessions(sessions app.SessionStore) *RouteModule {
+	r.sessions = sessions
+	return r
+}
+
+func (r *RouteModule) InjectRoutes(router *mux.Router) {
+	r.router = router
+	r.muxRoutes = make(map[*mux.Route]*Route, len(r.routes))
+
 	for _, route := range r.routes {
 		var handler http.Handler
 		handler = r.wrap(route.handler, r.log)
 
-		if len(route.permissions) != 0 || route.role != "" {
-			handler = route.authMiddleware(handler, r.jwtWrapper)
+		if route.requiresAuth() {
+			handler = route.authMiddleware(handler)
 		}
 
-		mux.Handle(route.path, handler).Methods(route.method)
+		r.muxRoutes[router.Handle(route.path, handler).Methods(route.method)] = route
+	}
+}
+
+// Match returns the injected route that would serve method and path along
+// with its route variables.
+func (r *RouteModule) Match(method, path string) (*Route, map[string]string, bool) {
+	if r.router == nil {
+		return nil, nil, false
 	}
+
+	req, err := http.NewRequest(method, path, nil)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	var match mux.RouteMatch
+	if !r.router.Match(req, &match) {
+		return nil, nil, false
+	}
+
+	route, ok := r.muxRoutes[match.Route]
+	return route, match.Vars, ok
 }
 
 func (r *RouteModule) Get(path string, handler ApiFunc) *Route {
@@ -67,19 +108,84 @@ func (r *RouteModule) Put(path string, handler ApiFunc) *Route {
 	return route
 }
 
+func (r *RouteModule) Patch(path string, handler ApiFunc) *Route {
+	route := &Route{
+		handler: handler,
+		path:    path,
+		method:  "PATCH",
+		module:  r,
+	}
+	r.routes = append(r.routes, route)
+	return route
+}
+
+func (r *RouteModule) Delete(path string, handler ApiFunc) *Route {
+	route := &Route{
+		handler: handler,
+		path:    path,
+		method:  "DELETE",
+		module:  r,
+	}
+	r.routes = append(r.routes, route)
+	return route
+}
+
+// sessionError returns the error that rejects a token with claims: the
+// status of its account unless it is active, or ErrAuthSessionRevoked when
+// the session it was issued for has been revoked. It returns nil for tokens
+// that are still valid.
+func (r *RouteModule) sessionError(ctx context.Context, claims *jwt.UserClaims) (*errors.WrappedError, error) {
+	if r.sessions == nil || claims.RegisteredClaims == nil {
+		return nil, nil
+	}
+
+	status, err := r.sessions.AccountStatus(ctx, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusErr, ok := status.Err().(*errors.WrappedError); ok {
+		return statusErr, nil
+	}
+
+	if claims.IssuedAt == nil {
+		return nil, nil
+	}
+
+	revoked, err := r.sessions.IsSessionRevoked(ctx, claims.Subject, claims.IssuedAt.Time)
+	if err != nil || !revoked {
+		return nil, err
+	}
+
+	return ErrAuthSessionRevoked, nil
+}
+
 func (r *RouteModule) wrap(handler ApiFunc, log *logger.Logger) http.Handler {
+	if log == nil {
+		log = logger.SharedGlobalLogger
+	}
+
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		resp := &Response{ResponseWriter: rw}
 		err := handler(r.Context(), resp, r)
 		if err != nil {
+			// Handlers that return errors without writing them still get
+			// the status of wrapped errors, such as storage errors.
 			if !resp.WasWritten() {
-				resp.WriteHeader(http.StatusInternalServerError)
+				if e, ok := errors.IsWrappedError(r.Context(), err); ok {
+					resp.WriteHeader(int(e.Code))
+					resp.WriteJSON(errors.NewErrorResponse(e.Msg, int(e.Code)))
+				} else {
+					resp.WriteHeader(http.StatusInternalServerError)
+				}
 			}
 
-			switch resp.Status() {
-			case http.StatusInternalServerError:
+			switch status := resp.Status(); {
+			case status == http.StatusInternalServerError:
 				log.LogRequestError(err, r)
 				resp.WriteJSON(errors.NewErrorResponse("Internal server error has occured", http.StatusInternalServerError))
+			case status > http.StatusInternalServerError:
+				log.LogRequestError(err, r)
 			}
 
 			return
@@ -7,6 +7,7 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/audit"
 	"github.com/rislah/fakes/internal/errors"
 	"github.com/rislah/fakes/internal/jwt"
 	"github.com/rislah/fakes/internal/ratelimiter"
@@ -15,21 +16,37 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/rislah/fakes/internal/logger"
+	"github.com/rislah/fakes/internal/pow"
 	"github.com/rislah/fakes/internal/redis"
 )
 
 type Mux struct {
 	*mux.Router
 	userBackend             app.UserBackend
+	orgBackend              app.OrganizationBackend
+	groupBackend            app.GroupBackend
+	privacyBackend          app.PrivacyBackend
+	inviteBackend           app.InviteBackend
+	inviteOnly              bool
+	usernameBackend         app.UsernameBackend
+	identifierBackend       app.IdentifierBackend
 	authenticator           app.Authenticator
+	rbac                    app.RBAC
 	userRegisterRatelimiter *ratelimiter.Ratelimiter
 	userLoginRatelimiter    *ratelimiter.Ratelimiter
+	usernameRatelimiter     *ratelimiter.Ratelimiter
 	globalRatelimiter       *ratelimiter.Ratelimiter
 	jwtWrapper              jwt.Wrapper
+	sessions                app.SessionStore
+	routeModule             *RouteModule
+	scimTokenHash           []byte
+	scimManifest            []RouteManifestEntry
+	auditLog                *audit.Log
+	powGuard                *pow.Guard
 	logger                  *logger.Logger
 }
 
-func NewMux(userBackend app.UserBackend, authenticator app.Authenticator, jwtWrapper jwt.Wrapper, gip geoip.GeoIP, client redis.Client, logger *logger.Logger) *Mux {
+func NewMux(userBackend app.UserBackend, orgBackend app.OrganizationBackend, groupBackend app.GroupBackend, privacyBackend app.PrivacyBackend, authenticator app.Authenticator, rbac app.RBAC, jwtWrapper jwt.Wrapper, gip geoip.GeoIP, client redis.Client, logger *logger.Logger) *Mux {
 	router := mux.NewRouter()
 	router.Handle("/metrics", promhttp.Handler())
 
@@ -53,6 +70,17 @@ func NewMux(userBackend app.UserBackend, authenticator app.Authenticator, jwtWra
 		DevMode:        true,
 	})
 
+	// Not in dev mode, unlike the others, as availability checks are the
+	// cheapest way to enumerate users.
+	usernameRatelimiter := ratelimiter.NewRateLimiter(&ratelimiter.Options{
+		Name:           "username_availability",
+		Datastore:      ratelimiter.NewRedisDatastore(client),
+		LimitPerMinute: 20,
+		WindowInterval: 1 * time.Minute,
+		BucketInterval: 5 * time.Second,
+		WriteHeaders:   true,
+	})
+
 	globalRateLimiter := ratelimiter.NewRateLimiter(&ratelimiter.Options{
 		Name:           "global",
 		Datastore:      ratelimiter.NewRedisDatastore(client),
@@ -66,11 +94,17 @@ func NewMux(userBackend app.UserBackend, authenticator app.Authenticator, jwtWra
 	s := &Mux{
 		Router:                  router,
 		userBackend:             userBackend,
+		orgBackend:              orgBackend,
+		groupBackend:            groupBackend,
+		privacyBackend:          privacyBackend,
 		authenticator:           authenticator,
+		rbac:                    rbac,
 		userRegisterRatelimiter: userRegisterRatelimiter,
 		userLoginRatelimiter:    userLoginRatelimiter,
+		usernameRatelimiter:     usernameRatelimiter,
 		globalRatelimiter:       globalRateLimiter,
 		jwtWrapper:              jwtWrapper,
+		sessions:                app.NewRedisSessionStore(client),
 		logger:                  logger,
 	}
 
@@ -80,12 +114,52 @@ func NewMux(userBackend app.UserBackend, authenticator app.Authenticator, jwtWra
 	subRouter.Use(contextMiddleWare)
 	subRouter.Use(s.ratelimiterMiddleware)
 
-	routeModule := NewRouteModule(jwtWrapper)
+	routeModule := NewRouteModule(jwtWrapper, rbac, gip).WithSessions(s.sessions)
 	routeModule.Get("/testauth", s.test).Permissions("viewTest")
-	routeModule.Get("/users", s.GetUsers)
+	routeModule.Get("/users", s.GetUsers).Permissions(app.ManageUsers)
+	routeModule.Get("/users/{user_id}", s.GetUser).OwnedBy(PathVar("user_id"), app.ManageUsers)
+	routeModule.Patch("/users/{user_id}", s.UpdateUser).OwnedBy(PathVar("user_id"), app.ManageUsers)
+	routeModule.Delete("/users/{user_id}", s.DeleteUser).OwnedBy(PathVar("user_id"), app.ManageUsers)
+	routeModule.Patch("/me/profile", s.UpdateProfile).Authenticated()
+	routeModule.Get("/me/export", s.ExportUserData).Authenticated()
+	routeModule.Get("/me/erasure", s.GetErasureRequest).Authenticated()
+	routeModule.Post("/me/erasure", s.RequestErasure).Authenticated()
+	routeModule.Delete("/me/erasure", s.CancelErasure).Authenticated()
+	routeModule.Get("/me/identifiers", s.GetIdentifiers).Authenticated()
+	routeModule.Post("/me/identifiers", s.AddIdentifier).Authenticated()
+	routeModule.Post("/me/identifiers/{identifier_id}/verify", s.VerifyIdentifier).Authenticated()
+	routeModule.Delete("/me/identifiers/{identifier_id}", s.RemoveIdentifier).Authenticated()
+	routeModule.Put("/users/{user_id}/role", s.UpdateUserRole).Permissions(app.AssignRoles)
+	routeModule.Post("/users/{user_id}/suspend", s.SuspendUser).Permissions(app.ManageAccountStatus)
+	routeModule.Post("/users/{user_id}/reinstate", s.ReinstateUser).Permissions(app.ManageAccountStatus)
 	routeModule.Post("/register", s.CreateUser)
 	routeModule.Post("/login", s.Login)
+	routeModule.Get("/pow/challenge", s.GetPowChallenge)
+	routeModule.Get("/usernames/{name}/availability", s.GetUsernameAvailability)
+	routeModule.Get("/roles", s.GetRoles).Permissions(app.ManageRoles)
+	routeModule.Post("/roles", s.CreateRole).Permissions(app.ManageRoles)
+	routeModule.Put("/roles/{role}", s.UpdateRole).Permissions(app.ManageRoles)
+	routeModule.Delete("/roles/{role}", s.DeleteRole).Permissions(app.ManageRoles)
+	routeModule.Get("/organizations", s.GetOrganizations).Authenticated()
+	routeModule.Post("/organizations", s.CreateOrganization).Permissions(app.ManageOrganizations)
+	routeModule.Post("/organizations/switch", s.SwitchOrganization).Authenticated()
+	routeModule.Post("/organizations/{org_id}/members", s.AddOrganizationMember).Permissions(app.ManageMembers).ActiveOrganization(PathVar("org_id"))
+	routeModule.Get("/groups", s.GetGroups).Permissions(app.ManageGroups)
+	routeModule.Post("/groups", s.CreateGroup).Permissions(app.ManageGroups)
+	routeModule.Put("/groups/{group_id}", s.UpdateGroup).Permissions(app.ManageGroups)
+	routeModule.Delete("/groups/{group_id}", s.DeleteGroup).Permissions(app.ManageGroups)
+	routeModule.Get("/groups/{group_id}/members", s.GetGroupMembers).Permissions(app.ManageGroups)
+	routeModule.Put("/groups/{group_id}/members/{user_id}", s.AddGroupMember).Permissions(app.ManageGroups)
+	routeModule.Delete("/groups/{group_id}/members/{user_id}", s.RemoveGroupMember).Permissions(app.ManageGroups)
+	routeModule.Post("/authz/explain", s.ExplainAuthorization).Permissions(app.ExplainAuthorization)
+	routeModule.Get("/routes", s.GetRouteManifest).Permissions(app.ViewRouteManifest)
+	routeModule.Get("/audit/events", s.GetAuditEvents).Permissions(app.ViewAuditLog)
+	routeModule.Get("/invites", s.GetInvites).Permissions(app.ManageInvites)
+	routeModule.Post("/invites", s.CreateInvite).Permissions(app.ManageInvites)
+	routeModule.Delete("/invites/{invite_id}", s.RevokeInvite).Permissions(app.ManageInvites)
 	routeModule.InjectRoutes(subRouter)
+	s.routeModule = routeModule
+	s.injectSCIMRoutes(subRouter, routeModule)
 
 	return s
 }
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/audit"
+	"github.com/rislah/fakes/internal/errors"
+	"github.com/rislah/fakes/internal/jwt"
+)
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+type OrganizationResponse struct {
+	OrgID string `json:"org_id"`
+	Name  string `json:"name"`
+}
+
+type AddOrganizationMemberRequest struct {
+	UserID string   `json:"user_id"`
+	Role   app.Role `json:"role"`
+}
+
+type MembershipResponse struct {
+	OrgID  string   `json:"org_id"`
+	UserID string   `json:"user_id"`
+	Role   app.Role `json:"role"`
+}
+
+// SwitchOrganizationRequest selects the active organization. An empty OrgID
+// switches back to the caller's global role.
+type SwitchOrganizationRequest struct {
+	OrgID string `json:"org_id"`
+}
+
+type SwitchOrganizationResponse struct {
+	Token string `json:"token"`
+}
+
+func (s *Mux) CreateOrganization(ctx context.Context, response *Response, req *http.Request) error {
+	var orgReq CreateOrganizationRequest
+	if err := json.NewDecoder(req.Body).Decode(&orgReq); err != nil {
+		return err
+	}
+
+	claims, _ := ctx.Value(jwtClaimsKey).(*jwt.UserClaims)
+	org, err := s.orgBackend.CreateOrganization(ctx, orgReq.Name, claims.Subject)
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	s.auditEvent(req, audit.Event{Action: audit.OrgCreated, TargetID: org.OrgID, Details: audit.Details{"name": org.Name}})
+
+	response.WriteHeader(http.StatusCreated)
+	return response.WriteJSON(OrganizationResponse{
+		OrgID: org.OrgID,
+		Name:  org.Name,
+	})
+}
+
+func (s *Mux) GetOrganizations(ctx context.Context, response *Response, req *http.Request) error {
+	claims, _ := ctx.Value(jwtClaimsKey).(*jwt.UserClaims)
+	memberships, err := s.orgBackend.GetMemberships(ctx, claims.Subject)
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	res := []MembershipResponse{}
+	for _, membership := range memberships {
+		res = append(res, MembershipResponse{
+			OrgID:  membership.OrgID,
+			UserID: membership.UserID,
+			Role:   membership.Role,
+		})
+	}
+
+	return response.WriteJSON(res)
+}
+
+func (s *Mux) AddOrganizationMember(ctx context.Context, response *Response, req *http.Request) error {
+	var memberReq AddOrganizationMemberRequest
+	if err := json.NewDecoder(req.Body).Decode(&memberReq); err != nil {
+		return err
+	}
+
+	membership := app.Membership{
+		OrgID:  mux.Vars(req)["org_id"],
+		UserID: memberReq.UserID,
+		Role:   memberReq.Role,
+	}
+
+	if err := s.orgBackend.AddMember(ctx, membership); err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	s.auditEvent(req, audit.Event{
+		Action:   audit.OrgMemberAdded,
+		TargetID: membership.UserID,
+		Details:  audit.Details{"org_id": membership.OrgID, "role": membership.Role.String()},
+	})
+
+	response.WriteHeader(http.StatusCreated)
+	return response.WriteJSON(MembershipResponse{
+		OrgID:  membership.OrgID,
+		UserID: membership.UserID,
+		Role:   membership.Role,
+	})
+}
+
+func (s *Mux) SwitchOrganization(ctx context.Context, response *Response, req *http.Request) error {
+	var switchReq SwitchOrganizationRequest
+	if err := json.NewDecoder(req.Body).Decode(&switchReq); err != nil {
+		return err
+	}
+
+	// The token of the personal context carries the global role, which a
+	// lookup scoped to the current organization would replace.
+	claims, _ := ctx.Value(jwtClaimsKey).(*jwt.UserClaims)
+	usr, err := s.userBackend.GetUserByUsername(app.WithoutOrganization(ctx), claims.Username)
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	var membership app.Membership
+	if switchReq.OrgID != "" {
+		membership, err = s.orgBackend.GetMembership(ctx, switchReq.OrgID, usr.UserID)
+		if err != nil {
+			return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+		}
+	}
+
+	var token string
+	if membership.IsEmpty() {
+		var grants app.Grants
+		grants, err = s.groupBackend.GetUserGrants(ctx, usr.UserID)
+		if err != nil {
+			return err
+		}
+
+		token, err = s.authenticator.GenerateJWT(usr, grants)
+	} else {
+		token, err = s.authenticator.GenerateOrganizationJWT(usr, membership)
+	}
+	if err != nil {
+		return err
+	}
+
+	return response.WriteJSON(SwitchOrganizationResponse{Token: token})
+}
@@ -0,0 +1,12 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestLocalOrganizations(t *testing.T) {
+	tests.TestAPIOrganizations(t, local.MakeOrganizationDB, local.MakeRedis)
+}
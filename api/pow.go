@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/rislah/fakes/internal/errors"
+	"github.com/rislah/fakes/internal/logger"
+	"github.com/rislah/fakes/internal/pow"
+)
+
+// Clients send the token of a solved challenge and its nonce in these
+// headers.
+const (
+	PowChallengeHeader = "Pow-Challenge"
+	PowNonceHeader     = "Pow-Nonce"
+)
+
+type PowChallengeResponse struct {
+	Challenge  string    `json:"challenge"`
+	Scope      pow.Scope `json:"scope"`
+	Difficulty int       `json:"difficulty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Required is false when a solution wouldn't be checked right now.
+	Required bool `json:"required"`
+}
+
+// WithProofOfWork requires solved challenges on /register and /login while
+// guard considers abuse signals high.
+func (s *Mux) WithProofOfWork(guard *pow.Guard) *Mux {
+	s.powGuard = guard
+	return s
+}
+
+func (s *Mux) GetPowChallenge(ctx context.Context, response *Response, req *http.Request) error {
+	if s.powGuard == nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, ErrProofOfWorkDisabled)
+	}
+
+	scope := pow.Scope(req.URL.Query().Get("scope"))
+	token, challenge, required, err := s.powGuard.Challenge(ctx, scope, remoteIP(ctx))
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	response.Header().Set("Cache-Control", "no-store")
+	return response.WriteJSON(PowChallengeResponse{
+		Challenge:  token,
+		Scope:      challenge.Scope,
+		Difficulty: challenge.Difficulty,
+		ExpiresAt:  challenge.ExpiresAt,
+		Required:   required,
+	})
+}
+
+// checkProofOfWork returns an error for the client when the request needs a
+// solved challenge and lacks one. Like the rate limiters, it lets requests
+// through when the signals can't be read.
+func (s *Mux) checkProofOfWork(ctx context.Context, req *http.Request, scope pow.Scope) error {
+	if s.powGuard == nil {
+		return nil
+	}
+
+	err := s.powGuard.Check(ctx, scope, remoteIP(ctx), req.Header.Get(PowChallengeHeader), req.Header.Get(PowNonceHeader))
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := errors.IsWrappedError(ctx, err); ok {
+		return err
+	}
+
+	s.powLogger().LogRequestError(errors.Wrap(err, "proofOfWork"), req)
+	return nil
+}
+
+// recordAbuseSignal counts an attempt towards the difficulty of scope.
+func (s *Mux) recordAbuseSignal(ctx context.Context, req *http.Request, scope pow.Scope) {
+	if s.powGuard == nil {
+		return
+	}
+
+	if err := s.powGuard.Record(ctx, scope, remoteIP(ctx)); err != nil {
+		s.powLogger().LogRequestError(errors.Wrap(err, "proofOfWork"), req)
+	}
+}
+
+func (s *Mux) powLogger() *logger.Logger {
+	if s.logger == nil {
+		return logger.SharedGlobalLogger
+	}
+	return s.logger
+}
+
+func remoteIP(ctx context.Context) string {
+	if ip, ok := ctx.Value(RemoteIPContextKey).(net.IP); ok && ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+var (
+	ErrProofOfWorkDisabled = &errors.WrappedError{
+		Code: errors.ErrNotFound,
+		Msg:  "Proof of work is not enabled",
+	}
+)
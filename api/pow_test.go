@@ -0,0 +1,12 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestLocalProofOfWork(t *testing.T) {
+	tests.TestAPIProofOfWork(t, local.MakeUserDB, local.MakeRedis)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/audit"
+	"github.com/rislah/fakes/internal/errors"
+	"github.com/rislah/fakes/internal/jwt"
+)
+
+type ErasureResponse struct {
+	RequestID    string    `json:"request_id"`
+	RequestedAt  time.Time `json:"requested_at"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+}
+
+func newErasureResponse(request app.ErasureRequest) ErasureResponse {
+	return ErasureResponse{
+		RequestID:    request.RequestID,
+		RequestedAt:  request.RequestedAt,
+		ScheduledFor: request.ScheduledFor,
+	}
+}
+
+// ExportUserData serves the data stored about the caller as a JSON file.
+func (s *Mux) ExportUserData(ctx context.Context, response *Response, req *http.Request) error {
+	claims, _ := ctx.Value(jwtClaimsKey).(*jwt.UserClaims)
+	export, err := s.privacyBackend.ExportUserData(ctx, claims.Subject)
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	s.auditEvent(req, audit.Event{Action: audit.DataExported, TargetID: claims.Subject})
+
+	filename := fmt.Sprintf("%s-%s.json", export.Profile.Username, export.GeneratedAt.Format("20060102"))
+	response.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	response.Header().Set("Cache-Control", "no-store")
+	return response.WriteJSON(export)
+}
+
+func (s *Mux) RequestErasure(ctx context.Context, response *Response, req *http.Request) error {
+	claims, _ := ctx.Value(jwtClaimsKey).(*jwt.UserClaims)
+	request, err := s.privacyBackend.RequestErasure(ctx, claims.Subject)
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	s.auditEvent(req, audit.Event{
+		Action:   audit.ErasureRequested,
+		TargetID: claims.Subject,
+		Details: audit.Details{
+			"request_id":    request.RequestID,
+			"scheduled_for": request.ScheduledFor.Format(time.RFC3339),
+		},
+	})
+
+	response.WriteHeader(http.StatusAccepted)
+	return response.WriteJSON(newErasureResponse(request))
+}
+
+func (s *Mux) GetErasureRequest(ctx context.Context, response *Response, req *http.Request) error {
+	claims, _ := ctx.Value(jwtClaimsKey).(*jwt.UserClaims)
+	request, err := s.privacyBackend.GetErasureRequest(ctx, claims.Subject)
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	return response.WriteJSON(newErasureResponse(request))
+}
+
+func (s *Mux) CancelErasure(ctx context.Context, response *Response, req *http.Request) error {
+	claims, _ := ctx.Value(jwtClaimsKey).(*jwt.UserClaims)
+	if err := s.privacyBackend.CancelErasure(ctx, claims.Subject); err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	s.auditEvent(req, audit.Event{Action: audit.ErasureCancelled, TargetID: claims.Subject})
+
+	response.WriteHeader(http.StatusNoContent)
+	return nil
+}
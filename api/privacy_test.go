@@ -0,0 +1,12 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestLocalPrivacy(t *testing.T) {
+	tests.TestAPIPrivacy(t, local.MakeUserDB, local.MakeRedis)
+}
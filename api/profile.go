@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/audit"
+	"github.com/rislah/fakes/internal/errors"
+	"github.com/rislah/fakes/internal/jwt"
+)
+
+// UpdateProfileRequest changes the profile attributes that are set. An empty
+// string clears the attribute.
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"display_name"`
+	AvatarURL   *string `json:"avatar_url"`
+	Locale      *string `json:"locale"`
+	Timezone    *string `json:"timezone"`
+}
+
+func (s *Mux) UpdateProfile(ctx context.Context, response *Response, req *http.Request) error {
+	var profileReq UpdateProfileRequest
+	if err := json.NewDecoder(req.Body).Decode(&profileReq); err != nil {
+		return err
+	}
+
+	claims, _ := ctx.Value(jwtClaimsKey).(*jwt.UserClaims)
+	usr, err := s.userBackend.UpdateProfile(ctx, claims.Subject, app.ProfileUpdate{
+		DisplayName: profileReq.DisplayName,
+		AvatarURL:   profileReq.AvatarURL,
+		Locale:      profileReq.Locale,
+		Timezone:    profileReq.Timezone,
+	})
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	s.auditEvent(req, audit.Event{Action: audit.ProfileUpdated, TargetID: usr.UserID})
+
+	return response.WriteJSON(newGetUsersResponse(usr))
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/errors"
+)
+
+type RoleRequest struct {
+	Name        string     `json:"name"`
+	Parents     []app.Role `json:"parents"`
+	Permissions []string   `json:"permissions"`
+}
+
+type RoleResponse struct {
+	Name        app.Role   `json:"name"`
+	Parents     []app.Role `json:"parents"`
+	Permissions []string   `json:"permissions"`
+}
+
+func (s *Mux) GetRoles(ctx context.Context, response *Response, req *http.Request) error {
+	roles, err := s.rbac.GetRoles(ctx)
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	res := []RoleResponse{}
+	for _, role := range roles {
+		res = append(res, RoleResponse{
+			Name:        role.Name,
+			Parents:     role.Parents,
+			Permissions: role.Permissions,
+		})
+	}
+
+	return response.WriteJSON(res)
+}
+
+func (s *Mux) CreateRole(ctx context.Context, response *Response, req *http.Request) error {
+	var roleReq RoleRequest
+	if err := json.NewDecoder(req.Body).Decode(&roleReq); err != nil {
+		return err
+	}
+
+	role := app.RoleDefinition{
+		Name:        app.Role(roleReq.Name),
+		Parents:     roleReq.Parents,
+		Permissions: roleReq.Permissions,
+	}
+
+	if err := s.rbac.CreateRole(ctx, role); err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	response.WriteHeader(http.StatusCreated)
+	return response.WriteJSON(RoleResponse{
+		Name:        role.Name,
+		Parents:     role.Parents,
+		Permissions: role.Permissions,
+	})
+}
+
+func (s *Mux) UpdateRole(ctx context.Context, response *Response, req *http.Request) error {
+	var roleReq RoleRequest
+	if err := json.NewDecoder(req.Body).Decode(&roleReq); err != nil {
+		return err
+	}
+
+	role := app.RoleDefinition{
+		Name:        app.Role(mux.Vars(req)["role"]),
+		Parents:     roleReq.Parents,
+		Permissions: roleReq.Permissions,
+	}
+
+	if err := s.rbac.UpdateRole(ctx, role); err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	return response.WriteJSON(RoleResponse{
+		Name:        role.Name,
+		Parents:     role.Parents,
+		Permissions: role.Permissions,
+	})
+}
+
+func (s *Mux) DeleteRole(ctx context.Context, response *Response, req *http.Request) error {
+	role := app.Role(mux.Vars(req)["role"])
+	if err := s.rbac.DeleteRole(ctx, role); err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+	return nil
+}
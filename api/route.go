@@ -4,11 +4,24 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/gorilla/mux"
 	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/policy"
 )
 
 type ApiFunc func(ctx context.Context, response *Response, request *http.Request) error
 
+// OwnerFunc extracts the ID of the user that owns the requested resource.
+type OwnerFunc func(request *http.Request) string
+
+// PathVar returns an OwnerFunc that reads the owner from a route variable,
+// e.g. PathVar("user_id") for "/users/{user_id}".
+func PathVar(name string) OwnerFunc {
+	return func(request *http.Request) string {
+		return mux.Vars(request)[name]
+	}
+}
+
 type Route struct {
 	handler     ApiFunc
 	method      string
@@ -16,6 +29,16 @@ type Route struct {
 	path        string
 	permissions []string
 	role        app.Role
+	policies    []*policy.Policy
+	owner       *ownership
+
+	authenticated bool
+	organization  OwnerFunc
+}
+
+type ownership struct {
+	owner              OwnerFunc
+	overridePermission string
 }
 
 func NewRoute(path string, handler ApiFunc, method string) *Route {
@@ -35,3 +58,35 @@ func (r *Route) Role(role app.Role) *Route {
 	r.role = role
 	return r
 }
+
+func (r *Route) Policies(policies ...*policy.Policy) *Route {
+	r.policies = policies
+	return r
+}
+
+// OwnedBy restricts the route to the owner of the resource, as returned by
+// owner, and to callers holding overridePermission.
+func (r *Route) OwnedBy(owner OwnerFunc, overridePermission string) *Route {
+	r.owner = &ownership{
+		owner:              owner,
+		overridePermission: overridePermission,
+	}
+	return r
+}
+
+// Authenticated requires a valid token without demanding any permission.
+func (r *Route) Authenticated() *Route {
+	r.authenticated = true
+	return r
+}
+
+// ActiveOrganization restricts the route to callers whose active
+// organization is the one returned by org, e.g. PathVar("org_id").
+func (r *Route) ActiveOrganization(org OwnerFunc) *Route {
+	r.organization = org
+	return r
+}
+
+func (r *Route) requiresAuth() bool {
+	return r.authenticated || len(r.permissions) != 0 || r.role != "" || len(r.policies) != 0 || r.owner != nil || r.organization != nil
+}
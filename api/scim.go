@@ -0,0 +1,321 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rislah/fakes/internal/errors"
+)
+
+// SCIM 2.0 (RFC 7643 and RFC 7644) provisioning lets identity providers
+// manage users and groups. It is authenticated with a single provisioning
+// token rather than user tokens, and is disabled until one is configured.
+const (
+	SCIMUserSchema         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCIMGroupSchema        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SCIMListResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCIMPatchOpSchema      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SCIMErrorSchema        = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+	scimPrefix       = "/scim/v2"
+	scimContentType  = "application/scim+json;charset=utf-8"
+	maxSCIMPageCount = 100
+)
+
+// scimClientKey marks requests authenticated with the provisioning token,
+// which have no user behind them.
+const scimClientKey ContextKey = "scim_client"
+
+// scimFilterRegex matches the only filter form supported, an attribute
+// compared for equality with a string, e.g. userName eq "alice".
+var scimFilterRegex = regexp.MustCompile(`(?i)^\s*([a-z][a-z0-9_.]*)\s+eq\s+("(?:[^"\\]|\\.)*")\s*$`)
+
+type SCIMMeta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location"`
+}
+
+type SCIMListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+type SCIMError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	SCIMType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+type SCIMPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations"`
+}
+
+// SCIMPatchOperation is decoded as is and interpreted by the resource it
+// applies to, since the type of Value depends on Path.
+type SCIMPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// scimPage selects a page by its 1-based start index, as SCIM pages by offset.
+type scimPage struct {
+	startIndex int
+	count      int
+}
+
+// WithSCIMToken enables the SCIM endpoints for requests bearing token. They
+// stay disabled while token is empty.
+func (s *Mux) WithSCIMToken(token string) *Mux {
+	s.scimTokenHash = nil
+	if token != "" {
+		hash := sha256.Sum256([]byte(token))
+		s.scimTokenHash = hash[:]
+	}
+	return s
+}
+
+func (s *Mux) injectSCIMRoutes(router *mux.Router, routeModule *RouteModule) {
+	scim := router.PathPrefix(scimPrefix).Subrouter()
+	scim.Use(s.scimAuthMiddleware)
+
+	handle := func(path string, handler ApiFunc, method string) {
+		scim.Handle(path, routeModule.wrap(handler, routeModule.log)).Methods(method)
+		s.scimManifest = append(s.scimManifest, RouteManifestEntry{
+			Method:            method,
+			Path:              scimPrefix + path,
+			AuthRequired:      true,
+			Permissions:       []string{},
+			ProvisioningToken: true,
+		})
+	}
+
+	handle("/Users", s.SCIMListUsers, http.MethodGet)
+	handle("/Users", s.SCIMCreateUser, http.MethodPost)
+	handle("/Users/{user_id}", s.SCIMGetUser, http.MethodGet)
+	handle("/Users/{user_id}", s.SCIMReplaceUser, http.MethodPut)
+	handle("/Users/{user_id}", s.SCIMPatchUser, http.MethodPatch)
+	handle("/Users/{user_id}", s.SCIMDeleteUser, http.MethodDelete)
+	handle("/Groups", s.SCIMListGroups, http.MethodGet)
+	handle("/Groups", s.SCIMCreateGroup, http.MethodPost)
+	handle("/Groups/{group_id}", s.SCIMGetGroup, http.MethodGet)
+	handle("/Groups/{group_id}", s.SCIMReplaceGroup, http.MethodPut)
+	handle("/Groups/{group_id}", s.SCIMPatchGroup, http.MethodPatch)
+	handle("/Groups/{group_id}", s.SCIMDeleteGroup, http.MethodDelete)
+}
+
+// scimAuthMiddleware compares hashes of the tokens so that the comparison
+// takes the same time whatever the length of the token presented.
+func (s *Mux) scimAuthMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		response := &Response{ResponseWriter: rw}
+		if s.scimTokenHash == nil {
+			writeSCIM(response, http.StatusNotFound, newSCIMError(http.StatusNotFound, "", "SCIM provisioning is not enabled"))
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		hash := sha256.Sum256([]byte(token))
+		if token == "" || subtle.ConstantTimeCompare(hash[:], s.scimTokenHash) != 1 {
+			response.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+			writeSCIM(response, http.StatusUnauthorized, newSCIMError(http.StatusUnauthorized, "", "Invalid provisioning token"))
+			return
+		}
+
+		h.ServeHTTP(response, r.WithContext(context.WithValue(r.Context(), scimClientKey, true)))
+	})
+}
+
+func writeSCIM(response *Response, status int, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	response.Header().Set("Content-Type", scimContentType)
+	response.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	response.WriteHeader(status)
+	_, err = response.Write(b)
+	return err
+}
+
+// writeSCIMError writes err in the SCIM error format when it is meant for
+// clients, and returns it otherwise.
+func writeSCIMError(ctx context.Context, response *Response, err error) error {
+	wrapped, ok := errors.IsWrappedError(ctx, err)
+	if !ok {
+		return err
+	}
+
+	status := int(wrapped.Code)
+	return writeSCIM(response, status, newSCIMError(status, scimErrorType(wrapped), wrapped.Msg))
+}
+
+func newSCIMError(status int, scimType string, detail string) SCIMError {
+	return SCIMError{
+		Schemas:  []string{SCIMErrorSchema},
+		Status:   strconv.Itoa(status),
+		SCIMType: scimType,
+		Detail:   detail,
+	}
+}
+
+// scimErrorType returns the scimType of err, which the specification
+// defines for bad requests and conflicts only.
+func scimErrorType(err *errors.WrappedError) string {
+	if scimErr, ok := scimErrorTypes[err]; ok {
+		return scimErr
+	}
+
+	switch int(err.Code) {
+	case http.StatusBadRequest:
+		return "invalidValue"
+	case http.StatusConflict:
+		return "uniqueness"
+	default:
+		return ""
+	}
+}
+
+var (
+	ErrSCIMInvalidSyntax = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Request body is not a valid SCIM resource",
+	}
+	ErrSCIMInvalidFilter = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Only filters of the form attribute eq \"value\" are supported",
+	}
+	ErrSCIMInvalidPath = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Path does not refer to a supported attribute",
+	}
+	ErrSCIMNoTarget = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Path is required to remove an attribute",
+	}
+	ErrSCIMInvalidValue = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Value has the wrong type for the attribute",
+	}
+	ErrSCIMInvalidOp = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Operation must be one of add, replace or remove",
+	}
+	ErrSCIMMutability = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Attribute is required and cannot be removed",
+	}
+	ErrSCIMUserNameRequired = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "userName is required",
+	}
+	ErrSCIMMemberInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Group members must be existing users",
+	}
+)
+
+var scimErrorTypes = map[*errors.WrappedError]string{
+	ErrSCIMInvalidSyntax: "invalidSyntax",
+	ErrSCIMInvalidFilter: "invalidFilter",
+	ErrSCIMInvalidPath:   "invalidPath",
+	ErrSCIMNoTarget:      "noTarget",
+	ErrSCIMMutability:    "mutability",
+}
+
+func decodeSCIM(req *http.Request, v interface{}) error {
+	if err := json.NewDecoder(req.Body).Decode(v); err != nil {
+		return ErrSCIMInvalidSyntax
+	}
+
+	return nil
+}
+
+// parseSCIMFilter returns the value the filter in req compares attribute
+// with, or false when there is no filter. Attribute names are
+// case-insensitive.
+func parseSCIMFilter(req *http.Request, attribute string) (string, bool, error) {
+	filter := req.URL.Query().Get("filter")
+	if filter == "" {
+		return "", false, nil
+	}
+
+	match := scimFilterRegex.FindStringSubmatch(filter)
+	if match == nil || !strings.EqualFold(match[1], attribute) {
+		return "", false, ErrSCIMInvalidFilter
+	}
+
+	value, err := strconv.Unquote(match[2])
+	if err != nil {
+		return "", false, ErrSCIMInvalidFilter
+	}
+
+	return value, true, nil
+}
+
+// parseSCIMPage reads startIndex and count, treating values out of range the
+// way RFC 7644 section 3.4.2.4 asks.
+func parseSCIMPage(req *http.Request) scimPage {
+	page := scimPage{startIndex: 1, count: maxSCIMPageCount}
+	query := req.URL.Query()
+
+	if startIndex, err := strconv.Atoi(query.Get("startIndex")); err == nil && startIndex > 1 {
+		page.startIndex = startIndex
+	}
+
+	if count, err := strconv.Atoi(query.Get("count")); err == nil {
+		page.count = count
+		if count < 0 {
+			page.count = 0
+		}
+		if count > maxSCIMPageCount {
+			page.count = maxSCIMPageCount
+		}
+	}
+
+	return page
+}
+
+// bounds returns the slice bounds of the page within total resources.
+func (p scimPage) bounds(total int) (int, int) {
+	start := p.startIndex - 1
+	if start > total {
+		start = total
+	}
+
+	end := start + p.count
+	if end > total {
+		end = total
+	}
+
+	return start, end
+}
+
+func newSCIMListResponse(page scimPage, total int, resources interface{}, items int) SCIMListResponse {
+	return SCIMListResponse{
+		Schemas:      []string{SCIMListResponseSchema},
+		TotalResults: total,
+		StartIndex:   page.startIndex,
+		ItemsPerPage: items,
+		Resources:    resources,
+	}
+}
+
+// scimAttribute is the name of a top-level attribute in a patch path, which
+// SCIM compares case-insensitively.
+func scimAttribute(path string) string {
+	return strings.ToLower(strings.TrimSpace(path))
+}
@@ -0,0 +1,397 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/audit"
+)
+
+// scimMemberPathRegex matches the path that selects a single member, e.g.
+// members[value eq "<user_id>"].
+var scimMemberPathRegex = regexp.MustCompile(`(?i)^\s*members\s*\[\s*value\s+eq\s+("(?:[^"\\]|\\.)*")\s*\]\s*$`)
+
+// SCIMGroup is both the request and the response representation of a group.
+// The roles and permissions a group grants aren't part of the SCIM schema
+// and are left as they are.
+type SCIMGroup struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []SCIMMember `json:"members,omitempty"`
+	Meta        *SCIMMeta    `json:"meta,omitempty"`
+}
+
+type SCIMMember struct {
+	Value string `json:"value"`
+	Ref   string `json:"$ref,omitempty"`
+}
+
+func newSCIMGroup(group app.Group, members []string) SCIMGroup {
+	res := SCIMGroup{
+		Schemas:     []string{SCIMGroupSchema},
+		ID:          group.GroupID,
+		DisplayName: group.Name,
+		Meta: &SCIMMeta{
+			ResourceType: "Group",
+			Location:     scimPrefix + "/Groups/" + group.GroupID,
+		},
+	}
+
+	for _, userID := range members {
+		res.Members = append(res.Members, SCIMMember{Value: userID, Ref: scimPrefix + "/Users/" + userID})
+	}
+
+	return res
+}
+
+func (s *Mux) SCIMListGroups(ctx context.Context, response *Response, req *http.Request) error {
+	displayName, filtered, err := parseSCIMFilter(req, "displayName")
+	if err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	groups, err := s.groupBackend.GetGroups(ctx)
+	if err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	matching := []app.Group{}
+	for _, group := range groups {
+		if !filtered || strings.EqualFold(group.Name, displayName) {
+			matching = append(matching, group)
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool { return matching[i].Name < matching[j].Name })
+
+	page := parseSCIMPage(req)
+	start, end := page.bounds(len(matching))
+	resources := []SCIMGroup{}
+	for _, group := range matching[start:end] {
+		res, err := s.scimGroup(ctx, req, group)
+		if err != nil {
+			return writeSCIMError(ctx, response, err)
+		}
+		resources = append(resources, res)
+	}
+
+	return writeSCIM(response, http.StatusOK, newSCIMListResponse(page, len(matching), resources, len(resources)))
+}
+
+func (s *Mux) SCIMGetGroup(ctx context.Context, response *Response, req *http.Request) error {
+	group, err := s.groupBackend.GetGroup(ctx, mux.Vars(req)["group_id"])
+	if err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	res, err := s.scimGroup(ctx, req, group)
+	if err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	return writeSCIM(response, http.StatusOK, res)
+}
+
+func (s *Mux) SCIMCreateGroup(ctx context.Context, response *Response, req *http.Request) error {
+	var scimGroup SCIMGroup
+	if err := decodeSCIM(req, &scimGroup); err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	members := scimMemberIDs(scimGroup.Members)
+	if err := s.validateSCIMMembers(ctx, members); err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	group, err := s.groupBackend.CreateGroup(ctx, app.Group{Name: scimGroup.DisplayName})
+	if err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	s.auditEvent(req, audit.Event{Action: audit.GroupCreated, TargetID: group.GroupID, Details: audit.Details{"name": group.Name}})
+
+	if err := s.setSCIMGroupMembers(ctx, req, group.GroupID, nil, members); err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	res := newSCIMGroup(group, members)
+	response.Header().Set("Location", res.Meta.Location)
+	return writeSCIM(response, http.StatusCreated, res)
+}
+
+func (s *Mux) SCIMReplaceGroup(ctx context.Context, response *Response, req *http.Request) error {
+	var scimGroup SCIMGroup
+	if err := decodeSCIM(req, &scimGroup); err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	group, err := s.groupBackend.GetGroup(ctx, mux.Vars(req)["group_id"])
+	if err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	current, err := s.groupBackend.GetGroupMembers(ctx, group.GroupID)
+	if err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	members := scimMemberIDs(scimGroup.Members)
+	if group, err = s.applySCIMGroup(ctx, req, group, scimGroup.DisplayName, current, members); err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	return writeSCIM(response, http.StatusOK, newSCIMGroup(group, members))
+}
+
+func (s *Mux) SCIMPatchGroup(ctx context.Context, response *Response, req *http.Request) error {
+	var patch SCIMPatchRequest
+	if err := decodeSCIM(req, &patch); err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	group, err := s.groupBackend.GetGroup(ctx, mux.Vars(req)["group_id"])
+	if err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	current, err := s.groupBackend.GetGroupMembers(ctx, group.GroupID)
+	if err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	name := group.Name
+	members := append([]string{}, current...)
+	for _, op := range patch.Operations {
+		if name, members, err = scimGroupPatch(op, name, members); err != nil {
+			return writeSCIMError(ctx, response, err)
+		}
+	}
+
+	if group, err = s.applySCIMGroup(ctx, req, group, name, current, members); err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	return writeSCIM(response, http.StatusOK, newSCIMGroup(group, members))
+}
+
+func (s *Mux) SCIMDeleteGroup(ctx context.Context, response *Response, req *http.Request) error {
+	if err := s.deleteGroup(ctx, req, mux.Vars(req)["group_id"]); err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// scimGroup returns the representation of group, leaving the members out
+// when the client asked for it with excludedAttributes, as listing them
+// takes a query per group.
+func (s *Mux) scimGroup(ctx context.Context, req *http.Request, group app.Group) (SCIMGroup, error) {
+	for _, attribute := range strings.Split(req.URL.Query().Get("excludedAttributes"), ",") {
+		if scimAttribute(attribute) == "members" {
+			return newSCIMGroup(group, nil), nil
+		}
+	}
+
+	members, err := s.groupBackend.GetGroupMembers(ctx, group.GroupID)
+	if err != nil {
+		return SCIMGroup{}, err
+	}
+
+	return newSCIMGroup(group, members), nil
+}
+
+// applySCIMGroup renames group to name when it differs and replaces the
+// members in current with members.
+func (s *Mux) applySCIMGroup(ctx context.Context, req *http.Request, group app.Group, name string, current []string, members []string) (app.Group, error) {
+	if err := s.validateSCIMMembers(ctx, missingMembers(members, current)); err != nil {
+		return app.Group{}, err
+	}
+
+	if name != group.Name {
+		group.Name = name
+		if err := s.groupBackend.UpdateGroup(ctx, group); err != nil {
+			return app.Group{}, err
+		}
+
+		s.auditEvent(req, audit.Event{Action: audit.GroupUpdated, TargetID: group.GroupID})
+	}
+
+	if err := s.setSCIMGroupMembers(ctx, req, group.GroupID, current, members); err != nil {
+		return app.Group{}, err
+	}
+
+	return group, nil
+}
+
+func (s *Mux) setSCIMGroupMembers(ctx context.Context, req *http.Request, groupID string, current []string, members []string) error {
+	for _, userID := range missingMembers(members, current) {
+		if err := s.groupBackend.AddGroupMember(ctx, groupID, userID); err != nil {
+			return err
+		}
+
+		s.auditEvent(req, audit.Event{Action: audit.GroupMemberAdded, TargetID: userID, Details: audit.Details{"group_id": groupID}})
+	}
+
+	for _, userID := range missingMembers(current, members) {
+		if err := s.groupBackend.RemoveGroupMember(ctx, groupID, userID); err != nil {
+			return err
+		}
+
+		s.auditEvent(req, audit.Event{Action: audit.GroupMemberRemoved, TargetID: userID, Details: audit.Details{"group_id": groupID}})
+
+		if err := s.revokeSessions(ctx, req, userID, "group_member_removed"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateSCIMMembers reports a reference to a user that doesn't exist as a
+// bad request rather than as a missing group.
+func (s *Mux) validateSCIMMembers(ctx context.Context, userIDs []string) error {
+	for _, userID := range userIDs {
+		_, err := s.userBackend.GetUser(ctx, userID)
+		if err == app.ErrUserNotFound {
+			return ErrSCIMMemberInvalid
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scimGroupPatch applies op to the name and members of a group.
+func scimGroupPatch(op SCIMPatchOperation, name string, members []string) (string, []string, error) {
+	operation := strings.ToLower(op.Op)
+	if operation != "add" && operation != "replace" && operation != "remove" {
+		return "", nil, ErrSCIMInvalidOp
+	}
+
+	if match := scimMemberPathRegex.FindStringSubmatch(op.Path); match != nil {
+		if operation != "remove" {
+			return "", nil, ErrSCIMInvalidPath
+		}
+
+		userID, err := strconv.Unquote(match[1])
+		if err != nil {
+			return "", nil, ErrSCIMInvalidPath
+		}
+
+		return name, missingMembers(members, []string{userID}), nil
+	}
+
+	switch scimAttribute(op.Path) {
+	case "":
+		if operation == "remove" {
+			return "", nil, ErrSCIMNoTarget
+		}
+
+		var attributes map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &attributes); err != nil {
+			return "", nil, ErrSCIMInvalidSyntax
+		}
+
+		var err error
+		for attribute, value := range attributes {
+			switch scimAttribute(attribute) {
+			case "displayname":
+				if name, err = scimGroupName(value); err != nil {
+					return "", nil, err
+				}
+			case "members":
+				if members, err = scimPatchMembers(operation, members, value); err != nil {
+					return "", nil, err
+				}
+			}
+		}
+
+		return name, members, nil
+	case "displayname":
+		if operation == "remove" {
+			return "", nil, ErrSCIMMutability
+		}
+
+		name, err := scimGroupName(op.Value)
+		return name, members, err
+	case "members":
+		members, err := scimPatchMembers(operation, members, op.Value)
+		return name, members, err
+	default:
+		return "", nil, ErrSCIMInvalidPath
+	}
+}
+
+// scimPatchMembers adds, replaces or removes the members listed in value.
+// Removing without a value removes every member.
+func scimPatchMembers(operation string, members []string, value json.RawMessage) ([]string, error) {
+	if operation == "remove" && len(value) == 0 {
+		return []string{}, nil
+	}
+
+	var listed []SCIMMember
+	if err := json.Unmarshal(value, &listed); err != nil {
+		return nil, ErrSCIMInvalidValue
+	}
+
+	userIDs := scimMemberIDs(listed)
+	switch operation {
+	case "add":
+		return append(members, missingMembers(userIDs, members)...), nil
+	case "replace":
+		return userIDs, nil
+	default:
+		return missingMembers(members, userIDs), nil
+	}
+}
+
+func scimGroupName(value json.RawMessage) (string, error) {
+	var name string
+	if err := json.Unmarshal(value, &name); err != nil {
+		return "", ErrSCIMInvalidValue
+	}
+
+	return name, nil
+}
+
+// scimMemberIDs returns the distinct user IDs of members.
+func scimMemberIDs(members []SCIMMember) []string {
+	userIDs := []string{}
+	seen := map[string]struct{}{}
+	for _, member := range members {
+		if _, ok := seen[member.Value]; ok || member.Value == "" {
+			continue
+		}
+		seen[member.Value] = struct{}{}
+		userIDs = append(userIDs, member.Value)
+	}
+
+	return userIDs
+}
+
+// missingMembers returns the user IDs in members that aren't in other.
+func missingMembers(members []string, other []string) []string {
+	exclude := make(map[string]struct{}, len(other))
+	for _, userID := range other {
+		exclude[userID] = struct{}{}
+	}
+
+	missing := []string{}
+	for _, userID := range members {
+		if _, ok := exclude[userID]; !ok {
+			missing = append(missing, userID)
+		}
+	}
+
+	return missing
+}
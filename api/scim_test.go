@@ -0,0 +1,12 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestLocalSCIM(t *testing.T) {
+	tests.TestAPISCIM(t, local.MakeGroupDB, local.MakeRedis)
+}
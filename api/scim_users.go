@@ -0,0 +1,413 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/audit"
+	"github.com/rislah/fakes/internal/credentials"
+)
+
+// scimDeprovisionReason is the status reason of accounts deactivated by
+// setting active to false.
+const scimDeprovisionReason = "Deprovisioned by the identity provider"
+
+// SCIMUser is both the request and the response representation of a user.
+// Password is write-only and never returned.
+type SCIMUser struct {
+	Schemas     []string  `json:"schemas"`
+	ID          string    `json:"id,omitempty"`
+	UserName    string    `json:"userName"`
+	DisplayName string    `json:"displayName,omitempty"`
+	Locale      string    `json:"locale,omitempty"`
+	Timezone    string    `json:"timezone,omitempty"`
+	Active      *bool     `json:"active,omitempty"`
+	Password    string    `json:"password,omitempty"`
+	Meta        *SCIMMeta `json:"meta,omitempty"`
+}
+
+// scimUserChanges are the changes requested to a user, applied by
+// applySCIMUser.
+type scimUserChanges struct {
+	username *string
+	password *string
+	profile  app.ProfileUpdate
+	active   *bool
+}
+
+func newSCIMUser(usr app.User) SCIMUser {
+	active := usr.Status.Err() == nil
+	return SCIMUser{
+		Schemas:     []string{SCIMUserSchema},
+		ID:          usr.UserID,
+		UserName:    usr.Username,
+		DisplayName: usr.DisplayName,
+		Locale:      usr.Locale,
+		Timezone:    usr.Timezone,
+		Active:      &active,
+		Meta: &SCIMMeta{
+			ResourceType: "User",
+			Location:     scimPrefix + "/Users/" + usr.UserID,
+		},
+	}
+}
+
+func (s *Mux) SCIMListUsers(ctx context.Context, response *Response, req *http.Request) error {
+	userName, filtered, err := parseSCIMFilter(req, "userName")
+	if err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	page := parseSCIMPage(req)
+	if !filtered {
+		users, total, err := s.userBackend.ListUsersAt(ctx, page.startIndex-1, page.count)
+		if err != nil {
+			return writeSCIMError(ctx, response, err)
+		}
+
+		return writeSCIM(response, http.StatusOK, newSCIMListResponse(page, total, newSCIMUsers(users), len(users)))
+	}
+
+	var users []app.User
+	usr, err := s.userBackend.GetUserByUsername(ctx, strings.ToLower(userName))
+	if err != nil && err != app.ErrUserNotFound {
+		return writeSCIMError(ctx, response, err)
+	}
+	if err == nil {
+		users = append(users, usr)
+	}
+
+	start, end := page.bounds(len(users))
+	return writeSCIM(response, http.StatusOK, newSCIMListResponse(page, len(users), newSCIMUsers(users[start:end]), end-start))
+}
+
+func newSCIMUsers(users []app.User) []SCIMUser {
+	resources := []SCIMUser{}
+	for _, usr := range users {
+		resources = append(resources, newSCIMUser(usr))
+	}
+
+	return resources
+}
+
+func (s *Mux) SCIMGetUser(ctx context.Context, response *Response, req *http.Request) error {
+	usr, err := s.userBackend.GetUser(ctx, mux.Vars(req)["user_id"])
+	if err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	return writeSCIM(response, http.StatusOK, newSCIMUser(usr))
+}
+
+// SCIMCreateUser creates the user with a random password when none is given,
+// as provisioned users usually sign in through the identity provider.
+func (s *Mux) SCIMCreateUser(ctx context.Context, response *Response, req *http.Request) error {
+	var scimUser SCIMUser
+	if err := decodeSCIM(req, &scimUser); err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	if scimUser.UserName == "" {
+		return writeSCIMError(ctx, response, ErrSCIMUserNameRequired)
+	}
+
+	// Checked up front so that an invalid profile doesn't leave a user
+	// behind.
+	if err := app.ValidateProfile(app.User{DisplayName: scimUser.DisplayName, Locale: scimUser.Locale, Timezone: scimUser.Timezone}); err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	password := scimUser.Password
+	if password == "" {
+		var err error
+		if password, err = randomSCIMPassword(); err != nil {
+			return err
+		}
+	}
+
+	username := strings.ToLower(scimUser.UserName)
+	if err := s.userBackend.CreateUser(ctx, credentials.New(username, password)); err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	usr, err := s.userBackend.GetUserByUsername(ctx, username)
+	if err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	s.auditEvent(req, audit.Event{Action: audit.UserProvisioned, TargetID: usr.UserID})
+
+	usr, err = s.applySCIMUser(ctx, req, usr, scimUserChanges{
+		profile: app.ProfileUpdate{
+			DisplayName: &scimUser.DisplayName,
+			Locale:      &scimUser.Locale,
+			Timezone:    &scimUser.Timezone,
+		},
+		active: scimUser.Active,
+	})
+	if err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	res := newSCIMUser(usr)
+	response.Header().Set("Location", res.Meta.Location)
+	return writeSCIM(response, http.StatusCreated, res)
+}
+
+// SCIMReplaceUser replaces the attributes of the user. Omitting the password
+// or active keeps them as they are.
+func (s *Mux) SCIMReplaceUser(ctx context.Context, response *Response, req *http.Request) error {
+	var scimUser SCIMUser
+	if err := decodeSCIM(req, &scimUser); err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	if scimUser.UserName == "" {
+		return writeSCIMError(ctx, response, ErrSCIMUserNameRequired)
+	}
+
+	usr, err := s.userBackend.GetUser(ctx, mux.Vars(req)["user_id"])
+	if err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	username := strings.ToLower(scimUser.UserName)
+	changes := scimUserChanges{
+		username: &username,
+		profile: app.ProfileUpdate{
+			DisplayName: &scimUser.DisplayName,
+			Locale:      &scimUser.Locale,
+			Timezone:    &scimUser.Timezone,
+		},
+		active: scimUser.Active,
+	}
+	if scimUser.Password != "" {
+		changes.password = &scimUser.Password
+	}
+
+	if usr, err = s.applySCIMUser(ctx, req, usr, changes); err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	return writeSCIM(response, http.StatusOK, newSCIMUser(usr))
+}
+
+func (s *Mux) SCIMPatchUser(ctx context.Context, response *Response, req *http.Request) error {
+	var patch SCIMPatchRequest
+	if err := decodeSCIM(req, &patch); err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	usr, err := s.userBackend.GetUser(ctx, mux.Vars(req)["user_id"])
+	if err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	changes, err := scimUserPatch(patch.Operations)
+	if err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	if usr, err = s.applySCIMUser(ctx, req, usr, changes); err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	return writeSCIM(response, http.StatusOK, newSCIMUser(usr))
+}
+
+func (s *Mux) SCIMDeleteUser(ctx context.Context, response *Response, req *http.Request) error {
+	userID := mux.Vars(req)["user_id"]
+	if err := s.userBackend.DeleteUser(ctx, userID); err != nil {
+		return writeSCIMError(ctx, response, err)
+	}
+
+	s.auditEvent(req, audit.Event{Action: audit.UserDeleted, TargetID: userID})
+
+	if err := s.revokeSessions(ctx, req, userID, "deleted"); err != nil {
+		return err
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// applySCIMUser applies changes to usr one backend call at a time, so a
+// failing change leaves the ones before it applied. Identity providers retry
+// until the user matches, which makes that harmless.
+func (s *Mux) applySCIMUser(ctx context.Context, req *http.Request, usr app.User, changes scimUserChanges) (app.User, error) {
+	if changes.username != nil && *changes.username == usr.Username {
+		changes.username = nil
+	}
+
+	if changes.username != nil || changes.password != nil {
+		updated, err := s.userBackend.UpdateUser(ctx, usr.UserID, app.UserUpdate{
+			Username: changes.username,
+			Password: changes.password,
+		})
+		if err != nil {
+			return app.User{}, err
+		}
+
+		details := audit.Details{
+			"username_changed": strconv.FormatBool(updated.Username != usr.Username),
+			"password_changed": strconv.FormatBool(changes.password != nil),
+		}
+		s.auditEvent(req, audit.Event{Action: audit.UserUpdated, TargetID: usr.UserID, Details: details})
+
+		// As with UpdateUser, tokens carry the username.
+		if err := s.revokeSessions(ctx, req, usr.UserID, "credentials_changed"); err != nil {
+			return app.User{}, err
+		}
+		usr = updated
+	}
+
+	if changes.profile != (app.ProfileUpdate{}) {
+		var err error
+		if usr, err = s.userBackend.UpdateProfile(ctx, usr.UserID, changes.profile); err != nil {
+			return app.User{}, err
+		}
+	}
+
+	if changes.active != nil {
+		active := usr.Status.Err() == nil
+		switch {
+		case *changes.active && !active:
+			return s.applyUserStatus(ctx, req, usr.UserID, app.StatusActive, "")
+		// Suspended and pending accounts are inactive already, and keep the
+		// reason they are.
+		case !*changes.active && active:
+			return s.applyUserStatus(ctx, req, usr.UserID, app.StatusDeactivated, scimDeprovisionReason)
+		}
+	}
+
+	return usr, nil
+}
+
+// scimUserPatch collects the changes of operations. Attributes outside the
+// user schema are ignored when setting the whole resource, but rejected when
+// named by a path.
+func scimUserPatch(operations []SCIMPatchOperation) (scimUserChanges, error) {
+	var changes scimUserChanges
+	for _, op := range operations {
+		switch strings.ToLower(op.Op) {
+		case "add", "replace":
+			if op.Path != "" {
+				if err := changes.set(op.Path, op.Value); err != nil {
+					return scimUserChanges{}, err
+				}
+				continue
+			}
+
+			var attributes map[string]json.RawMessage
+			if err := json.Unmarshal(op.Value, &attributes); err != nil {
+				return scimUserChanges{}, ErrSCIMInvalidSyntax
+			}
+
+			for name, value := range attributes {
+				if err := changes.set(name, value); err != nil && err != ErrSCIMInvalidPath {
+					return scimUserChanges{}, err
+				}
+			}
+		case "remove":
+			if err := changes.remove(op.Path); err != nil {
+				return scimUserChanges{}, err
+			}
+		default:
+			return scimUserChanges{}, ErrSCIMInvalidOp
+		}
+	}
+
+	return changes, nil
+}
+
+func (c *scimUserChanges) set(path string, value json.RawMessage) error {
+	attribute := scimAttribute(path)
+	if attribute == "active" {
+		active, err := scimBool(value)
+		if err != nil {
+			return err
+		}
+		c.active = &active
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return ErrSCIMInvalidValue
+	}
+
+	switch attribute {
+	case "username":
+		s = strings.ToLower(s)
+		c.username = &s
+	case "password":
+		c.password = &s
+	case "displayname":
+		c.profile.DisplayName = &s
+	case "locale":
+		c.profile.Locale = &s
+	case "timezone":
+		c.profile.Timezone = &s
+	default:
+		return ErrSCIMInvalidPath
+	}
+
+	return nil
+}
+
+func (c *scimUserChanges) remove(path string) error {
+	empty := ""
+	switch scimAttribute(path) {
+	case "":
+		return ErrSCIMNoTarget
+	case "displayname":
+		c.profile.DisplayName = &empty
+	case "locale":
+		c.profile.Locale = &empty
+	case "timezone":
+		c.profile.Timezone = &empty
+	case "username", "password", "active":
+		return ErrSCIMMutability
+	default:
+		return ErrSCIMInvalidPath
+	}
+
+	return nil
+}
+
+// scimBool accepts booleans as well as the strings "true" and "false", which
+// some identity providers send for active.
+func scimBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		switch strings.ToLower(s) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+
+	return false, ErrSCIMInvalidValue
+}
+
+func randomSCIMPassword() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
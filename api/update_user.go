@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/audit"
+	"github.com/rislah/fakes/internal/errors"
+	"github.com/rislah/fakes/internal/jwt"
+)
+
+// UpdateUserRequest changes the fields that are set. Changing the username or
+// password revokes the user's sessions. Users changing their own credentials
+// must confirm them with CurrentPassword.
+type UpdateUserRequest struct {
+	Username        *string `json:"username"`
+	Password        *string `json:"password"`
+	CurrentPassword *string `json:"current_password"`
+}
+
+func (s *Mux) UpdateUser(ctx context.Context, response *Response, req *http.Request) error {
+	var updateReq UpdateUserRequest
+	if err := json.NewDecoder(req.Body).Decode(&updateReq); err != nil {
+		return err
+	}
+
+	userID := mux.Vars(req)["user_id"]
+	previous, err := s.userBackend.GetUser(ctx, userID)
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	if updateReq.Username != nil || updateReq.Password != nil {
+		if err := s.authorizeCredentialChange(ctx, previous, updateReq); err != nil {
+			return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+		}
+	}
+
+	usr, err := s.userBackend.UpdateUser(ctx, userID, app.UserUpdate{
+		Username:        updateReq.Username,
+		Password:        updateReq.Password,
+		CurrentPassword: updateReq.CurrentPassword,
+	})
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	details := audit.Details{
+		"username_changed": strconv.FormatBool(usr.Username != previous.Username),
+		"password_changed": strconv.FormatBool(updateReq.Password != nil),
+	}
+
+	s.auditEvent(req, audit.Event{Action: audit.UserUpdated, TargetID: userID, Details: details})
+
+	// Tokens carry the username, and a new password should lock out whoever
+	// knew the old one.
+	if usr.Username != previous.Username || updateReq.Password != nil {
+		if err := s.revokeSessions(ctx, req, userID, "credentials_changed"); err != nil {
+			return err
+		}
+	}
+
+	return response.WriteJSON(newGetUsersResponse(usr))
+}
+
+// authorizeCredentialChange lets users change their own credentials once they
+// confirm their current password, and callers holding manageUsers change
+// those of users whose role is below theirs. Otherwise anyone who can manage
+// users could take over an administrator's account.
+func (s *Mux) authorizeCredentialChange(ctx context.Context, usr app.User, updateReq UpdateUserRequest) error {
+	claims, _ := ctx.Value(jwtClaimsKey).(*jwt.UserClaims)
+	if claims.Subject == usr.UserID {
+		if updateReq.CurrentPassword == nil {
+			return ErrCurrentPasswordRequired
+		}
+		return nil
+	}
+
+	roles := append([]app.Role{app.Role(claims.EffectiveRole())}, claimsGrants(claims).Roles...)
+	for _, role := range roles {
+		inherited, err := s.rbac.InheritedRoles(ctx, role)
+		if err != nil {
+			return err
+		}
+
+		if containsRole(inherited, usr.Role) {
+			return nil
+		}
+	}
+
+	return ErrAuthInsufficientPrivileges
+}
+
+var (
+	ErrCurrentPasswordRequired = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Current password is required to change your credentials",
+	}
+)
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/audit"
+	"github.com/rislah/fakes/internal/errors"
+)
+
+type UpdateUserRoleRequest struct {
+	Role app.Role `json:"role"`
+}
+
+type UpdateUserRoleResponse struct {
+	UserID string   `json:"user_id"`
+	Role   app.Role `json:"role"`
+}
+
+func (s *Mux) UpdateUserRole(ctx context.Context, response *Response, req *http.Request) error {
+	var updateReq UpdateUserRoleRequest
+	if err := json.NewDecoder(req.Body).Decode(&updateReq); err != nil {
+		return err
+	}
+
+	userID := mux.Vars(req)["user_id"]
+	previous, err := s.userBackend.UpdateUserRole(ctx, userID, updateReq.Role)
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	s.auditEvent(req, audit.Event{
+		Action:   audit.RoleChanged,
+		TargetID: userID,
+		Details:  audit.Details{"from": previous.String(), "to": updateReq.Role.String()},
+	})
+
+	// Tokens carry the role they were issued with, so they have to go for
+	// the new one to apply.
+	if previous != updateReq.Role {
+		if err := s.revokeSessions(ctx, req, userID, "role_changed"); err != nil {
+			return err
+		}
+	}
+
+	return response.WriteJSON(UpdateUserRoleResponse{
+		UserID: userID,
+		Role:   updateReq.Role,
+	})
+}
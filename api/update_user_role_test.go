@@ -0,0 +1,12 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestLocalUpdateUserRole(t *testing.T) {
+	tests.TestAPIUpdateUserRole(t, local.MakeUserDB, local.MakeRedis)
+}
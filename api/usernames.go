@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/errors"
+	"github.com/rislah/fakes/internal/ratelimiter"
+)
+
+type UsernameAvailabilityResponse struct {
+	Username  string                `json:"username"`
+	Available bool                  `json:"available"`
+	Reason    app.UnavailableReason `json:"reason,omitempty"`
+}
+
+// WithUsernames enables the username availability endpoint.
+func (s *Mux) WithUsernames(usernames app.UsernameBackend) *Mux {
+	s.usernameBackend = usernames
+	return s
+}
+
+// GetUsernameAvailability answers whether a username can be registered.
+// It is rate limited on its own and more strictly than registration, as it
+// is cheaper to call in order to enumerate users.
+func (s *Mux) GetUsernameAvailability(ctx context.Context, response *Response, req *http.Request) error {
+	if s.usernameBackend == nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, ErrUsernamesDisabled)
+	}
+
+	throttled, err := s.usernameRatelimiter.ShouldThrottle(ctx, response, ratelimiter.Field{
+		Scope:      "ip",
+		Identifier: remoteIP(ctx),
+	})
+	if err != nil {
+		s.powLogger().LogRequestError(errors.Wrap(err, "usernameRatelimiter"), req)
+	}
+
+	if throttled {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, ErrUsernameAvailabilityThrottled)
+	}
+
+	availability, err := s.usernameBackend.CheckAvailability(ctx, mux.Vars(req)["name"])
+	if err != nil {
+		return errors.IsWrappedErrorWriteErrorResponse(ctx, response, err)
+	}
+
+	response.Header().Set("Cache-Control", "no-store")
+	return response.WriteJSON(UsernameAvailabilityResponse{
+		Username:  availability.Username,
+		Available: availability.Available,
+		Reason:    availability.Reason,
+	})
+}
+
+var (
+	ErrUsernamesDisabled = &errors.WrappedError{
+		Code: errors.ErrNotFound,
+		Msg:  "Username availability is not enabled",
+	}
+	ErrUsernameAvailabilityThrottled = &errors.WrappedError{
+		Code: http.StatusTooManyRequests,
+		Msg:  "You are being ratelimited",
+	}
+)
@@ -0,0 +1,12 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestLocalUsernameAvailability(t *testing.T) {
+	tests.TestAPIUsernameAvailability(t, local.MakeUserDB, local.MakeRedis)
+}
@@ -0,0 +1,16 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestLocalUserCRUD(t *testing.T) {
+	tests.TestAPIUserCRUD(t, local.MakeUserDB, local.MakeRedis)
+}
+
+func TestLocalStorageErrors(t *testing.T) {
+	tests.TestAPIStorageErrors(t, local.MakeUserDB, local.MakeRedis)
+}
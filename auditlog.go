@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/rislah/fakes/internal/audit"
+	"github.com/rislah/fakes/internal/logger"
+)
+
+const auditUsage = `usage:
+  fakes audit verify
+
+verify walks the audit log hash chain and exits with 1 when an event was
+altered or removed. Removing the newest events leaves a valid chain, so
+compare the head it prints with one recorded earlier.`
+
+// runAudit runs the audit subcommand with args and returns the exit code.
+func runAudit(conf config, log *logger.Logger, args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) != 1 || args[0] != "verify" {
+		fmt.Fprintln(stderr, auditUsage)
+		return 2
+	}
+
+	res, err := audit.NewLog(initStores(conf, log).auditStore).Verify(context.Background())
+	if err != nil {
+		fmt.Fprintln(stderr, "verification failed:", err)
+		return 1
+	}
+
+	if !res.Valid() {
+		fmt.Fprintf(stderr, "chain broken at event %d: %s\n", res.Broken.Seq, res.Reason)
+		fmt.Fprintf(stdout, "verified %d events before the break\n", res.Events)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "verified %d events, head %d %s\n", res.Events, res.Head.Seq, res.Head.Hash)
+	return 0
+}
@@ -0,0 +1,41 @@
+package integration_tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rislah/fakes/internal/audit"
+	"github.com/rislah/fakes/internal/postgres"
+	"github.com/rislah/fakes/internal/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntegrationAuditStore(t *testing.T) {
+	tests.TestAuditStore(t, makeAuditStore)
+}
+
+func TestIntegrationAuditStoreAppendOnly(t *testing.T) {
+	conn, cb, teardown, err := makePostgres()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		assert.NoError(t, teardown())
+	}()
+
+	store, err := postgres.NewAuditStore(conn, cb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	_, err = audit.NewLog(store).Record(ctx, audit.Event{Action: audit.UserDeleted, TargetID: "user"})
+	assert.NoError(t, err)
+
+	_, err = conn.ExecContext(ctx, "update audit_event set target_id = 'other'")
+	assert.Error(t, err)
+	_, err = conn.ExecContext(ctx, "delete from audit_event")
+	assert.Error(t, err)
+	_, err = conn.ExecContext(ctx, "truncate audit_event")
+	assert.Error(t, err)
+}
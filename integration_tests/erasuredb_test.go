@@ -0,0 +1,15 @@
+package integration_tests
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestIntegrationErasureDB(t *testing.T) {
+	tests.TestErasureDB(t, makeErasureDB)
+}
+
+func TestIntegrationMySQLErasureDB(t *testing.T) {
+	tests.TestErasureDB(t, makeMySQLErasureDB)
+}
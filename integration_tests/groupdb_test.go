@@ -0,0 +1,11 @@
+package integration_tests
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestIntegrationGroupDB(t *testing.T) {
+	tests.TestGroupDB(t, makeGroupDB)
+}
@@ -0,0 +1,15 @@
+package integration_tests
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestIntegrationIdentifierDB(t *testing.T) {
+	tests.TestIdentifierDB(t, makeIdentifierDB)
+}
+
+func TestIntegrationMySQLIdentifierDB(t *testing.T) {
+	tests.TestIdentifierDB(t, makeMySQLIdentifierDB)
+}
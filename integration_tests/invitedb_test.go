@@ -0,0 +1,15 @@
+package integration_tests
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestIntegrationInviteDB(t *testing.T) {
+	tests.TestInviteDB(t, makeInviteDB)
+}
+
+func TestIntegrationMySQLInviteDB(t *testing.T) {
+	tests.TestInviteDB(t, makeMySQLInviteDB)
+}
@@ -0,0 +1,11 @@
+package integration_tests
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestIntegrationOrganizationDB(t *testing.T) {
+	tests.TestOrganizationDB(t, makeOrganizationDB)
+}
@@ -0,0 +1,15 @@
+package integration_tests
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestIntegrationRoleDB(t *testing.T) {
+	tests.TestRoleDB(t, makeRoleDB)
+}
+
+func TestIntegrationMySQLRoleDB(t *testing.T) {
+	tests.TestRoleDB(t, makeMySQLRoleDB)
+}
@@ -0,0 +1,15 @@
+package integration_tests
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestIntegrationUserImport(t *testing.T) {
+	tests.TestUserImport(t, makeUserDB)
+}
+
+func TestIntegrationCachedUserImport(t *testing.T) {
+	tests.TestUserImport(t, makeCachedUserDB)
+}
@@ -9,3 +9,7 @@ import (
 func TestIntegrationUserDB(t *testing.T) {
 	tests.TestUserDB(t, makeUserDB)
 }
+
+func TestIntegrationCachedUserDB(t *testing.T) {
+	tests.TestUserDB(t, makeCachedUserDB)
+}
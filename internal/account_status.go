@@ -0,0 +1,152 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rislah/fakes/internal/errors"
+)
+
+const maxStatusReasonLength = 500
+
+// AccountStatus is where an account is in its lifecycle. Only active
+// accounts can log in or use their tokens.
+type AccountStatus string
+
+const (
+	StatusPending     AccountStatus = "pending"
+	StatusActive      AccountStatus = "active"
+	StatusSuspended   AccountStatus = "suspended"
+	StatusDeactivated AccountStatus = "deactivated"
+)
+
+// statusTransitions lists the statuses each status can change to.
+var statusTransitions = map[AccountStatus][]AccountStatus{
+	StatusPending:     {StatusActive, StatusDeactivated},
+	StatusActive:      {StatusSuspended, StatusDeactivated},
+	StatusSuspended:   {StatusActive, StatusDeactivated},
+	StatusDeactivated: {StatusActive},
+}
+
+func (s AccountStatus) String() string {
+	return string(s)
+}
+
+func (s AccountStatus) Valid() bool {
+	_, ok := statusTransitions[s]
+	return ok
+}
+
+func (s AccountStatus) CanTransitionTo(to AccountStatus) bool {
+	for _, allowed := range statusTransitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Err returns the error reported to users of an account with status s, or nil
+// when the account is active.
+func (s AccountStatus) Err() error {
+	switch s {
+	case StatusActive, "":
+		return nil
+	case StatusPending:
+		return ErrAccountPending
+	case StatusSuspended:
+		return ErrAccountSuspended
+	default:
+		return ErrAccountDeactivated
+	}
+}
+
+// StatusChange moves an account from one status to another. UserDB
+// implementations apply it only while the account still has status From.
+type StatusChange struct {
+	From   AccountStatus
+	To     AccountStatus
+	Reason string
+	At     time.Time
+}
+
+func (u *userImpl) ChangeUserStatus(ctx context.Context, userID string, to AccountStatus, reason string) (User, error) {
+	if userID == "" {
+		return User{}, ErrUserNotFound
+	}
+
+	if !to.Valid() {
+		return User{}, ErrAccountStatusInvalid
+	}
+
+	reason = strings.TrimSpace(reason)
+	if len(reason) > maxStatusReasonLength {
+		return User{}, ErrStatusReasonInvalid
+	}
+	if reason == "" && to != StatusActive {
+		return User{}, ErrStatusReasonInvalid
+	}
+
+	usr, err := u.userDB.GetUserByID(ctx, userID)
+	if err != nil {
+		return User{}, err
+	}
+
+	if usr.IsEmpty() {
+		return User{}, ErrUserNotFound
+	}
+
+	if !usr.Status.CanTransitionTo(to) {
+		return User{}, ErrAccountStatusTransition
+	}
+
+	change := StatusChange{
+		From:   usr.Status,
+		To:     to,
+		Reason: reason,
+		At:     time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if err := u.userDB.UpdateUserStatus(ctx, userID, change); err != nil {
+		return User{}, err
+	}
+
+	usr.Status = change.To
+	usr.StatusReason = change.Reason
+	usr.StatusChangedAt = change.At
+
+	return usr.Sanitize(), nil
+}
+
+var (
+	ErrAccountPending = &errors.WrappedError{
+		Code: http.StatusForbidden,
+		Msg:  "Account is pending activation",
+	}
+	ErrAccountSuspended = &errors.WrappedError{
+		Code: http.StatusForbidden,
+		Msg:  "Account is suspended",
+	}
+	ErrAccountDeactivated = &errors.WrappedError{
+		Code: http.StatusForbidden,
+		Msg:  "Account is deactivated",
+	}
+	ErrAccountStatusInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Account status must be one of pending, active, suspended or deactivated",
+	}
+	ErrAccountStatusTransition = &errors.WrappedError{
+		Code: errors.ErrConflict,
+		Msg:  "Account status can't be changed to the requested status",
+	}
+	ErrStatusReasonInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "A reason of at most 500 characters is required",
+	}
+	ErrAccountStatusConflict = &errors.WrappedError{
+		Code: errors.ErrConflict,
+		Msg:  "Account status was changed concurrently",
+	}
+)
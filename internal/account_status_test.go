@@ -0,0 +1,107 @@
+package app_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/jwt"
+	"github.com/rislah/fakes/internal/local"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountStatus_CanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to app.AccountStatus
+		allowed  bool
+	}{
+		{app.StatusPending, app.StatusActive, true},
+		{app.StatusPending, app.StatusSuspended, false},
+		{app.StatusActive, app.StatusSuspended, true},
+		{app.StatusActive, app.StatusActive, false},
+		{app.StatusSuspended, app.StatusActive, true},
+		{app.StatusSuspended, app.StatusDeactivated, true},
+		{app.StatusDeactivated, app.StatusSuspended, false},
+		{app.StatusDeactivated, app.StatusActive, true},
+		{"unknown", app.StatusActive, false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.allowed, test.from.CanTransitionTo(test.to), "%s -> %s", test.from, test.to)
+	}
+}
+
+func TestUserImpl_ChangeUserStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		test func(ctx context.Context, t *testing.T, userBackend app.UserBackend, usr app.User)
+	}{
+		{
+			name: "should suspend and reinstate",
+			test: func(ctx context.Context, t *testing.T, userBackend app.UserBackend, usr app.User) {
+				res, err := userBackend.ChangeUserStatus(ctx, usr.UserID, app.StatusSuspended, "  spam  ")
+				assert.NoError(t, err)
+				assert.Equal(t, app.StatusSuspended, res.Status)
+				assert.Equal(t, "spam", res.StatusReason)
+				assert.Empty(t, res.Password)
+
+				res, err = userBackend.ChangeUserStatus(ctx, usr.UserID, app.StatusActive, "")
+				assert.NoError(t, err)
+				assert.Equal(t, app.StatusActive, res.Status)
+				assert.Empty(t, res.StatusReason)
+			},
+		},
+		{
+			name: "should require a reason",
+			test: func(ctx context.Context, t *testing.T, userBackend app.UserBackend, usr app.User) {
+				_, err := userBackend.ChangeUserStatus(ctx, usr.UserID, app.StatusSuspended, " ")
+				assert.Equal(t, app.ErrStatusReasonInvalid, err)
+
+				_, err = userBackend.ChangeUserStatus(ctx, usr.UserID, app.StatusSuspended, strings.Repeat("a", 501))
+				assert.Equal(t, app.ErrStatusReasonInvalid, err)
+			},
+		},
+		{
+			name: "should refuse invalid statuses and transitions",
+			test: func(ctx context.Context, t *testing.T, userBackend app.UserBackend, usr app.User) {
+				_, err := userBackend.ChangeUserStatus(ctx, usr.UserID, "banned", "spam")
+				assert.Equal(t, app.ErrAccountStatusInvalid, err)
+
+				_, err = userBackend.ChangeUserStatus(ctx, usr.UserID, app.StatusActive, "")
+				assert.Equal(t, app.ErrAccountStatusTransition, err)
+			},
+		},
+		{
+			name: "should return error if user doesnt exist",
+			test: func(ctx context.Context, t *testing.T, userBackend app.UserBackend, usr app.User) {
+				_, err := userBackend.ChangeUserStatus(ctx, "11111111-1111-1111-1111-111111111111", app.StatusSuspended, "spam")
+				assert.Equal(t, app.ErrUserNotFound, err)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			db, teardown, err := local.MakeUserDB()
+			assert.NoError(t, err)
+
+			defer func() {
+				assert.NoError(t, teardown())
+			}()
+
+			assert.NoError(t, db.CreateUser(ctx, app.User{Username: "asdf", Password: "hash"}))
+			usr, err := db.GetUserByUsername(ctx, "asdf")
+			assert.NoError(t, err)
+
+			userBackend := app.NewUserBackend(db, jwt.NewHS256Wrapper("wrapper"))
+			test.test(ctx, t, userBackend, usr)
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package audit
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rislah/fakes/internal/errors"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 100
+)
+
+// Action is what an event records. Actions are named <resource>.<verb>.
+type Action string
+
+const (
+	UserRegistered     Action = "user.registered"
+	UserProvisioned    Action = "user.provisioned"
+	LoginSucceeded     Action = "user.login_succeeded"
+	LoginFailed        Action = "user.login_failed"
+	UserUpdated        Action = "user.updated"
+	UserDeleted        Action = "user.deleted"
+	RoleChanged        Action = "user.role_changed"
+	StatusChanged      Action = "user.status_changed"
+	ProfileUpdated     Action = "user.profile_updated"
+	SessionsRevoked    Action = "user.sessions_revoked"
+	DataExported       Action = "user.data_exported"
+	ErasureRequested   Action = "user.erasure_requested"
+	ErasureCancelled   Action = "user.erasure_cancelled"
+	IdentifierAdded    Action = "user.identifier_added"
+	IdentifierVerified Action = "user.identifier_verified"
+	IdentifierRemoved  Action = "user.identifier_removed"
+	GroupCreated       Action = "group.created"
+	GroupUpdated       Action = "group.updated"
+	GroupDeleted       Action = "group.deleted"
+	GroupMemberAdded   Action = "group.member_added"
+	GroupMemberRemoved Action = "group.member_removed"
+	OrgCreated         Action = "organization.created"
+	OrgMemberAdded     Action = "organization.member_added"
+	InviteCreated      Action = "invite.created"
+	InviteRevoked      Action = "invite.revoked"
+)
+
+var actions = map[Action]struct{}{
+	UserRegistered: {}, UserProvisioned: {}, LoginSucceeded: {}, LoginFailed: {},
+	UserUpdated: {}, UserDeleted: {}, RoleChanged: {}, StatusChanged: {},
+	ProfileUpdated: {}, SessionsRevoked: {}, DataExported: {}, ErasureRequested: {},
+	ErasureCancelled: {}, GroupCreated: {}, GroupUpdated: {}, GroupDeleted: {},
+	GroupMemberAdded: {}, GroupMemberRemoved: {}, OrgCreated: {}, OrgMemberAdded: {},
+	InviteCreated: {}, InviteRevoked: {}, IdentifierAdded: {}, IdentifierVerified: {},
+	IdentifierRemoved: {},
+}
+
+func (a Action) Valid() bool {
+	_, ok := actions[a]
+	return ok
+}
+
+// Event is a security-relevant action. Seq, PrevHash and Hash are assigned
+// when the event is appended and chain each event to the one before it.
+//
+// Events can't be changed once appended, so they refer to users by ID only
+// and never hold usernames, email addresses or IP addresses. Once a user is
+// erased, nothing links the ID to the person anymore.
+type Event struct {
+	Seq        int64     `db:"seq" json:"seq"`
+	OccurredAt time.Time `db:"occurred_at" json:"occurred_at"`
+	Action     Action    `db:"action" json:"action"`
+	// ActorID is empty when the actor isn't a user, e.g. a failed login
+	// or a provisioning client, and Actor names it instead.
+	ActorID  string `db:"actor_id" json:"actor_id,omitempty"`
+	Actor    string `db:"actor" json:"actor,omitempty"`
+	TargetID string `db:"target_id" json:"target_id,omitempty"`
+	// IP is only set on events recorded before addresses stopped being
+	// stored, and stays so that their hashes remain valid.
+	IP       string  `db:"ip" json:"ip,omitempty"`
+	Details  Details `db:"details" json:"details,omitempty"`
+	PrevHash string  `db:"prev_hash" json:"prev_hash"`
+	Hash     string  `db:"hash" json:"hash"`
+}
+
+// Details are the attributes specific to an action, such as the role a
+// user had before a role change.
+type Details map[string]string
+
+func (d Details) Value() (driver.Value, error) {
+	if d == nil {
+		return []byte("{}"), nil
+	}
+
+	return json.Marshal(d)
+}
+
+func (d *Details) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	case nil:
+		*d = nil
+		return nil
+	default:
+		return fmt.Errorf("audit: cannot scan %T into Details", src)
+	}
+
+	details := Details{}
+	if err := json.Unmarshal(b, &details); err != nil {
+		return err
+	}
+
+	if len(details) == 0 {
+		details = nil
+	}
+	*d = details
+	return nil
+}
+
+type Store interface {
+	// Append chains event to the last event stored with Chain and stores
+	// it. Appends are serialized, so that each event has exactly one
+	// successor.
+	Append(ctx context.Context, event Event) (Event, error)
+	// Search returns up to query.Limit events matching query, newest first.
+	Search(ctx context.Context, query Query) ([]Event, error)
+	// Events returns up to limit events with a Seq greater than after, in
+	// order.
+	Events(ctx context.Context, after int64, limit int) ([]Event, error)
+}
+
+// Query selects events. Empty fields match every event, and From and To
+// bound OccurredAt to [From, To).
+type Query struct {
+	ActorID  string
+	TargetID string
+	Action   Action
+	From     time.Time
+	To       time.Time
+	// Before skips events with a Seq of Before or more.
+	Before int64
+	Limit  int
+}
+
+// SearchQuery is a request for a page of events. Cursor is the NextCursor
+// of the previous page.
+type SearchQuery struct {
+	ActorID  string
+	TargetID string
+	Action   Action
+	From     time.Time
+	To       time.Time
+	Cursor   string
+	Limit    int
+}
+
+type Page struct {
+	Events []Event
+	// NextCursor is empty on the last page.
+	NextCursor string
+}
+
+// Log records events to a Store and reads them back.
+type Log struct {
+	store Store
+}
+
+func NewLog(store Store) *Log {
+	if store == nil {
+		panic("store is required")
+	}
+
+	return &Log{store: store}
+}
+
+// Record appends event, stamping it with the current time.
+func (l *Log) Record(ctx context.Context, event Event) (Event, error) {
+	if !event.Action.Valid() {
+		return Event{}, ErrActionInvalid
+	}
+
+	event.OccurredAt = time.Now().UTC().Truncate(time.Microsecond)
+	return l.store.Append(ctx, event)
+}
+
+func (l *Log) Search(ctx context.Context, query SearchQuery) (Page, error) {
+	if query.Limit == 0 {
+		query.Limit = DefaultPageSize
+	}
+	if query.Limit < 0 || query.Limit > MaxPageSize {
+		return Page{}, ErrPageSizeInvalid
+	}
+
+	if query.Action != "" && !query.Action.Valid() {
+		return Page{}, ErrActionInvalid
+	}
+
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return Page{}, ErrTimeRangeInvalid
+	}
+
+	var before int64
+	if query.Cursor != "" {
+		var err error
+		if before, err = strconv.ParseInt(query.Cursor, 10, 64); err != nil || before < 1 {
+			return Page{}, ErrCursorInvalid
+		}
+	}
+
+	// One more event than asked for tells whether there is a next page.
+	events, err := l.store.Search(ctx, Query{
+		ActorID:  query.ActorID,
+		TargetID: query.TargetID,
+		Action:   query.Action,
+		From:     query.From,
+		To:       query.To,
+		Before:   before,
+		Limit:    query.Limit + 1,
+	})
+	if err != nil {
+		return Page{}, err
+	}
+
+	page := Page{Events: events}
+	if len(events) > query.Limit {
+		page.Events = events[:query.Limit]
+		page.NextCursor = strconv.FormatInt(page.Events[query.Limit-1].Seq, 10)
+	}
+
+	return page, nil
+}
+
+// UserEvents returns every event the user performed or was the target of,
+// oldest first.
+func (l *Log) UserEvents(ctx context.Context, userID string) ([]Event, error) {
+	seen := map[int64]struct{}{}
+	var events []Event
+	for _, query := range []Query{{ActorID: userID}, {TargetID: userID}} {
+		for {
+			query.Limit = MaxPageSize
+			page, err := l.store.Search(ctx, query)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, event := range page {
+				if _, ok := seen[event.Seq]; !ok {
+					seen[event.Seq] = struct{}{}
+					events = append(events, event)
+				}
+			}
+
+			if len(page) < query.Limit {
+				break
+			}
+			query.Before = page[len(page)-1].Seq
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Seq < events[j].Seq
+	})
+	return events, nil
+}
+
+// Verify walks the whole chain. See Verify.
+func (l *Log) Verify(ctx context.Context) (Verification, error) {
+	return Verify(ctx, l.store)
+}
+
+var (
+	ErrActionInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Unknown audit action",
+	}
+	ErrPageSizeInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Limit must be between 1 and 100",
+	}
+	ErrCursorInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Invalid cursor",
+	}
+	ErrTimeRangeInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "from must be before to",
+	}
+)
@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// verifyPageSize is the number of events Verify reads at a time.
+const verifyPageSize = 500
+
+// hashedEvent is the part of an event covered by its hash. Fields are only
+// ever added at the end, and only with omitempty, so that the hashes of
+// existing events stay valid.
+type hashedEvent struct {
+	Seq        int64   `json:"seq"`
+	OccurredAt string  `json:"occurred_at"`
+	Action     Action  `json:"action"`
+	ActorID    string  `json:"actor_id"`
+	Actor      string  `json:"actor"`
+	TargetID   string  `json:"target_id"`
+	IP         string  `json:"ip"`
+	Details    Details `json:"details"`
+	PrevHash   string  `json:"prev_hash"`
+}
+
+// Chain makes event the successor of last, the event stored last or an
+// empty Event for the first one, and computes its hash.
+func Chain(last Event, event Event) Event {
+	event.Seq = last.Seq + 1
+	event.PrevHash = last.Hash
+	event.Hash = event.ComputeHash()
+	return event
+}
+
+// ComputeHash returns the hex-encoded SHA-256 of the event, which covers the
+// hash of the previous event.
+func (e Event) ComputeHash() string {
+	details := e.Details
+	if details == nil {
+		details = Details{}
+	}
+
+	// Marshalling a struct of strings and a map with sorted keys can't fail.
+	b, _ := json.Marshal(hashedEvent{
+		Seq:        e.Seq,
+		OccurredAt: e.OccurredAt.UTC().Format(time.RFC3339Nano),
+		Action:     e.Action,
+		ActorID:    e.ActorID,
+		Actor:      e.Actor,
+		TargetID:   e.TargetID,
+		IP:         e.IP,
+		Details:    details,
+		PrevHash:   e.PrevHash,
+	})
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verification is the outcome of walking the chain. Removing events from the
+// end of the chain leaves a valid chain, so Head should be compared with a
+// head recorded earlier.
+type Verification struct {
+	Events int64
+	Head   Event
+	// Broken is the first event that isn't the successor of the event
+	// before it, and is nil when the chain is intact.
+	Broken *Event
+	Reason string
+}
+
+func (v Verification) Valid() bool {
+	return v.Broken == nil
+}
+
+// Verify walks the chain in store, checking that sequence numbers are
+// contiguous, that each event points at the hash of the one before it and
+// that each hash matches its event.
+func Verify(ctx context.Context, store Store) (Verification, error) {
+	var res Verification
+	for {
+		events, err := store.Events(ctx, res.Head.Seq, verifyPageSize)
+		if err != nil {
+			return Verification{}, err
+		}
+
+		for _, event := range events {
+			if reason := verifySuccessor(res.Head, event); reason != "" {
+				broken := event
+				res.Broken = &broken
+				res.Reason = reason
+				return res, nil
+			}
+
+			res.Events++
+			res.Head = event
+		}
+
+		if len(events) < verifyPageSize {
+			return res, nil
+		}
+	}
+}
+
+func verifySuccessor(last Event, event Event) string {
+	switch {
+	case event.Seq != last.Seq+1:
+		return fmt.Sprintf("expected event %d, found event %d", last.Seq+1, event.Seq)
+	case event.PrevHash != last.Hash:
+		return "previous hash doesn't match the hash of the previous event"
+	case event.Hash != event.ComputeHash():
+		return "hash doesn't match the event"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,17 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/sqlite"
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestLocalAuditStore(t *testing.T) {
+	tests.TestAuditStore(t, local.MakeAuditStore)
+}
+
+func TestSQLiteAuditStore(t *testing.T) {
+	tests.TestAuditStore(t, sqlite.MakeAuditStore)
+}
@@ -9,7 +9,12 @@ import (
 
 type Authenticator interface {
 	AuthenticatePassword(context.Context, credentials.Credentials) (User, error)
-	GenerateJWT(User) (string, error)
+	// GenerateJWT issues a token for usr carrying the roles and permissions
+	// it's granted through groups.
+	GenerateJWT(User, Grants) (string, error)
+	// GenerateOrganizationJWT issues a token with membership's organization
+	// as the active one.
+	GenerateOrganizationJWT(User, Membership) (string, error)
 }
 
 type authenticatorImpl struct {
@@ -25,11 +30,25 @@ func NewAuthenticator(userdb UserDB, jwtWrapper jwt.Wrapper) authenticatorImpl {
 }
 
 func (a authenticatorImpl) AuthenticatePassword(ctx context.Context, creds credentials.Credentials) (User, error) {
-	if err := creds.Valid(); err != nil {
+	if creds.Username == "" {
+		return User{}, credentials.ErrUsernameMissing
+	}
+
+	if creds.Password == "" {
+		return User{}, credentials.ErrPasswordMissing
+	}
+
+	if err := creds.Password.ValidateLength(); err != nil {
 		return User{}, err
 	}
 
-	usr, err := a.userDB.GetUserByUsername(ctx, string(creds.Username))
+	// The username of the credentials can be any identifier of the user.
+	_, identifier, err := ParseIdentifier(creds.Username.String())
+	if err != nil {
+		return User{}, err
+	}
+
+	usr, err := a.userDB.GetUserByIdentifier(ctx, identifier)
 	if err != nil {
 		return User{}, err
 	}
@@ -42,11 +61,33 @@ func (a authenticatorImpl) AuthenticatePassword(ctx context.Context, creds crede
 		return User{}, err
 	}
 
+	if err := usr.Status.Err(); err != nil {
+		return User{}, err
+	}
+
 	return usr, nil
 }
 
-func (a authenticatorImpl) GenerateJWT(usr User) (string, error) {
+func (a authenticatorImpl) GenerateJWT(usr User, grants Grants) (string, error) {
+	usrClaims := jwt.NewUserClaims(usr.Username, usr.Role.String())
+	usrClaims.Subject = usr.UserID
+	for _, role := range grants.Roles {
+		usrClaims.Roles = append(usrClaims.Roles, role.String())
+	}
+	usrClaims.Permissions = grants.Permissions
+	tokenStr, err := a.jwtWrapper.Encode(usrClaims)
+	if err != nil {
+		return "", err
+	}
+
+	return tokenStr, nil
+}
+
+func (a authenticatorImpl) GenerateOrganizationJWT(usr User, membership Membership) (string, error) {
 	usrClaims := jwt.NewUserClaims(usr.Username, usr.Role.String())
+	usrClaims.Subject = usr.UserID
+	usrClaims.Organization = membership.OrgID
+	usrClaims.OrganizationRole = membership.Role.String()
 	tokenStr, err := a.jwtWrapper.Encode(usrClaims)
 	if err != nil {
 		return "", err
@@ -11,11 +11,19 @@ func (u *userImpl) CreateUser(ctx context.Context, creds credentials.Credentials
 		return err
 	}
 
+	if creds.Username.IsReserved() {
+		return credentials.ErrUsernameReserved
+	}
+
 	if _, err := creds.Password.ValidateStrength(creds.Username.String()); err != nil {
 		return err
 	}
 
-	usr, err := u.userDB.GetUserByUsername(ctx, creds.Username.String())
+	// The lookup only spares hashing the password for a taken username. A
+	// registration racing this one is caught by the insert, where the
+	// database reports the username as taken too. Usernames are unique
+	// across organizations.
+	usr, err := u.userDB.GetUserByUsername(WithoutOrganization(ctx), creds.Username.String())
 	if err != nil {
 		return err
 	}
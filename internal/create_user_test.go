@@ -2,10 +2,13 @@ package app_test
 
 import (
 	"context"
+	stderrors "errors"
 	"github.com/rislah/fakes/internal/credentials"
+	"sync"
 	"testing"
 
 	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/errors"
 	"github.com/rislah/fakes/internal/jwt"
 	"github.com/rislah/fakes/internal/local"
 	"github.com/stretchr/testify/assert"
@@ -44,6 +47,14 @@ func TestUserImpl_CreateUser(t *testing.T) {
 				assert.Equal(t, credentials.ErrPasswordNotComplexEnough, err)
 			},
 		},
+		{
+			name:  "reserved username",
+			creds: credentials.New("admin", "parool123!"),
+			test: func(ctx context.Context, t *testing.T, creds credentials.Credentials, userBackend app.UserBackend, db app.UserDB) {
+				err := userBackend.CreateUser(ctx, creds)
+				assert.Equal(t, credentials.ErrUsernameReserved, err)
+			},
+		},
 		{
 			name:  "user already exists",
 			creds: credentials.New("kasutaja", "parool123!"),
@@ -76,6 +87,44 @@ func TestUserImpl_CreateUser(t *testing.T) {
 				assert.NotEmpty(t, usr.Role)
 			},
 		},
+		{
+			name:  "concurrent registrations of one username",
+			creds: credentials.New("kasutaja", "parool123!"),
+			test: func(ctx context.Context, t *testing.T, creds credentials.Credentials, userBackend app.UserBackend, db app.UserDB) {
+				errs := make(chan error, 4)
+				var wg sync.WaitGroup
+				for i := 0; i < cap(errs); i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						errs <- userBackend.CreateUser(ctx, creds)
+					}()
+				}
+				wg.Wait()
+				close(errs)
+
+				created := 0
+				for err := range errs {
+					if err == nil {
+						created++
+						continue
+					}
+					assert.Equal(t, app.ErrUserAlreadyExists, err)
+				}
+				assert.Equal(t, 1, created)
+			},
+		},
+		{
+			name:  "storage conflict on insert",
+			creds: credentials.New("kasutaja", "parool123!"),
+			test: func(ctx context.Context, t *testing.T, creds credentials.Credentials, userBackend app.UserBackend, db app.UserDB) {
+				userBackend = app.NewUserBackend(conflictingUserDB{db}, jwt.NewHS256Wrapper("wrap"))
+
+				err := userBackend.CreateUser(ctx, creds)
+				assert.True(t, errors.IsStorageError(err, errors.ErrStorageConflict), "only the database tells a taken username apart")
+				assert.NotEqual(t, app.ErrUserAlreadyExists, err)
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -90,3 +139,15 @@ func TestUserImpl_CreateUser(t *testing.T) {
 		})
 	}
 }
+
+// conflictingUserDB fails inserts the way a backend does when a concurrent
+// transaction makes the insert roll back, such as in a deadlock.
+type conflictingUserDB struct {
+	app.UserDB
+}
+
+func (conflictingUserDB) CreateUser(ctx context.Context, user app.User) error {
+	return errors.Storage(stderrors.New("deadlock detected"), func(error) *errors.WrappedError {
+		return errors.ErrStorageConflict
+	})
+}
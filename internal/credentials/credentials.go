@@ -60,8 +60,15 @@ func (c Credentials) Valid() error {
 	return nil
 }
 
+// ComparePassword checks pass against a bcrypt or argon2 digest.
 func ComparePassword(digest string, pass Password) error {
-	compare, err := pass.CompareBCrypt(digest)
+	var compare bool
+	var err error
+	if isArgon2Hash(digest) {
+		compare, err = pass.CompareArgon2(digest)
+	} else {
+		compare, err = pass.CompareBCrypt(digest)
+	}
 	if err != nil {
 		return errors.New(err)
 	}
@@ -1,9 +1,14 @@
 package credentials_test
 
 import (
-	"github.com/rislah/fakes/internal/credentials"
+	"encoding/base64"
+	"fmt"
 	"testing"
 
+	"github.com/rislah/fakes/internal/credentials"
+	"github.com/rislah/fakes/internal/errors"
+	"golang.org/x/crypto/argon2"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -89,3 +94,47 @@ func TestPassword(t *testing.T) {
 		})
 	}
 }
+
+func TestComparePassword(t *testing.T) {
+	argon2id := func(password string, memory uint32) string {
+		salt := []byte("somesaltsomesalt")
+		key := argon2.IDKey([]byte(password), salt, 1, memory, 1, 32)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=1,p=1$%s$%s", argon2.Version, memory,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+	}
+
+	bcryptHash, err := credentials.NewPassword("p@r00l!23").GenerateBCrypt()
+	assert.NoError(t, err)
+
+	tests := []struct {
+		scenario string
+		digest   string
+		password credentials.Password
+		err      error
+	}{
+		{scenario: "bcrypt", digest: bcryptHash, password: "p@r00l!23"},
+		{scenario: "bcrypt mismatch", digest: bcryptHash, password: "wrong", err: credentials.ErrPasswordMismatch},
+		{scenario: "argon2id", digest: argon2id("p@r00l!23", 64), password: "p@r00l!23"},
+		{scenario: "argon2id mismatch", digest: argon2id("p@r00l!23", 64), password: "wrong", err: credentials.ErrPasswordMismatch},
+		{scenario: "argon2id too expensive", digest: argon2id("p@r00l!23", 1024*1024), password: "p@r00l!23", err: credentials.ErrPasswordHashUnsupported},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			err := credentials.ComparePassword(test.digest, test.password)
+			if test.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, test.err, errors.Cause(err))
+			}
+		})
+	}
+}
+
+func TestValidateHash(t *testing.T) {
+	assert.NoError(t, credentials.ValidateHash("$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"))
+	assert.NoError(t, credentials.ValidateHash("$argon2id$v=19$m=65536,t=3,p=4$c29tZXNhbHQ$RdescudvJCsgt3ub+b+dWRWJTmaaJObG"))
+	assert.Equal(t, credentials.ErrPasswordHashUnsupported, credentials.ValidateHash("plaintext"))
+	assert.Equal(t, credentials.ErrPasswordHashUnsupported, credentials.ValidateHash("$argon2d$v=19$m=65536,t=3,p=4$c29tZXNhbHQ$RdescudvJCsgt3ub+b+dWRWJTmaaJObG"))
+	assert.Equal(t, credentials.ErrPasswordHashUnsupported, credentials.ValidateHash("$argon2id$v=19$m=65536,t=3,p=4$$RdescudvJCsgt3ub+b+dWRWJTmaaJObG"))
+}
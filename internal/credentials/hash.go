@@ -0,0 +1,110 @@
+package credentials
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rislah/fakes/internal/errors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Limits on the cost of argon2 hashes, so that an imported hash can't make
+// every login attempt allocate gigabytes.
+const (
+	argon2MaxMemory      = 256 * 1024
+	argon2MaxIterations  = 10
+	argon2MaxParallelism = 16
+)
+
+var ErrPasswordHashUnsupported = &errors.WrappedError{
+	Code: http.StatusBadRequest,
+	Msg:  "Password hash must be a bcrypt or argon2 hash",
+}
+
+// argon2Hash is a hash in the PHC string format, as produced by the
+// reference implementation: $argon2id$v=19$m=65536,t=3,p=4$salt$key.
+type argon2Hash struct {
+	variant     string
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func parseArgon2Hash(digest string) (argon2Hash, error) {
+	parts := strings.Split(digest, "$")
+	if len(parts) != 6 || parts[0] != "" {
+		return argon2Hash{}, ErrPasswordHashUnsupported
+	}
+
+	h := argon2Hash{variant: parts[1]}
+	if h.variant != "argon2id" && h.variant != "argon2i" {
+		return argon2Hash{}, ErrPasswordHashUnsupported
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return argon2Hash{}, ErrPasswordHashUnsupported
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.memory, &h.iterations, &h.parallelism); err != nil {
+		return argon2Hash{}, ErrPasswordHashUnsupported
+	}
+
+	if h.memory == 0 || h.memory > argon2MaxMemory ||
+		h.iterations == 0 || h.iterations > argon2MaxIterations ||
+		h.parallelism == 0 || h.parallelism > argon2MaxParallelism {
+		return argon2Hash{}, ErrPasswordHashUnsupported
+	}
+
+	var err error
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil || len(h.salt) == 0 {
+		return argon2Hash{}, ErrPasswordHashUnsupported
+	}
+
+	if h.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(h.key) == 0 {
+		return argon2Hash{}, ErrPasswordHashUnsupported
+	}
+
+	return h, nil
+}
+
+func isArgon2Hash(digest string) bool {
+	return strings.HasPrefix(digest, "$argon2")
+}
+
+// ValidateHash checks that digest is a password hash ComparePassword can
+// verify.
+func ValidateHash(digest string) error {
+	if isArgon2Hash(digest) {
+		_, err := parseArgon2Hash(digest)
+		return err
+	}
+
+	if _, err := bcrypt.Cost([]byte(digest)); err != nil {
+		return ErrPasswordHashUnsupported
+	}
+
+	return nil
+}
+
+func (p Password) CompareArgon2(digest string) (bool, error) {
+	h, err := parseArgon2Hash(digest)
+	if err != nil {
+		return false, err
+	}
+
+	var key []byte
+	if h.variant == "argon2id" {
+		key = argon2.IDKey([]byte(p), h.salt, h.iterations, h.memory, h.parallelism, uint32(len(h.key)))
+	} else {
+		key = argon2.Key([]byte(p), h.salt, h.iterations, h.memory, h.parallelism, uint32(len(h.key)))
+	}
+
+	return subtle.ConstantTimeCompare(key, h.key) == 1, nil
+}
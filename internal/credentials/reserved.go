@@ -0,0 +1,42 @@
+package credentials
+
+import (
+	"net/http"
+
+	"github.com/rislah/fakes/internal/errors"
+)
+
+// reservedUsernames can't be registered, as they could pass for the service
+// or its staff. Existing accounts with these names can still log in.
+var reservedUsernames = map[Username]struct{}{
+	"abuse": {}, "admin": {}, "administrator": {}, "anonymous": {},
+	"billing": {}, "help": {}, "hostmaster": {}, "info": {},
+	"moderator": {}, "noreply": {}, "no_reply": {}, "null": {},
+	"official": {}, "postmaster": {}, "root": {}, "scim": {},
+	"security": {}, "staff": {}, "support": {}, "system": {},
+	"undefined": {}, "webmaster": {},
+}
+
+var ErrUsernameReserved = &errors.WrappedError{
+	Code: http.StatusBadRequest,
+	Msg:  "Username is reserved",
+}
+
+// Validate runs the rules every username has to follow.
+func (u Username) Validate() error {
+	if u == "" {
+		return ErrUsernameMissing
+	}
+
+	if err := u.ValidateLength(); err != nil {
+		return err
+	}
+
+	return u.ValidateRegex()
+}
+
+// IsReserved reports whether the username is kept from registration.
+func (u Username) IsReserved() bool {
+	_, ok := reservedUsernames[u]
+	return ok
+}
@@ -0,0 +1,162 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/rislah/fakes/internal/audit"
+)
+
+// DataExport is everything stored about a user, as handed to the user. It
+// never contains the password hash.
+type DataExport struct {
+	GeneratedAt   time.Time            `json:"generated_at"`
+	Profile       ExportedProfile      `json:"profile"`
+	Organizations []ExportedMembership `json:"organizations"`
+	Groups        []ExportedGroup      `json:"groups"`
+	Identifiers   []ExportedIdentifier `json:"identifiers"`
+	Sessions      ExportedSessions     `json:"sessions"`
+	Erasure       *ExportedErasure     `json:"erasure,omitempty"`
+	// AuditEvents are the events the user performed or was the target of,
+	// oldest first.
+	AuditEvents []audit.Event `json:"audit_events"`
+}
+
+type ExportedProfile struct {
+	UserID          string        `json:"user_id"`
+	Username        string        `json:"username"`
+	Role            Role          `json:"role"`
+	DisplayName     string        `json:"display_name,omitempty"`
+	AvatarURL       string        `json:"avatar_url,omitempty"`
+	Locale          string        `json:"locale,omitempty"`
+	Timezone        string        `json:"timezone,omitempty"`
+	Status          AccountStatus `json:"status"`
+	StatusReason    string        `json:"status_reason,omitempty"`
+	StatusChangedAt time.Time     `json:"status_changed_at"`
+}
+
+type ExportedMembership struct {
+	OrgID string `json:"org_id"`
+	Role  Role   `json:"role"`
+}
+
+type ExportedGroup struct {
+	GroupID string `json:"group_id"`
+	Name    string `json:"name"`
+}
+
+// ExportedIdentifier is an email or phone number of the user. The hash of
+// the verification code isn't exported.
+type ExportedIdentifier struct {
+	IdentifierID string         `json:"identifier_id"`
+	Type         IdentifierType `json:"type"`
+	Value        string         `json:"value"`
+	Verified     bool           `json:"verified"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+// ExportedSessions is the session state kept about the user. Tokens aren't
+// stored, so there is no list of sessions to export.
+type ExportedSessions struct {
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type ExportedErasure struct {
+	RequestID    string    `json:"request_id"`
+	RequestedAt  time.Time `json:"requested_at"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+}
+
+func (p *privacyImpl) ExportUserData(ctx context.Context, userID string) (DataExport, error) {
+	usr, err := p.userDB.GetUserByID(ctx, userID)
+	if err != nil {
+		return DataExport{}, err
+	}
+
+	if usr.IsEmpty() {
+		return DataExport{}, ErrUserNotFound
+	}
+
+	export := DataExport{
+		GeneratedAt: time.Now().UTC(),
+		Profile: ExportedProfile{
+			UserID:          usr.UserID,
+			Username:        usr.Username,
+			Role:            usr.Role,
+			DisplayName:     usr.DisplayName,
+			AvatarURL:       usr.AvatarURL,
+			Locale:          usr.Locale,
+			Timezone:        usr.Timezone,
+			Status:          usr.Status,
+			StatusReason:    usr.StatusReason,
+			StatusChangedAt: usr.StatusChangedAt,
+		},
+		Organizations: []ExportedMembership{},
+		Groups:        []ExportedGroup{},
+		Identifiers:   []ExportedIdentifier{},
+		AuditEvents:   []audit.Event{},
+	}
+
+	memberships, err := p.orgDB.GetMemberships(ctx, userID)
+	if err != nil {
+		return DataExport{}, err
+	}
+
+	for _, membership := range memberships {
+		export.Organizations = append(export.Organizations, ExportedMembership{OrgID: membership.OrgID, Role: membership.Role})
+	}
+
+	groups, err := p.groupDB.GetUserGroups(ctx, userID)
+	if err != nil {
+		return DataExport{}, err
+	}
+
+	for _, group := range groups {
+		export.Groups = append(export.Groups, ExportedGroup{GroupID: group.GroupID, Name: group.Name})
+	}
+
+	identifiers, err := p.identifierDB.ListIdentifiers(ctx, userID)
+	if err != nil {
+		return DataExport{}, err
+	}
+
+	for _, identifier := range identifiers {
+		export.Identifiers = append(export.Identifiers, ExportedIdentifier{
+			IdentifierID: identifier.IdentifierID,
+			Type:         identifier.Type,
+			Value:        identifier.Value,
+			Verified:     identifier.Verified,
+			CreatedAt:    identifier.CreatedAt,
+		})
+	}
+
+	revokedAt, err := p.sessions.SessionsRevokedAt(ctx, userID)
+	if err != nil {
+		return DataExport{}, err
+	}
+
+	if !revokedAt.IsZero() {
+		export.Sessions.RevokedAt = &revokedAt
+	}
+
+	erasure, err := p.erasureDB.GetPendingErasureRequest(ctx, userID)
+	if err != nil {
+		return DataExport{}, err
+	}
+
+	if !erasure.IsEmpty() {
+		export.Erasure = &ExportedErasure{
+			RequestID:    erasure.RequestID,
+			RequestedAt:  erasure.RequestedAt,
+			ScheduledFor: erasure.ScheduledFor,
+		}
+	}
+
+	events, err := p.auditLog.UserEvents(ctx, userID)
+	if err != nil {
+		return DataExport{}, err
+	}
+
+	export.AuditEvents = append(export.AuditEvents, events...)
+	return export, nil
+}
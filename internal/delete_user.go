@@ -0,0 +1,13 @@
+package app
+
+import (
+	"context"
+)
+
+func (u *userImpl) DeleteUser(ctx context.Context, userID string) error {
+	if userID == "" {
+		return ErrUserNotFound
+	}
+
+	return u.userDB.DeleteUser(ctx, userID)
+}
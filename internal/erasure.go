@@ -0,0 +1,93 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+func (p *privacyImpl) RequestErasure(ctx context.Context, userID string) (ErasureRequest, error) {
+	usr, err := p.userDB.GetUserByID(ctx, userID)
+	if err != nil {
+		return ErasureRequest{}, err
+	}
+
+	if usr.IsEmpty() {
+		return ErasureRequest{}, ErrUserNotFound
+	}
+
+	// The erasure would otherwise fail once it's due, long after the user
+	// was told it would happen.
+	if usr.Role == AdminRole {
+		admins, err := p.userDB.ListUsers(ctx, UserFilter{Role: AdminRole, Limit: 2})
+		if err != nil {
+			return ErasureRequest{}, err
+		}
+
+		if len(admins) <= 1 {
+			return ErasureRequest{}, ErrErasureLastAdministrator
+		}
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	return p.erasureDB.CreateErasureRequest(ctx, ErasureRequest{
+		UserID:       userID,
+		Status:       ErasurePending,
+		RequestedAt:  now,
+		ScheduledFor: now.Add(ErasureGracePeriod),
+	})
+}
+
+func (p *privacyImpl) GetErasureRequest(ctx context.Context, userID string) (ErasureRequest, error) {
+	request, err := p.erasureDB.GetPendingErasureRequest(ctx, userID)
+	if err != nil {
+		return ErasureRequest{}, err
+	}
+
+	if request.IsEmpty() {
+		return ErasureRequest{}, ErrErasureRequestNotFound
+	}
+
+	return request, nil
+}
+
+func (p *privacyImpl) CancelErasure(ctx context.Context, userID string) error {
+	return p.erasureDB.DeletePendingErasureRequest(ctx, userID)
+}
+
+// ProcessDueErasures deletes each due user along with its memberships and
+// revokes its sessions. A user that no longer exists has nothing left to
+// erase, so its request is completed as well.
+func (p *privacyImpl) ProcessDueErasures(ctx context.Context, now time.Time) ([]ErasureResult, error) {
+	requests, err := p.erasureDB.GetDueErasureRequests(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ErasureResult, 0, len(requests))
+	for _, request := range requests {
+		err := p.userDB.DeleteUser(ctx, request.UserID)
+		if err == ErrLastAdministrator {
+			results = append(results, ErasureResult{Request: request, Err: err})
+			continue
+		}
+
+		if err != nil && err != ErrUserNotFound {
+			return results, err
+		}
+
+		if err := p.sessions.RevokeUserSessions(ctx, request.UserID); err != nil {
+			return results, err
+		}
+
+		completedAt := now.UTC().Truncate(time.Microsecond)
+		if err := p.erasureDB.CompleteErasureRequest(ctx, request.RequestID, completedAt); err != nil {
+			return results, err
+		}
+
+		request.Status = ErasureCompleted
+		request.CompletedAt = &completedAt
+		results = append(results, ErasureResult{Request: request})
+	}
+
+	return results, nil
+}
@@ -0,0 +1,17 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/sqlite"
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestLocalErasureDB(t *testing.T) {
+	tests.TestErasureDB(t, local.MakeErasureDB)
+}
+
+func TestSQLiteErasureDB(t *testing.T) {
+	tests.TestErasureDB(t, sqlite.MakeErasureDB)
+}
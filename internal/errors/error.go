@@ -133,6 +133,7 @@ const (
 	ErrRateLimited  ErrorCode = 429
 	ErrConflict     ErrorCode = http.StatusConflict
 	ErrUnauthorized ErrorCode = http.StatusUnauthorized
+	ErrUnavailable  ErrorCode = http.StatusServiceUnavailable
 )
 
 type WrappedError struct {
@@ -197,15 +198,23 @@ func IsWrappedErrorWriteErrorResponse(ctx context.Context, w http.ResponseWriter
 		v := NewErrorResponse(e.Msg, int(e.Code))
 
 		w.WriteHeader(int(e.Code))
-		b, err := json.Marshal(v)
-		if err != nil {
-			return err
+		b, merr := json.Marshal(v)
+		if merr != nil {
+			return merr
 		}
 
 		w.Header().Add("Content-Type", "application/json;charset=utf-8")
 		w.Header().Add("Content-Length", strconv.Itoa(len(b)))
-		_, err = w.Write(b)
-		return err
+		if _, werr := w.Write(b); werr != nil {
+			return werr
+		}
+
+		// Server errors, such as an unavailable database, are still
+		// returned so that they get logged.
+		if e.Code >= http.StatusInternalServerError {
+			return err
+		}
+		return nil
 	}
 	return err
 }
@@ -0,0 +1,94 @@
+package errors
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	stderrors "errors"
+	"net"
+)
+
+// Storage errors classify database failures independently of the backend,
+// so that callers and the api layer can react to them without knowing the
+// driver. Backends return them through Storage, which keeps the driver error
+// in the fields for logging.
+var (
+	ErrStorageConflict = &WrappedError{
+		Code: ErrConflict,
+		Msg:  "Conflicting write, please retry",
+	}
+	ErrStorageNotFound = &WrappedError{
+		Code: ErrNotFound,
+		Msg:  "Not found",
+	}
+	ErrStorageUnavailable = &WrappedError{
+		Code: ErrUnavailable,
+		Msg:  "Service temporarily unavailable",
+	}
+	ErrStorageTimeout = &WrappedError{
+		Code: ErrUnavailable,
+		Msg:  "Service timed out",
+	}
+)
+
+// circuitError is implemented by the errors of an open or saturated circuit.
+type circuitError interface {
+	CircuitOpen() bool
+	ConcurrencyLimitReached() bool
+}
+
+// Storage wraps err, as returned by a database or the circuit around it, in
+// the storage error it corresponds to. classify maps driver specific errors,
+// while circuit, context and connection errors are recognized for every
+// backend. Errors that match no storage error are wrapped as is.
+func Storage(err error, classify func(error) *WrappedError) Error {
+	if err == nil {
+		return nil
+	}
+
+	cause := Cause(err)
+
+	var kind *WrappedError
+	if classify != nil {
+		kind = classify(cause)
+	}
+
+	if kind == nil {
+		kind = classifyStorage(cause)
+	}
+
+	if kind == nil {
+		return NewWithSkip(err, 1)
+	}
+
+	return NewWithSkip(kind, 1, Fields{"cause": err.Error()})
+}
+
+// IsStorageError reports whether err is the storage error kind.
+func IsStorageError(err error, kind *WrappedError) bool {
+	return err != nil && Cause(err) == kind
+}
+
+func classifyStorage(err error) *WrappedError {
+	var circuitErr circuitError
+	if stderrors.As(err, &circuitErr) && (circuitErr.CircuitOpen() || circuitErr.ConcurrencyLimitReached()) {
+		return ErrStorageUnavailable
+	}
+
+	var netErr net.Error
+	switch {
+	case stderrors.Is(err, context.DeadlineExceeded):
+		return ErrStorageTimeout
+	case stderrors.Is(err, sql.ErrNoRows):
+		return ErrStorageNotFound
+	case stderrors.Is(err, driver.ErrBadConn), stderrors.Is(err, sql.ErrConnDone):
+		return ErrStorageUnavailable
+	case stderrors.As(err, &netErr):
+		if netErr.Timeout() {
+			return ErrStorageTimeout
+		}
+		return ErrStorageUnavailable
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package errors_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	stderrors "errors"
+	"net"
+	"testing"
+
+	"github.com/rislah/fakes/internal/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type circuitOpen struct{}
+
+func (circuitOpen) Error() string                 { return "circuit is open" }
+func (circuitOpen) CircuitOpen() bool             { return true }
+func (circuitOpen) ConcurrencyLimitReached() bool { return false }
+
+func TestStorage(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		classify func(error) *errors.WrappedError
+		kind     *errors.WrappedError
+	}{
+		{
+			name: "open circuit",
+			err:  circuitOpen{},
+			kind: errors.ErrStorageUnavailable,
+		},
+		{
+			name: "deadline exceeded",
+			err:  context.DeadlineExceeded,
+			kind: errors.ErrStorageTimeout,
+		},
+		{
+			name: "bad connection",
+			err:  driver.ErrBadConn,
+			kind: errors.ErrStorageUnavailable,
+		},
+		{
+			name: "no rows",
+			err:  sql.ErrNoRows,
+			kind: errors.ErrStorageNotFound,
+		},
+		{
+			name: "network error",
+			err:  &net.OpError{Op: "dial", Err: stderrors.New("connection refused")},
+			kind: errors.ErrStorageUnavailable,
+		},
+		{
+			name: "wrapped error",
+			err:  errors.New(context.DeadlineExceeded),
+			kind: errors.ErrStorageTimeout,
+		},
+		{
+			name: "driver error",
+			err:  stderrors.New("duplicate key"),
+			classify: func(error) *errors.WrappedError {
+				return errors.ErrStorageConflict
+			},
+			kind: errors.ErrStorageConflict,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := errors.Storage(test.err, test.classify)
+			assert.True(t, errors.IsStorageError(err, test.kind))
+			assert.Equal(t, test.kind, errors.Unwrap(err))
+			assert.Equal(t, test.err.Error(), err.Fields()["cause"])
+		})
+	}
+}
+
+func TestStorageUnclassified(t *testing.T) {
+	assert.Nil(t, errors.Storage(nil, nil))
+
+	cause := stderrors.New("syntax error")
+	err := errors.Storage(cause, nil)
+	assert.Equal(t, cause, errors.Unwrap(err))
+	assert.False(t, errors.IsStorageError(err, errors.ErrStorageConflict))
+
+	_, ok := errors.IsWrappedError(context.Background(), err)
+	assert.False(t, ok)
+}
@@ -4,8 +4,11 @@ import (
 	"net"
 
 	"github.com/oschwald/geoip2-golang"
+	"github.com/rislah/fakes/internal/errors"
 )
 
+var ErrDatabaseNotLoaded = errors.New("geoip database not loaded")
+
 type GeoIP struct {
 	db *geoip2.Reader
 }
@@ -20,6 +23,10 @@ func New(path string) (GeoIP, error) {
 }
 
 func (g GeoIP) LookupCountryISO(ip net.IP) (string, error) {
+	if g.db == nil {
+		return "", ErrDatabaseNotLoaded
+	}
+
 	resp, err := g.db.Country(ip)
 	return resp.Country.IsoCode, err
 }
@@ -1,6 +1,7 @@
 package geoip_test
 
 import (
+	"net"
 	"testing"
 
 	"github.com/rislah/fakes/internal/geoip"
@@ -11,3 +12,8 @@ func TestGeoIP(t *testing.T) {
 	_, err := geoip.New(".")
 	assert.Error(t, err)
 }
+
+func TestGeoIPNotLoaded(t *testing.T) {
+	_, err := geoip.GeoIP{}.LookupCountryISO(net.ParseIP("127.0.0.1"))
+	assert.Equal(t, geoip.ErrDatabaseNotLoaded, err)
+}
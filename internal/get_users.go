@@ -16,3 +16,33 @@ func (u userImpl) GetUsers(ctx context.Context) ([]User, error) {
 
 	return users, nil
 }
+
+func (u userImpl) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	user, err := u.userDB.GetUserByUsername(ctx, username)
+	if err != nil {
+		return User{}, err
+	}
+
+	if user.IsEmpty() {
+		return User{}, ErrUserNotFound
+	}
+
+	return user.Sanitize(), nil
+}
+
+func (u userImpl) GetUser(ctx context.Context, userID string) (User, error) {
+	if userID == "" {
+		return User{}, ErrUserNotFound
+	}
+
+	user, err := u.userDB.GetUserByID(ctx, userID)
+	if err != nil {
+		return User{}, err
+	}
+
+	if user.IsEmpty() {
+		return User{}, ErrUserNotFound
+	}
+
+	return user.Sanitize(), nil
+}
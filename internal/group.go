@@ -0,0 +1,207 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/rislah/fakes/internal/errors"
+)
+
+const maxGroupNameLength = 100
+
+type GroupBackend interface {
+	CreateGroup(ctx context.Context, group Group) (Group, error)
+	GetGroups(ctx context.Context) ([]Group, error)
+	GetGroup(ctx context.Context, groupID string) (Group, error)
+	UpdateGroup(ctx context.Context, group Group) error
+	DeleteGroup(ctx context.Context, groupID string) error
+	AddGroupMember(ctx context.Context, groupID string, userID string) error
+	RemoveGroupMember(ctx context.Context, groupID string, userID string) error
+	GetGroupMembers(ctx context.Context, groupID string) ([]string, error)
+	// GetUserGrants returns the union of the roles and permissions of the
+	// groups userID belongs to.
+	GetUserGrants(ctx context.Context, userID string) (Grants, error)
+}
+
+type GroupDB interface {
+	// CreateGroup stores group, generating its ID when empty.
+	CreateGroup(ctx context.Context, group Group) (Group, error)
+	GetGroups(ctx context.Context) ([]Group, error)
+	// GetGroup returns an empty Group when it doesn't exist.
+	GetGroup(ctx context.Context, groupID string) (Group, error)
+	// UpdateGroup replaces the name, roles and permissions of the group.
+	UpdateGroup(ctx context.Context, group Group) error
+	DeleteGroup(ctx context.Context, groupID string) error
+	AddGroupMember(ctx context.Context, groupID string, userID string) error
+	RemoveGroupMember(ctx context.Context, groupID string, userID string) error
+	GetGroupMembers(ctx context.Context, groupID string) ([]string, error)
+	GetUserGroups(ctx context.Context, userID string) ([]Group, error)
+}
+
+type Group struct {
+	GroupID     string
+	Name        string
+	Roles       []Role
+	Permissions []string
+}
+
+func (g Group) IsEmpty() bool {
+	return g.GroupID == ""
+}
+
+func (g Group) Valid() error {
+	name := strings.TrimSpace(g.Name)
+	if name == "" || len(name) > maxGroupNameLength {
+		return ErrGroupNameInvalid
+	}
+
+	for _, role := range g.Roles {
+		if err := (RoleDefinition{Name: role}).Valid(); err != nil {
+			return err
+		}
+	}
+
+	for _, permission := range g.Permissions {
+		if permission == "" {
+			return ErrPermissionNameInvalid
+		}
+	}
+
+	return nil
+}
+
+// Grants are the roles and permissions a user holds on top of its own role.
+type Grants struct {
+	Roles       []Role
+	Permissions []string
+}
+
+type groupImpl struct {
+	groupDB GroupDB
+}
+
+func NewGroupBackend(db GroupDB) GroupBackend {
+	if db == nil {
+		panic("database is required")
+	}
+
+	return &groupImpl{groupDB: db}
+}
+
+func (g groupImpl) CreateGroup(ctx context.Context, group Group) (Group, error) {
+	if err := group.Valid(); err != nil {
+		return Group{}, err
+	}
+
+	group.Name = strings.TrimSpace(group.Name)
+	return g.groupDB.CreateGroup(ctx, group)
+}
+
+func (g groupImpl) GetGroups(ctx context.Context) ([]Group, error) {
+	return g.groupDB.GetGroups(ctx)
+}
+
+func (g groupImpl) GetGroup(ctx context.Context, groupID string) (Group, error) {
+	group, err := g.groupDB.GetGroup(ctx, groupID)
+	if err != nil {
+		return Group{}, err
+	}
+
+	if group.IsEmpty() {
+		return Group{}, ErrGroupNotFound
+	}
+
+	return group, nil
+}
+
+func (g groupImpl) UpdateGroup(ctx context.Context, group Group) error {
+	if err := group.Valid(); err != nil {
+		return err
+	}
+
+	group.Name = strings.TrimSpace(group.Name)
+	return g.groupDB.UpdateGroup(ctx, group)
+}
+
+func (g groupImpl) DeleteGroup(ctx context.Context, groupID string) error {
+	return g.groupDB.DeleteGroup(ctx, groupID)
+}
+
+func (g groupImpl) AddGroupMember(ctx context.Context, groupID string, userID string) error {
+	if userID == "" {
+		return ErrUserNotFound
+	}
+
+	return g.groupDB.AddGroupMember(ctx, groupID, userID)
+}
+
+func (g groupImpl) RemoveGroupMember(ctx context.Context, groupID string, userID string) error {
+	return g.groupDB.RemoveGroupMember(ctx, groupID, userID)
+}
+
+func (g groupImpl) GetGroupMembers(ctx context.Context, groupID string) ([]string, error) {
+	if _, err := g.GetGroup(ctx, groupID); err != nil {
+		return nil, err
+	}
+
+	return g.groupDB.GetGroupMembers(ctx, groupID)
+}
+
+func (g groupImpl) GetUserGrants(ctx context.Context, userID string) (Grants, error) {
+	groups, err := g.groupDB.GetUserGroups(ctx, userID)
+	if err != nil {
+		return Grants{}, err
+	}
+
+	roles := map[Role]struct{}{}
+	permissions := map[string]struct{}{}
+	for _, group := range groups {
+		for _, role := range group.Roles {
+			roles[role] = struct{}{}
+		}
+		for _, permission := range group.Permissions {
+			permissions[permission] = struct{}{}
+		}
+	}
+
+	grants := Grants{
+		Roles:       make([]Role, 0, len(roles)),
+		Permissions: make([]string, 0, len(permissions)),
+	}
+	for role := range roles {
+		grants.Roles = append(grants.Roles, role)
+	}
+	for permission := range permissions {
+		grants.Permissions = append(grants.Permissions, permission)
+	}
+
+	sort.Slice(grants.Roles, func(i, j int) bool { return grants.Roles[i] < grants.Roles[j] })
+	sort.Strings(grants.Permissions)
+
+	return grants, nil
+}
+
+var (
+	ErrGroupNotFound = &errors.WrappedError{
+		Code: errors.ErrNotFound,
+		Msg:  "Group not found",
+	}
+	ErrGroupAlreadyExists = &errors.WrappedError{
+		Code: errors.ErrConflict,
+		Msg:  "Group already exists",
+	}
+	ErrGroupNameInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Group name must be between 1 and 100 characters",
+	}
+	ErrGroupMemberAlreadyExists = &errors.WrappedError{
+		Code: errors.ErrConflict,
+		Msg:  "User is already a member of the group",
+	}
+	ErrGroupMemberNotFound = &errors.WrappedError{
+		Code: errors.ErrNotFound,
+		Msg:  "User is not a member of the group",
+	}
+)
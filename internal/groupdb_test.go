@@ -0,0 +1,17 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/sqlite"
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestLocalGroupDB(t *testing.T) {
+	tests.TestGroupDB(t, local.MakeGroupDB)
+}
+
+func TestSQLiteGroupDB(t *testing.T) {
+	tests.TestGroupDB(t, sqlite.MakeGroupDB)
+}
@@ -0,0 +1,344 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rislah/fakes/internal/credentials"
+	"github.com/rislah/fakes/internal/errors"
+)
+
+const (
+	// VerificationCodeExpiry is how long after an identifier is added its
+	// verification code can be used.
+	VerificationCodeExpiry = 15 * time.Minute
+	// MaxVerificationAttempts is how many codes can be tried for an
+	// identifier before it has to be added again.
+	MaxVerificationAttempts = 5
+	// MaxIdentifiers bounds the emails and phone numbers of a user.
+	MaxIdentifiers = 10
+)
+
+// IdentifierType is the kind of value a user can log in with.
+type IdentifierType string
+
+const (
+	IdentifierUsername IdentifierType = "username"
+	IdentifierEmail    IdentifierType = "email"
+	IdentifierPhone    IdentifierType = "phone"
+)
+
+// IdentifierBackend manages the emails and phone numbers users can log in
+// with besides their username.
+type IdentifierBackend interface {
+	// ListIdentifiers returns the username of the user followed by its
+	// emails and phone numbers, oldest first.
+	ListIdentifiers(ctx context.Context, userID string) ([]Identifier, error)
+	// AddIdentifier adds an unverified email or phone number to the user
+	// and sends a verification code to it.
+	AddIdentifier(ctx context.Context, userID string, value string) (Identifier, error)
+	// VerifyIdentifier checks code and, once it matches, lets the
+	// identifier be used to log in.
+	VerifyIdentifier(ctx context.Context, userID string, identifierID string, code string) (Identifier, error)
+	RemoveIdentifier(ctx context.Context, userID string, identifierID string) error
+}
+
+// IdentifierDB stores emails and phone numbers. Verified ones share a single
+// namespace, so a value can't be verified for two users or as both an email
+// and a phone number. Usernames can't contain "@" or "+", so they never
+// collide with either.
+type IdentifierDB interface {
+	// CreateIdentifier stores an unverified identifier, generating its ID.
+	// It fails with ErrIdentifierTaken when the user already has it.
+	CreateIdentifier(ctx context.Context, identifier Identifier) (Identifier, error)
+	// ListIdentifiers returns the identifiers of the user, oldest first.
+	ListIdentifiers(ctx context.Context, userID string) ([]Identifier, error)
+	// GetIdentifier returns an empty Identifier when it doesn't exist.
+	GetIdentifier(ctx context.Context, identifierID string) (Identifier, error)
+	// VerifyIdentifier counts an attempt and marks the identifier verified
+	// when codeHash matches and fewer than MaxVerificationAttempts were made
+	// before. It fails with ErrVerificationCodeInvalid otherwise, and with
+	// ErrIdentifierTaken when the value was verified for another user first.
+	VerifyIdentifier(ctx context.Context, identifierID string, codeHash string, now time.Time) error
+	DeleteIdentifier(ctx context.Context, identifierID string) error
+}
+
+// VerificationSender delivers verification codes to emails and phone numbers.
+type VerificationSender interface {
+	SendVerificationCode(ctx context.Context, identifier Identifier, code string) error
+}
+
+// Identifier is a normalized value a user can log in with once verified.
+// Usernames are always verified and are only ever listed, never stored as
+// identifiers.
+type Identifier struct {
+	IdentifierID string         `db:"identifier_id"`
+	UserID       string         `db:"user_id"`
+	Type         IdentifierType `db:"type"`
+	Value        string         `db:"value"`
+	Verified     bool           `db:"verified"`
+	CodeHash     string         `db:"code_hash"`
+	Attempts     int            `db:"attempts"`
+	CreatedAt    time.Time      `db:"created_at"`
+}
+
+func (i Identifier) IsEmpty() bool {
+	return i.IdentifierID == ""
+}
+
+// ParseIdentifier tells usernames, emails and phone numbers apart and
+// returns value in the form it is stored and looked up in. Emails are
+// lowercased and phone numbers reduced to a plus sign followed by digits.
+func ParseIdentifier(value string) (IdentifierType, string, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case strings.Contains(value, "@"):
+		email := strings.ToLower(value)
+		if len(email) > 254 || !validInviteEmail(email) {
+			return "", "", ErrIdentifierInvalid
+		}
+		return IdentifierEmail, email, nil
+	case strings.HasPrefix(value, "+"):
+		phone := strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "").Replace(value[1:])
+		if len(phone) < 8 || len(phone) > 15 || strings.Trim(phone, "0123456789") != "" || phone[0] == '0' {
+			return "", "", ErrIdentifierInvalid
+		}
+		return IdentifierPhone, "+" + phone, nil
+	default:
+		if err := credentials.NewUsername(value).Validate(); err != nil {
+			return "", "", err
+		}
+		return IdentifierUsername, value, nil
+	}
+}
+
+type identifierImpl struct {
+	userDB       UserDB
+	identifierDB IdentifierDB
+	sender       VerificationSender
+	now          func() time.Time
+}
+
+func NewIdentifierBackend(userDB UserDB, identifierDB IdentifierDB, sender VerificationSender) IdentifierBackend {
+	if userDB == nil || identifierDB == nil {
+		panic("database is required")
+	}
+
+	if sender == nil {
+		panic("verification sender is required")
+	}
+
+	return &identifierImpl{
+		userDB:       userDB,
+		identifierDB: identifierDB,
+		sender:       sender,
+		now:          func() time.Time { return time.Now().UTC().Truncate(time.Microsecond) },
+	}
+}
+
+func (i *identifierImpl) ListIdentifiers(ctx context.Context, userID string) ([]Identifier, error) {
+	usr, err := i.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	identifiers, err := i.identifierDB.ListIdentifiers(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	username := Identifier{UserID: usr.UserID, Type: IdentifierUsername, Value: usr.Username, Verified: true}
+	return append([]Identifier{username}, identifiers...), nil
+}
+
+func (i *identifierImpl) AddIdentifier(ctx context.Context, userID string, value string) (Identifier, error) {
+	typ, value, err := ParseIdentifier(value)
+	if err != nil {
+		return Identifier{}, err
+	}
+
+	if typ == IdentifierUsername {
+		return Identifier{}, ErrIdentifierTypeInvalid
+	}
+
+	if _, err := i.getUser(ctx, userID); err != nil {
+		return Identifier{}, err
+	}
+
+	existing, err := i.identifierDB.ListIdentifiers(ctx, userID)
+	if err != nil {
+		return Identifier{}, err
+	}
+
+	// Adding an unverified identifier again replaces it, which is how a new
+	// code is requested.
+	count := len(existing)
+	for _, identifier := range existing {
+		if identifier.Value != value {
+			continue
+		}
+
+		if identifier.Verified {
+			return Identifier{}, ErrIdentifierTaken
+		}
+
+		if err := i.identifierDB.DeleteIdentifier(ctx, identifier.IdentifierID); err != nil {
+			return Identifier{}, err
+		}
+		count--
+	}
+
+	if count >= MaxIdentifiers {
+		return Identifier{}, ErrTooManyIdentifiers
+	}
+
+	code, err := newVerificationCode()
+	if err != nil {
+		return Identifier{}, err
+	}
+
+	// Whether someone else verified the value is only checked once the code
+	// proves the user controls it, so that adding doesn't reveal who has
+	// registered an email or phone number.
+	identifier, err := i.identifierDB.CreateIdentifier(ctx, Identifier{
+		UserID:    userID,
+		Type:      typ,
+		Value:     value,
+		CodeHash:  hashVerificationCode(value, code),
+		CreatedAt: i.now(),
+	})
+	if err != nil {
+		return Identifier{}, err
+	}
+
+	if err := i.sender.SendVerificationCode(ctx, identifier, code); err != nil {
+		if deleteErr := i.identifierDB.DeleteIdentifier(ctx, identifier.IdentifierID); deleteErr != nil {
+			return Identifier{}, errors.Wrap(deleteErr, "deleting identifier after failed delivery")
+		}
+		return Identifier{}, err
+	}
+
+	return identifier, nil
+}
+
+func (i *identifierImpl) VerifyIdentifier(ctx context.Context, userID string, identifierID string, code string) (Identifier, error) {
+	identifier, err := i.getIdentifier(ctx, userID, identifierID)
+	if err != nil {
+		return Identifier{}, err
+	}
+
+	if identifier.Verified {
+		return identifier, nil
+	}
+
+	now := i.now()
+	if !now.Before(identifier.CreatedAt.Add(VerificationCodeExpiry)) {
+		return Identifier{}, ErrVerificationCodeExpired
+	}
+
+	if code == "" {
+		return Identifier{}, ErrVerificationCodeInvalid
+	}
+
+	if err := i.identifierDB.VerifyIdentifier(ctx, identifierID, hashVerificationCode(identifier.Value, code), now); err != nil {
+		return Identifier{}, err
+	}
+
+	identifier.Verified = true
+	identifier.Attempts++
+	return identifier, nil
+}
+
+func (i *identifierImpl) RemoveIdentifier(ctx context.Context, userID string, identifierID string) error {
+	if _, err := i.getIdentifier(ctx, userID, identifierID); err != nil {
+		return err
+	}
+
+	return i.identifierDB.DeleteIdentifier(ctx, identifierID)
+}
+
+func (i *identifierImpl) getUser(ctx context.Context, userID string) (User, error) {
+	usr, err := i.userDB.GetUserByID(ctx, userID)
+	if err != nil {
+		return User{}, err
+	}
+
+	if usr.IsEmpty() {
+		return User{}, ErrUserNotFound
+	}
+
+	return usr, nil
+}
+
+// getIdentifier returns the identifier if it belongs to the user, so that
+// the identifiers of others look like they don't exist.
+func (i *identifierImpl) getIdentifier(ctx context.Context, userID string, identifierID string) (Identifier, error) {
+	if identifierID == "" {
+		return Identifier{}, ErrIdentifierNotFound
+	}
+
+	identifier, err := i.identifierDB.GetIdentifier(ctx, identifierID)
+	if err != nil {
+		return Identifier{}, err
+	}
+
+	if identifier.IsEmpty() || identifier.UserID != userID {
+		return Identifier{}, ErrIdentifierNotFound
+	}
+
+	return identifier, nil
+}
+
+func newVerificationCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// hashVerificationCode includes the value, so that equal codes for
+// different identifiers don't share a hash.
+func hashVerificationCode(value string, code string) string {
+	sum := sha256.Sum256([]byte(value + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+var (
+	ErrIdentifierInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Identifier must be a username, an email or a phone number starting with +",
+	}
+	ErrIdentifierTypeInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Only emails and phone numbers can be added, usernames are changed by updating the user",
+	}
+	ErrIdentifierTaken = &errors.WrappedError{
+		Code: errors.ErrConflict,
+		Msg:  "Identifier is already in use",
+	}
+	ErrIdentifierNotFound = &errors.WrappedError{
+		Code: errors.ErrNotFound,
+		Msg:  "Identifier not found",
+	}
+	ErrTooManyIdentifiers = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "A user can have at most 10 emails and phone numbers",
+	}
+	ErrVerificationCodeInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Invalid verification code",
+	}
+	ErrVerificationCodeExpired = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Verification code has expired, add the identifier again",
+	}
+)
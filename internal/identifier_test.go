@@ -0,0 +1,136 @@
+package app_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/credentials"
+	"github.com/rislah/fakes/internal/jwt"
+	"github.com/rislah/fakes/internal/local"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIdentifier(t *testing.T) {
+	tests := []struct {
+		value string
+		typ   app.IdentifierType
+		res   string
+		err   error
+	}{
+		{value: "alice", typ: app.IdentifierUsername, res: "alice"},
+		{value: " Alice@Example.com ", typ: app.IdentifierEmail, res: "alice@example.com"},
+		{value: "+372 (555) 1234-5", typ: app.IdentifierPhone, res: "+37255512345"},
+		{value: "alice@", err: app.ErrIdentifierInvalid},
+		{value: "+0123456789", err: app.ErrIdentifierInvalid},
+		{value: "+1234", err: app.ErrIdentifierInvalid},
+		{value: "+1234567x9", err: app.ErrIdentifierInvalid},
+		{value: "Alice", err: credentials.ErrUsernameRegexFail},
+		{value: "", err: credentials.ErrUsernameMissing},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			typ, res, err := app.ParseIdentifier(test.value)
+			assert.Equal(t, test.err, err)
+			assert.Equal(t, test.typ, typ)
+			assert.Equal(t, test.res, res)
+		})
+	}
+}
+
+func TestIdentifierImpl(t *testing.T) {
+	ctx := context.Background()
+	db := local.NewUserDB()
+	sender := local.NewVerificationSender()
+	identifiers := app.NewIdentifierBackend(db, db, sender)
+
+	hash, err := credentials.NewPassword("parool123!").GenerateBCrypt()
+	assert.NoError(t, err)
+	for _, username := range []string{"alice", "bob_the_user"} {
+		assert.NoError(t, db.CreateUser(ctx, app.User{Username: username, Password: hash}))
+	}
+	alice, err := db.GetUserByUsername(ctx, "alice")
+	assert.NoError(t, err)
+	bob, err := db.GetUserByUsername(ctx, "bob_the_user")
+	assert.NoError(t, err)
+
+	_, err = identifiers.AddIdentifier(ctx, alice.UserID, "alice_two")
+	assert.Equal(t, app.ErrIdentifierTypeInvalid, err)
+
+	_, err = identifiers.AddIdentifier(ctx, "11111111-1111-1111-1111-111111111111", "nobody@example.com")
+	assert.Equal(t, app.ErrUserNotFound, err)
+
+	email, err := identifiers.AddIdentifier(ctx, alice.UserID, "Alice@Example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice@example.com", email.Value)
+	assert.False(t, email.Verified)
+	firstCode := sender.Code("alice@example.com")
+	assert.Len(t, firstCode, 6)
+
+	// Adding it again sends a new code and retires the old one.
+	email, err = identifiers.AddIdentifier(ctx, alice.UserID, "alice@example.com")
+	assert.NoError(t, err)
+	code := sender.Code("alice@example.com")
+
+	list, err := identifiers.ListIdentifiers(ctx, alice.UserID)
+	assert.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, app.Identifier{UserID: alice.UserID, Type: app.IdentifierUsername, Value: "alice", Verified: true}, list[0])
+		assert.Equal(t, email.IdentifierID, list[1].IdentifierID)
+	}
+
+	_, err = identifiers.VerifyIdentifier(ctx, bob.UserID, email.IdentifierID, code)
+	assert.Equal(t, app.ErrIdentifierNotFound, err, "the identifiers of others look like they don't exist")
+
+	if firstCode != code {
+		_, err = identifiers.VerifyIdentifier(ctx, alice.UserID, email.IdentifierID, firstCode)
+		assert.Equal(t, app.ErrVerificationCodeInvalid, err)
+	}
+
+	authenticator := app.NewAuthenticator(db, jwt.NewHS256Wrapper("secret"))
+	_, err = authenticator.AuthenticatePassword(ctx, credentials.New("alice@example.com", "parool123!"))
+	assert.Equal(t, app.ErrUserNotFound, err)
+
+	verified, err := identifiers.VerifyIdentifier(ctx, alice.UserID, email.IdentifierID, code)
+	assert.NoError(t, err)
+	assert.True(t, verified.Verified)
+
+	usr, err := authenticator.AuthenticatePassword(ctx, credentials.New("ALICE@example.com", "parool123!"))
+	assert.NoError(t, err)
+	assert.Equal(t, alice.UserID, usr.UserID)
+
+	_, err = identifiers.AddIdentifier(ctx, alice.UserID, "alice@example.com")
+	assert.Equal(t, app.ErrIdentifierTaken, err)
+
+	// A value verified for alice can be added but not verified by bob.
+	bobEmail, err := identifiers.AddIdentifier(ctx, bob.UserID, "alice@example.com")
+	assert.NoError(t, err)
+	_, err = identifiers.VerifyIdentifier(ctx, bob.UserID, bobEmail.IdentifierID, sender.Code("alice@example.com"))
+	assert.Equal(t, app.ErrIdentifierTaken, err)
+
+	expired, err := db.CreateIdentifier(ctx, app.Identifier{
+		UserID:    bob.UserID,
+		Type:      app.IdentifierPhone,
+		Value:     "+3725551234",
+		CreatedAt: time.Now().Add(-app.VerificationCodeExpiry),
+	})
+	assert.NoError(t, err)
+	_, err = identifiers.VerifyIdentifier(ctx, bob.UserID, expired.IdentifierID, "123456")
+	assert.Equal(t, app.ErrVerificationCodeExpired, err)
+
+	assert.Equal(t, app.ErrIdentifierNotFound, identifiers.RemoveIdentifier(ctx, bob.UserID, email.IdentifierID))
+	assert.NoError(t, identifiers.RemoveIdentifier(ctx, alice.UserID, email.IdentifierID))
+
+	_, err = authenticator.AuthenticatePassword(ctx, credentials.New("alice@example.com", "parool123!"))
+	assert.Equal(t, app.ErrUserNotFound, err)
+
+	for i := 0; i < app.MaxIdentifiers; i++ {
+		_, err := identifiers.AddIdentifier(ctx, alice.UserID, fmt.Sprintf("+3725550%04d", i))
+		assert.NoError(t, err)
+	}
+	_, err = identifiers.AddIdentifier(ctx, alice.UserID, "alice@example.org")
+	assert.Equal(t, app.ErrTooManyIdentifiers, err)
+}
@@ -0,0 +1,17 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/sqlite"
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestLocalIdentifierDB(t *testing.T) {
+	tests.TestIdentifierDB(t, local.MakeIdentifierDB)
+}
+
+func TestSQLiteIdentifierDB(t *testing.T) {
+	tests.TestIdentifierDB(t, sqlite.MakeIdentifierDB)
+}
@@ -0,0 +1,293 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rislah/fakes/internal/credentials"
+	"github.com/rislah/fakes/internal/errors"
+)
+
+const (
+	DefaultInviteExpiry = 7 * 24 * time.Hour
+	MaxInviteExpiry     = 90 * 24 * time.Hour
+	MaxInviteUses       = 1000
+)
+
+// InviteBackend manages the invitations that registration requires when it
+// is invite-only.
+type InviteBackend interface {
+	// CreateInvite stores an invitation and returns it along with its
+	// code, which is only ever available here.
+	CreateInvite(ctx context.Context, spec InviteSpec) (Invite, error)
+	// ListPendingInvites returns the invitations that can still be
+	// redeemed, oldest first.
+	ListPendingInvites(ctx context.Context) ([]Invite, error)
+	RevokeInvite(ctx context.Context, inviteID string) error
+	// Register creates a user with the credentials and the role of the
+	// invitation, consuming one of its uses. Email must match the email the
+	// invitation is bound to, if any.
+	Register(ctx context.Context, code string, email string, creds credentials.Credentials) (User, error)
+}
+
+type InviteDB interface {
+	// CreateInvite stores invite, generating its ID.
+	CreateInvite(ctx context.Context, invite Invite) (Invite, error)
+	// GetInviteByCodeHash returns an empty Invite when it doesn't exist.
+	GetInviteByCodeHash(ctx context.Context, codeHash string) (Invite, error)
+	// ListPendingInvites returns the invites that expire after now and have
+	// uses left, oldest first.
+	ListPendingInvites(ctx context.Context, now time.Time) ([]Invite, error)
+	// RedeemInvite uses the invite once, failing with ErrInviteInvalid when
+	// it expired at now or has no uses left.
+	RedeemInvite(ctx context.Context, inviteID string, now time.Time) error
+	// ReleaseInvite gives back a use taken by RedeemInvite.
+	ReleaseInvite(ctx context.Context, inviteID string) error
+	DeleteInvite(ctx context.Context, inviteID string) error
+}
+
+// Invite allows registering MaxUses users until ExpiresAt. Only the hash of
+// its code is stored.
+type Invite struct {
+	InviteID string `db:"invite_id"`
+	// Code is only set on a created invite.
+	Code     string `db:"-"`
+	CodeHash string `db:"code_hash"`
+	// Email is empty when anyone with the code can register.
+	Email     string    `db:"email"`
+	Role      Role      `db:"role"`
+	MaxUses   int       `db:"max_uses"`
+	Uses      int       `db:"uses"`
+	ExpiresAt time.Time `db:"expires_at"`
+	CreatedBy string    `db:"created_by"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (i Invite) IsEmpty() bool {
+	return i.InviteID == ""
+}
+
+// Pending reports whether the invite can still be redeemed at now.
+func (i Invite) Pending(now time.Time) bool {
+	return i.Uses < i.MaxUses && now.Before(i.ExpiresAt)
+}
+
+// InviteSpec describes an invite to create. Zero values default to a single
+// use invite for UserRole expiring after DefaultInviteExpiry.
+type InviteSpec struct {
+	Email     string
+	Role      Role
+	MaxUses   int
+	ExpiresAt time.Time
+	CreatedBy string
+}
+
+// HashInviteCode returns the hash invites are stored and looked up by.
+func HashInviteCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+type inviteImpl struct {
+	userBackend UserBackend
+	inviteDB    InviteDB
+	now         func() time.Time
+}
+
+func NewInviteBackend(userBackend UserBackend, inviteDB InviteDB) InviteBackend {
+	if userBackend == nil {
+		panic("user backend is required")
+	}
+
+	if inviteDB == nil {
+		panic("database is required")
+	}
+
+	return &inviteImpl{
+		userBackend: userBackend,
+		inviteDB:    inviteDB,
+		now:         func() time.Time { return time.Now().UTC().Truncate(time.Microsecond) },
+	}
+}
+
+func (i *inviteImpl) CreateInvite(ctx context.Context, spec InviteSpec) (Invite, error) {
+	now := i.now()
+	invite := Invite{
+		Email:     normalizeInviteEmail(spec.Email),
+		Role:      spec.Role,
+		MaxUses:   spec.MaxUses,
+		ExpiresAt: spec.ExpiresAt.UTC(),
+		CreatedBy: spec.CreatedBy,
+		CreatedAt: now,
+	}
+
+	if invite.Role == "" {
+		invite.Role = UserRole
+	}
+	if err := (RoleDefinition{Name: invite.Role}).Valid(); err != nil {
+		return Invite{}, err
+	}
+
+	if invite.MaxUses == 0 {
+		invite.MaxUses = 1
+	}
+	if invite.MaxUses < 1 || invite.MaxUses > MaxInviteUses {
+		return Invite{}, ErrInviteMaxUsesInvalid
+	}
+
+	if spec.ExpiresAt.IsZero() {
+		invite.ExpiresAt = now.Add(DefaultInviteExpiry)
+	}
+	if !invite.ExpiresAt.After(now) || invite.ExpiresAt.Sub(now) > MaxInviteExpiry {
+		return Invite{}, ErrInviteExpiryInvalid
+	}
+
+	if invite.Email != "" && !validInviteEmail(invite.Email) {
+		return Invite{}, ErrInviteEmailInvalid
+	}
+
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return Invite{}, err
+	}
+	code := base64.RawURLEncoding.EncodeToString(b)
+	invite.CodeHash = HashInviteCode(code)
+
+	invite, err := i.inviteDB.CreateInvite(ctx, invite)
+	if err != nil {
+		return Invite{}, err
+	}
+
+	invite.Code = code
+	return invite, nil
+}
+
+func (i *inviteImpl) ListPendingInvites(ctx context.Context) ([]Invite, error) {
+	return i.inviteDB.ListPendingInvites(ctx, i.now())
+}
+
+func (i *inviteImpl) RevokeInvite(ctx context.Context, inviteID string) error {
+	if inviteID == "" {
+		return ErrInviteNotFound
+	}
+
+	return i.inviteDB.DeleteInvite(ctx, inviteID)
+}
+
+func (i *inviteImpl) Register(ctx context.Context, code string, email string, creds credentials.Credentials) (User, error) {
+	if code == "" {
+		return User{}, ErrInviteRequired
+	}
+
+	// Credentials are checked before the invite is used, so that a typo
+	// doesn't cost a use.
+	if err := creds.Valid(); err != nil {
+		return User{}, err
+	}
+
+	if creds.Username.IsReserved() {
+		return User{}, credentials.ErrUsernameReserved
+	}
+
+	if _, err := creds.Password.ValidateStrength(creds.Username.String()); err != nil {
+		return User{}, err
+	}
+
+	invite, err := i.inviteDB.GetInviteByCodeHash(ctx, HashInviteCode(code))
+	if err != nil {
+		return User{}, err
+	}
+
+	now := i.now()
+	if invite.IsEmpty() || !invite.Pending(now) {
+		return User{}, ErrInviteInvalid
+	}
+
+	if invite.Email != "" && invite.Email != normalizeInviteEmail(email) {
+		return User{}, ErrInviteEmailMismatch
+	}
+
+	if err := i.inviteDB.RedeemInvite(ctx, invite.InviteID, now); err != nil {
+		return User{}, err
+	}
+
+	usr, err := i.createUser(ctx, creds, invite.Role)
+	if err != nil {
+		if releaseErr := i.inviteDB.ReleaseInvite(ctx, invite.InviteID); releaseErr != nil {
+			return User{}, errors.Wrap(releaseErr, "releasing invite after failed registration")
+		}
+		return User{}, err
+	}
+
+	return usr, nil
+}
+
+func (i *inviteImpl) createUser(ctx context.Context, creds credentials.Credentials, role Role) (User, error) {
+	if err := i.userBackend.CreateUser(ctx, creds); err != nil {
+		return User{}, err
+	}
+
+	usr, err := i.userBackend.GetUserByUsername(ctx, creds.Username.String())
+	if err != nil {
+		return User{}, err
+	}
+
+	if usr.Role != role {
+		if _, err := i.userBackend.UpdateUserRole(ctx, usr.UserID, role); err != nil {
+			// The invite is released, so the user it created goes too.
+			if deleteErr := i.userBackend.DeleteUser(ctx, usr.UserID); deleteErr != nil {
+				return User{}, errors.Wrap(deleteErr, "deleting user after failed role assignment")
+			}
+			return User{}, err
+		}
+		usr.Role = role
+	}
+
+	return usr, nil
+}
+
+func normalizeInviteEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func validInviteEmail(email string) bool {
+	at := strings.LastIndex(email, "@")
+	return at > 0 && at < len(email)-1 && !strings.ContainsAny(email, " \t\r\n")
+}
+
+var (
+	ErrInviteRequired = &errors.WrappedError{
+		Code: http.StatusForbidden,
+		Msg:  "Registration requires an invite",
+	}
+	ErrInviteInvalid = &errors.WrappedError{
+		Code: http.StatusForbidden,
+		Msg:  "Invite is invalid, expired or used up",
+	}
+	ErrInviteEmailMismatch = &errors.WrappedError{
+		Code: http.StatusForbidden,
+		Msg:  "Invite was issued for a different email",
+	}
+	ErrInviteNotFound = &errors.WrappedError{
+		Code: errors.ErrNotFound,
+		Msg:  "Invite not found",
+	}
+	ErrInviteMaxUsesInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Max uses must be between 1 and 1000",
+	}
+	ErrInviteExpiryInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Expiry must be in the future and at most 90 days away",
+	}
+	ErrInviteEmailInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Invalid email",
+	}
+)
@@ -0,0 +1,142 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/credentials"
+	"github.com/rislah/fakes/internal/jwt"
+	"github.com/rislah/fakes/internal/local"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInviteImpl(t *testing.T) {
+	tests := []struct {
+		name string
+		test func(ctx context.Context, t *testing.T, invites app.InviteBackend, db app.InviteDB)
+	}{
+		{
+			name: "should create a single use invite for users by default",
+			test: func(ctx context.Context, t *testing.T, invites app.InviteBackend, db app.InviteDB) {
+				invite, err := invites.CreateInvite(ctx, app.InviteSpec{Email: " Alice@Example.com "})
+				assert.NoError(t, err)
+				assert.NotEmpty(t, invite.Code)
+				assert.Equal(t, app.HashInviteCode(invite.Code), invite.CodeHash)
+				assert.Equal(t, "alice@example.com", invite.Email)
+				assert.Equal(t, app.UserRole, invite.Role)
+				assert.Equal(t, 1, invite.MaxUses)
+				assert.Equal(t, app.DefaultInviteExpiry, invite.ExpiresAt.Sub(invite.CreatedAt))
+
+				pending, err := invites.ListPendingInvites(ctx)
+				assert.NoError(t, err)
+				if assert.Len(t, pending, 1) {
+					assert.Equal(t, invite.InviteID, pending[0].InviteID)
+					assert.Empty(t, pending[0].Code, "codes aren't stored")
+				}
+			},
+		},
+		{
+			name: "should reject invalid invites",
+			test: func(ctx context.Context, t *testing.T, invites app.InviteBackend, db app.InviteDB) {
+				_, err := invites.CreateInvite(ctx, app.InviteSpec{MaxUses: app.MaxInviteUses + 1})
+				assert.Equal(t, app.ErrInviteMaxUsesInvalid, err)
+				_, err = invites.CreateInvite(ctx, app.InviteSpec{MaxUses: -1})
+				assert.Equal(t, app.ErrInviteMaxUsesInvalid, err)
+				_, err = invites.CreateInvite(ctx, app.InviteSpec{ExpiresAt: time.Now().Add(-time.Minute)})
+				assert.Equal(t, app.ErrInviteExpiryInvalid, err)
+				_, err = invites.CreateInvite(ctx, app.InviteSpec{ExpiresAt: time.Now().Add(app.MaxInviteExpiry + time.Hour)})
+				assert.Equal(t, app.ErrInviteExpiryInvalid, err)
+				_, err = invites.CreateInvite(ctx, app.InviteSpec{Email: "alice"})
+				assert.Equal(t, app.ErrInviteEmailInvalid, err)
+				_, err = invites.CreateInvite(ctx, app.InviteSpec{Role: "Admin"})
+				assert.Equal(t, app.ErrRoleNameInvalid, err)
+				_, err = invites.CreateInvite(ctx, app.InviteSpec{Role: "doesnotexist"})
+				assert.Equal(t, app.ErrRoleNotFound, err)
+			},
+		},
+		{
+			name: "should register with the role of the invite until it is used up",
+			test: func(ctx context.Context, t *testing.T, invites app.InviteBackend, db app.InviteDB) {
+				invite, err := invites.CreateInvite(ctx, app.InviteSpec{Role: app.DeveloperRole, MaxUses: 2})
+				assert.NoError(t, err)
+
+				usr, err := invites.Register(ctx, invite.Code, "", credentials.New("alice", "parool123!"))
+				assert.NoError(t, err)
+				assert.Equal(t, "alice", usr.Username)
+				assert.Equal(t, app.DeveloperRole, usr.Role)
+
+				_, err = invites.Register(ctx, invite.Code, "", credentials.New("bob_the_user", "parool123!"))
+				assert.NoError(t, err)
+
+				_, err = invites.Register(ctx, invite.Code, "", credentials.New("carol", "parool123!"))
+				assert.Equal(t, app.ErrInviteInvalid, err)
+
+				pending, err := invites.ListPendingInvites(ctx)
+				assert.NoError(t, err)
+				assert.Empty(t, pending)
+			},
+		},
+		{
+			name: "should require a known code and the invited email",
+			test: func(ctx context.Context, t *testing.T, invites app.InviteBackend, db app.InviteDB) {
+				invite, err := invites.CreateInvite(ctx, app.InviteSpec{Email: "alice@example.com"})
+				assert.NoError(t, err)
+
+				_, err = invites.Register(ctx, "", "alice@example.com", credentials.New("alice", "parool123!"))
+				assert.Equal(t, app.ErrInviteRequired, err)
+				_, err = invites.Register(ctx, "unknown", "alice@example.com", credentials.New("alice", "parool123!"))
+				assert.Equal(t, app.ErrInviteInvalid, err)
+				_, err = invites.Register(ctx, invite.Code, "bob@example.com", credentials.New("alice", "parool123!"))
+				assert.Equal(t, app.ErrInviteEmailMismatch, err)
+
+				_, err = invites.Register(ctx, invite.Code, "ALICE@example.com", credentials.New("alice", "parool123!"))
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "should give back the use when registration fails",
+			test: func(ctx context.Context, t *testing.T, invites app.InviteBackend, db app.InviteDB) {
+				invite, err := invites.CreateInvite(ctx, app.InviteSpec{})
+				assert.NoError(t, err)
+
+				_, err = invites.Register(ctx, invite.Code, "", credentials.New("alice", "parool"))
+				assert.Equal(t, credentials.ErrPasswordLength, err)
+
+				_, err = invites.Register(ctx, invite.Code, "", credentials.New("alice", "parool123!"))
+				assert.NoError(t, err)
+
+				other, err := invites.CreateInvite(ctx, app.InviteSpec{})
+				assert.NoError(t, err)
+				_, err = invites.Register(ctx, other.Code, "", credentials.New("alice", "parool123!"))
+				assert.Equal(t, app.ErrUserAlreadyExists, err)
+
+				res, err := db.GetInviteByCodeHash(ctx, other.CodeHash)
+				assert.NoError(t, err)
+				assert.Equal(t, 0, res.Uses)
+			},
+		},
+		{
+			name: "should revoke an invite",
+			test: func(ctx context.Context, t *testing.T, invites app.InviteBackend, db app.InviteDB) {
+				invite, err := invites.CreateInvite(ctx, app.InviteSpec{})
+				assert.NoError(t, err)
+
+				assert.NoError(t, invites.RevokeInvite(ctx, invite.InviteID))
+				assert.Equal(t, app.ErrInviteNotFound, invites.RevokeInvite(ctx, invite.InviteID))
+
+				_, err = invites.Register(ctx, invite.Code, "", credentials.New("alice", "parool123!"))
+				assert.Equal(t, app.ErrInviteInvalid, err)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := local.NewUserDB()
+			invites := app.NewInviteBackend(app.NewUserBackend(db, jwt.NewHS256Wrapper("secret")), db)
+			test.test(context.Background(), t, invites, db)
+		})
+	}
+}
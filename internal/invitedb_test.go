@@ -0,0 +1,17 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/sqlite"
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestLocalInviteDB(t *testing.T) {
+	tests.TestInviteDB(t, local.MakeInviteDB)
+}
+
+func TestSQLiteInviteDB(t *testing.T) {
+	tests.TestInviteDB(t, sqlite.MakeInviteDB)
+}
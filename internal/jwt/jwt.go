@@ -7,9 +7,8 @@ import (
 	"github.com/rislah/fakes/internal/errors"
 )
 
-const (
-	expiresIn = 24 * time.Hour
-)
+// ExpiresIn is the lifetime of user tokens.
+const ExpiresIn = 24 * time.Hour
 
 var (
 	ErrJWTAlgMismatch = &errors.WrappedError{
@@ -32,6 +31,25 @@ type UserClaims struct {
 	*jwt.RegisteredClaims
 	Username string `json:"username"`
 	Role     string `json:"role"`
+
+	// Roles and Permissions are granted through group membership on top of
+	// Role. They don't apply within an organization.
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+
+	// Organization is the active organization, if any. Permissions are then
+	// checked against OrganizationRole instead of Role.
+	Organization     string `json:"org,omitempty"`
+	OrganizationRole string `json:"org_role,omitempty"`
+}
+
+// EffectiveRole is the role permissions are checked against: the role within
+// the active organization, if there is one.
+func (c UserClaims) EffectiveRole() string {
+	if c.Organization != "" {
+		return c.OrganizationRole
+	}
+	return c.Role
 }
 
 func NewRegisteredClaims(expiresIn time.Duration) jwt.RegisteredClaims {
@@ -43,7 +61,7 @@ func NewRegisteredClaims(expiresIn time.Duration) jwt.RegisteredClaims {
 }
 
 func NewUserClaims(username string, role string) UserClaims {
-	rc := NewRegisteredClaims(expiresIn)
+	rc := NewRegisteredClaims(ExpiresIn)
 	uc := UserClaims{
 		RegisteredClaims: &rc,
 		Username:         username,
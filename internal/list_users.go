@@ -0,0 +1,154 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+)
+
+const (
+	DefaultUserPageSize = 50
+	MaxUserPageSize     = 100
+)
+
+type UserSort string
+
+const (
+	SortByUsername     UserSort = "username"
+	SortByUsernameDesc UserSort = "-username"
+)
+
+// UserQuery is a request for a page of users. Cursor is the NextCursor of
+// the previous page and must be used with the same Sort.
+type UserQuery struct {
+	Limit          int
+	Cursor         string
+	Role           Role
+	UsernamePrefix string
+	Sort           UserSort
+}
+
+type UserPage struct {
+	Users []User
+	// NextCursor is empty on the last page.
+	NextCursor string
+}
+
+// userCursor is the position after which the next page starts. Usernames are
+// unique, so the last username of a page is a stable key.
+type userCursor struct {
+	Sort  UserSort `json:"s"`
+	After string   `json:"a"`
+}
+
+func (u userImpl) ListUsers(ctx context.Context, query UserQuery) (UserPage, error) {
+	if query.Limit == 0 {
+		query.Limit = DefaultUserPageSize
+	}
+	if query.Limit < 0 || query.Limit > MaxUserPageSize {
+		return UserPage{}, ErrInvalidPageSize
+	}
+
+	if query.Sort == "" {
+		query.Sort = SortByUsername
+	}
+	if query.Sort != SortByUsername && query.Sort != SortByUsernameDesc {
+		return UserPage{}, ErrInvalidUserSort
+	}
+
+	filter := UserFilter{
+		Role:           query.Role,
+		UsernamePrefix: query.UsernamePrefix,
+		Descending:     query.Sort == SortByUsernameDesc,
+		// One extra user tells whether there is a next page.
+		Limit: query.Limit + 1,
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeUserCursor(query.Cursor)
+		if err != nil || cursor.Sort != query.Sort {
+			return UserPage{}, ErrInvalidCursor
+		}
+		filter.After = cursor.After
+	}
+
+	users, err := u.userDB.ListUsers(ctx, filter)
+	if err != nil {
+		return UserPage{}, err
+	}
+
+	if len(users) == 0 && query.Cursor == "" {
+		return UserPage{}, ErrUsersNotFound
+	}
+
+	var page UserPage
+	if len(users) > query.Limit {
+		users = users[:query.Limit]
+		page.NextCursor, err = encodeUserCursor(userCursor{
+			Sort:  query.Sort,
+			After: users[len(users)-1].Username,
+		})
+		if err != nil {
+			return UserPage{}, err
+		}
+	}
+
+	page.Users = make([]User, 0, len(users))
+	for _, usr := range users {
+		page.Users = append(page.Users, usr.Sanitize())
+	}
+
+	return page, nil
+}
+
+// ListUsersAt pages by offset, for clients such as SCIM that can't follow a
+// cursor. Unlike ListUsers, an empty page isn't an error.
+func (u userImpl) ListUsersAt(ctx context.Context, offset, limit int) ([]User, int, error) {
+	if limit < 0 || limit > MaxUserPageSize {
+		return nil, 0, ErrInvalidPageSize
+	}
+
+	total, err := u.userDB.CountUsers(ctx, UserFilter{})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	users := []User{}
+	if limit == 0 || offset >= total {
+		return users, total, nil
+	}
+
+	page, err := u.userDB.ListUsers(ctx, UserFilter{Offset: offset, Limit: limit})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, usr := range page {
+		users = append(users, usr.Sanitize())
+	}
+
+	return users, total, nil
+}
+
+func encodeUserCursor(cursor userCursor) (string, error) {
+	b, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeUserCursor(s string) (userCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return userCursor{}, err
+	}
+
+	var cursor userCursor
+	if err := json.Unmarshal(b, &cursor); err != nil {
+		return userCursor{}, err
+	}
+
+	return cursor, nil
+}
@@ -0,0 +1,100 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/jwt"
+	"github.com/rislah/fakes/internal/local"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserImpl_ListUsers(t *testing.T) {
+	tests := []struct {
+		name string
+		test func(ctx context.Context, t *testing.T, userBackend app.UserBackend, db app.UserDB)
+	}{
+		{
+			name: "should return error if users is empty",
+			test: func(ctx context.Context, t *testing.T, userBackend app.UserBackend, db app.UserDB) {
+				_, err := userBackend.ListUsers(ctx, app.UserQuery{})
+				assert.Equal(t, app.ErrUsersNotFound, err)
+			},
+		},
+		{
+			name: "should follow cursors to the last page",
+			test: func(ctx context.Context, t *testing.T, userBackend app.UserBackend, db app.UserDB) {
+				for _, username := range []string{"dave", "bob", "carol", "alice", "erin"} {
+					assert.NoError(t, db.CreateUser(ctx, app.User{Username: username, Password: "hash"}))
+				}
+
+				var pages [][]string
+				query := app.UserQuery{Limit: 2, Sort: app.SortByUsernameDesc}
+				for {
+					page, err := userBackend.ListUsers(ctx, query)
+					assert.NoError(t, err)
+
+					var names []string
+					for _, usr := range page.Users {
+						assert.Empty(t, usr.Password)
+						names = append(names, usr.Username)
+					}
+					pages = append(pages, names)
+
+					if page.NextCursor == "" {
+						break
+					}
+					query.Cursor = page.NextCursor
+				}
+
+				assert.Equal(t, [][]string{{"erin", "dave"}, {"carol", "bob"}, {"alice"}}, pages)
+			},
+		},
+		{
+			name: "should reject invalid queries",
+			test: func(ctx context.Context, t *testing.T, userBackend app.UserBackend, db app.UserDB) {
+				assert.NoError(t, db.CreateUser(ctx, app.User{Username: "alice", Password: "hash"}))
+				assert.NoError(t, db.CreateUser(ctx, app.User{Username: "bob", Password: "hash"}))
+
+				_, err := userBackend.ListUsers(ctx, app.UserQuery{Limit: app.MaxUserPageSize + 1})
+				assert.Equal(t, app.ErrInvalidPageSize, err)
+
+				_, err = userBackend.ListUsers(ctx, app.UserQuery{Sort: "role"})
+				assert.Equal(t, app.ErrInvalidUserSort, err)
+
+				_, err = userBackend.ListUsers(ctx, app.UserQuery{Cursor: "not a cursor"})
+				assert.Equal(t, app.ErrInvalidCursor, err)
+
+				page, err := userBackend.ListUsers(ctx, app.UserQuery{Limit: 1})
+				assert.NoError(t, err)
+				assert.NotEmpty(t, page.NextCursor)
+
+				_, err = userBackend.ListUsers(ctx, app.UserQuery{Cursor: page.NextCursor, Sort: app.SortByUsernameDesc})
+				assert.Equal(t, app.ErrInvalidCursor, err, "cursors are bound to their sort order")
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			db, teardown, err := local.MakeUserDB()
+			assert.NoError(t, err)
+
+			jwtWrapper := jwt.NewHS256Wrapper("wrapper")
+			userBackend := app.NewUserBackend(db, jwtWrapper)
+
+			defer func() {
+				assert.NoError(t, teardown())
+			}()
+
+			test.test(ctx, t, userBackend, db)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package local
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rislah/fakes/internal/audit"
+)
+
+type localAuditStore struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+var _ audit.Store = &localAuditStore{}
+
+func NewAuditStore() *localAuditStore {
+	return &localAuditStore{}
+}
+
+func MakeAuditStore() (audit.Store, func() error, error) {
+	store := NewAuditStore()
+	return store, store.flushAll, nil
+}
+
+func (l *localAuditStore) flushAll() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.events = nil
+	return nil
+}
+
+func (l *localAuditStore) Append(ctx context.Context, event audit.Event) (audit.Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var last audit.Event
+	if len(l.events) > 0 {
+		last = l.events[len(l.events)-1]
+	}
+
+	event = audit.Chain(last, copyEvent(event))
+	l.events = append(l.events, event)
+	return copyEvent(event), nil
+}
+
+func (l *localAuditStore) Search(ctx context.Context, query audit.Query) ([]audit.Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	events := []audit.Event{}
+	for i := len(l.events) - 1; i >= 0 && len(events) < query.Limit; i-- {
+		event := l.events[i]
+		switch {
+		case query.Before != 0 && event.Seq >= query.Before,
+			query.ActorID != "" && event.ActorID != query.ActorID,
+			query.TargetID != "" && event.TargetID != query.TargetID,
+			query.Action != "" && event.Action != query.Action,
+			!query.From.IsZero() && event.OccurredAt.Before(query.From),
+			!query.To.IsZero() && !event.OccurredAt.Before(query.To):
+			continue
+		}
+
+		events = append(events, copyEvent(event))
+	}
+
+	return events, nil
+}
+
+func (l *localAuditStore) Events(ctx context.Context, after int64, limit int) ([]audit.Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	events := []audit.Event{}
+	for _, event := range l.events {
+		if len(events) == limit {
+			break
+		}
+		if event.Seq > after {
+			events = append(events, copyEvent(event))
+		}
+	}
+
+	return events, nil
+}
+
+// copyEvent keeps callers from changing stored events through their details.
+func copyEvent(event audit.Event) audit.Event {
+	if event.Details == nil {
+		return event
+	}
+
+	details := make(audit.Details, len(event.Details))
+	for k, v := range event.Details {
+		details[k] = v
+	}
+	event.Details = details
+	return event
+}
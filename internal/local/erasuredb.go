@@ -0,0 +1,100 @@
+package local
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+)
+
+func MakeErasureDB() (app.ErasureDB, func() error, error) {
+	db := NewUserDB()
+	return db, db.flushAll, nil
+}
+
+var _ app.ErasureDB = &localDB{}
+
+func (ld *localDB) CreateErasureRequest(ctx context.Context, request app.ErasureRequest) (app.ErasureRequest, error) {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	for _, value := range ld.erasures {
+		if value.UserID == request.UserID && value.Status == app.ErasurePending {
+			return app.ErasureRequest{}, app.ErrErasureAlreadyRequested
+		}
+	}
+
+	id, err := newUUID()
+	if err != nil {
+		return app.ErasureRequest{}, err
+	}
+	request.RequestID = id
+
+	if request.Status == "" {
+		request.Status = app.ErasurePending
+	}
+
+	ld.erasures = append(ld.erasures, request)
+	return request, nil
+}
+
+func (ld *localDB) GetPendingErasureRequest(ctx context.Context, userID string) (app.ErasureRequest, error) {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+
+	for _, value := range ld.erasures {
+		if value.UserID == userID && value.Status == app.ErasurePending {
+			return value, nil
+		}
+	}
+
+	return app.ErasureRequest{}, nil
+}
+
+func (ld *localDB) DeletePendingErasureRequest(ctx context.Context, userID string) error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	for i, value := range ld.erasures {
+		if value.UserID == userID && value.Status == app.ErasurePending {
+			ld.erasures = append(ld.erasures[:i], ld.erasures[i+1:]...)
+			return nil
+		}
+	}
+
+	return app.ErrErasureRequestNotFound
+}
+
+func (ld *localDB) GetDueErasureRequests(ctx context.Context, now time.Time) ([]app.ErasureRequest, error) {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+
+	due := []app.ErasureRequest{}
+	for _, value := range ld.erasures {
+		if value.Status == app.ErasurePending && !value.ScheduledFor.After(now) {
+			due = append(due, value)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].ScheduledFor.Before(due[j].ScheduledFor)
+	})
+
+	return due, nil
+}
+
+func (ld *localDB) CompleteErasureRequest(ctx context.Context, requestID string, completedAt time.Time) error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	for i, value := range ld.erasures {
+		if value.RequestID == requestID && value.Status == app.ErasurePending {
+			ld.erasures[i].Status = app.ErasureCompleted
+			ld.erasures[i].CompletedAt = &completedAt
+			return nil
+		}
+	}
+
+	return app.ErrErasureRequestNotFound
+}
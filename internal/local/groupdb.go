@@ -0,0 +1,214 @@
+package local
+
+import (
+	"context"
+
+	app "github.com/rislah/fakes/internal"
+)
+
+type groupMember struct {
+	groupID string
+	userID  string
+}
+
+// MakeGroupDB returns a group database together with the user database that
+// shares its store.
+func MakeGroupDB() (app.UserDB, app.GroupDB, func() error, error) {
+	db := NewUserDB()
+	return db, db, db.flushAll, nil
+}
+
+var _ app.GroupDB = &localDB{}
+
+func (ld *localDB) CreateGroup(ctx context.Context, group app.Group) (app.Group, error) {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	for _, value := range ld.groups {
+		if value.Name == group.Name {
+			return app.Group{}, app.ErrGroupAlreadyExists
+		}
+	}
+
+	for _, role := range group.Roles {
+		if !ld.isKnownRole(ctx, role) {
+			return app.Group{}, app.ErrRoleNotFound
+		}
+	}
+
+	if group.GroupID == "" {
+		id, err := newUUID()
+		if err != nil {
+			return app.Group{}, err
+		}
+		group.GroupID = id
+	}
+
+	group = copyGroup(group)
+	ld.groups = append(ld.groups, group)
+	return copyGroup(group), nil
+}
+
+func (ld *localDB) GetGroups(ctx context.Context) ([]app.Group, error) {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+
+	groups := make([]app.Group, 0, len(ld.groups))
+	for _, group := range ld.groups {
+		groups = append(groups, copyGroup(group))
+	}
+
+	return groups, nil
+}
+
+func (ld *localDB) GetGroup(ctx context.Context, groupID string) (app.Group, error) {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+
+	if i := ld.indexOfGroup(groupID); i != -1 {
+		return copyGroup(ld.groups[i]), nil
+	}
+
+	return app.Group{}, nil
+}
+
+func (ld *localDB) UpdateGroup(ctx context.Context, group app.Group) error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	i := ld.indexOfGroup(group.GroupID)
+	if i == -1 {
+		return app.ErrGroupNotFound
+	}
+
+	for j, value := range ld.groups {
+		if j != i && value.Name == group.Name {
+			return app.ErrGroupAlreadyExists
+		}
+	}
+
+	for _, role := range group.Roles {
+		if !ld.isKnownRole(ctx, role) {
+			return app.ErrRoleNotFound
+		}
+	}
+
+	ld.groups[i] = copyGroup(group)
+	return nil
+}
+
+func (ld *localDB) DeleteGroup(ctx context.Context, groupID string) error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	i := ld.indexOfGroup(groupID)
+	if i == -1 {
+		return app.ErrGroupNotFound
+	}
+
+	ld.groups = append(ld.groups[:i], ld.groups[i+1:]...)
+
+	members := ld.groupMembers[:0]
+	for _, member := range ld.groupMembers {
+		if member.groupID != groupID {
+			members = append(members, member)
+		}
+	}
+	ld.groupMembers = members
+
+	return nil
+}
+
+func (ld *localDB) AddGroupMember(ctx context.Context, groupID string, userID string) error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	if ld.indexOfGroup(groupID) == -1 {
+		return app.ErrGroupNotFound
+	}
+
+	if ld.indexOfUser(userID) == -1 {
+		return app.ErrUserNotFound
+	}
+
+	if ld.indexOfGroupMember(groupID, userID) != -1 {
+		return app.ErrGroupMemberAlreadyExists
+	}
+
+	ld.groupMembers = append(ld.groupMembers, groupMember{groupID: groupID, userID: userID})
+	return nil
+}
+
+func (ld *localDB) RemoveGroupMember(ctx context.Context, groupID string, userID string) error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	i := ld.indexOfGroupMember(groupID, userID)
+	if i == -1 {
+		return app.ErrGroupMemberNotFound
+	}
+
+	ld.groupMembers = append(ld.groupMembers[:i], ld.groupMembers[i+1:]...)
+	return nil
+}
+
+func (ld *localDB) GetGroupMembers(ctx context.Context, groupID string) ([]string, error) {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+
+	members := []string{}
+	for _, member := range ld.groupMembers {
+		if member.groupID == groupID {
+			members = append(members, member.userID)
+		}
+	}
+
+	return members, nil
+}
+
+func (ld *localDB) GetUserGroups(ctx context.Context, userID string) ([]app.Group, error) {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+
+	groups := []app.Group{}
+	for _, member := range ld.groupMembers {
+		if member.userID != userID {
+			continue
+		}
+
+		if i := ld.indexOfGroup(member.groupID); i != -1 {
+			groups = append(groups, copyGroup(ld.groups[i]))
+		}
+	}
+
+	return groups, nil
+}
+
+func (ld *localDB) indexOfGroup(groupID string) int {
+	for i, value := range ld.groups {
+		if value.GroupID == groupID {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (ld *localDB) indexOfGroupMember(groupID string, userID string) int {
+	for i, member := range ld.groupMembers {
+		if member.groupID == groupID && member.userID == userID {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func copyGroup(group app.Group) app.Group {
+	return app.Group{
+		GroupID:     group.GroupID,
+		Name:        group.Name,
+		Roles:       append([]app.Role{}, group.Roles...),
+		Permissions: append([]string{}, group.Permissions...),
+	}
+}
@@ -0,0 +1,130 @@
+package local
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+)
+
+// MakeIdentifierDB returns an identifier database together with the user
+// database that shares its store, so that users can be looked up by their
+// identifiers.
+func MakeIdentifierDB() (app.UserDB, app.IdentifierDB, func() error, error) {
+	db := NewUserDB()
+	return db, db, db.flushAll, nil
+}
+
+var _ app.IdentifierDB = &localDB{}
+
+func (ld *localDB) CreateIdentifier(ctx context.Context, identifier app.Identifier) (app.Identifier, error) {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	if ld.indexOfUser(identifier.UserID) == -1 {
+		return app.Identifier{}, app.ErrUserNotFound
+	}
+
+	for _, value := range ld.identifiers {
+		if value.UserID == identifier.UserID && value.Value == identifier.Value {
+			return app.Identifier{}, app.ErrIdentifierTaken
+		}
+	}
+
+	id, err := newUUID()
+	if err != nil {
+		return app.Identifier{}, err
+	}
+	identifier.IdentifierID = id
+	identifier.Verified = false
+	identifier.Attempts = 0
+
+	ld.identifiers = append(ld.identifiers, identifier)
+	return identifier, nil
+}
+
+func (ld *localDB) ListIdentifiers(ctx context.Context, userID string) ([]app.Identifier, error) {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+
+	identifiers := []app.Identifier{}
+	for _, value := range ld.identifiers {
+		if value.UserID == userID {
+			identifiers = append(identifiers, value)
+		}
+	}
+
+	sort.SliceStable(identifiers, func(i, j int) bool {
+		return identifiers[i].CreatedAt.Before(identifiers[j].CreatedAt)
+	})
+
+	return identifiers, nil
+}
+
+func (ld *localDB) GetIdentifier(ctx context.Context, identifierID string) (app.Identifier, error) {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+
+	if i := ld.indexOfIdentifier(identifierID); i != -1 {
+		return ld.identifiers[i], nil
+	}
+
+	return app.Identifier{}, nil
+}
+
+func (ld *localDB) VerifyIdentifier(ctx context.Context, identifierID string, codeHash string, now time.Time) error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	index := ld.indexOfIdentifier(identifierID)
+	if index == -1 {
+		return app.ErrIdentifierNotFound
+	}
+
+	identifier := &ld.identifiers[index]
+	if identifier.Verified {
+		return nil
+	}
+
+	if identifier.Attempts >= app.MaxVerificationAttempts {
+		return app.ErrVerificationCodeInvalid
+	}
+
+	identifier.Attempts++
+	if identifier.CodeHash != codeHash {
+		return app.ErrVerificationCodeInvalid
+	}
+
+	for _, value := range ld.identifiers {
+		if value.Value == identifier.Value && value.Verified {
+			return app.ErrIdentifierTaken
+		}
+	}
+
+	identifier.Verified = true
+	return nil
+}
+
+func (ld *localDB) DeleteIdentifier(ctx context.Context, identifierID string) error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	index := ld.indexOfIdentifier(identifierID)
+	if index == -1 {
+		return app.ErrIdentifierNotFound
+	}
+
+	ld.identifiers = append(ld.identifiers[:index], ld.identifiers[index+1:]...)
+	return nil
+}
+
+func (ld *localDB) indexOfIdentifier(identifierID string) int {
+	for i, value := range ld.identifiers {
+		if value.IdentifierID == identifierID {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,114 @@
+package local
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+)
+
+func MakeInviteDB() (app.InviteDB, func() error, error) {
+	db := NewUserDB()
+	return db, db.flushAll, nil
+}
+
+var _ app.InviteDB = &localDB{}
+
+func (ld *localDB) CreateInvite(ctx context.Context, invite app.Invite) (app.Invite, error) {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	if !ld.isKnownRole(ctx, invite.Role) {
+		return app.Invite{}, app.ErrRoleNotFound
+	}
+
+	id, err := newUUID()
+	if err != nil {
+		return app.Invite{}, err
+	}
+	invite.InviteID = id
+	invite.Code = ""
+
+	ld.invites = append(ld.invites, invite)
+	return invite, nil
+}
+
+func (ld *localDB) GetInviteByCodeHash(ctx context.Context, codeHash string) (app.Invite, error) {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+
+	for _, value := range ld.invites {
+		if value.CodeHash == codeHash {
+			return value, nil
+		}
+	}
+
+	return app.Invite{}, nil
+}
+
+func (ld *localDB) ListPendingInvites(ctx context.Context, now time.Time) ([]app.Invite, error) {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+
+	invites := []app.Invite{}
+	for _, value := range ld.invites {
+		if value.Pending(now) {
+			invites = append(invites, value)
+		}
+	}
+
+	sort.SliceStable(invites, func(i, j int) bool {
+		return invites[i].CreatedAt.Before(invites[j].CreatedAt)
+	})
+
+	return invites, nil
+}
+
+func (ld *localDB) RedeemInvite(ctx context.Context, inviteID string, now time.Time) error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	for i, value := range ld.invites {
+		if value.InviteID == inviteID {
+			if !value.Pending(now) {
+				return app.ErrInviteInvalid
+			}
+
+			ld.invites[i].Uses++
+			return nil
+		}
+	}
+
+	return app.ErrInviteInvalid
+}
+
+func (ld *localDB) ReleaseInvite(ctx context.Context, inviteID string) error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	for i, value := range ld.invites {
+		if value.InviteID == inviteID {
+			if value.Uses > 0 {
+				ld.invites[i].Uses--
+			}
+			return nil
+		}
+	}
+
+	return app.ErrInviteNotFound
+}
+
+func (ld *localDB) DeleteInvite(ctx context.Context, inviteID string) error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	for i, value := range ld.invites {
+		if value.InviteID == inviteID {
+			ld.invites = append(ld.invites[:i], ld.invites[i+1:]...)
+			return nil
+		}
+	}
+
+	return app.ErrInviteNotFound
+}
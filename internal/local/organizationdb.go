@@ -0,0 +1,119 @@
+package local
+
+import (
+	"context"
+
+	app "github.com/rislah/fakes/internal"
+)
+
+// MakeOrganizationDB returns an organization database together with the user
+// database that shares its store, so that tenant-scoped user queries see the
+// memberships.
+func MakeOrganizationDB() (app.UserDB, app.OrganizationDB, func() error, error) {
+	db := NewUserDB()
+	return db, db, db.flushAll, nil
+}
+
+var _ app.OrganizationDB = &localDB{}
+
+func (ld *localDB) CreateOrganization(ctx context.Context, org app.Organization) (app.Organization, error) {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	for _, value := range ld.organizations {
+		if value.Name == org.Name {
+			return app.Organization{}, app.ErrOrganizationAlreadyExists
+		}
+	}
+
+	if org.OrgID == "" {
+		id, err := newUUID()
+		if err != nil {
+			return app.Organization{}, err
+		}
+		org.OrgID = id
+	}
+
+	ld.organizations = append(ld.organizations, org)
+	return org, nil
+}
+
+func (ld *localDB) GetOrganization(ctx context.Context, orgID string) (app.Organization, error) {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+
+	if i := ld.indexOfOrganization(orgID); i != -1 {
+		return ld.organizations[i], nil
+	}
+
+	return app.Organization{}, nil
+}
+
+func (ld *localDB) AddMember(ctx context.Context, membership app.Membership) error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	if !ld.isKnownRole(ctx, membership.Role) {
+		return app.ErrRoleNotFound
+	}
+
+	if ld.indexOfOrganization(membership.OrgID) == -1 {
+		return app.ErrOrganizationNotFound
+	}
+
+	if ld.indexOfUser(membership.UserID) == -1 {
+		return app.ErrUserNotFound
+	}
+
+	if ld.indexOfMembership(membership.OrgID, membership.UserID) != -1 {
+		return app.ErrMemberAlreadyExists
+	}
+
+	ld.memberships = append(ld.memberships, membership)
+	return nil
+}
+
+func (ld *localDB) GetMembership(ctx context.Context, orgID string, userID string) (app.Membership, error) {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+
+	if i := ld.indexOfMembership(orgID, userID); i != -1 {
+		return ld.memberships[i], nil
+	}
+
+	return app.Membership{}, nil
+}
+
+func (ld *localDB) GetMemberships(ctx context.Context, userID string) ([]app.Membership, error) {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+
+	memberships := []app.Membership{}
+	for _, value := range ld.memberships {
+		if value.UserID == userID {
+			memberships = append(memberships, value)
+		}
+	}
+
+	return memberships, nil
+}
+
+func (ld *localDB) indexOfOrganization(orgID string) int {
+	for i, value := range ld.organizations {
+		if value.OrgID == orgID {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (ld *localDB) indexOfMembership(orgID string, userID string) int {
+	for i, value := range ld.memberships {
+		if value.OrgID == orgID && value.UserID == userID {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,155 @@
+package local
+
+import (
+	"context"
+	"sync"
+
+	app "github.com/rislah/fakes/internal"
+)
+
+type localRoleDB struct {
+	mu    sync.RWMutex
+	roles []app.RoleDefinition
+}
+
+func NewRoleDB() *localRoleDB {
+	db := &localRoleDB{}
+	db.seed()
+	return db
+}
+
+func MakeRoleDB() (app.RoleDB, func() error, error) {
+	db := NewRoleDB()
+	return db, db.flushAll, nil
+}
+
+var _ app.RoleDB = &localRoleDB{}
+
+func (ld *localRoleDB) CreateRole(ctx context.Context, role app.RoleDefinition) error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	if ld.indexOf(role.Name) != -1 {
+		return app.ErrRoleAlreadyExists
+	}
+
+	roles := append(ld.copyRoles(), copyRole(role))
+	if _, err := app.NewRoleHierarchy(roles); err != nil {
+		return err
+	}
+
+	ld.roles = roles
+	return nil
+}
+
+func (ld *localRoleDB) GetRoles(ctx context.Context) ([]app.RoleDefinition, error) {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+
+	return ld.copyRoles(), nil
+}
+
+func (ld *localRoleDB) GetRoleByName(ctx context.Context, name app.Role) (app.RoleDefinition, error) {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+
+	i := ld.indexOf(name)
+	if i == -1 {
+		return app.RoleDefinition{}, nil
+	}
+
+	return copyRole(ld.roles[i]), nil
+}
+
+func (ld *localRoleDB) UpdateRole(ctx context.Context, role app.RoleDefinition) error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	i := ld.indexOf(role.Name)
+	if i == -1 {
+		return app.ErrRoleNotFound
+	}
+
+	roles := ld.copyRoles()
+	roles[i] = copyRole(role)
+	if _, err := app.NewRoleHierarchy(roles); err != nil {
+		return err
+	}
+
+	ld.roles = roles
+	return nil
+}
+
+func (ld *localRoleDB) DeleteRole(ctx context.Context, name app.Role) error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	i := ld.indexOf(name)
+	if i == -1 {
+		return app.ErrRoleNotFound
+	}
+
+	ld.roles = append(ld.roles[:i], ld.roles[i+1:]...)
+	return nil
+}
+
+// localDB serves the roles of its role database, the ones it validates
+// assignments against.
+var _ app.RoleDB = &localDB{}
+
+func (ld *localDB) CreateRole(ctx context.Context, role app.RoleDefinition) error {
+	return ld.roles.CreateRole(ctx, role)
+}
+
+func (ld *localDB) GetRoles(ctx context.Context) ([]app.RoleDefinition, error) {
+	return ld.roles.GetRoles(ctx)
+}
+
+func (ld *localDB) GetRoleByName(ctx context.Context, name app.Role) (app.RoleDefinition, error) {
+	return ld.roles.GetRoleByName(ctx, name)
+}
+
+func (ld *localDB) UpdateRole(ctx context.Context, role app.RoleDefinition) error {
+	return ld.roles.UpdateRole(ctx, role)
+}
+
+func (ld *localDB) DeleteRole(ctx context.Context, name app.Role) error {
+	return ld.roles.DeleteRole(ctx, name)
+}
+
+func (ld *localRoleDB) indexOf(name app.Role) int {
+	for i, role := range ld.roles {
+		if role.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (ld *localRoleDB) copyRoles() []app.RoleDefinition {
+	roles := make([]app.RoleDefinition, 0, len(ld.roles))
+	for _, role := range ld.roles {
+		roles = append(roles, copyRole(role))
+	}
+
+	return roles
+}
+
+func (ld *localRoleDB) seed() {
+	ld.roles = app.DefaultRoles()
+}
+
+func (ld *localRoleDB) flushAll() error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	ld.seed()
+	return nil
+}
+
+func copyRole(role app.RoleDefinition) app.RoleDefinition {
+	role.Parents = append([]app.Role{}, role.Parents...)
+	role.Permissions = append([]string{}, role.Permissions...)
+	return role
+}
@@ -2,63 +2,403 @@ package local
 
 import (
 	"context"
+	"crypto/rand"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/pkg/errors"
 	app "github.com/rislah/fakes/internal"
 )
 
+// localDB is an in-memory store with the semantics of the Postgres
+// databases, shared by the local user, organization, group, erasure, invite,
+// identifier and role databases. Like the user_role table, roles are kept
+// apart from users and joined in on reads, and only roles defined in the role
+// database can be assigned. All methods are safe for concurrent use;
+// unexported helpers expect the caller to hold mu.
 type localDB struct {
-	users []app.User
+	mu            sync.RWMutex
+	roles         *localRoleDB
+	users         []app.User
+	userRoles     map[string]app.Role
+	organizations []app.Organization
+	memberships   []app.Membership
+	groups        []app.Group
+	groupMembers  []groupMember
+	erasures      []app.ErasureRequest
+	invites       []app.Invite
+	identifiers   []app.Identifier
 }
 
 func NewUserDB() *localDB {
-	return &localDB{}
+	return &localDB{userRoles: map[string]app.Role{}, roles: NewRoleDB()}
 }
 
 func MakeUserDB() (app.UserDB, func() error, error) {
 	db := NewUserDB()
-	return NewUserDB(), db.flushAll, nil
+	return db, db.flushAll, nil
 }
 
 var _ app.UserDB = &localDB{}
 
+// CreateUser generates the user ID and assigns the guest role, ignoring the
+// ones in user, as Postgres does.
 func (ld *localDB) CreateUser(ctx context.Context, user app.User) error {
-	var found bool
-	for _, value := range ld.users {
-		if value.Username == user.Username {
-			found = true
-			break
-		}
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	if ld.indexOfUsername(user.Username) != -1 {
+		return app.ErrUserAlreadyExists
 	}
 
-	if found {
-		return errors.New("unique constraint error")
+	id, err := newUUID()
+	if err != nil {
+		return err
 	}
 
-	usr := user
-	if usr.Role == "" {
-		usr.Role = "guest"
+	status := user.Status
+	if status == "" {
+		status = app.StatusActive
 	}
 
-	ld.users = append(ld.users, usr)
+	ld.users = append(ld.users, app.User{
+		UserID:          id,
+		Username:        user.Username,
+		Password:        user.Password,
+		DisplayName:     user.DisplayName,
+		AvatarURL:       user.AvatarURL,
+		Locale:          user.Locale,
+		Timezone:        user.Timezone,
+		Status:          status,
+		StatusChangedAt: time.Now().UTC().Truncate(time.Microsecond),
+	})
+	ld.userRoles[id] = app.GuestRole
 	return nil
 }
 
 func (ld *localDB) GetUserByUsername(ctx context.Context, username string) (app.User, error) {
-	for _, value := range ld.users {
-		if value.Username == username {
-			return value, nil
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+
+	if i := ld.indexOfUsername(username); i != -1 {
+		return ld.scopedUserAt(ctx, i), nil
+	}
+
+	return app.User{}, nil
+}
+
+func (ld *localDB) GetUserByIdentifier(ctx context.Context, identifier string) (app.User, error) {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+
+	if i := ld.indexOfUsername(identifier); i != -1 {
+		return ld.scopedUserAt(ctx, i), nil
+	}
+
+	for _, value := range ld.identifiers {
+		if value.Value != identifier || !value.Verified {
+			continue
 		}
+
+		if i := ld.indexOfUser(value.UserID); i != -1 {
+			return ld.scopedUserAt(ctx, i), nil
+		}
+	}
+
+	return app.User{}, nil
+}
+
+func (ld *localDB) GetUserByID(ctx context.Context, userID string) (app.User, error) {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+
+	if i := ld.indexOfUser(userID); i != -1 {
+		return ld.scopedUserAt(ctx, i), nil
 	}
 
 	return app.User{}, nil
 }
 
+func (ld *localDB) UpdateUser(ctx context.Context, user app.User) error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	index := ld.indexOfUser(user.UserID)
+	if index == -1 {
+		return app.ErrUserNotFound
+	}
+
+	if i := ld.indexOfUsername(user.Username); i != -1 && i != index {
+		return app.ErrUserAlreadyExists
+	}
+
+	usr := &ld.users[index]
+	usr.Username = user.Username
+	usr.Password = user.Password
+	usr.DisplayName = user.DisplayName
+	usr.AvatarURL = user.AvatarURL
+	usr.Locale = user.Locale
+	usr.Timezone = user.Timezone
+	return nil
+}
+
+func (ld *localDB) UpdateUserStatus(ctx context.Context, userID string, change app.StatusChange) error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	index := ld.indexOfUser(userID)
+	if index == -1 {
+		return app.ErrUserNotFound
+	}
+
+	if ld.users[index].Status != change.From {
+		return app.ErrAccountStatusConflict
+	}
+
+	ld.users[index].Status = change.To
+	ld.users[index].StatusReason = change.Reason
+	ld.users[index].StatusChangedAt = change.At
+	return nil
+}
+
+func (ld *localDB) DeleteUser(ctx context.Context, userID string) error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	index := ld.indexOfUser(userID)
+	if index == -1 {
+		return app.ErrUserNotFound
+	}
+
+	if ld.userRoles[userID] == app.AdminRole && ld.countAdmins() <= 1 {
+		return app.ErrLastAdministrator
+	}
+
+	ld.users = append(ld.users[:index], ld.users[index+1:]...)
+	delete(ld.userRoles, userID)
+
+	memberships := ld.memberships[:0]
+	for _, membership := range ld.memberships {
+		if membership.UserID != userID {
+			memberships = append(memberships, membership)
+		}
+	}
+	ld.memberships = memberships
+
+	groupMembers := ld.groupMembers[:0]
+	for _, member := range ld.groupMembers {
+		if member.userID != userID {
+			groupMembers = append(groupMembers, member)
+		}
+	}
+	ld.groupMembers = groupMembers
+
+	identifiers := ld.identifiers[:0]
+	for _, identifier := range ld.identifiers {
+		if identifier.UserID != userID {
+			identifiers = append(identifiers, identifier)
+		}
+	}
+	ld.identifiers = identifiers
+
+	return nil
+}
+
 func (ld *localDB) GetUsers(ctx context.Context) ([]app.User, error) {
-	return ld.users, nil
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+
+	return ld.getUsers(ctx), nil
+}
+
+// getUsers joins users with their roles, or with their memberships when ctx
+// is scoped to an organization.
+func (ld *localDB) getUsers(ctx context.Context) []app.User {
+	users := []app.User{}
+
+	orgID := app.OrganizationFromContext(ctx)
+	if orgID == "" {
+		for i := range ld.users {
+			users = append(users, ld.userAt(i))
+		}
+		return users
+	}
+
+	for _, membership := range ld.memberships {
+		if membership.OrgID != orgID {
+			continue
+		}
+
+		if i := ld.indexOfUser(membership.UserID); i != -1 {
+			usr := ld.users[i]
+			usr.Role = membership.Role
+			users = append(users, usr)
+		}
+	}
+
+	return users
+}
+
+func (ld *localDB) ListUsers(ctx context.Context, filter app.UserFilter) ([]app.User, error) {
+	ld.mu.RLock()
+	users := ld.getUsers(ctx)
+	ld.mu.RUnlock()
+
+	matching := []app.User{}
+	for _, usr := range users {
+		if filter.Role != "" && usr.Role != filter.Role {
+			continue
+		}
+		if !strings.HasPrefix(usr.Username, filter.UsernamePrefix) {
+			continue
+		}
+		if filter.After != "" && (filter.Descending && usr.Username >= filter.After || !filter.Descending && usr.Username <= filter.After) {
+			continue
+		}
+		matching = append(matching, usr)
+	}
+
+	sort.Slice(matching, func(i, j int) bool {
+		if filter.Descending {
+			return matching[i].Username > matching[j].Username
+		}
+		return matching[i].Username < matching[j].Username
+	})
+
+	if filter.Offset > len(matching) {
+		filter.Offset = len(matching)
+	}
+	matching = matching[filter.Offset:]
+
+	if filter.Limit > 0 && len(matching) > filter.Limit {
+		matching = matching[:filter.Limit]
+	}
+
+	return matching, nil
+}
+
+func (ld *localDB) CountUsers(ctx context.Context, filter app.UserFilter) (int, error) {
+	ld.mu.RLock()
+	users := ld.getUsers(ctx)
+	ld.mu.RUnlock()
+
+	count := 0
+	for _, usr := range users {
+		if (filter.Role == "" || usr.Role == filter.Role) && strings.HasPrefix(usr.Username, filter.UsernamePrefix) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (ld *localDB) indexOfUser(userID string) int {
+	for i, value := range ld.users {
+		if value.UserID == userID {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (ld *localDB) indexOfUsername(username string) int {
+	for i, value := range ld.users {
+		if value.Username == username {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// userAt returns the user at index i joined with its role.
+func (ld *localDB) userAt(i int) app.User {
+	usr := ld.users[i]
+	usr.Role = ld.userRoles[usr.UserID]
+	return usr
+}
+
+// scopedUserAt is userAt for lookups: scoped to an organization, the user
+// must be one of its members and has the role it holds in it.
+func (ld *localDB) scopedUserAt(ctx context.Context, i int) app.User {
+	orgID := app.OrganizationFromContext(ctx)
+	if orgID == "" {
+		return ld.userAt(i)
+	}
+
+	m := ld.indexOfMembership(orgID, ld.users[i].UserID)
+	if m == -1 {
+		return app.User{}
+	}
+
+	usr := ld.users[i]
+	usr.Role = ld.memberships[m].Role
+	return usr
+}
+
+func (ld *localDB) countAdmins() int {
+	admins := 0
+	for _, role := range ld.userRoles {
+		if role == app.AdminRole {
+			admins++
+		}
+	}
+
+	return admins
+}
+
+func (ld *localDB) UpdateUserRole(ctx context.Context, userID string, role app.Role) (app.Role, error) {
+	if !ld.isKnownRole(ctx, role) {
+		return "", app.ErrRoleNotFound
+	}
+
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	if ld.indexOfUser(userID) == -1 {
+		return "", app.ErrUserNotFound
+	}
+
+	previous := ld.userRoles[userID]
+	if previous == app.AdminRole && role != app.AdminRole && ld.countAdmins() <= 1 {
+		return "", app.ErrLastAdministrator
+	}
+
+	ld.userRoles[userID] = role
+	return previous, nil
+}
+
+func (ld *localDB) isKnownRole(ctx context.Context, role app.Role) bool {
+	definition, _ := ld.roles.GetRoleByName(ctx, role)
+	return !definition.IsEmpty()
+}
+
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
 }
 
 func (ld *localDB) flushAll() error {
-	ld.users = ld.users[:0]
-	return nil
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	ld.users = nil
+	ld.userRoles = map[string]app.Role{}
+	ld.organizations = nil
+	ld.memberships = nil
+	ld.groups = nil
+	ld.groupMembers = nil
+	ld.erasures = nil
+	ld.invites = nil
+	ld.identifiers = nil
+	return ld.roles.flushAll()
 }
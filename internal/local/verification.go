@@ -0,0 +1,35 @@
+package local
+
+import (
+	"context"
+	"sync"
+
+	app "github.com/rislah/fakes/internal"
+)
+
+// VerificationSender keeps the last code sent to each identifier instead of
+// delivering it.
+type VerificationSender struct {
+	mu    sync.Mutex
+	codes map[string]string
+}
+
+var _ app.VerificationSender = &VerificationSender{}
+
+func NewVerificationSender() *VerificationSender {
+	return &VerificationSender{codes: map[string]string{}}
+}
+
+func (v *VerificationSender) SendVerificationCode(ctx context.Context, identifier app.Identifier, code string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.codes[identifier.Value] = code
+	return nil
+}
+
+// Code returns the last code sent to value.
+func (v *VerificationSender) Code(value string) string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.codes[value]
+}
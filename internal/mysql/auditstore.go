@@ -0,0 +1,115 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/cep21/circuit/v3"
+	"github.com/jmoiron/sqlx"
+	"github.com/rislah/fakes/internal/audit"
+)
+
+type mysqlAuditStore struct {
+	db      *sqlx.DB
+	circuit *circuit.Circuit
+}
+
+var _ audit.Store = &mysqlAuditStore{}
+
+func NewAuditStore(db *sqlx.DB, cc *circuit.Circuit) (*mysqlAuditStore, error) {
+	return &mysqlAuditStore{db: db, circuit: cc}, nil
+}
+
+const auditEventColumns = "seq, occurred_at, action, actor_id, actor, target_id, ip, details, prev_hash, hash"
+
+// Append holds a named lock while it reads the last event and inserts its
+// successor, so that concurrent appends can't fork the chain. Details are
+// sent as bytes, which MySQL only accepts as JSON once they have a character
+// set.
+func (m *mysqlAuditStore) Append(ctx context.Context, event audit.Event) (audit.Event, error) {
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		tx, release, err := beginLocked(ctx, m.db, "audit_event")
+		if err != nil {
+			return err
+		}
+		defer release()
+		defer tx.Rollback()
+
+		var last audit.Event
+		err = tx.GetContext(ctx, &last, "select seq, hash from audit_event order by seq desc limit 1")
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		event = audit.Chain(last, event)
+		_, err = tx.NamedExecContext(ctx, `
+			insert into audit_event (`+auditEventColumns+`)
+			values (:seq, :occurred_at, :action, :actor_id, :actor, :target_id, :ip, convert(:details using utf8mb4), :prev_hash, :hash)`, event)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return audit.Event{}, storageError(err)
+	}
+
+	return event, nil
+}
+
+func (m *mysqlAuditStore) Search(ctx context.Context, query audit.Query) ([]audit.Event, error) {
+	var args []interface{}
+	sqlQuery := "select " + auditEventColumns + " from audit_event where true"
+	if query.ActorID != "" {
+		sqlQuery += " and actor_id = ?"
+		args = append(args, query.ActorID)
+	}
+	if query.TargetID != "" {
+		sqlQuery += " and target_id = ?"
+		args = append(args, query.TargetID)
+	}
+	if query.Action != "" {
+		sqlQuery += " and action = ?"
+		args = append(args, query.Action)
+	}
+	if !query.From.IsZero() {
+		sqlQuery += " and occurred_at >= ?"
+		args = append(args, query.From)
+	}
+	if !query.To.IsZero() {
+		sqlQuery += " and occurred_at < ?"
+		args = append(args, query.To)
+	}
+	if query.Before != 0 {
+		sqlQuery += " and seq < ?"
+		args = append(args, query.Before)
+	}
+	sqlQuery += " order by seq desc limit ?"
+	args = append(args, query.Limit)
+
+	events := []audit.Event{}
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		return m.db.SelectContext(ctx, &events, sqlQuery, args...)
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return events, nil
+}
+
+func (m *mysqlAuditStore) Events(ctx context.Context, after int64, limit int) ([]audit.Event, error) {
+	events := []audit.Event{}
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		return m.db.SelectContext(ctx, &events, "select "+auditEventColumns+" from audit_event where seq > ? order by seq limit ?", after, limit)
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return events, nil
+}
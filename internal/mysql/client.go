@@ -0,0 +1,134 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/golang-migrate/migrate/v4"
+	migratemysql "github.com/golang-migrate/migrate/v4/database/mysql"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+type Options struct {
+	// DSN is a go-sql-driver/mysql data source name, such as
+	// "user:password@tcp(127.0.0.1:3306)/user".
+	DSN          string
+	MaxIdleConns int
+	MaxOpenConns int
+}
+
+// NewClient connects to the database and migrates it to the latest schema.
+// Times are read and written in UTC regardless of the DSN.
+func NewClient(opts Options) (*sqlx.DB, error) {
+	cfg, err := mysql.ParseDSN(opts.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+
+	if err := Migrate(cfg); err != nil {
+		return nil, err
+	}
+
+	db, err := sqlx.Connect("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.MaxIdleConns == 0 {
+		opts.MaxIdleConns = 100
+	}
+
+	if opts.MaxOpenConns == 0 {
+		opts.MaxOpenConns = 100
+	}
+
+	db.SetMaxIdleConns(opts.MaxIdleConns)
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+
+	return db, nil
+}
+
+// Migrate applies the migrations in the migrations directory, which mirror
+// the Postgres ones version for version in the MySQL dialect. Migrations
+// are applied over a connection of their own, as they need multiple
+// statements per query, which the client doesn't allow.
+func Migrate(cfg *mysql.Config) error {
+	cfg = cfg.Clone()
+	cfg.MultiStatements = true
+
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return err
+	}
+
+	source, err := iofs.New(migrations, "migrations")
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	target, err := migratemysql.WithInstance(db, &migratemysql.Config{})
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "mysql", target)
+	if err != nil {
+		db.Close()
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		return err
+	}
+
+	return nil
+}
+
+// beginLocked begins a transaction on a connection holding the named lock,
+// which is MySQL's counterpart of a Postgres advisory lock. The lock belongs to
+// the connection rather than the transaction, so release must be called
+// once the transaction is committed or rolled back.
+func beginLocked(ctx context.Context, db *sqlx.DB, name string) (*sqlx.Tx, func(), error) {
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var locked sql.NullBool
+	if err := conn.GetContext(ctx, &locked, "select get_lock(?, -1)", name); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	if !locked.Bool {
+		conn.Close()
+		return nil, nil, fmt.Errorf("acquiring lock %s", name)
+	}
+
+	release := func() {
+		conn.ExecContext(context.Background(), "select release_lock(?)", name)
+		conn.Close()
+	}
+
+	tx, err := conn.BeginTxx(ctx, &sql.TxOptions{})
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+
+	return tx, release, nil
+}
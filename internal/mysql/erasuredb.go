@@ -0,0 +1,151 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/cep21/circuit/v3"
+	"github.com/jmoiron/sqlx"
+	app "github.com/rislah/fakes/internal"
+)
+
+type mysqlErasureDB struct {
+	db      *sqlx.DB
+	circuit *circuit.Circuit
+}
+
+var _ app.ErasureDB = &mysqlErasureDB{}
+
+func NewErasureDB(db *sqlx.DB, cc *circuit.Circuit) (*mysqlErasureDB, error) {
+	return &mysqlErasureDB{db: db, circuit: cc}, nil
+}
+
+const erasureRequestColumns = "request_id, user_id, status, requested_at, scheduled_for, completed_at"
+
+func (m *mysqlErasureDB) CreateErasureRequest(ctx context.Context, request app.ErasureRequest) (app.ErasureRequest, error) {
+	requestID, err := newUUID()
+	if err != nil {
+		return app.ErasureRequest{}, err
+	}
+
+	var outErr error
+	err = m.circuit.Run(ctx, func(c context.Context) error {
+		_, err := m.db.ExecContext(ctx, `
+			insert into erasure_request (request_id, user_id, requested_at, scheduled_for)
+			values (?, ?, ?, ?)`, requestID, request.UserID, request.RequestedAt, request.ScheduledFor)
+		if err != nil {
+			if isDuplicateEntry(err, mysqlErasureRequestPendingUserIDKey) {
+				outErr = app.ErrErasureAlreadyRequested
+				return nil
+			}
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return app.ErasureRequest{}, storageError(err)
+	}
+
+	if outErr != nil {
+		return app.ErasureRequest{}, outErr
+	}
+
+	request.RequestID = requestID
+	request.Status = app.ErasurePending
+	return request, nil
+}
+
+func (m *mysqlErasureDB) GetPendingErasureRequest(ctx context.Context, userID string) (app.ErasureRequest, error) {
+	var request app.ErasureRequest
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		err := m.db.GetContext(ctx, &request, "select "+erasureRequestColumns+" from erasure_request where pending_user_id = ?", userID)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return app.ErasureRequest{}, storageError(err)
+	}
+
+	return request, nil
+}
+
+func (m *mysqlErasureDB) DeletePendingErasureRequest(ctx context.Context, userID string) error {
+	var outErr error
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		res, err := m.db.ExecContext(ctx, "delete from erasure_request where pending_user_id = ?", userID)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			outErr = app.ErrErasureRequestNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (m *mysqlErasureDB) GetDueErasureRequests(ctx context.Context, now time.Time) ([]app.ErasureRequest, error) {
+	requests := []app.ErasureRequest{}
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		return m.db.SelectContext(ctx, &requests, `
+			select `+erasureRequestColumns+`
+			from erasure_request
+			where status = 'pending' and scheduled_for <= ?
+			order by scheduled_for, id`, now)
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return requests, nil
+}
+
+func (m *mysqlErasureDB) CompleteErasureRequest(ctx context.Context, requestID string, completedAt time.Time) error {
+	var outErr error
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		res, err := m.db.ExecContext(ctx, `
+			update erasure_request
+			set status = 'completed', completed_at = ?
+			where request_id = ? and status = 'pending'`, completedAt, requestID)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			outErr = app.ErrErasureRequestNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
@@ -0,0 +1,47 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/rislah/fakes/internal/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageError(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind *errors.WrappedError
+	}{
+		{err: &mysql.MySQLError{Number: mysqlDeadlock}, kind: errors.ErrStorageConflict},
+		{err: &mysql.MySQLError{Number: mysqlLockWaitTimeout}, kind: errors.ErrStorageTimeout},
+		{err: &mysql.MySQLError{Number: mysqlTooManyConnections}, kind: errors.ErrStorageUnavailable},
+		{err: mysql.ErrInvalidConn, kind: errors.ErrStorageUnavailable},
+		{err: context.DeadlineExceeded, kind: errors.ErrStorageTimeout},
+	}
+
+	for _, test := range tests {
+		assert.True(t, errors.IsStorageError(storageError(test.err), test.kind), "%#v", test.err)
+	}
+
+	for _, number := range []uint16{mysqlDuplicateEntry, mysqlRowIsReferenced, mysqlNoReferencedRow} {
+		err := &mysql.MySQLError{Number: number}
+		assert.Equal(t, err, errors.Unwrap(storageError(err)), "%d isn't a storage error", number)
+	}
+}
+
+func TestIsDuplicateEntry(t *testing.T) {
+	duplicate := func(message string) error {
+		return &mysql.MySQLError{Number: mysqlDuplicateEntry, Message: message}
+	}
+
+	assert.True(t, isDuplicateEntry(duplicate("Duplicate entry 'alice' for key 'users.username'"), mysqlUsersUsernameKey))
+	assert.True(t, isDuplicateEntry(duplicate("Duplicate entry 'alice' for key 'username'"), mysqlUsersUsernameKey), "before MySQL 8.0.19")
+	assert.False(t, isDuplicateEntry(duplicate("Duplicate entry '1111' for key 'users.user_id'"), mysqlUsersUsernameKey))
+	assert.False(t, isDuplicateEntry(&mysql.MySQLError{Number: mysqlDeadlock}, mysqlUsersUsernameKey))
+
+	assert.True(t, isDuplicateEntry(duplicate("duplicate unique key given: [alice]"), mysqlUsersUsernameKey), "go-mysql-server")
+	assert.False(t, isDuplicateEntry(duplicate("duplicate unique key given: [alice]"), mysqlGroupMemberPrimaryKey), "go-mysql-server")
+	assert.True(t, isDuplicateEntry(duplicate("duplicate primary key given: [1,2]"), mysqlGroupMemberPrimaryKey), "go-mysql-server")
+}
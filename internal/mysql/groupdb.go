@@ -0,0 +1,358 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/cep21/circuit/v3"
+	"github.com/jmoiron/sqlx"
+	app "github.com/rislah/fakes/internal"
+)
+
+type mysqlGroupDB struct {
+	db      *sqlx.DB
+	circuit *circuit.Circuit
+}
+
+var _ app.GroupDB = &mysqlGroupDB{}
+
+func NewGroupDB(db *sqlx.DB, cc *circuit.Circuit) (*mysqlGroupDB, error) {
+	return &mysqlGroupDB{db: db, circuit: cc}, nil
+}
+
+// groupRow is a group, or one of its roles or permissions, by name.
+type groupRow struct {
+	GroupID string `db:"group_id"`
+	Name    string `db:"name"`
+}
+
+func (m *mysqlGroupDB) CreateGroup(ctx context.Context, group app.Group) (app.Group, error) {
+	if group.GroupID == "" {
+		groupID, err := newUUID()
+		if err != nil {
+			return app.Group{}, err
+		}
+		group.GroupID = groupID
+	}
+
+	var outErr error
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		tx, err := m.db.BeginTxx(ctx, &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		_, err = tx.ExecContext(ctx, "insert into user_group (group_id, name) values (?, ?)", group.GroupID, group.Name)
+		if err != nil {
+			if isDuplicateEntry(err, mysqlUserGroupNameKey) || isDuplicateEntry(err, mysqlUserGroupGroupIDKey) {
+				outErr = app.ErrGroupAlreadyExists
+				return nil
+			}
+			return err
+		}
+
+		if err := setGroupGrants(ctx, tx, group); err != nil {
+			if err == app.ErrRoleNotFound {
+				outErr = err
+				return nil
+			}
+			return err
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return app.Group{}, storageError(err)
+	}
+
+	if outErr != nil {
+		return app.Group{}, outErr
+	}
+
+	group.Roles = append([]app.Role{}, group.Roles...)
+	group.Permissions = append([]string{}, group.Permissions...)
+	return group, nil
+}
+
+func (m *mysqlGroupDB) GetGroups(ctx context.Context) ([]app.Group, error) {
+	return m.selectGroups(ctx, "true")
+}
+
+func (m *mysqlGroupDB) GetGroup(ctx context.Context, groupID string) (app.Group, error) {
+	groups, err := m.selectGroups(ctx, "g.group_id = ?", groupID)
+	if err != nil {
+		return app.Group{}, err
+	}
+
+	if len(groups) == 0 {
+		return app.Group{}, nil
+	}
+
+	return groups[0], nil
+}
+
+func (m *mysqlGroupDB) GetUserGroups(ctx context.Context, userID string) ([]app.Group, error) {
+	return m.selectGroups(ctx, "g.group_id in (select group_id from group_member where user_id = ?)", userID)
+}
+
+func (m *mysqlGroupDB) UpdateGroup(ctx context.Context, group app.Group) error {
+	var outErr error
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		tx, err := m.db.BeginTxx(ctx, &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		// MySQL counts changed rows rather than matched ones, so keeping the
+		// name can't tell a missing group apart.
+		var exists bool
+		if err := tx.GetContext(ctx, &exists, "select exists (select 1 from user_group where group_id = ? for update)", group.GroupID); err != nil {
+			return err
+		}
+
+		if !exists {
+			outErr = app.ErrGroupNotFound
+			return nil
+		}
+
+		_, err = tx.ExecContext(ctx, "update user_group set name = ? where group_id = ?", group.Name, group.GroupID)
+		if err != nil {
+			if isDuplicateEntry(err, mysqlUserGroupNameKey) {
+				outErr = app.ErrGroupAlreadyExists
+				return nil
+			}
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "delete from group_role where group_id = ?", group.GroupID); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "delete from group_permission where group_id = ?", group.GroupID); err != nil {
+			return err
+		}
+
+		if err := setGroupGrants(ctx, tx, group); err != nil {
+			if err == app.ErrRoleNotFound {
+				outErr = err
+				return nil
+			}
+			return err
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (m *mysqlGroupDB) DeleteGroup(ctx context.Context, groupID string) error {
+	var outErr error
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		res, err := m.db.ExecContext(ctx, "delete from user_group where group_id = ?", groupID)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			outErr = app.ErrGroupNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (m *mysqlGroupDB) AddGroupMember(ctx context.Context, groupID string, userID string) error {
+	var outErr error
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		_, err := m.db.ExecContext(ctx, "insert into group_member (group_id, user_id) values (?, ?)", groupID, userID)
+		if err == nil {
+			return nil
+		}
+
+		if isDuplicateEntry(err, mysqlGroupMemberPrimaryKey) {
+			outErr = app.ErrGroupMemberAlreadyExists
+			return nil
+		}
+
+		if !isErrorNumber(err, mysqlNoReferencedRow) {
+			return err
+		}
+
+		// Either the group or the user is missing.
+		var exists bool
+		if err := m.db.GetContext(ctx, &exists, "select exists (select 1 from user_group where group_id = ?)", groupID); err != nil {
+			return err
+		}
+
+		outErr = app.ErrUserNotFound
+		if !exists {
+			outErr = app.ErrGroupNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (m *mysqlGroupDB) RemoveGroupMember(ctx context.Context, groupID string, userID string) error {
+	var outErr error
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		res, err := m.db.ExecContext(ctx, "delete from group_member where group_id = ? and user_id = ?", groupID, userID)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			outErr = app.ErrGroupMemberNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (m *mysqlGroupDB) GetGroupMembers(ctx context.Context, groupID string) ([]string, error) {
+	members := []string{}
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		return m.db.SelectContext(ctx, &members, "select user_id from group_member where group_id = ? order by user_id", groupID)
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return members, nil
+}
+
+// selectGroups loads the groups matching condition, a predicate on the
+// user_group table aliased as g, together with their roles and permissions.
+func (m *mysqlGroupDB) selectGroups(ctx context.Context, condition string, args ...interface{}) ([]app.Group, error) {
+	var rows []groupRow
+	var roleRows, permissionRows []groupRow
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		err := m.db.SelectContext(ctx, &rows, "select g.group_id, g.name from user_group g where "+condition+" order by g.name", args...)
+		if err != nil {
+			return err
+		}
+
+		err = m.db.SelectContext(ctx, &roleRows, `
+			select g.group_id, r.name
+			from user_group g
+			inner join group_role gr on gr.group_id = g.group_id
+			inner join role r on r.id = gr.role_id
+			where `+condition+`
+			order by r.name`, args...)
+		if err != nil {
+			return err
+		}
+
+		return m.db.SelectContext(ctx, &permissionRows, `
+			select g.group_id, pm.name
+			from user_group g
+			inner join group_permission gp on gp.group_id = g.group_id
+			inner join permission pm on pm.id = gp.permission_id
+			where `+condition+`
+			order by pm.name`, args...)
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	indexByID := map[string]int{}
+	groups := make([]app.Group, 0, len(rows))
+	for _, row := range rows {
+		indexByID[row.GroupID] = len(groups)
+		groups = append(groups, app.Group{GroupID: row.GroupID, Name: row.Name, Roles: []app.Role{}, Permissions: []string{}})
+	}
+
+	for _, row := range roleRows {
+		if i, ok := indexByID[row.GroupID]; ok {
+			groups[i].Roles = append(groups[i].Roles, app.Role(row.Name))
+		}
+	}
+
+	for _, row := range permissionRows {
+		if i, ok := indexByID[row.GroupID]; ok {
+			groups[i].Permissions = append(groups[i].Permissions, row.Name)
+		}
+	}
+
+	return groups, nil
+}
+
+func setGroupGrants(ctx context.Context, tx *sqlx.Tx, group app.Group) error {
+	for _, role := range group.Roles {
+		var roleID int
+		if err := tx.GetContext(ctx, &roleID, "select id from role where name = ?", role); err != nil {
+			if err == sql.ErrNoRows {
+				return app.ErrRoleNotFound
+			}
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			insert into group_role (group_id, role_id) values (?, ?)
+			on duplicate key update role_id = role_id`, group.GroupID, roleID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, permission := range group.Permissions {
+		if err := insertPermission(ctx, tx, permission); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			insert into group_permission (group_id, permission_id)
+			select ?, id from permission where name = ?
+			on duplicate key update permission_id = group_permission.permission_id`, group.GroupID, permission)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertPermission adds the permission unless it exists. Unlike insert
+// ignore, on duplicate key update still fails on other errors.
+func insertPermission(ctx context.Context, tx *sqlx.Tx, permission string) error {
+	_, err := tx.ExecContext(ctx, "insert into permission (name) values (?) on duplicate key update name = name", permission)
+	return err
+}
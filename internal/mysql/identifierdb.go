@@ -0,0 +1,191 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/cep21/circuit/v3"
+	"github.com/jmoiron/sqlx"
+	app "github.com/rislah/fakes/internal"
+)
+
+type mysqlIdentifierDB struct {
+	db      *sqlx.DB
+	circuit *circuit.Circuit
+}
+
+var _ app.IdentifierDB = &mysqlIdentifierDB{}
+
+func NewIdentifierDB(db *sqlx.DB, cc *circuit.Circuit) (*mysqlIdentifierDB, error) {
+	return &mysqlIdentifierDB{db: db, circuit: cc}, nil
+}
+
+const identifierColumns = "identifier_id, user_id, type, value, verified_at is not null as verified, code_hash, attempts, created_at"
+
+func (m *mysqlIdentifierDB) CreateIdentifier(ctx context.Context, identifier app.Identifier) (app.Identifier, error) {
+	identifierID, err := newUUID()
+	if err != nil {
+		return app.Identifier{}, err
+	}
+
+	var outErr error
+	err = m.circuit.Run(ctx, func(c context.Context) error {
+		_, err := m.db.ExecContext(ctx, `
+			insert into user_identifier (identifier_id, user_id, type, value, code_hash, created_at)
+			values (?, ?, ?, ?, ?, ?)`, identifierID, identifier.UserID, identifier.Type, identifier.Value, identifier.CodeHash, identifier.CreatedAt)
+		if err != nil {
+			switch {
+			case isDuplicateEntry(err, mysqlUserIdentifierUserIDValueKey):
+				outErr = app.ErrIdentifierTaken
+				return nil
+			case isErrorNumber(err, mysqlNoReferencedRow):
+				outErr = app.ErrUserNotFound
+				return nil
+			}
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return app.Identifier{}, storageError(err)
+	}
+
+	if outErr != nil {
+		return app.Identifier{}, outErr
+	}
+
+	identifier.IdentifierID = identifierID
+	identifier.Verified = false
+	identifier.Attempts = 0
+	return identifier, nil
+}
+
+func (m *mysqlIdentifierDB) ListIdentifiers(ctx context.Context, userID string) ([]app.Identifier, error) {
+	identifiers := []app.Identifier{}
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		return m.db.SelectContext(ctx, &identifiers, `
+			select `+identifierColumns+`
+			from user_identifier
+			where user_id = ?
+			order by created_at, id`, userID)
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return identifiers, nil
+}
+
+func (m *mysqlIdentifierDB) GetIdentifier(ctx context.Context, identifierID string) (app.Identifier, error) {
+	var identifier app.Identifier
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		err := m.db.GetContext(ctx, &identifier, `
+			select `+identifierColumns+`
+			from user_identifier
+			where identifier_id = ?`, identifierID)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return app.Identifier{}, storageError(err)
+	}
+
+	return identifier, nil
+}
+
+// VerifyIdentifier locks the identifier, so that concurrent attempts are
+// all counted. The attempt is kept even when the value turns out to be
+// taken, as MySQL only rolls back the failing statement.
+func (m *mysqlIdentifierDB) VerifyIdentifier(ctx context.Context, identifierID string, codeHash string, now time.Time) error {
+	var outErr error
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		tx, err := m.db.BeginTxx(ctx, &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var identifier app.Identifier
+		err = tx.GetContext(ctx, &identifier, `
+			select `+identifierColumns+`
+			from user_identifier
+			where identifier_id = ?
+			for update`, identifierID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				outErr = app.ErrIdentifierNotFound
+				return nil
+			}
+			return err
+		}
+
+		if identifier.Verified {
+			return nil
+		}
+
+		if identifier.Attempts >= app.MaxVerificationAttempts {
+			outErr = app.ErrVerificationCodeInvalid
+			return nil
+		}
+
+		if _, err := tx.ExecContext(ctx, "update user_identifier set attempts = attempts + 1 where identifier_id = ?", identifierID); err != nil {
+			return err
+		}
+
+		if identifier.CodeHash != codeHash {
+			outErr = app.ErrVerificationCodeInvalid
+			return tx.Commit()
+		}
+
+		_, err = tx.ExecContext(ctx, "update user_identifier set verified_at = ? where identifier_id = ?", now, identifierID)
+		if err != nil {
+			if !isDuplicateEntry(err, mysqlUserIdentifierVerifiedValueKey) {
+				return err
+			}
+			outErr = app.ErrIdentifierTaken
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (m *mysqlIdentifierDB) DeleteIdentifier(ctx context.Context, identifierID string) error {
+	var outErr error
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		res, err := m.db.ExecContext(ctx, "delete from user_identifier where identifier_id = ?", identifierID)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			outErr = app.ErrIdentifierNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
@@ -0,0 +1,191 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/cep21/circuit/v3"
+	"github.com/jmoiron/sqlx"
+	app "github.com/rislah/fakes/internal"
+)
+
+type mysqlInviteDB struct {
+	db      *sqlx.DB
+	circuit *circuit.Circuit
+}
+
+var _ app.InviteDB = &mysqlInviteDB{}
+
+func NewInviteDB(db *sqlx.DB, cc *circuit.Circuit) (*mysqlInviteDB, error) {
+	return &mysqlInviteDB{db: db, circuit: cc}, nil
+}
+
+const inviteColumns = "i.invite_id, i.code_hash, i.email, r.name as role, i.max_uses, i.uses, i.expires_at, i.created_by, i.created_at"
+
+func (m *mysqlInviteDB) CreateInvite(ctx context.Context, invite app.Invite) (app.Invite, error) {
+	inviteID, err := newUUID()
+	if err != nil {
+		return app.Invite{}, err
+	}
+
+	var outErr error
+	err = m.circuit.Run(ctx, func(c context.Context) error {
+		res, err := m.db.ExecContext(ctx, `
+			insert into invite (invite_id, code_hash, email, role_id, max_uses, expires_at, created_by, created_at)
+			select ?, ?, ?, r.id, ?, ?, ?, ?
+			from role r
+			where r.name = ?`, inviteID, invite.CodeHash, invite.Email, invite.MaxUses, invite.ExpiresAt, invite.CreatedBy, invite.CreatedAt, invite.Role)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			outErr = app.ErrRoleNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return app.Invite{}, storageError(err)
+	}
+
+	if outErr != nil {
+		return app.Invite{}, outErr
+	}
+
+	invite.InviteID = inviteID
+	invite.Code = ""
+	return invite, nil
+}
+
+func (m *mysqlInviteDB) GetInviteByCodeHash(ctx context.Context, codeHash string) (app.Invite, error) {
+	var invite app.Invite
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		err := m.db.GetContext(ctx, &invite, `
+			select `+inviteColumns+`
+			from invite i
+			join role r on r.id = i.role_id
+			where i.code_hash = ?`, codeHash)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return app.Invite{}, storageError(err)
+	}
+
+	return invite, nil
+}
+
+func (m *mysqlInviteDB) ListPendingInvites(ctx context.Context, now time.Time) ([]app.Invite, error) {
+	invites := []app.Invite{}
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		return m.db.SelectContext(ctx, &invites, `
+			select `+inviteColumns+`
+			from invite i
+			join role r on r.id = i.role_id
+			where i.uses < i.max_uses and i.expires_at > ?
+			order by i.created_at, i.id`, now)
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return invites, nil
+}
+
+// RedeemInvite checks and takes a use in a single statement, so that
+// concurrent registrations can't use an invite more than MaxUses times.
+func (m *mysqlInviteDB) RedeemInvite(ctx context.Context, inviteID string, now time.Time) error {
+	return m.updateInvite(ctx, app.ErrInviteInvalid, `
+		update invite
+		set uses = uses + 1
+		where invite_id = ? and uses < max_uses and expires_at > ?`, inviteID, now)
+}
+
+// ReleaseInvite only updates invites that have been used. MySQL counts
+// changed rows rather than matched ones, so an invite without uses is looked
+// up to tell it apart from a missing one.
+func (m *mysqlInviteDB) ReleaseInvite(ctx context.Context, inviteID string) error {
+	err := m.updateInvite(ctx, app.ErrInviteNotFound, "update invite set uses = uses - 1 where invite_id = ? and uses > 0", inviteID)
+	if err != app.ErrInviteNotFound {
+		return err
+	}
+
+	invite, err := m.getInvite(ctx, inviteID)
+	if err != nil {
+		return err
+	}
+
+	if invite.InviteID == "" {
+		return app.ErrInviteNotFound
+	}
+
+	return nil
+}
+
+func (m *mysqlInviteDB) DeleteInvite(ctx context.Context, inviteID string) error {
+	return m.updateInvite(ctx, app.ErrInviteNotFound, "delete from invite where invite_id = ?", inviteID)
+}
+
+func (m *mysqlInviteDB) getInvite(ctx context.Context, inviteID string) (app.Invite, error) {
+	var invite app.Invite
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		err := m.db.GetContext(ctx, &invite, `
+			select `+inviteColumns+`
+			from invite i
+			join role r on r.id = i.role_id
+			where i.invite_id = ?`, inviteID)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return app.Invite{}, storageError(err)
+	}
+
+	return invite, nil
+}
+
+// updateInvite runs query, which changes a single invite, and returns
+// notFound when it changed none.
+func (m *mysqlInviteDB) updateInvite(ctx context.Context, notFound error, query string, args ...interface{}) error {
+	var outErr error
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		res, err := m.db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			outErr = notFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
@@ -0,0 +1,25 @@
+-- UUIDs are generated by the application, so that inserts don't have to read
+-- them back. Text columns compare bytewise, like Postgres, and times are
+-- stored in UTC.
+CREATE TABLE role (
+    id   INT          AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL
+) COLLATE utf8mb4_bin;
+
+INSERT INTO role (name) VALUES ('guest');
+
+CREATE TABLE users (
+    id            INT          AUTO_INCREMENT PRIMARY KEY,
+    user_id       CHAR(36)     NOT NULL UNIQUE,
+    username      VARCHAR(255) NOT NULL UNIQUE,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at    DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+) COLLATE utf8mb4_bin;
+
+CREATE TABLE user_role (
+    id      INT      AUTO_INCREMENT PRIMARY KEY,
+    user_id CHAR(36) NOT NULL UNIQUE,
+    role_id INT      NOT NULL DEFAULT 1,
+    CONSTRAINT user_role_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT user_role_role_id_fkey FOREIGN KEY (role_id) REFERENCES role(id) ON DELETE CASCADE ON UPDATE CASCADE
+) COLLATE utf8mb4_bin;
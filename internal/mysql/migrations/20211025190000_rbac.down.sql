@@ -0,0 +1,9 @@
+ALTER TABLE user_role DROP FOREIGN KEY user_role_role_id_fkey;
+ALTER TABLE user_role ADD CONSTRAINT user_role_role_id_fkey FOREIGN KEY (role_id) REFERENCES role(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+DROP TABLE role_permission;
+DROP TABLE permission;
+
+DELETE FROM role WHERE name IN ('user', 'developer');
+
+ALTER TABLE role DROP INDEX role_name_key;
@@ -0,0 +1,26 @@
+ALTER TABLE role ADD CONSTRAINT role_name_key UNIQUE (name);
+
+INSERT INTO role (name) VALUES ('user'), ('developer');
+
+CREATE TABLE permission (
+    id   INT          AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE
+) COLLATE utf8mb4_bin;
+
+CREATE TABLE role_permission (
+    role_id       INT NOT NULL,
+    permission_id INT NOT NULL,
+    PRIMARY KEY (role_id, permission_id),
+    CONSTRAINT role_permission_role_id_fkey FOREIGN KEY (role_id) REFERENCES role(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT role_permission_permission_id_fkey FOREIGN KEY (permission_id) REFERENCES permission(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+INSERT INTO permission (name) VALUES ('viewTest'), ('manageRoles');
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM role r, permission p
+WHERE (r.name = 'guest' AND p.name = 'viewTest') OR (r.name = 'developer' AND p.name = 'manageRoles');
+
+ALTER TABLE user_role DROP FOREIGN KEY user_role_role_id_fkey;
+ALTER TABLE user_role ADD CONSTRAINT user_role_role_id_fkey FOREIGN KEY (role_id) REFERENCES role(id) ON DELETE RESTRICT ON UPDATE CASCADE;
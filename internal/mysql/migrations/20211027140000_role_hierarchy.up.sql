@@ -0,0 +1,13 @@
+CREATE TABLE role_parent (
+    role_id   INT NOT NULL,
+    parent_id INT NOT NULL,
+    PRIMARY KEY (role_id, parent_id),
+    CONSTRAINT role_parent_role_id_fkey FOREIGN KEY (role_id) REFERENCES role(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT role_parent_parent_id_fkey FOREIGN KEY (parent_id) REFERENCES role(id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT role_parent_check CHECK (role_id <> parent_id)
+);
+
+INSERT INTO role_parent (role_id, parent_id)
+SELECT r.id, p.id
+FROM role r, role p
+WHERE (r.name = 'user' AND p.name = 'guest') OR (r.name = 'developer' AND p.name = 'user');
@@ -0,0 +1,24 @@
+CREATE TABLE organization (
+    id         INT          AUTO_INCREMENT PRIMARY KEY,
+    org_id     CHAR(36)     NOT NULL UNIQUE,
+    name       VARCHAR(255) NOT NULL UNIQUE,
+    created_at DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+) COLLATE utf8mb4_bin;
+
+CREATE TABLE organization_member (
+    org_id  CHAR(36) NOT NULL,
+    user_id CHAR(36) NOT NULL,
+    role_id INT      NOT NULL,
+    PRIMARY KEY (org_id, user_id),
+    INDEX organization_member_user_id_idx (user_id),
+    CONSTRAINT organization_member_org_id_fkey FOREIGN KEY (org_id) REFERENCES organization(org_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT organization_member_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT organization_member_role_id_fkey FOREIGN KEY (role_id) REFERENCES role(id) ON DELETE RESTRICT ON UPDATE CASCADE
+) COLLATE utf8mb4_bin;
+
+INSERT INTO permission (name) VALUES ('manageOrganizations'), ('manageMembers');
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM role r, permission p
+WHERE r.name = 'admin' AND p.name IN ('manageOrganizations', 'manageMembers');
@@ -0,0 +1,38 @@
+CREATE TABLE user_group (
+    id         INT          AUTO_INCREMENT PRIMARY KEY,
+    group_id   CHAR(36)     NOT NULL UNIQUE,
+    name       VARCHAR(255) NOT NULL UNIQUE,
+    created_at DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+) COLLATE utf8mb4_bin;
+
+CREATE TABLE group_role (
+    group_id CHAR(36) NOT NULL,
+    role_id  INT      NOT NULL,
+    PRIMARY KEY (group_id, role_id),
+    CONSTRAINT group_role_group_id_fkey FOREIGN KEY (group_id) REFERENCES user_group(group_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT group_role_role_id_fkey FOREIGN KEY (role_id) REFERENCES role(id) ON DELETE RESTRICT ON UPDATE CASCADE
+) COLLATE utf8mb4_bin;
+
+CREATE TABLE group_permission (
+    group_id      CHAR(36) NOT NULL,
+    permission_id INT      NOT NULL,
+    PRIMARY KEY (group_id, permission_id),
+    CONSTRAINT group_permission_group_id_fkey FOREIGN KEY (group_id) REFERENCES user_group(group_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT group_permission_permission_id_fkey FOREIGN KEY (permission_id) REFERENCES permission(id) ON DELETE CASCADE ON UPDATE CASCADE
+) COLLATE utf8mb4_bin;
+
+CREATE TABLE group_member (
+    group_id CHAR(36) NOT NULL,
+    user_id  CHAR(36) NOT NULL,
+    PRIMARY KEY (group_id, user_id),
+    INDEX group_member_user_id_idx (user_id),
+    CONSTRAINT group_member_group_id_fkey FOREIGN KEY (group_id) REFERENCES user_group(group_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT group_member_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE
+) COLLATE utf8mb4_bin;
+
+INSERT INTO permission (name) VALUES ('manageGroups');
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM role r, permission p
+WHERE r.name = 'admin' AND p.name = 'manageGroups';
@@ -0,0 +1,4 @@
+-- The unique index on username already serves the ordering, prefix filter
+-- and keyset cursor of paginated user listings, as the column compares
+-- bytewise. This version only keeps the migration sets aligned.
+SELECT 1;
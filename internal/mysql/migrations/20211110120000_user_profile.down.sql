@@ -0,0 +1,5 @@
+ALTER TABLE users
+    DROP COLUMN display_name,
+    DROP COLUMN avatar_url,
+    DROP COLUMN locale,
+    DROP COLUMN timezone;
@@ -0,0 +1,5 @@
+ALTER TABLE users
+    ADD COLUMN display_name VARCHAR(255)  NOT NULL DEFAULT '',
+    ADD COLUMN avatar_url   VARCHAR(2048) NOT NULL DEFAULT '',
+    ADD COLUMN locale       VARCHAR(255)  NOT NULL DEFAULT '',
+    ADD COLUMN timezone     VARCHAR(255)  NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+DELETE FROM permission WHERE name = 'manageAccountStatus';
+
+ALTER TABLE users DROP CHECK users_status_check;
+
+ALTER TABLE users
+    DROP COLUMN status,
+    DROP COLUMN status_reason,
+    DROP COLUMN status_changed_at;
@@ -0,0 +1,13 @@
+ALTER TABLE users
+    ADD COLUMN status            VARCHAR(32)   NOT NULL DEFAULT 'active',
+    ADD COLUMN status_reason     VARCHAR(1024) NOT NULL DEFAULT '',
+    ADD COLUMN status_changed_at DATETIME(6)   NOT NULL DEFAULT CURRENT_TIMESTAMP(6);
+
+ALTER TABLE users ADD CONSTRAINT users_status_check CHECK (status IN ('pending', 'active', 'suspended', 'deactivated'));
+
+INSERT INTO permission (name) VALUES ('manageAccountStatus');
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM role r, permission p
+WHERE r.name = 'admin' AND p.name = 'manageAccountStatus';
@@ -0,0 +1,17 @@
+-- Erasure requests don't reference users, as the record of a completed
+-- erasure outlives the user it erased. MySQL has no partial indexes, so
+-- pending_user_id is only set while the request is pending and a unique
+-- index on it allows one pending request per user.
+CREATE TABLE erasure_request (
+    id              INT         AUTO_INCREMENT PRIMARY KEY,
+    request_id      CHAR(36)    NOT NULL UNIQUE,
+    user_id         CHAR(36)    NOT NULL,
+    status          VARCHAR(32) NOT NULL DEFAULT 'pending',
+    requested_at    DATETIME(6) NOT NULL,
+    scheduled_for   DATETIME(6) NOT NULL,
+    completed_at    DATETIME(6),
+    pending_user_id CHAR(36) AS (IF(status = 'pending', user_id, NULL)),
+    UNIQUE INDEX erasure_request_pending_user_id_idx (pending_user_id),
+    INDEX erasure_request_status_scheduled_for_idx (status, scheduled_for),
+    CONSTRAINT erasure_request_status_check CHECK (status IN ('pending', 'completed'))
+) COLLATE utf8mb4_bin;
@@ -0,0 +1,28 @@
+-- Audit events are chained by hash. Unlike on Postgres, the table isn't made
+-- append-only by triggers, as MySQL's TRUNCATE skips them; the service
+-- account should be granted only SELECT and INSERT on it. Actor and target
+-- IDs aren't foreign keys, as the record of an action outlives the users it
+-- involves.
+CREATE TABLE audit_event (
+    seq         BIGINT        PRIMARY KEY,
+    occurred_at DATETIME(6)   NOT NULL,
+    action      VARCHAR(255)  NOT NULL,
+    actor_id    VARCHAR(255)  NOT NULL DEFAULT '',
+    actor       VARCHAR(255)  NOT NULL DEFAULT '',
+    target_id   VARCHAR(255)  NOT NULL DEFAULT '',
+    ip          VARCHAR(64)   NOT NULL DEFAULT '',
+    details     JSON          NOT NULL,
+    prev_hash   VARCHAR(64)   NOT NULL,
+    hash        VARCHAR(64)   NOT NULL UNIQUE,
+    INDEX audit_event_actor_id_idx (actor_id, seq),
+    INDEX audit_event_target_id_idx (target_id, seq),
+    INDEX audit_event_action_idx (action, seq),
+    INDEX audit_event_occurred_at_idx (occurred_at)
+) COLLATE utf8mb4_bin;
+
+INSERT INTO permission (name) VALUES ('viewAuditLog');
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM role r, permission p
+WHERE r.name = 'admin' AND p.name = 'viewAuditLog';
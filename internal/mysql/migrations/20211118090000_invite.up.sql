@@ -0,0 +1,25 @@
+-- Invites only store the hash of their code. Deleting a role deletes the
+-- invites for it.
+CREATE TABLE invite (
+    id         INT          AUTO_INCREMENT PRIMARY KEY,
+    invite_id  CHAR(36)     NOT NULL UNIQUE,
+    code_hash  VARCHAR(64)  NOT NULL UNIQUE,
+    email      VARCHAR(254) NOT NULL DEFAULT '',
+    role_id    INT          NOT NULL,
+    max_uses   INT          NOT NULL,
+    uses       INT          NOT NULL DEFAULT 0,
+    expires_at DATETIME(6)  NOT NULL,
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at DATETIME(6)  NOT NULL,
+    INDEX invite_expires_at_idx (expires_at),
+    CONSTRAINT invite_role_id_fkey FOREIGN KEY (role_id) REFERENCES role(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT invite_max_uses_check CHECK (max_uses > 0),
+    CONSTRAINT invite_uses_check CHECK (uses >= 0 AND uses <= max_uses)
+) COLLATE utf8mb4_bin;
+
+INSERT INTO permission (name) VALUES ('manageInvites');
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM role r, permission p
+WHERE r.name = 'admin' AND p.name = 'manageInvites';
@@ -0,0 +1,22 @@
+-- Emails and phone numbers users can log in with besides their username.
+-- Verified values are unique across users and identifier types, unverified
+-- ones only per user, so that nobody can hold an address they don't control.
+-- MySQL has no partial indexes, so verified_value is only set once the value
+-- is verified.
+CREATE TABLE user_identifier (
+    id             INT          AUTO_INCREMENT PRIMARY KEY,
+    identifier_id  CHAR(36)     NOT NULL UNIQUE,
+    user_id        CHAR(36)     NOT NULL,
+    type           VARCHAR(16)  NOT NULL,
+    value          VARCHAR(254) NOT NULL,
+    code_hash      VARCHAR(64)  NOT NULL,
+    attempts       INT          NOT NULL DEFAULT 0,
+    verified_at    DATETIME(6),
+    created_at     DATETIME(6)  NOT NULL,
+    verified_value VARCHAR(254) AS (IF(verified_at IS NULL, NULL, value)),
+    UNIQUE INDEX user_identifier_user_id_value_key (user_id, value),
+    UNIQUE INDEX user_identifier_verified_value_idx (verified_value),
+    CONSTRAINT user_identifier_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT user_identifier_type_check CHECK (type IN ('email', 'phone')),
+    CONSTRAINT user_identifier_attempts_check CHECK (attempts >= 0)
+) COLLATE utf8mb4_bin;
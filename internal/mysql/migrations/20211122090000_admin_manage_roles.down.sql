@@ -0,0 +1,3 @@
+UPDATE role_permission SET role_id = (SELECT id FROM role WHERE name = 'developer')
+WHERE role_id = (SELECT id FROM role WHERE name = 'admin')
+AND permission_id = (SELECT id FROM permission WHERE name = 'manageRoles');
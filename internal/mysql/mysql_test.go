@@ -0,0 +1,140 @@
+package mysql_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cep21/circuit/v3"
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/jmoiron/sqlx"
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/audit"
+	"github.com/rislah/fakes/internal/circuitbreaker"
+	"github.com/rislah/fakes/internal/mysql"
+	"github.com/rislah/fakes/internal/tests"
+)
+
+// The role, erasure, identifier and invite stores rely on foreign key actions,
+// generated columns and insert ... select with parameters, which
+// go-mysql-server doesn't support, so they are tested against a MySQL server
+// in the integration tests.
+
+func TestMySQLUserDB(t *testing.T) {
+	tests.TestUserDB(t, makeUserDB)
+}
+
+func TestMySQLOrganizationDB(t *testing.T) {
+	tests.TestOrganizationDB(t, makeOrganizationDB)
+}
+
+func TestMySQLGroupDB(t *testing.T) {
+	tests.TestGroupDB(t, makeGroupDB)
+}
+
+func TestMySQLAuditStore(t *testing.T) {
+	tests.TestAuditStore(t, makeAuditStore)
+}
+
+// makeMySQL serves an empty in-memory database over the MySQL protocol, so
+// that the driver, the migrations and the queries are all exercised without
+// a MySQL server.
+func makeMySQL() (*sqlx.DB, *circuit.Circuit, func() error, error) {
+	database := memory.NewDatabase("user")
+	database.EnablePrimaryKeyIndexes()
+
+	pro := memory.NewDBProvider(database)
+	srv, err := server.NewServer(server.Config{Protocol: "tcp", Address: "127.0.0.1:0"}, sqle.NewDefault(pro), memory.NewSessionBuilder(pro), nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	go srv.Start()
+
+	client, err := mysql.NewClient(mysql.Options{DSN: fmt.Sprintf("root@tcp(%s)/user", srv.Listener.Addr())})
+	if err != nil {
+		srv.Close()
+		return nil, nil, nil, err
+	}
+
+	cb, err := circuitbreaker.New("mysql_test", circuitbreaker.Config{Timeout: time.Second})
+	if err != nil {
+		client.Close()
+		srv.Close()
+		return nil, nil, nil, err
+	}
+
+	return client, cb, func() error {
+		if err := client.Close(); err != nil {
+			return err
+		}
+		return srv.Close()
+	}, nil
+}
+
+func makeUserDB() (app.UserDB, func() error, error) {
+	client, cb, teardown, err := makeMySQL()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := mysql.NewUserDB(client, cb)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return db, teardown, nil
+}
+
+func makeOrganizationDB() (app.UserDB, app.OrganizationDB, func() error, error) {
+	client, cb, teardown, err := makeMySQL()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	userDB, err := mysql.NewUserDB(client, cb)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	db, err := mysql.NewOrganizationDB(client, cb)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return userDB, db, teardown, nil
+}
+
+func makeGroupDB() (app.UserDB, app.GroupDB, func() error, error) {
+	client, cb, teardown, err := makeMySQL()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	userDB, err := mysql.NewUserDB(client, cb)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	db, err := mysql.NewGroupDB(client, cb)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return userDB, db, teardown, nil
+}
+
+func makeAuditStore() (audit.Store, func() error, error) {
+	client, cb, teardown, err := makeMySQL()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	store, err := mysql.NewAuditStore(client, cb)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return store, teardown, nil
+}
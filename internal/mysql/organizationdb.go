@@ -0,0 +1,168 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/cep21/circuit/v3"
+	"github.com/jmoiron/sqlx"
+	app "github.com/rislah/fakes/internal"
+)
+
+type mysqlOrganizationDB struct {
+	db      *sqlx.DB
+	circuit *circuit.Circuit
+}
+
+var _ app.OrganizationDB = &mysqlOrganizationDB{}
+
+func NewOrganizationDB(db *sqlx.DB, cc *circuit.Circuit) (*mysqlOrganizationDB, error) {
+	return &mysqlOrganizationDB{db: db, circuit: cc}, nil
+}
+
+func (m *mysqlOrganizationDB) CreateOrganization(ctx context.Context, org app.Organization) (app.Organization, error) {
+	if org.OrgID == "" {
+		orgID, err := newUUID()
+		if err != nil {
+			return app.Organization{}, err
+		}
+		org.OrgID = orgID
+	}
+
+	var outErr error
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		_, err := m.db.ExecContext(ctx, "insert into organization (org_id, name) values (?, ?)", org.OrgID, org.Name)
+		if err != nil {
+			if isDuplicateEntry(err, mysqlOrganizationNameKey) || isDuplicateEntry(err, mysqlOrganizationOrgIDKey) {
+				outErr = app.ErrOrganizationAlreadyExists
+				return nil
+			}
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return app.Organization{}, storageError(err)
+	}
+
+	if outErr != nil {
+		return app.Organization{}, outErr
+	}
+
+	return org, nil
+}
+
+func (m *mysqlOrganizationDB) GetOrganization(ctx context.Context, orgID string) (app.Organization, error) {
+	var org app.Organization
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		err := m.db.GetContext(ctx, &org, "select org_id, name from organization where org_id = ?", orgID)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return app.Organization{}, storageError(err)
+	}
+
+	return org, nil
+}
+
+func (m *mysqlOrganizationDB) AddMember(ctx context.Context, membership app.Membership) error {
+	var outErr error
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		tx, err := m.db.BeginTxx(ctx, &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var exists bool
+		if err := tx.GetContext(ctx, &exists, "select exists (select 1 from organization where org_id = ?)", membership.OrgID); err != nil {
+			return err
+		}
+
+		if !exists {
+			outErr = app.ErrOrganizationNotFound
+			return nil
+		}
+
+		res, err := tx.ExecContext(ctx, `
+			insert into organization_member (org_id, user_id, role_id)
+			select ?, ?, r.id from role r where r.name = ?`,
+			membership.OrgID, membership.UserID, membership.Role)
+		if err != nil {
+			switch {
+			case isDuplicateEntry(err, mysqlOrganizationMemberPrimaryKey):
+				outErr = app.ErrMemberAlreadyExists
+				return nil
+			case isErrorNumber(err, mysqlNoReferencedRow):
+				outErr = app.ErrUserNotFound
+				return nil
+			}
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			outErr = app.ErrRoleNotFound
+			return nil
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (m *mysqlOrganizationDB) GetMembership(ctx context.Context, orgID string, userID string) (app.Membership, error) {
+	var membership app.Membership
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		err := m.db.GetContext(ctx, &membership, `
+			select om.org_id, om.user_id, r.name as role
+			from organization_member om
+			inner join role r on om.role_id = r.id
+			where om.org_id = ? and om.user_id = ?`, orgID, userID)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return app.Membership{}, storageError(err)
+	}
+
+	return membership, nil
+}
+
+func (m *mysqlOrganizationDB) GetMemberships(ctx context.Context, userID string) ([]app.Membership, error) {
+	memberships := []app.Membership{}
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		return m.db.SelectContext(ctx, &memberships, `
+			select om.org_id, om.user_id, r.name as role
+			from organization_member om
+			inner join role r on om.role_id = r.id
+			where om.user_id = ?
+			order by om.org_id`, userID)
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return memberships, nil
+}
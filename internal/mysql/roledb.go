@@ -0,0 +1,313 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/cep21/circuit/v3"
+	"github.com/jmoiron/sqlx"
+	app "github.com/rislah/fakes/internal"
+)
+
+type mysqlRoleDB struct {
+	db      *sqlx.DB
+	circuit *circuit.Circuit
+}
+
+var _ app.RoleDB = &mysqlRoleDB{}
+
+func NewRoleDB(db *sqlx.DB, cc *circuit.Circuit) (*mysqlRoleDB, error) {
+	return &mysqlRoleDB{db: db, circuit: cc}, nil
+}
+
+// roleHierarchyLock serializes transactions that change role parents, so
+// that the cycle check in setRoleParents sees every parent committed before
+// it.
+const roleHierarchyLock = "role_parent"
+
+type rolePermissionRow struct {
+	Name       string         `db:"name"`
+	Permission sql.NullString `db:"permission"`
+}
+
+type roleParentRow struct {
+	Name   string `db:"name"`
+	Parent string `db:"parent"`
+}
+
+func (m *mysqlRoleDB) CreateRole(ctx context.Context, role app.RoleDefinition) error {
+	var outErr error
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		tx, release, err := beginLocked(ctx, m.db, roleHierarchyLock)
+		if err != nil {
+			return err
+		}
+		defer release()
+		defer tx.Rollback()
+
+		res, err := tx.ExecContext(ctx, "insert into role (name) values (?)", role.Name)
+		if err != nil {
+			if isDuplicateEntry(err, mysqlRoleNameKey) {
+				outErr = app.ErrRoleAlreadyExists
+				return nil
+			}
+			return err
+		}
+
+		roleID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		if err := setRolePermissions(ctx, tx, roleID, role.Permissions); err != nil {
+			return err
+		}
+
+		if err := setRoleParents(ctx, tx, roleID, role.Parents); err != nil {
+			if err == app.ErrRoleParentNotFound || err == app.ErrRoleHierarchyCycle {
+				outErr = err
+				return nil
+			}
+			return err
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (m *mysqlRoleDB) GetRoles(ctx context.Context) ([]app.RoleDefinition, error) {
+	var rows []rolePermissionRow
+	var parentRows []roleParentRow
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		err := m.db.SelectContext(ctx, &rows, `
+			select r.name, p.name as permission
+			from role r
+			left join role_permission rp on rp.role_id = r.id
+			left join permission p on p.id = rp.permission_id
+			order by r.name, p.name`)
+		if err != nil {
+			return err
+		}
+
+		return m.db.SelectContext(ctx, &parentRows, `
+			select r.name, p.name as parent
+			from role_parent rp
+			inner join role r on r.id = rp.role_id
+			inner join role p on p.id = rp.parent_id
+			order by r.name, p.name`)
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return groupRoleRows(rows, parentRows), nil
+}
+
+func (m *mysqlRoleDB) GetRoleByName(ctx context.Context, name app.Role) (app.RoleDefinition, error) {
+	var rows []rolePermissionRow
+	var parentRows []roleParentRow
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		err := m.db.SelectContext(ctx, &rows, `
+			select r.name, p.name as permission
+			from role r
+			left join role_permission rp on rp.role_id = r.id
+			left join permission p on p.id = rp.permission_id
+			where r.name = ?
+			order by p.name`, name)
+		if err != nil {
+			return err
+		}
+
+		return m.db.SelectContext(ctx, &parentRows, `
+			select r.name, p.name as parent
+			from role_parent rp
+			inner join role r on r.id = rp.role_id
+			inner join role p on p.id = rp.parent_id
+			where r.name = ?
+			order by p.name`, name)
+	})
+
+	if err != nil {
+		return app.RoleDefinition{}, storageError(err)
+	}
+
+	roles := groupRoleRows(rows, parentRows)
+	if len(roles) == 0 {
+		return app.RoleDefinition{}, nil
+	}
+
+	return roles[0], nil
+}
+
+func (m *mysqlRoleDB) UpdateRole(ctx context.Context, role app.RoleDefinition) error {
+	var outErr error
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		tx, release, err := beginLocked(ctx, m.db, roleHierarchyLock)
+		if err != nil {
+			return err
+		}
+		defer release()
+		defer tx.Rollback()
+
+		var roleID int64
+		err = tx.GetContext(ctx, &roleID, "select id from role where name = ? for update", role.Name)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				outErr = app.ErrRoleNotFound
+				return nil
+			}
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "delete from role_permission where role_id = ?", roleID); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "delete from role_parent where role_id = ?", roleID); err != nil {
+			return err
+		}
+
+		if err := setRolePermissions(ctx, tx, roleID, role.Permissions); err != nil {
+			return err
+		}
+
+		if err := setRoleParents(ctx, tx, roleID, role.Parents); err != nil {
+			if err == app.ErrRoleParentNotFound || err == app.ErrRoleHierarchyCycle {
+				outErr = err
+				return nil
+			}
+			return err
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (m *mysqlRoleDB) DeleteRole(ctx context.Context, name app.Role) error {
+	var outErr error
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		res, err := m.db.ExecContext(ctx, "delete from role where name = ?", name)
+		if err != nil {
+			if isErrorNumber(err, mysqlRowIsReferenced) {
+				outErr = app.ErrRoleInUse
+				return nil
+			}
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			outErr = app.ErrRoleNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func setRolePermissions(ctx context.Context, tx *sqlx.Tx, roleID int64, permissions []string) error {
+	for _, permission := range permissions {
+		if err := insertPermission(ctx, tx, permission); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			insert into role_permission (role_id, permission_id)
+			select ?, id from permission where name = ?
+			on duplicate key update permission_id = role_permission.permission_id`, roleID, permission)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func setRoleParents(ctx context.Context, tx *sqlx.Tx, roleID int64, parents []app.Role) error {
+	for _, parent := range parents {
+		var parentID int64
+		if err := tx.GetContext(ctx, &parentID, "select id from role where name = ?", parent); err != nil {
+			if err == sql.ErrNoRows {
+				return app.ErrRoleParentNotFound
+			}
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			insert into role_parent (role_id, parent_id) values (?, ?)
+			on duplicate key update parent_id = parent_id`, roleID, parentID)
+		if err != nil {
+			return err
+		}
+	}
+
+	var cycle bool
+	err := tx.GetContext(ctx, &cycle, `
+		with recursive ancestor as (
+			select parent_id as id from role_parent where role_id = ?
+			union
+			select rp.parent_id from ancestor a inner join role_parent rp on rp.role_id = a.id
+		)
+		select exists (select 1 from ancestor where id = ?)`, roleID, roleID)
+	if err != nil {
+		return err
+	}
+
+	if cycle {
+		return app.ErrRoleHierarchyCycle
+	}
+
+	return nil
+}
+
+func groupRoleRows(rows []rolePermissionRow, parentRows []roleParentRow) []app.RoleDefinition {
+	indexByName := map[string]int{}
+	roles := []app.RoleDefinition{}
+	for _, row := range rows {
+		i, ok := indexByName[row.Name]
+		if !ok {
+			i = len(roles)
+			indexByName[row.Name] = i
+			roles = append(roles, app.RoleDefinition{Name: app.Role(row.Name), Parents: []app.Role{}, Permissions: []string{}})
+		}
+
+		if row.Permission.Valid {
+			roles[i].Permissions = append(roles[i].Permissions, row.Permission.String)
+		}
+	}
+
+	for _, row := range parentRows {
+		if i, ok := indexByName[row.Name]; ok {
+			roles[i].Parents = append(roles[i].Parents, app.Role(row.Parent))
+		}
+	}
+
+	for _, role := range roles {
+		sort.Strings(role.Permissions)
+	}
+
+	return roles
+}
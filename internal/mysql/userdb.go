@@ -0,0 +1,547 @@
+package mysql
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cep21/circuit/v3"
+	"github.com/jmoiron/sqlx"
+	app "github.com/rislah/fakes/internal"
+)
+
+type mysqlUserDB struct {
+	db      *sqlx.DB
+	circuit *circuit.Circuit
+}
+
+var _ app.UserDB = &mysqlUserDB{}
+var _ app.UserBatchCreator = &mysqlUserDB{}
+
+func NewUserDB(db *sqlx.DB, cc *circuit.Circuit) (*mysqlUserDB, error) {
+	return &mysqlUserDB{db: db, circuit: cc}, nil
+}
+
+func (m *mysqlUserDB) CreateUser(ctx context.Context, user app.User) error {
+	var outErr error
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		tx, err := m.db.BeginTxx(ctx, &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		userID, err := insertUser(ctx, tx, user)
+		if err != nil {
+			if isDuplicateEntry(err, mysqlUsersUsernameKey) {
+				outErr = app.ErrUserAlreadyExists
+				return nil
+			}
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "insert into user_role (user_id) values (?)", userID); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+// CreateUsers inserts users in a single transaction. Each user is inserted
+// under a savepoint, so that a duplicate username or an unknown role only
+// skips that user.
+func (m *mysqlUserDB) CreateUsers(ctx context.Context, users []app.User) ([]error, error) {
+	userErrs := make([]error, len(users))
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		tx, err := m.db.BeginTxx(ctx, &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		for i, user := range users {
+			if _, err := tx.ExecContext(ctx, "savepoint create_user"); err != nil {
+				return err
+			}
+
+			role := user.Role
+			if role == "" {
+				role = app.GuestRole
+			}
+
+			var roleID int
+			err := tx.GetContext(ctx, &roleID, "select id from role where name = ?", role)
+			switch {
+			case err == sql.ErrNoRows:
+				userErrs[i] = app.ErrRoleNotFound
+			case err != nil:
+				return err
+			default:
+				userID, err := insertUser(ctx, tx, user)
+				if err == nil {
+					if _, err := tx.ExecContext(ctx, "insert into user_role (user_id, role_id) values (?, ?)", userID, roleID); err != nil {
+						return err
+					}
+
+					if _, err := tx.ExecContext(ctx, "release savepoint create_user"); err != nil {
+						return err
+					}
+					continue
+				}
+
+				if !isDuplicateEntry(err, mysqlUsersUsernameKey) {
+					return err
+				}
+				userErrs[i] = app.ErrUserAlreadyExists
+			}
+
+			if _, err := tx.ExecContext(ctx, "rollback to savepoint create_user"); err != nil {
+				return err
+			}
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return userErrs, nil
+}
+
+func insertUser(ctx context.Context, tx *sqlx.Tx, user app.User) (string, error) {
+	userID, err := newUUID()
+	if err != nil {
+		return "", err
+	}
+
+	status := user.Status
+	if status == "" {
+		status = app.StatusActive
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		insert into users (user_id, username, password_hash, display_name, avatar_url, locale, timezone, status, status_changed_at)
+		values (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		userID, user.Username, user.Password, user.DisplayName, user.AvatarURL, user.Locale, user.Timezone, status, time.Now().UTC().Truncate(time.Microsecond))
+	if err != nil {
+		return "", err
+	}
+
+	return userID, nil
+}
+
+func (m *mysqlUserDB) GetUsers(ctx context.Context) ([]app.User, error) {
+	var users []app.User
+
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		if orgID := app.OrganizationFromContext(ctx); orgID != "" {
+			return m.db.SelectContext(ctx, &users, `
+				select u.user_id, u.username, u.password_hash, u.display_name, u.avatar_url, u.locale, u.timezone, u.status, u.status_reason, u.status_changed_at, r.name as role
+				from users u
+				inner join organization_member om on u.user_id = om.user_id
+				inner join role r on om.role_id = r.id
+				where om.org_id = ?`, orgID)
+		}
+
+		return m.db.SelectContext(ctx, &users, `
+			select u.user_id, u.username, u.password_hash, u.display_name, u.avatar_url, u.locale, u.timezone, u.status, u.status_reason, u.status_changed_at, r.name as role
+			from users u
+			inner join user_role ur on u.user_id = ur.user_id
+			inner join role r on ur.role_id = r.id`)
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return users, nil
+}
+
+func (m *mysqlUserDB) ListUsers(ctx context.Context, filter app.UserFilter) ([]app.User, error) {
+	from, args := usersMatching(ctx, filter)
+
+	// Usernames use a binary collation, so that the keyset cursor compares
+	// them bytewise like the Postgres listing does.
+	query := "select u.user_id, u.username, u.password_hash, u.display_name, u.avatar_url, u.locale, u.timezone, u.status, u.status_reason, u.status_changed_at, r.name as role" + from
+
+	order := "asc"
+	if filter.After != "" {
+		operator := ">"
+		if filter.Descending {
+			operator = "<"
+		}
+		query += " and u.username " + operator + " ?"
+		args = append(args, filter.After)
+	}
+	if filter.Descending {
+		order = "desc"
+	}
+	query += " order by u.username " + order
+
+	if filter.Limit > 0 {
+		query += " limit ?"
+		args = append(args, filter.Limit)
+	}
+
+	// MySQL only takes an offset after a limit, so an unlimited listing is
+	// given the largest one.
+	if filter.Offset > 0 {
+		if filter.Limit <= 0 {
+			query += " limit 18446744073709551615"
+		}
+		query += " offset ?"
+		args = append(args, filter.Offset)
+	}
+
+	users := []app.User{}
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		return m.db.SelectContext(ctx, &users, query, args...)
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return users, nil
+}
+
+func (m *mysqlUserDB) CountUsers(ctx context.Context, filter app.UserFilter) (int, error) {
+	from, args := usersMatching(ctx, filter)
+
+	var count int
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		return m.db.GetContext(ctx, &count, "select count(*)"+from, args...)
+	})
+
+	if err != nil {
+		return 0, storageError(err)
+	}
+
+	return count, nil
+}
+
+// usersMatching returns the from and where clauses selecting the users of the
+// organization in ctx, or all users, that have filter's role and username
+// prefix.
+func usersMatching(ctx context.Context, filter app.UserFilter) (string, []interface{}) {
+	var args []interface{}
+
+	var query string
+	if orgID := app.OrganizationFromContext(ctx); orgID != "" {
+		query = `
+			from users u
+			inner join organization_member om on u.user_id = om.user_id
+			inner join role r on om.role_id = r.id
+			where om.org_id = ?`
+		args = append(args, orgID)
+	} else {
+		query = `
+			from users u
+			inner join user_role ur on u.user_id = ur.user_id
+			inner join role r on ur.role_id = r.id
+			where true`
+	}
+
+	if filter.Role != "" {
+		query += " and r.name = ?"
+		args = append(args, filter.Role)
+	}
+
+	if filter.UsernamePrefix != "" {
+		query += ` and u.username like ? escape '\\'`
+		args = append(args, escapeLike(filter.UsernamePrefix)+"%")
+	}
+
+	return query, args
+}
+
+// escapeLike escapes the wildcards of a like pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func (m *mysqlUserDB) GetUserByUsername(ctx context.Context, username string) (app.User, error) {
+	return m.getUser(ctx, "u.username = ?", username)
+}
+
+func (m *mysqlUserDB) GetUserByIdentifier(ctx context.Context, identifier string) (app.User, error) {
+	return m.getUser(ctx, `
+		u.username = ?
+		or u.user_id = (select ui.user_id from user_identifier ui where ui.verified_value = ?)`, identifier, identifier)
+}
+
+func (m *mysqlUserDB) GetUserByID(ctx context.Context, userID string) (app.User, error) {
+	return m.getUser(ctx, "u.user_id = ?", userID)
+}
+
+// getUser returns the user matching where. Scoped to an organization, only
+// its members match and the role is the one they hold in it.
+func (m *mysqlUserDB) getUser(ctx context.Context, where string, args ...interface{}) (app.User, error) {
+	query := `
+		select u.user_id, u.username, u.password_hash, u.display_name, u.avatar_url, u.locale, u.timezone, u.status, u.status_reason, u.status_changed_at, r.name as role
+		from users u
+		inner join user_role ur on u.user_id = ur.user_id
+		inner join role r on ur.role_id = r.id
+		where ` + where
+	if orgID := app.OrganizationFromContext(ctx); orgID != "" {
+		query = `
+			select u.user_id, u.username, u.password_hash, u.display_name, u.avatar_url, u.locale, u.timezone, u.status, u.status_reason, u.status_changed_at, r.name as role
+			from users u
+			inner join organization_member om on u.user_id = om.user_id
+			inner join role r on om.role_id = r.id
+			where (` + where + `) and om.org_id = ?`
+		args = append(args, orgID)
+	}
+
+	var user app.User
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		err := m.db.GetContext(ctx, &user, query, args...)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return app.User{}, storageError(err)
+	}
+
+	return user, nil
+}
+
+func (m *mysqlUserDB) UpdateUser(ctx context.Context, user app.User) error {
+	var outErr error
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		tx, err := m.db.BeginTxx(ctx, &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		// MySQL counts changed rows rather than matched ones, so an update
+		// to the current values can't tell a missing user apart.
+		var exists bool
+		if err := tx.GetContext(ctx, &exists, "select exists (select 1 from users where user_id = ? for update)", user.UserID); err != nil {
+			return err
+		}
+
+		if !exists {
+			outErr = app.ErrUserNotFound
+			return nil
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			update users
+			set username = ?, password_hash = ?, display_name = ?, avatar_url = ?, locale = ?, timezone = ?
+			where user_id = ?`, user.Username, user.Password, user.DisplayName, user.AvatarURL, user.Locale, user.Timezone, user.UserID)
+		if err != nil {
+			if isDuplicateEntry(err, mysqlUsersUsernameKey) {
+				outErr = app.ErrUserAlreadyExists
+				return nil
+			}
+			return err
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (m *mysqlUserDB) UpdateUserStatus(ctx context.Context, userID string, change app.StatusChange) error {
+	var outErr error
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		res, err := m.db.ExecContext(ctx, `
+			update users
+			set status = ?, status_reason = ?, status_changed_at = ?
+			where user_id = ? and status = ?`, change.To, change.Reason, change.At, userID, change.From)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			var exists bool
+			if err := m.db.GetContext(ctx, &exists, "select exists (select 1 from users where user_id = ?)", userID); err != nil {
+				return err
+			}
+
+			outErr = app.ErrAccountStatusConflict
+			if !exists {
+				outErr = app.ErrUserNotFound
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (m *mysqlUserDB) DeleteUser(ctx context.Context, userID string) error {
+	var outErr error
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		tx, err := m.db.BeginTxx(ctx, &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		role, err := lockUserRole(ctx, tx, userID)
+		if err == sql.ErrNoRows {
+			outErr = app.ErrUserNotFound
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if role == app.AdminRole {
+			last, err := lockLastAdmin(ctx, tx)
+			if err != nil {
+				return err
+			}
+
+			if last {
+				outErr = app.ErrLastAdministrator
+				return nil
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, "delete from users where user_id = ?", userID); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (m *mysqlUserDB) UpdateUserRole(ctx context.Context, userID string, role app.Role) (app.Role, error) {
+	var previous app.Role
+	var outErr error
+	err := m.circuit.Run(ctx, func(c context.Context) error {
+		tx, err := m.db.BeginTxx(ctx, &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var roleID int
+		err = tx.GetContext(ctx, &roleID, "select id from role where name = ?", role)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				outErr = app.ErrRoleNotFound
+				return nil
+			}
+			return err
+		}
+
+		previous, err = lockUserRole(ctx, tx, userID)
+		if err == sql.ErrNoRows {
+			outErr = app.ErrUserNotFound
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if previous == app.AdminRole && role != app.AdminRole {
+			last, err := lockLastAdmin(ctx, tx)
+			if err != nil {
+				return err
+			}
+
+			if last {
+				outErr = app.ErrLastAdministrator
+				return nil
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, "update user_role set role_id = ? where user_id = ?", roleID, userID); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return "", storageError(err)
+	}
+
+	if outErr != nil {
+		return "", outErr
+	}
+
+	return previous, nil
+}
+
+func lockUserRole(ctx context.Context, tx *sqlx.Tx, userID string) (app.Role, error) {
+	var role app.Role
+	err := tx.GetContext(ctx, &role, `
+		select r.name
+		from user_role ur
+		inner join role r on ur.role_id = r.id
+		where ur.user_id = ?
+		for update`, userID)
+	return role, err
+}
+
+// lockLastAdmin locks the administrators' role assignments, so that two
+// demotions can't both see the other administrator.
+func lockLastAdmin(ctx context.Context, tx *sqlx.Tx) (bool, error) {
+	var admins []string
+	err := tx.SelectContext(ctx, &admins, `
+		select ur.user_id
+		from user_role ur
+		inner join role r on ur.role_id = r.id
+		where r.name = ?
+		for update`, app.AdminRole)
+	if err != nil {
+		return false, err
+	}
+
+	return len(admins) <= 1, nil
+}
+
+// newUUID generates the random IDs Postgres generates with gen_random_uuid.
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,158 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/rislah/fakes/internal/errors"
+)
+
+const maxOrganizationNameLength = 100
+
+type OrganizationBackend interface {
+	// CreateOrganization creates an organization and makes ownerID its
+	// administrator.
+	CreateOrganization(ctx context.Context, name string, ownerID string) (Organization, error)
+	AddMember(ctx context.Context, membership Membership) error
+	GetMembership(ctx context.Context, orgID string, userID string) (Membership, error)
+	GetMemberships(ctx context.Context, userID string) ([]Membership, error)
+}
+
+type OrganizationDB interface {
+	// CreateOrganization stores org, generating its ID when empty.
+	CreateOrganization(ctx context.Context, org Organization) (Organization, error)
+	// GetOrganization returns an empty Organization when it doesn't exist.
+	GetOrganization(ctx context.Context, orgID string) (Organization, error)
+	AddMember(ctx context.Context, membership Membership) error
+	// GetMembership returns an empty Membership when the user isn't a member.
+	GetMembership(ctx context.Context, orgID string, userID string) (Membership, error)
+	GetMemberships(ctx context.Context, userID string) ([]Membership, error)
+}
+
+type Organization struct {
+	OrgID string `db:"org_id"`
+	Name  string `db:"name"`
+}
+
+func (o Organization) IsEmpty() bool {
+	return o.OrgID == ""
+}
+
+// Membership is a user's role within an organization.
+type Membership struct {
+	OrgID  string `db:"org_id"`
+	UserID string `db:"user_id"`
+	Role   Role   `db:"role"`
+}
+
+func (m Membership) IsEmpty() bool {
+	return m.OrgID == "" || m.UserID == ""
+}
+
+type organizationContextKey struct{}
+
+// WithOrganization scopes ctx to an organization. UserDB implementations
+// restrict their queries and lookups to its members.
+func WithOrganization(ctx context.Context, orgID string) context.Context {
+	return context.WithValue(ctx, organizationContextKey{}, orgID)
+}
+
+// WithoutOrganization lifts the organization scope of ctx, for lookups of a
+// user's own identity, such as its global role.
+func WithoutOrganization(ctx context.Context) context.Context {
+	return WithOrganization(ctx, "")
+}
+
+// OrganizationFromContext returns the organization ctx is scoped to, if any.
+func OrganizationFromContext(ctx context.Context) string {
+	orgID, _ := ctx.Value(organizationContextKey{}).(string)
+	return orgID
+}
+
+type organizationImpl struct {
+	orgDB OrganizationDB
+}
+
+func NewOrganizationBackend(db OrganizationDB) OrganizationBackend {
+	if db == nil {
+		panic("database is required")
+	}
+
+	return &organizationImpl{orgDB: db}
+}
+
+func (o organizationImpl) CreateOrganization(ctx context.Context, name string, ownerID string) (Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxOrganizationNameLength {
+		return Organization{}, ErrOrganizationNameInvalid
+	}
+
+	org, err := o.orgDB.CreateOrganization(ctx, Organization{Name: name})
+	if err != nil {
+		return Organization{}, err
+	}
+
+	err = o.orgDB.AddMember(ctx, Membership{
+		OrgID:  org.OrgID,
+		UserID: ownerID,
+		Role:   AdminRole,
+	})
+	if err != nil {
+		return Organization{}, err
+	}
+
+	return org, nil
+}
+
+func (o organizationImpl) AddMember(ctx context.Context, membership Membership) error {
+	if membership.UserID == "" {
+		return ErrUserNotFound
+	}
+
+	if err := (RoleDefinition{Name: membership.Role}).Valid(); err != nil {
+		return err
+	}
+
+	return o.orgDB.AddMember(ctx, membership)
+}
+
+func (o organizationImpl) GetMembership(ctx context.Context, orgID string, userID string) (Membership, error) {
+	membership, err := o.orgDB.GetMembership(ctx, orgID, userID)
+	if err != nil {
+		return Membership{}, err
+	}
+
+	if membership.IsEmpty() {
+		return Membership{}, ErrMembershipNotFound
+	}
+
+	return membership, nil
+}
+
+func (o organizationImpl) GetMemberships(ctx context.Context, userID string) ([]Membership, error) {
+	return o.orgDB.GetMemberships(ctx, userID)
+}
+
+var (
+	ErrOrganizationNotFound = &errors.WrappedError{
+		Code: errors.ErrNotFound,
+		Msg:  "Organization not found",
+	}
+	ErrOrganizationAlreadyExists = &errors.WrappedError{
+		Code: errors.ErrConflict,
+		Msg:  "Organization already exists",
+	}
+	ErrOrganizationNameInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Organization name must be between 1 and 100 characters",
+	}
+	ErrMemberAlreadyExists = &errors.WrappedError{
+		Code: errors.ErrConflict,
+		Msg:  "User is already a member of the organization",
+	}
+	ErrMembershipNotFound = &errors.WrappedError{
+		Code: errors.ErrNotFound,
+		Msg:  "User is not a member of the organization",
+	}
+)
@@ -0,0 +1,17 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/sqlite"
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestLocalOrganizationDB(t *testing.T) {
+	tests.TestOrganizationDB(t, local.MakeOrganizationDB)
+}
+
+func TestSQLiteOrganizationDB(t *testing.T) {
+	tests.TestOrganizationDB(t, sqlite.MakeOrganizationDB)
+}
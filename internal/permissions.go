@@ -1,41 +1,55 @@
 package app
 
-import "sort"
-
 const (
-	ViewTest = "viewTest"
-)
+	ViewTest    = "viewTest"
+	ManageRoles = "manageRoles"
+	ManageUsers = "manageUsers"
+	AssignRoles = "assignRoles"
 
-func init() {
-	extendPermissions()
-	for role, permissions := range permissionsByRole {
-		sort.Strings(permissions)
-		permissionsByRole[role] = permissions
-	}
-}
+	ExplainAuthorization = "explainAuthorization"
+	ManageOrganizations  = "manageOrganizations"
+	ManageMembers        = "manageMembers"
+	ManageGroups         = "manageGroups"
+	ViewRouteManifest    = "viewRouteManifest"
+	ManageAccountStatus  = "manageAccountStatus"
+	ViewAuditLog         = "viewAuditLog"
+	ManageInvites        = "manageInvites"
+)
 
-var permissionsByRole = map[Role][]string{
-	GuestRole: {
-		ViewTest,
+// defaultRoles mirrors the roles seeded by the migrations:
+// guest < user < developer < admin.
+var defaultRoles = []RoleDefinition{
+	{
+		Name:        GuestRole,
+		Permissions: []string{ViewTest},
+	},
+	{
+		Name:    UserRole,
+		Parents: []Role{GuestRole},
+	},
+	{
+		Name:        DeveloperRole,
+		Parents:     []Role{UserRole},
+		Permissions: []string{ManageUsers},
+	},
+	{
+		Name:        AdminRole,
+		Parents:     []Role{DeveloperRole},
+		Permissions: []string{AssignRoles, ExplainAuthorization, ManageAccountStatus, ManageGroups, ManageInvites, ManageMembers, ManageOrganizations, ManageRoles, ViewAuditLog, ViewRouteManifest},
 	},
-	UserRole:      {},
-	DeveloperRole: {},
-}
-
-func extendPermissions() {
-	permissions := []string{}
-	for k, v := range permissionsByRole {
-		permissions = append(permissions, v...)
-		permissionsByRole[k] = permissions
-	}
 }
 
-func DoesRoleHavePermission(role Role, permission string) bool {
-	permissions, ok := permissionsByRole[role]
-	if !ok {
-		return false
+// DefaultRoles returns the built-in role definitions used to seed role
+// storage that isn't backed by the migrations.
+func DefaultRoles() []RoleDefinition {
+	roles := make([]RoleDefinition, 0, len(defaultRoles))
+	for _, role := range defaultRoles {
+		roles = append(roles, RoleDefinition{
+			Name:        role.Name,
+			Parents:     append([]Role{}, role.Parents...),
+			Permissions: append([]string{}, role.Permissions...),
+		})
 	}
 
-	i := sort.SearchStrings(permissions, permission)
-	return i < len(permissions) && permissions[i] == permission
+	return roles
 }
@@ -0,0 +1,425 @@
+package policy
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// node is a parsed expression that evaluates to a string, float64, bool,
+// []interface{} or nil against a request.
+type node interface {
+	eval(req Request) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n literalNode) eval(req Request) (interface{}, error) {
+	return n.value, nil
+}
+
+type attributeNode struct {
+	path string
+}
+
+func (n attributeNode) eval(req Request) (interface{}, error) {
+	return req.attribute(n.path), nil
+}
+
+type listNode struct {
+	items []node
+}
+
+func (n listNode) eval(req Request) (interface{}, error) {
+	values := make([]interface{}, 0, len(n.items))
+	for _, item := range n.items {
+		v, err := item.eval(req)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+
+	return values, nil
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n notNode) eval(req Request) (interface{}, error) {
+	v, err := evalBool(n.operand, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return !v, nil
+}
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+func (n logicalNode) eval(req Request) (interface{}, error) {
+	left, err := evalBool(n.left, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.op == "&&" && !left {
+		return false, nil
+	}
+
+	if n.op == "||" && left {
+		return true, nil
+	}
+
+	return evalBool(n.right, req)
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n compareNode) eval(req Request) (interface{}, error) {
+	left, err := n.left.eval(req)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := n.right.eval(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "in":
+		list, ok := right.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("right operand of in must be a list")
+		}
+		for _, item := range list {
+			if equal(left, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	if left == nil || right == nil {
+		return false, nil
+	}
+
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare number with %T", right)
+		}
+		return compareOrdered(n.op, l < r, l == r), nil
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare string with %T", right)
+		}
+		return compareOrdered(n.op, l < r, l == r), nil
+	default:
+		return nil, fmt.Errorf("cannot order %T", left)
+	}
+}
+
+type callNode struct {
+	name string
+	args []node
+}
+
+var functions = map[string]int{
+	"cidr":       2,
+	"startsWith": 2,
+}
+
+func (n callNode) eval(req Request) (interface{}, error) {
+	args := make([]string, 0, len(n.args))
+	for _, arg := range n.args {
+		v, err := arg.eval(req)
+		if err != nil {
+			return nil, err
+		}
+
+		s, _ := v.(string)
+		args = append(args, s)
+	}
+
+	switch n.name {
+	case "cidr":
+		ip := net.ParseIP(args[0])
+		_, network, err := net.ParseCIDR(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return ip != nil && network.Contains(ip), nil
+	case "startsWith":
+		return strings.HasPrefix(args[0], args[1]), nil
+	default:
+		return nil, fmt.Errorf("unknown function %s", n.name)
+	}
+}
+
+func evalBool(n node, req Request) (bool, error) {
+	v, err := n.eval(req)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected boolean, got %T", v)
+	}
+
+	return b, nil
+}
+
+func equal(left, right interface{}) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+
+	switch l := left.(type) {
+	case string, float64, bool:
+		return l == right
+	default:
+		return false
+	}
+}
+
+func compareOrdered(op string, less, eq bool) bool {
+	switch op {
+	case "<":
+		return less
+	case "<=":
+		return less || eq
+	case ">":
+		return !less && !eq
+	case ">=":
+		return !less
+	default:
+		return false
+	}
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func parse(input string) (node, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", tok.value, tok.pos)
+	}
+
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) expect(kind tokenKind, value string) error {
+	tok := p.next()
+	if tok.kind != kind {
+		return fmt.Errorf("expected %q at %d, got %q", value, tok.pos, tok.value)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for tok := p.peek(); tok.kind == tokenOperator && tok.value == "||"; tok = p.peek() {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "||", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for tok := p.peek(); tok.kind == tokenOperator && tok.value == "&&"; tok = p.peek() {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "&&", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if tok := p.peek(); tok.kind == tokenOperator && tok.value == "!" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	isCompare := tok.kind == tokenOperator && tok.value != "&&" && tok.value != "||" && tok.value != "!"
+	isIn := tok.kind == tokenIdent && tok.value == "in"
+	if !isCompare && !isIn {
+		return left, nil
+	}
+
+	p.next()
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	return compareNode{op: tok.value, left: left, right: right}, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenString:
+		return literalNode{tok.value}, nil
+	case tokenNumber:
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at %d", tok.value, tok.pos)
+		}
+		return literalNode{f}, nil
+	case tokenLParen:
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenRParen, ")"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case tokenLBracket:
+		var items []node
+		for p.peek().kind != tokenRBracket {
+			item, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect(tokenRBracket, "]"); err != nil {
+			return nil, err
+		}
+		return listNode{items}, nil
+	case tokenIdent:
+		switch tok.value {
+		case "true":
+			return literalNode{true}, nil
+		case "false":
+			return literalNode{false}, nil
+		case "null":
+			return literalNode{nil}, nil
+		}
+
+		if p.peek().kind == tokenLParen {
+			return p.parseCall(tok)
+		}
+
+		if !isAttribute(tok.value) {
+			return nil, fmt.Errorf("unknown attribute %q at %d", tok.value, tok.pos)
+		}
+		return attributeNode{tok.value}, nil
+	default:
+		return nil, fmt.Errorf("unexpected %q at %d", tok.value, tok.pos)
+	}
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	arity, ok := functions[name.value]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at %d", name.value, name.pos)
+	}
+
+	p.next()
+	var args []node
+	for p.peek().kind != tokenRParen {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+
+		if p.peek().kind != tokenComma {
+			break
+		}
+		p.next()
+	}
+
+	if err := p.expect(tokenRParen, ")"); err != nil {
+		return nil, err
+	}
+
+	if len(args) != arity {
+		return nil, fmt.Errorf("%s expects %d arguments, got %d", name.value, arity, len(args))
+	}
+
+	return callNode{name: name.value, args: args}, nil
+}
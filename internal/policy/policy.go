@@ -0,0 +1,171 @@
+// Package policy implements attribute-based access policies. A policy is a
+// boolean expression over four attribute namespaces:
+//
+//	subject.*     claims of the authenticated caller (subject.role, subject.username)
+//	resource.*    attributes of the requested resource (route variables)
+//	action        the requested action (HTTP method)
+//	environment.* request context (environment.ip, environment.country,
+//	              environment.hour, environment.weekday); env.* is an alias
+//
+// Expressions support string, number, boolean and list literals, the
+// comparison operators == != < <= > >= and in, the logical operators
+// && || ! and the functions cidr(ip, range) and startsWith(s, prefix), e.g.
+//
+//	subject.role == "developer" && cidr(environment.ip, "10.0.0.0/8")
+package policy
+
+import (
+	"strings"
+
+	"github.com/rislah/fakes/internal/errors"
+)
+
+type Effect string
+
+const (
+	Allow Effect = "allow"
+	Deny  Effect = "deny"
+)
+
+type Policy struct {
+	Name      string
+	Effect    Effect
+	Condition string
+	expr      node
+}
+
+func New(name string, effect Effect, condition string) (*Policy, error) {
+	if effect != Allow && effect != Deny {
+		return nil, errors.New("policy effect must be allow or deny", errors.Fields{"policy": name})
+	}
+
+	expr, err := parse(condition)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing policy condition", errors.Fields{"policy": name})
+	}
+
+	return &Policy{
+		Name:      name,
+		Effect:    effect,
+		Condition: condition,
+		expr:      expr,
+	}, nil
+}
+
+func MustNew(name string, effect Effect, condition string) *Policy {
+	p, err := New(name, effect, condition)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Matches reports whether the policy condition holds for the request.
+func (p *Policy) Matches(req Request) (bool, error) {
+	return evalBool(p.expr, req)
+}
+
+type Request struct {
+	Subject     map[string]interface{}
+	Resource    map[string]interface{}
+	Action      string
+	Environment map[string]interface{}
+}
+
+func (r Request) attribute(path string) interface{} {
+	if path == "action" {
+		return r.Action
+	}
+
+	root, key := splitAttribute(path)
+	var attrs map[string]interface{}
+	switch root {
+	case "subject":
+		attrs = r.Subject
+	case "resource":
+		attrs = r.Resource
+	case "environment", "env":
+		attrs = r.Environment
+	}
+
+	switch v := attrs[key].(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	default:
+		return v
+	}
+}
+
+func isAttribute(path string) bool {
+	if path == "action" {
+		return true
+	}
+
+	root, key := splitAttribute(path)
+	switch root {
+	case "subject", "resource", "environment", "env":
+		return key != ""
+	default:
+		return false
+	}
+}
+
+func splitAttribute(path string) (string, string) {
+	i := strings.Index(path, ".")
+	if i == -1 {
+		return path, ""
+	}
+	return path[:i], path[i+1:]
+}
+
+// Decision is the combined outcome of a set of policies. Policy names the
+// policy that determined the outcome, if any.
+type Decision struct {
+	Allowed bool
+	Policy  string
+}
+
+// Evaluate combines policies with deny-overrides semantics: a matching deny
+// policy always denies; otherwise, if any allow policies are present, at
+// least one of them must match. A policy that fails to evaluate denies.
+func Evaluate(policies []*Policy, req Request) (Decision, error) {
+	hasAllow := false
+	for _, p := range policies {
+		if p.Effect != Deny {
+			hasAllow = true
+			continue
+		}
+
+		matches, err := p.Matches(req)
+		if err != nil {
+			return Decision{Allowed: false, Policy: p.Name}, errors.Wrap(err, "evaluating policy", errors.Fields{"policy": p.Name})
+		}
+
+		if matches {
+			return Decision{Allowed: false, Policy: p.Name}, nil
+		}
+	}
+
+	if !hasAllow {
+		return Decision{Allowed: true}, nil
+	}
+
+	for _, p := range policies {
+		if p.Effect != Allow {
+			continue
+		}
+
+		matches, err := p.Matches(req)
+		if err != nil {
+			return Decision{Allowed: false, Policy: p.Name}, errors.Wrap(err, "evaluating policy", errors.Fields{"policy": p.Name})
+		}
+
+		if matches {
+			return Decision{Allowed: true, Policy: p.Name}, nil
+		}
+	}
+
+	return Decision{Allowed: false}, nil
+}
@@ -0,0 +1,148 @@
+package policy_test
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRequest() policy.Request {
+	return policy.Request{
+		Subject: map[string]interface{}{
+			"id":       "11111111-1111-1111-1111-111111111111",
+			"username": "developer1",
+			"role":     "developer",
+		},
+		Resource: map[string]interface{}{
+			"user_id": "11111111-1111-1111-1111-111111111111",
+		},
+		Action: "GET",
+		Environment: map[string]interface{}{
+			"ip":      "10.1.2.3",
+			"country": "EE",
+			"hour":    14,
+			"weekday": "Monday",
+		},
+	}
+}
+
+func TestPolicyMatches(t *testing.T) {
+	tests := []struct {
+		condition string
+		matches   bool
+	}{
+		{`subject.role == "developer"`, true},
+		{`subject.role != "developer"`, false},
+		{`subject.role in ["user", "developer"]`, true},
+		{`subject.role in ["guest"]`, false},
+		{`subject.id == resource.user_id`, true},
+		{`action == "GET" && environment.country == "EE"`, true},
+		{`action == "POST" || env.country == "EE"`, true},
+		{`!(env.country == "EE")`, false},
+		{`cidr(environment.ip, "10.0.0.0/8")`, true},
+		{`cidr(environment.ip, "192.168.0.0/16")`, false},
+		{`environment.hour >= 9 && environment.hour < 17`, true},
+		{`environment.hour > 14`, false},
+		{`startsWith(subject.username, 'dev')`, true},
+		{`subject.missing == null`, true},
+		{`subject.missing == "x"`, false},
+		{`subject.missing < 5`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.condition, func(t *testing.T) {
+			p, err := policy.New("test", policy.Allow, test.condition)
+			assert.NoError(t, err)
+
+			matches, err := p.Matches(testRequest())
+			assert.NoError(t, err)
+			assert.Equal(t, test.matches, matches)
+		})
+	}
+}
+
+func TestPolicyParseErrors(t *testing.T) {
+	conditions := []string{
+		`subject.role ==`,
+		`unknown.attr == "x"`,
+		`subject.role == "unterminated`,
+		`nosuchfunc(subject.role)`,
+		`cidr(environment.ip)`,
+		`(subject.role == "x"`,
+		`subject.role == "x" extra`,
+	}
+
+	for _, condition := range conditions {
+		t.Run(condition, func(t *testing.T) {
+			_, err := policy.New("test", policy.Allow, condition)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := policy.New("test", "maybe", `action == "GET"`)
+	assert.Error(t, err)
+}
+
+func TestEvaluate(t *testing.T) {
+	officeOnly := policy.MustNew("office_only", policy.Allow, `cidr(environment.ip, "10.0.0.0/8")`)
+	ownRecord := policy.MustNew("own_record", policy.Allow, `subject.id == resource.user_id`)
+	blockCountry := policy.MustNew("block_country", policy.Deny, `environment.country in ["EE"]`)
+	blockPost := policy.MustNew("block_post", policy.Deny, `action == "POST"`)
+	typeError := policy.MustNew("type_error", policy.Deny, `subject.role`)
+
+	tests := []struct {
+		name     string
+		policies []*policy.Policy
+		decision policy.Decision
+		err      bool
+	}{
+		{
+			name:     "no policies allow",
+			decision: policy.Decision{Allowed: true},
+		},
+		{
+			name:     "matching allow",
+			policies: []*policy.Policy{officeOnly},
+			decision: policy.Decision{Allowed: true, Policy: "office_only"},
+		},
+		{
+			name:     "any allow is enough",
+			policies: []*policy.Policy{policy.MustNew("nope", policy.Allow, "false"), ownRecord},
+			decision: policy.Decision{Allowed: true, Policy: "own_record"},
+		},
+		{
+			name:     "no matching allow denies",
+			policies: []*policy.Policy{policy.MustNew("nope", policy.Allow, "false")},
+			decision: policy.Decision{Allowed: false},
+		},
+		{
+			name:     "deny overrides allow",
+			policies: []*policy.Policy{officeOnly, blockCountry},
+			decision: policy.Decision{Allowed: false, Policy: "block_country"},
+		},
+		{
+			name:     "non matching deny without allows",
+			policies: []*policy.Policy{blockPost},
+			decision: policy.Decision{Allowed: true},
+		},
+		{
+			name:     "evaluation error denies",
+			policies: []*policy.Policy{typeError},
+			decision: policy.Decision{Allowed: false, Policy: "type_error"},
+			err:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decision, err := policy.Evaluate(test.policies, testRequest())
+			if test.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.decision, decision)
+		})
+	}
+}
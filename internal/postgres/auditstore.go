@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/cep21/circuit/v3"
+	"github.com/jmoiron/sqlx"
+	"github.com/rislah/fakes/internal/audit"
+)
+
+type postgresAuditStore struct {
+	pg      *sqlx.DB
+	circuit *circuit.Circuit
+}
+
+var _ audit.Store = &postgresAuditStore{}
+
+func NewAuditStore(pg *sqlx.DB, cc *circuit.Circuit) (*postgresAuditStore, error) {
+	return &postgresAuditStore{pg: pg, circuit: cc}, nil
+}
+
+const auditEventColumns = "seq, occurred_at, action, actor_id, actor, target_id, ip, details, prev_hash, hash"
+
+// Append holds a transaction-level advisory lock while it reads the last
+// event and inserts its successor, so that concurrent appends can't fork the
+// chain.
+func (p *postgresAuditStore) Append(ctx context.Context, event audit.Event) (audit.Event, error) {
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		tx, err := p.pg.BeginTxx(ctx, &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx, "select pg_advisory_xact_lock(hashtext('audit_event'))"); err != nil {
+			return err
+		}
+
+		var last audit.Event
+		err = tx.GetContext(ctx, &last, "select seq, hash from audit_event order by seq desc limit 1")
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		event = audit.Chain(last, event)
+		_, err = tx.NamedExecContext(ctx, `
+			insert into audit_event (`+auditEventColumns+`)
+			values (:seq, :occurred_at, :action, :actor_id, :actor, :target_id, :ip, :details, :prev_hash, :hash)`, event)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return audit.Event{}, storageError(err)
+	}
+
+	return event, nil
+}
+
+func (p *postgresAuditStore) Search(ctx context.Context, query audit.Query) ([]audit.Event, error) {
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	sqlQuery := "select " + auditEventColumns + " from audit_event where true"
+	if query.ActorID != "" {
+		sqlQuery += " and actor_id = " + arg(query.ActorID)
+	}
+	if query.TargetID != "" {
+		sqlQuery += " and target_id = " + arg(query.TargetID)
+	}
+	if query.Action != "" {
+		sqlQuery += " and action = " + arg(query.Action)
+	}
+	if !query.From.IsZero() {
+		sqlQuery += " and occurred_at >= " + arg(query.From)
+	}
+	if !query.To.IsZero() {
+		sqlQuery += " and occurred_at < " + arg(query.To)
+	}
+	if query.Before != 0 {
+		sqlQuery += " and seq < " + arg(query.Before)
+	}
+	sqlQuery += " order by seq desc limit " + arg(query.Limit)
+
+	events := []audit.Event{}
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		return p.pg.SelectContext(ctx, &events, sqlQuery, args...)
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return events, nil
+}
+
+func (p *postgresAuditStore) Events(ctx context.Context, after int64, limit int) ([]audit.Event, error) {
+	events := []audit.Event{}
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		return p.pg.SelectContext(ctx, &events, "select "+auditEventColumns+" from audit_event where seq > $1 order by seq limit $2", after, limit)
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return events, nil
+}
@@ -3,6 +3,9 @@ package postgres
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/cep21/circuit/v3"
 	"github.com/jmoiron/sqlx"
@@ -34,16 +37,76 @@ func (c cacheKey) String() string {
 	return string(c)
 }
 
+// userCacheVersion is part of the keys users are cached under. Bump it
+// whenever cachedUser changes so entries written by older releases are never
+// read.
+const userCacheVersion = "3"
+
 const (
-	UsersKey cacheKey = "users"
+	UsersKey cacheKey = "users:v" + userCacheVersion
+	// UsersGenerationKey changes whenever users do, retiring every cached
+	// page at once.
+	UsersGenerationKey cacheKey = "users:generation"
+	usersPageKeyPrefix cacheKey = "users:v" + userCacheVersion + ":page"
+
+	usersPageTTL = 5 * time.Minute
 )
 
+// cachedUser is how users are serialized in the cache. It is kept apart from
+// app.User so that changing the latter can't silently change the format.
+type cachedUser struct {
+	UserID      string   `json:"user_id"`
+	Username    string   `json:"username"`
+	Role        app.Role `json:"role"`
+	DisplayName string   `json:"display_name,omitempty"`
+	AvatarURL   string   `json:"avatar_url,omitempty"`
+	Locale      string   `json:"locale,omitempty"`
+	Timezone    string   `json:"timezone,omitempty"`
+
+	Status          app.AccountStatus `json:"status"`
+	StatusReason    string            `json:"status_reason,omitempty"`
+	StatusChangedAt time.Time         `json:"status_changed_at"`
+}
+
+func newCachedUser(usr app.User) cachedUser {
+	return cachedUser{
+		UserID:      usr.UserID,
+		Username:    usr.Username,
+		Role:        usr.Role,
+		DisplayName: usr.DisplayName,
+		AvatarURL:   usr.AvatarURL,
+		Locale:      usr.Locale,
+		Timezone:    usr.Timezone,
+
+		Status:          usr.Status,
+		StatusReason:    usr.StatusReason,
+		StatusChangedAt: usr.StatusChangedAt,
+	}
+}
+
+func (c cachedUser) user() app.User {
+	return app.User{
+		UserID:      c.UserID,
+		Username:    c.Username,
+		Role:        c.Role,
+		DisplayName: c.DisplayName,
+		AvatarURL:   c.AvatarURL,
+		Locale:      c.Locale,
+		Timezone:    c.Timezone,
+
+		Status:          c.Status,
+		StatusReason:    c.StatusReason,
+		StatusChangedAt: c.StatusChangedAt,
+	}
+}
+
 type postgresCachedUserDB struct {
 	userDB *postgresUserDB
 	redis  redis.Client
 }
 
 var _ app.UserDB = &postgresCachedUserDB{}
+var _ app.UserBatchCreator = &postgresCachedUserDB{}
 
 func NewCachedUserDB(pg *sqlx.DB, rd redis.Client, cc *circuit.Circuit) (*postgresCachedUserDB, error) {
 	pgUserDB := &postgresUserDB{pg: pg, circuit: cc}
@@ -56,29 +119,48 @@ func NewCachedUserDB(pg *sqlx.DB, rd redis.Client, cc *circuit.Circuit) (*postgr
 
 func (cdb *postgresCachedUserDB) CreateUser(ctx context.Context, user app.User) error {
 	if err := cdb.userDB.CreateUser(ctx, user); err != nil {
-		return errors.New(err)
+		return err
 	}
-	if err := cdb.redis.Del(UsersKey.String()); err != nil {
-		return errors.New(err)
+	if err := cdb.invalidateUsers(); err != nil {
+		return storageError(err)
 	}
 	return nil
 }
 
+func (cdb *postgresCachedUserDB) CreateUsers(ctx context.Context, users []app.User) ([]error, error) {
+	userErrs, err := cdb.userDB.CreateUsers(ctx, users)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cdb.invalidateUsers(); err != nil {
+		return nil, storageError(err)
+	}
+
+	return userErrs, nil
+}
+
 func (cdb *postgresCachedUserDB) GetUsers(ctx context.Context) ([]app.User, error) {
+	// Only the unscoped listing is cached; membership changes don't go
+	// through this type and couldn't invalidate per-organization entries.
+	if app.OrganizationFromContext(ctx) != "" {
+		return cdb.userDB.GetUsers(ctx)
+	}
+
 	resp, err := cdb.redis.SMembers(ctx, UsersKey.String())
 	if err != nil {
-		return nil, errors.New(err)
+		return nil, storageError(err)
 	}
 
 	if len(resp) > 0 {
 		users := []app.User{}
 		for _, r := range resp {
-			user := app.User{}
-			err := json.Unmarshal([]byte(r), &user)
+			var cached cachedUser
+			err := json.Unmarshal([]byte(r), &cached)
 			if err != nil {
-				return nil, errors.New(err)
+				return nil, storageError(err)
 			}
-			users = append(users, user)
+			users = append(users, cached.user())
 		}
 
 		cacheHit.WithLabelValues("getUsers").Inc()
@@ -88,7 +170,7 @@ func (cdb *postgresCachedUserDB) GetUsers(ctx context.Context) ([]app.User, erro
 
 	users, err := cdb.userDB.GetUsers(ctx)
 	if err != nil {
-		return nil, errors.New(err)
+		return nil, storageError(err)
 	}
 
 	if len(users) > 0 {
@@ -98,15 +180,15 @@ func (cdb *postgresCachedUserDB) GetUsers(ctx context.Context) ([]app.User, erro
 		}
 
 		for _, usr := range users {
-			b, err := json.Marshal(usr.Sanitize())
+			b, err := json.Marshal(newCachedUser(usr))
 			if err != nil {
-				return nil, errors.New(err)
+				return nil, storageError(err)
 			}
 			marshalledUsers = append(marshalledUsers, b)
 		}
 
 		if err := cdb.redis.SAdd(ctx, UsersKey.String(), marshalledUsers...); err != nil {
-			return nil, errors.New(err)
+			return nil, storageError(err)
 		}
 	}
 
@@ -114,6 +196,136 @@ func (cdb *postgresCachedUserDB) GetUsers(ctx context.Context) ([]app.User, erro
 	return users, nil
 }
 
+func (cdb *postgresCachedUserDB) ListUsers(ctx context.Context, filter app.UserFilter) ([]app.User, error) {
+	if app.OrganizationFromContext(ctx) != "" {
+		return cdb.userDB.ListUsers(ctx, filter)
+	}
+
+	generation, err := cdb.redis.Get(UsersGenerationKey.String())
+	if err != nil && !errors.IsWrappedRedisNilError(err) {
+		return nil, storageError(err)
+	}
+
+	f, err := json.Marshal(filter)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	key := fmt.Sprintf("%s:%s:%s", usersPageKeyPrefix, generation, f)
+
+	resp, err := cdb.redis.Get(key)
+	if err != nil && !errors.IsWrappedRedisNilError(err) {
+		return nil, storageError(err)
+	}
+
+	if err == nil {
+		var cached []cachedUser
+		if err := json.Unmarshal([]byte(resp), &cached); err != nil {
+			return nil, storageError(err)
+		}
+
+		users := make([]app.User, 0, len(cached))
+		for _, c := range cached {
+			users = append(users, c.user())
+		}
+
+		cacheHit.WithLabelValues("listUsers").Inc()
+		return users, nil
+	}
+
+	users, err := cdb.userDB.ListUsers(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	cached := make([]cachedUser, 0, len(users))
+	for _, usr := range users {
+		cached = append(cached, newCachedUser(usr))
+	}
+
+	b, err := json.Marshal(cached)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	if err := cdb.redis.Set(key, b, usersPageTTL); err != nil {
+		return nil, storageError(err)
+	}
+
+	cacheMiss.WithLabelValues("listUsers").Inc()
+	return users, nil
+}
+
+// CountUsers isn't cached, as it is only used alongside offset listings.
+func (cdb *postgresCachedUserDB) CountUsers(ctx context.Context, filter app.UserFilter) (int, error) {
+	return cdb.userDB.CountUsers(ctx, filter)
+}
+
+// invalidateUsers drops the cached user listings.
+func (cdb *postgresCachedUserDB) invalidateUsers() error {
+	if err := cdb.redis.Del(UsersKey.String()); err != nil {
+		return err
+	}
+
+	return cdb.redis.Set(UsersGenerationKey.String(), strconv.FormatInt(time.Now().UnixNano(), 10), 0)
+}
+
 func (cdb *postgresCachedUserDB) GetUserByUsername(ctx context.Context, username string) (app.User, error) {
 	return cdb.userDB.GetUserByUsername(ctx, username)
 }
+
+func (cdb *postgresCachedUserDB) GetUserByIdentifier(ctx context.Context, identifier string) (app.User, error) {
+	return cdb.userDB.GetUserByIdentifier(ctx, identifier)
+}
+
+func (cdb *postgresCachedUserDB) UpdateUserRole(ctx context.Context, userID string, role app.Role) (app.Role, error) {
+	previous, err := cdb.userDB.UpdateUserRole(ctx, userID, role)
+	if err != nil {
+		return "", err
+	}
+
+	if err := cdb.invalidateUsers(); err != nil {
+		return "", storageError(err)
+	}
+
+	return previous, nil
+}
+
+func (cdb *postgresCachedUserDB) GetUserByID(ctx context.Context, userID string) (app.User, error) {
+	return cdb.userDB.GetUserByID(ctx, userID)
+}
+
+func (cdb *postgresCachedUserDB) UpdateUser(ctx context.Context, user app.User) error {
+	if err := cdb.userDB.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+
+	if err := cdb.invalidateUsers(); err != nil {
+		return storageError(err)
+	}
+
+	return nil
+}
+
+func (cdb *postgresCachedUserDB) DeleteUser(ctx context.Context, userID string) error {
+	if err := cdb.userDB.DeleteUser(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := cdb.invalidateUsers(); err != nil {
+		return storageError(err)
+	}
+
+	return nil
+}
+
+func (cdb *postgresCachedUserDB) UpdateUserStatus(ctx context.Context, userID string, change app.StatusChange) error {
+	if err := cdb.userDB.UpdateUserStatus(ctx, userID, change); err != nil {
+		return err
+	}
+
+	if err := cdb.invalidateUsers(); err != nil {
+		return storageError(err)
+	}
+
+	return nil
+}
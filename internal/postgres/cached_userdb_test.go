@@ -1,7 +1,32 @@
 package postgres
 
-import "testing"
+import (
+	"encoding/json"
+	"testing"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/stretchr/testify/assert"
+)
 
 func TestLocalCachedUserDB(t *testing.T) {
 
 }
+
+func TestCachedUserOmitsPassword(t *testing.T) {
+	usr := app.User{
+		UserID:      "11111111-1111-1111-1111-111111111111",
+		Username:    "user",
+		Password:    "hash",
+		Role:        app.GuestRole,
+		DisplayName: "User",
+		Locale:      "en-US",
+	}
+
+	b, err := json.Marshal(newCachedUser(usr))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "hash")
+
+	var cached cachedUser
+	assert.NoError(t, json.Unmarshal(b, &cached))
+	assert.Equal(t, usr.Sanitize(), cached.user())
+}
@@ -0,0 +1,167 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/cep21/circuit/v3"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	app "github.com/rislah/fakes/internal"
+)
+
+type postgresErasureDB struct {
+	pg      *sqlx.DB
+	circuit *circuit.Circuit
+}
+
+var _ app.ErasureDB = &postgresErasureDB{}
+
+func NewErasureDB(pg *sqlx.DB, cc *circuit.Circuit) (*postgresErasureDB, error) {
+	return &postgresErasureDB{pg: pg, circuit: cc}, nil
+}
+
+const erasureRequestColumns = "request_id, user_id, status, requested_at, scheduled_for, completed_at"
+
+func (p *postgresErasureDB) CreateErasureRequest(ctx context.Context, request app.ErasureRequest) (app.ErasureRequest, error) {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		err := p.pg.GetContext(ctx, &request.RequestID, `
+			insert into erasure_request (user_id, requested_at, scheduled_for)
+			values ($1, $2, $3)
+			returning request_id`, request.UserID, request.RequestedAt, request.ScheduledFor)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok {
+				switch pqErr.Code {
+				case pqUniqueViolation:
+					outErr = app.ErrErasureAlreadyRequested
+					return nil
+				case pqInvalidTextRepresentation:
+					outErr = app.ErrUserNotFound
+					return nil
+				}
+			}
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return app.ErasureRequest{}, storageError(err)
+	}
+
+	if outErr != nil {
+		return app.ErasureRequest{}, outErr
+	}
+
+	request.Status = app.ErasurePending
+	return request, nil
+}
+
+func (p *postgresErasureDB) GetPendingErasureRequest(ctx context.Context, userID string) (app.ErasureRequest, error) {
+	var request app.ErasureRequest
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		err := p.pg.GetContext(ctx, &request, "select "+erasureRequestColumns+" from erasure_request where user_id = $1 and status = 'pending'", userID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				return nil
+			}
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return app.ErasureRequest{}, storageError(err)
+	}
+
+	return request, nil
+}
+
+func (p *postgresErasureDB) DeletePendingErasureRequest(ctx context.Context, userID string) error {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		res, err := p.pg.ExecContext(ctx, "delete from erasure_request where user_id = $1 and status = 'pending'", userID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				outErr = app.ErrErasureRequestNotFound
+				return nil
+			}
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			outErr = app.ErrErasureRequestNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (p *postgresErasureDB) GetDueErasureRequests(ctx context.Context, now time.Time) ([]app.ErasureRequest, error) {
+	requests := []app.ErasureRequest{}
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		return p.pg.SelectContext(ctx, &requests, `
+			select `+erasureRequestColumns+`
+			from erasure_request
+			where status = 'pending' and scheduled_for <= $1
+			order by scheduled_for, id`, now)
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return requests, nil
+}
+
+func (p *postgresErasureDB) CompleteErasureRequest(ctx context.Context, requestID string, completedAt time.Time) error {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		res, err := p.pg.ExecContext(ctx, `
+			update erasure_request
+			set status = 'completed', completed_at = $2
+			where request_id = $1 and status = 'pending'`, requestID, completedAt)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				outErr = app.ErrErasureRequestNotFound
+				return nil
+			}
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			outErr = app.ErrErasureRequestNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"github.com/lib/pq"
+	"github.com/rislah/fakes/internal/errors"
+)
+
+const (
+	pqForeignKeyViolation       = "23503"
+	pqUniqueViolation           = "23505"
+	pqInvalidTextRepresentation = "22P02"
+	pqSerializationFailure      = "40001"
+	pqDeadlockDetected          = "40P01"
+	pqLockNotAvailable          = "55P03"
+	pqQueryCanceled             = "57014"
+
+	pqUsersUsernameKey = "users_username_key"
+	pqRoleNameKey      = "role_name_key"
+
+	pqClassConnectionException   = "08"
+	pqClassInsufficientResources = "53"
+	pqClassOperatorIntervention  = "57"
+)
+
+// storageError wraps err in the storage error it corresponds to.
+func storageError(err error) error {
+	return errors.Storage(err, classifyPQError)
+}
+
+// classifyPQError maps Postgres error codes that aren't handled by the
+// queries themselves onto storage errors. Only serialization failures and
+// deadlocks are conflicts, as retrying the transaction can succeed;
+// constraint violations the queries don't expect are internal errors.
+func classifyPQError(err error) *errors.WrappedError {
+	pqErr, ok := err.(*pq.Error)
+	if !ok {
+		return nil
+	}
+
+	switch pqErr.Code {
+	case pqSerializationFailure, pqDeadlockDetected:
+		return errors.ErrStorageConflict
+	case pqLockNotAvailable, pqQueryCanceled:
+		return errors.ErrStorageTimeout
+	}
+
+	switch pqErr.Code.Class() {
+	case pqClassConnectionException, pqClassInsufficientResources, pqClassOperatorIntervention:
+		return errors.ErrStorageUnavailable
+	}
+
+	return nil
+}
+
+// isUniqueViolation reports whether err violates the unique constraint.
+func isUniqueViolation(err error, constraint string) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == pqUniqueViolation && pqErr.Constraint == constraint
+}
@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/rislah/fakes/internal/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageError(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind *errors.WrappedError
+	}{
+		{err: &pq.Error{Code: pqSerializationFailure}, kind: errors.ErrStorageConflict},
+		{err: &pq.Error{Code: pqDeadlockDetected}, kind: errors.ErrStorageConflict},
+		{err: &pq.Error{Code: pqQueryCanceled}, kind: errors.ErrStorageTimeout},
+		{err: &pq.Error{Code: "08006"}, kind: errors.ErrStorageUnavailable},
+		{err: &pq.Error{Code: "53300"}, kind: errors.ErrStorageUnavailable},
+		{err: &pq.Error{Code: "57P01"}, kind: errors.ErrStorageUnavailable},
+		{err: context.DeadlineExceeded, kind: errors.ErrStorageTimeout},
+	}
+
+	for _, test := range tests {
+		assert.True(t, errors.IsStorageError(storageError(test.err), test.kind), "%#v", test.err)
+	}
+
+	for _, code := range []pq.ErrorCode{"42601", pqUniqueViolation, pqForeignKeyViolation} {
+		err := storageError(&pq.Error{Code: code})
+		assert.Equal(t, code, errors.Unwrap(err).(*pq.Error).Code, "%s isn't a storage error", code)
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	assert.True(t, isUniqueViolation(&pq.Error{Code: pqUniqueViolation, Constraint: pqUsersUsernameKey}, pqUsersUsernameKey))
+	assert.False(t, isUniqueViolation(&pq.Error{Code: pqUniqueViolation, Constraint: "users_user_id_key"}, pqUsersUsernameKey))
+	assert.False(t, isUniqueViolation(&pq.Error{Code: pqForeignKeyViolation, Constraint: pqUsersUsernameKey}, pqUsersUsernameKey))
+	assert.False(t, isUniqueViolation(&pq.Error{Code: pqSerializationFailure}, pqUsersUsernameKey))
+}
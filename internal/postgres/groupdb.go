@@ -0,0 +1,392 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/cep21/circuit/v3"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	app "github.com/rislah/fakes/internal"
+)
+
+type postgresGroupDB struct {
+	pg      *sqlx.DB
+	circuit *circuit.Circuit
+}
+
+var _ app.GroupDB = &postgresGroupDB{}
+
+func NewGroupDB(pg *sqlx.DB, cc *circuit.Circuit) (*postgresGroupDB, error) {
+	return &postgresGroupDB{pg: pg, circuit: cc}, nil
+}
+
+// groupRow is a group, or one of its roles or permissions, by name.
+type groupRow struct {
+	GroupID string `db:"group_id"`
+	Name    string `db:"name"`
+}
+
+func (p *postgresGroupDB) CreateGroup(ctx context.Context, group app.Group) (app.Group, error) {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		tx, err := p.pg.BeginTxx(ctx, &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if group.GroupID == "" {
+			err = tx.GetContext(ctx, &group.GroupID, "insert into user_group (name) values ($1) returning group_id", group.Name)
+		} else {
+			_, err = tx.ExecContext(ctx, "insert into user_group (group_id, name) values ($1, $2)", group.GroupID, group.Name)
+		}
+
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqUniqueViolation {
+				outErr = app.ErrGroupAlreadyExists
+				return nil
+			}
+			return err
+		}
+
+		if err := setGroupGrants(ctx, tx, group); err != nil {
+			if err == app.ErrRoleNotFound {
+				outErr = err
+				return nil
+			}
+			return err
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return app.Group{}, storageError(err)
+	}
+
+	if outErr != nil {
+		return app.Group{}, outErr
+	}
+
+	group.Roles = append([]app.Role{}, group.Roles...)
+	group.Permissions = append([]string{}, group.Permissions...)
+	return group, nil
+}
+
+func (p *postgresGroupDB) GetGroups(ctx context.Context) ([]app.Group, error) {
+	return p.selectGroups(ctx, "true")
+}
+
+func (p *postgresGroupDB) GetGroup(ctx context.Context, groupID string) (app.Group, error) {
+	groups, err := p.selectGroups(ctx, "g.group_id = $1", groupID)
+	if err != nil {
+		return app.Group{}, err
+	}
+
+	if len(groups) == 0 {
+		return app.Group{}, nil
+	}
+
+	return groups[0], nil
+}
+
+func (p *postgresGroupDB) GetUserGroups(ctx context.Context, userID string) ([]app.Group, error) {
+	return p.selectGroups(ctx, "g.group_id in (select group_id from group_member where user_id = $1)", userID)
+}
+
+func (p *postgresGroupDB) UpdateGroup(ctx context.Context, group app.Group) error {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		tx, err := p.pg.BeginTxx(ctx, &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		res, err := tx.ExecContext(ctx, "update user_group set name = $1 where group_id = $2", group.Name, group.GroupID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok {
+				switch pqErr.Code {
+				case pqUniqueViolation:
+					outErr = app.ErrGroupAlreadyExists
+					return nil
+				case pqInvalidTextRepresentation:
+					outErr = app.ErrGroupNotFound
+					return nil
+				}
+			}
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			outErr = app.ErrGroupNotFound
+			return nil
+		}
+
+		if _, err := tx.ExecContext(ctx, "delete from group_role where group_id = $1", group.GroupID); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "delete from group_permission where group_id = $1", group.GroupID); err != nil {
+			return err
+		}
+
+		if err := setGroupGrants(ctx, tx, group); err != nil {
+			if err == app.ErrRoleNotFound {
+				outErr = err
+				return nil
+			}
+			return err
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (p *postgresGroupDB) DeleteGroup(ctx context.Context, groupID string) error {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		res, err := p.pg.ExecContext(ctx, "delete from user_group where group_id = $1", groupID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				outErr = app.ErrGroupNotFound
+				return nil
+			}
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			outErr = app.ErrGroupNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (p *postgresGroupDB) AddGroupMember(ctx context.Context, groupID string, userID string) error {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		tx, err := p.pg.BeginTxx(ctx, &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var exists bool
+		err = tx.GetContext(ctx, &exists, "select exists (select 1 from user_group where group_id = $1)", groupID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				outErr = app.ErrGroupNotFound
+				return nil
+			}
+			return err
+		}
+
+		if !exists {
+			outErr = app.ErrGroupNotFound
+			return nil
+		}
+
+		_, err = tx.ExecContext(ctx, "insert into group_member (group_id, user_id) values ($1, $2)", groupID, userID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok {
+				switch pqErr.Code {
+				case pqUniqueViolation:
+					outErr = app.ErrGroupMemberAlreadyExists
+					return nil
+				case pqForeignKeyViolation, pqInvalidTextRepresentation:
+					outErr = app.ErrUserNotFound
+					return nil
+				}
+			}
+			return err
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (p *postgresGroupDB) RemoveGroupMember(ctx context.Context, groupID string, userID string) error {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		res, err := p.pg.ExecContext(ctx, "delete from group_member where group_id = $1 and user_id = $2", groupID, userID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				outErr = app.ErrGroupMemberNotFound
+				return nil
+			}
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			outErr = app.ErrGroupMemberNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (p *postgresGroupDB) GetGroupMembers(ctx context.Context, groupID string) ([]string, error) {
+	members := []string{}
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		err := p.pg.SelectContext(ctx, &members, "select user_id from group_member where group_id = $1 order by user_id", groupID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				return nil
+			}
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return members, nil
+}
+
+// selectGroups loads the groups matching condition, a predicate on the
+// user_group table aliased as g, together with their roles and permissions.
+func (p *postgresGroupDB) selectGroups(ctx context.Context, condition string, args ...interface{}) ([]app.Group, error) {
+	var rows []groupRow
+	var roleRows, permissionRows []groupRow
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		err := p.pg.SelectContext(ctx, &rows, "select g.group_id, g.name from user_group g where "+condition+" order by g.name", args...)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				return nil
+			}
+			return err
+		}
+
+		err = p.pg.SelectContext(ctx, &roleRows, `
+			select g.group_id, r.name
+			from user_group g
+			inner join group_role gr on gr.group_id = g.group_id
+			inner join role r on r.id = gr.role_id
+			where `+condition+`
+			order by r.name`, args...)
+		if err != nil {
+			return err
+		}
+
+		return p.pg.SelectContext(ctx, &permissionRows, `
+			select g.group_id, pm.name
+			from user_group g
+			inner join group_permission gp on gp.group_id = g.group_id
+			inner join permission pm on pm.id = gp.permission_id
+			where `+condition+`
+			order by pm.name`, args...)
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	indexByID := map[string]int{}
+	groups := make([]app.Group, 0, len(rows))
+	for _, row := range rows {
+		indexByID[row.GroupID] = len(groups)
+		groups = append(groups, app.Group{GroupID: row.GroupID, Name: row.Name, Roles: []app.Role{}, Permissions: []string{}})
+	}
+
+	for _, row := range roleRows {
+		if i, ok := indexByID[row.GroupID]; ok {
+			groups[i].Roles = append(groups[i].Roles, app.Role(row.Name))
+		}
+	}
+
+	for _, row := range permissionRows {
+		if i, ok := indexByID[row.GroupID]; ok {
+			groups[i].Permissions = append(groups[i].Permissions, row.Name)
+		}
+	}
+
+	return groups, nil
+}
+
+func setGroupGrants(ctx context.Context, tx *sqlx.Tx, group app.Group) error {
+	for _, role := range group.Roles {
+		res, err := tx.ExecContext(ctx, `
+			insert into group_role (group_id, role_id)
+			select $1, id from role where name = $2
+			on conflict do nothing`, group.GroupID, role)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			var exists bool
+			if err := tx.GetContext(ctx, &exists, "select exists (select 1 from role where name = $1)", role); err != nil {
+				return err
+			}
+			if !exists {
+				return app.ErrRoleNotFound
+			}
+		}
+	}
+
+	for _, permission := range group.Permissions {
+		_, err := tx.ExecContext(ctx, "insert into permission (name) values ($1) on conflict (name) do nothing", permission)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			insert into group_permission (group_id, permission_id)
+			select $1, id from permission where name = $2
+			on conflict do nothing`, group.GroupID, permission)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
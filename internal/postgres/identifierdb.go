@@ -0,0 +1,216 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/cep21/circuit/v3"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	app "github.com/rislah/fakes/internal"
+)
+
+type postgresIdentifierDB struct {
+	pg      *sqlx.DB
+	circuit *circuit.Circuit
+}
+
+var _ app.IdentifierDB = &postgresIdentifierDB{}
+
+func NewIdentifierDB(pg *sqlx.DB, cc *circuit.Circuit) (*postgresIdentifierDB, error) {
+	return &postgresIdentifierDB{pg: pg, circuit: cc}, nil
+}
+
+const identifierColumns = "identifier_id, user_id, type, value, verified_at is not null as verified, code_hash, attempts, created_at"
+
+func (p *postgresIdentifierDB) CreateIdentifier(ctx context.Context, identifier app.Identifier) (app.Identifier, error) {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		err := p.pg.GetContext(ctx, &identifier.IdentifierID, `
+			insert into user_identifier (user_id, type, value, code_hash, created_at)
+			values ($1, $2, $3, $4, $5)
+			returning identifier_id`, identifier.UserID, identifier.Type, identifier.Value, identifier.CodeHash, identifier.CreatedAt)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok {
+				switch pqErr.Code {
+				case pqUniqueViolation:
+					outErr = app.ErrIdentifierTaken
+					return nil
+				case pqForeignKeyViolation, pqInvalidTextRepresentation:
+					outErr = app.ErrUserNotFound
+					return nil
+				}
+			}
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return app.Identifier{}, storageError(err)
+	}
+
+	if outErr != nil {
+		return app.Identifier{}, outErr
+	}
+
+	identifier.Verified = false
+	identifier.Attempts = 0
+	return identifier, nil
+}
+
+func (p *postgresIdentifierDB) ListIdentifiers(ctx context.Context, userID string) ([]app.Identifier, error) {
+	identifiers := []app.Identifier{}
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		err := p.pg.SelectContext(ctx, &identifiers, `
+			select `+identifierColumns+`
+			from user_identifier
+			where user_id = $1
+			order by created_at, id`, userID)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+			return nil
+		}
+
+		return err
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return identifiers, nil
+}
+
+func (p *postgresIdentifierDB) GetIdentifier(ctx context.Context, identifierID string) (app.Identifier, error) {
+	var identifier app.Identifier
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		err := p.pg.GetContext(ctx, &identifier, `
+			select `+identifierColumns+`
+			from user_identifier
+			where identifier_id = $1`, identifierID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				return nil
+			}
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return app.Identifier{}, storageError(err)
+	}
+
+	return identifier, nil
+}
+
+// VerifyIdentifier locks the identifier, so that concurrent attempts are
+// all counted. The attempt is kept even when the value turns out to be taken.
+func (p *postgresIdentifierDB) VerifyIdentifier(ctx context.Context, identifierID string, codeHash string, now time.Time) error {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		tx, err := p.pg.BeginTxx(ctx, &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var identifier app.Identifier
+		err = tx.GetContext(ctx, &identifier, `
+			select `+identifierColumns+`
+			from user_identifier
+			where identifier_id = $1
+			for update`, identifierID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				outErr = app.ErrIdentifierNotFound
+				return nil
+			}
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				outErr = app.ErrIdentifierNotFound
+				return nil
+			}
+			return err
+		}
+
+		if identifier.Verified {
+			return nil
+		}
+
+		if identifier.Attempts >= app.MaxVerificationAttempts {
+			outErr = app.ErrVerificationCodeInvalid
+			return nil
+		}
+
+		if _, err := tx.ExecContext(ctx, "update user_identifier set attempts = attempts + 1 where identifier_id = $1", identifierID); err != nil {
+			return err
+		}
+
+		if identifier.CodeHash != codeHash {
+			outErr = app.ErrVerificationCodeInvalid
+			return tx.Commit()
+		}
+
+		if _, err := tx.ExecContext(ctx, "savepoint verify_identifier"); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "update user_identifier set verified_at = $1 where identifier_id = $2", now, identifierID)
+		if err != nil {
+			pqErr, ok := err.(*pq.Error)
+			if !ok || pqErr.Code != pqUniqueViolation {
+				return err
+			}
+
+			if _, err := tx.ExecContext(ctx, "rollback to savepoint verify_identifier"); err != nil {
+				return err
+			}
+			outErr = app.ErrIdentifierTaken
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (p *postgresIdentifierDB) DeleteIdentifier(ctx context.Context, identifierID string) error {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		res, err := p.pg.ExecContext(ctx, "delete from user_identifier where identifier_id = $1", identifierID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				outErr = app.ErrIdentifierNotFound
+				return nil
+			}
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			outErr = app.ErrIdentifierNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
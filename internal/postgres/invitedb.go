@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/cep21/circuit/v3"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	app "github.com/rislah/fakes/internal"
+)
+
+type postgresInviteDB struct {
+	pg      *sqlx.DB
+	circuit *circuit.Circuit
+}
+
+var _ app.InviteDB = &postgresInviteDB{}
+
+func NewInviteDB(pg *sqlx.DB, cc *circuit.Circuit) (*postgresInviteDB, error) {
+	return &postgresInviteDB{pg: pg, circuit: cc}, nil
+}
+
+const inviteColumns = "i.invite_id, i.code_hash, i.email, r.name as role, i.max_uses, i.uses, i.expires_at, i.created_by, i.created_at"
+
+func (p *postgresInviteDB) CreateInvite(ctx context.Context, invite app.Invite) (app.Invite, error) {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		err := p.pg.GetContext(ctx, &invite.InviteID, `
+			insert into invite (code_hash, email, role_id, max_uses, expires_at, created_by, created_at)
+			select $1, $2, r.id, $4, $5, $6, $7
+			from role r
+			where r.name = $3
+			returning invite_id`, invite.CodeHash, invite.Email, invite.Role, invite.MaxUses, invite.ExpiresAt, invite.CreatedBy, invite.CreatedAt)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				outErr = app.ErrRoleNotFound
+				return nil
+			}
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return app.Invite{}, storageError(err)
+	}
+
+	if outErr != nil {
+		return app.Invite{}, outErr
+	}
+
+	invite.Code = ""
+	return invite, nil
+}
+
+func (p *postgresInviteDB) GetInviteByCodeHash(ctx context.Context, codeHash string) (app.Invite, error) {
+	var invite app.Invite
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		err := p.pg.GetContext(ctx, &invite, `
+			select `+inviteColumns+`
+			from invite i
+			join role r on r.id = i.role_id
+			where i.code_hash = $1`, codeHash)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return app.Invite{}, storageError(err)
+	}
+
+	return invite, nil
+}
+
+func (p *postgresInviteDB) ListPendingInvites(ctx context.Context, now time.Time) ([]app.Invite, error) {
+	invites := []app.Invite{}
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		return p.pg.SelectContext(ctx, &invites, `
+			select `+inviteColumns+`
+			from invite i
+			join role r on r.id = i.role_id
+			where i.uses < i.max_uses and i.expires_at > $1
+			order by i.created_at, i.id`, now)
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return invites, nil
+}
+
+// RedeemInvite checks and takes a use in a single statement, so that
+// concurrent registrations can't use an invite more than MaxUses times.
+func (p *postgresInviteDB) RedeemInvite(ctx context.Context, inviteID string, now time.Time) error {
+	return p.updateInvite(ctx, app.ErrInviteInvalid, `
+		update invite
+		set uses = uses + 1
+		where invite_id = $1 and uses < max_uses and expires_at > $2`, inviteID, now)
+}
+
+func (p *postgresInviteDB) ReleaseInvite(ctx context.Context, inviteID string) error {
+	return p.updateInvite(ctx, app.ErrInviteNotFound, `
+		update invite
+		set uses = greatest(uses - 1, 0)
+		where invite_id = $1`, inviteID)
+}
+
+func (p *postgresInviteDB) DeleteInvite(ctx context.Context, inviteID string) error {
+	return p.updateInvite(ctx, app.ErrInviteNotFound, "delete from invite where invite_id = $1", inviteID)
+}
+
+// updateInvite runs query, which changes a single invite, and returns
+// notFound when it changed none.
+func (p *postgresInviteDB) updateInvite(ctx context.Context, notFound error, query string, args ...interface{}) error {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		res, err := p.pg.ExecContext(ctx, query, args...)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				outErr = notFound
+				return nil
+			}
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			outErr = notFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
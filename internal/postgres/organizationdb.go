@@ -0,0 +1,194 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/cep21/circuit/v3"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	app "github.com/rislah/fakes/internal"
+)
+
+type postgresOrganizationDB struct {
+	pg      *sqlx.DB
+	circuit *circuit.Circuit
+}
+
+var _ app.OrganizationDB = &postgresOrganizationDB{}
+
+func NewOrganizationDB(pg *sqlx.DB, cc *circuit.Circuit) (*postgresOrganizationDB, error) {
+	return &postgresOrganizationDB{pg: pg, circuit: cc}, nil
+}
+
+func (p *postgresOrganizationDB) CreateOrganization(ctx context.Context, org app.Organization) (app.Organization, error) {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		var err error
+		if org.OrgID == "" {
+			err = p.pg.GetContext(ctx, &org.OrgID, "insert into organization (name) values ($1) returning org_id", org.Name)
+		} else {
+			_, err = p.pg.ExecContext(ctx, "insert into organization (org_id, name) values ($1, $2)", org.OrgID, org.Name)
+		}
+
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqUniqueViolation {
+				outErr = app.ErrOrganizationAlreadyExists
+				return nil
+			}
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return app.Organization{}, storageError(err)
+	}
+
+	if outErr != nil {
+		return app.Organization{}, outErr
+	}
+
+	return org, nil
+}
+
+func (p *postgresOrganizationDB) GetOrganization(ctx context.Context, orgID string) (app.Organization, error) {
+	var org app.Organization
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		err := p.pg.GetContext(ctx, &org, "select org_id, name from organization where org_id = $1", orgID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				return nil
+			}
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return app.Organization{}, storageError(err)
+	}
+
+	return org, nil
+}
+
+func (p *postgresOrganizationDB) AddMember(ctx context.Context, membership app.Membership) error {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		tx, err := p.pg.BeginTxx(ctx, &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var exists bool
+		err = tx.GetContext(ctx, &exists, "select exists (select 1 from organization where org_id = $1)", membership.OrgID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				outErr = app.ErrOrganizationNotFound
+				return nil
+			}
+			return err
+		}
+
+		if !exists {
+			outErr = app.ErrOrganizationNotFound
+			return nil
+		}
+
+		res, err := tx.ExecContext(ctx, `
+			insert into organization_member (org_id, user_id, role_id)
+			select $1, $2, r.id from role r where r.name = $3`,
+			membership.OrgID, membership.UserID, membership.Role)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok {
+				switch pqErr.Code {
+				case pqUniqueViolation:
+					outErr = app.ErrMemberAlreadyExists
+					return nil
+				case pqForeignKeyViolation, pqInvalidTextRepresentation:
+					outErr = app.ErrUserNotFound
+					return nil
+				}
+			}
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			outErr = app.ErrRoleNotFound
+			return nil
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (p *postgresOrganizationDB) GetMembership(ctx context.Context, orgID string, userID string) (app.Membership, error) {
+	var membership app.Membership
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		err := p.pg.GetContext(ctx, &membership, `
+			select om.org_id, om.user_id, r.name as role
+			from organization_member om
+			inner join role r on om.role_id = r.id
+			where om.org_id = $1 and om.user_id = $2`, orgID, userID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				return nil
+			}
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return app.Membership{}, storageError(err)
+	}
+
+	return membership, nil
+}
+
+func (p *postgresOrganizationDB) GetMemberships(ctx context.Context, userID string) ([]app.Membership, error) {
+	memberships := []app.Membership{}
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		err := p.pg.SelectContext(ctx, &memberships, `
+			select om.org_id, om.user_id, r.name as role
+			from organization_member om
+			inner join role r on om.role_id = r.id
+			where om.user_id = $1
+			order by om.org_id`, userID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				return nil
+			}
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return memberships, nil
+}
@@ -0,0 +1,327 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/cep21/circuit/v3"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	app "github.com/rislah/fakes/internal"
+)
+
+type postgresRoleDB struct {
+	pg      *sqlx.DB
+	circuit *circuit.Circuit
+}
+
+var _ app.RoleDB = &postgresRoleDB{}
+
+func NewRoleDB(pg *sqlx.DB, cc *circuit.Circuit) (*postgresRoleDB, error) {
+	return &postgresRoleDB{pg: pg, circuit: cc}, nil
+}
+
+type rolePermissionRow struct {
+	Name       string         `db:"name"`
+	Permission sql.NullString `db:"permission"`
+}
+
+type roleParentRow struct {
+	Name   string `db:"name"`
+	Parent string `db:"parent"`
+}
+
+func (p *postgresRoleDB) CreateRole(ctx context.Context, role app.RoleDefinition) error {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		tx, err := p.pg.BeginTxx(ctx, &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := lockRoleHierarchy(ctx, tx); err != nil {
+			return err
+		}
+
+		var roleID int
+		err = tx.QueryRowContext(ctx, "insert into role (name) values ($1) returning id", role.Name).Scan(&roleID)
+		if err != nil {
+			if isUniqueViolation(err, pqRoleNameKey) {
+				outErr = app.ErrRoleAlreadyExists
+				return nil
+			}
+			return err
+		}
+
+		if err := setRolePermissions(ctx, tx, roleID, role.Permissions); err != nil {
+			return err
+		}
+
+		if err := setRoleParents(ctx, tx, roleID, role.Parents); err != nil {
+			if err == app.ErrRoleParentNotFound || err == app.ErrRoleHierarchyCycle {
+				outErr = err
+				return nil
+			}
+			return err
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (p *postgresRoleDB) GetRoles(ctx context.Context) ([]app.RoleDefinition, error) {
+	var rows []rolePermissionRow
+	var parentRows []roleParentRow
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		err := p.pg.SelectContext(ctx, &rows, `
+			select r.name, p.name as permission
+			from role r
+			left join role_permission rp on rp.role_id = r.id
+			left join permission p on p.id = rp.permission_id
+			order by r.name, p.name`)
+		if err != nil {
+			return err
+		}
+
+		return p.pg.SelectContext(ctx, &parentRows, `
+			select r.name, p.name as parent
+			from role_parent rp
+			inner join role r on r.id = rp.role_id
+			inner join role p on p.id = rp.parent_id
+			order by r.name, p.name`)
+	})
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return groupRoleRows(rows, parentRows), nil
+}
+
+func (p *postgresRoleDB) GetRoleByName(ctx context.Context, name app.Role) (app.RoleDefinition, error) {
+	var rows []rolePermissionRow
+	var parentRows []roleParentRow
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		err := p.pg.SelectContext(ctx, &rows, `
+			select r.name, p.name as permission
+			from role r
+			left join role_permission rp on rp.role_id = r.id
+			left join permission p on p.id = rp.permission_id
+			where r.name = $1
+			order by p.name`, name)
+		if err != nil {
+			return err
+		}
+
+		return p.pg.SelectContext(ctx, &parentRows, `
+			select r.name, p.name as parent
+			from role_parent rp
+			inner join role r on r.id = rp.role_id
+			inner join role p on p.id = rp.parent_id
+			where r.name = $1
+			order by p.name`, name)
+	})
+
+	if err != nil {
+		return app.RoleDefinition{}, storageError(err)
+	}
+
+	roles := groupRoleRows(rows, parentRows)
+	if len(roles) == 0 {
+		return app.RoleDefinition{}, nil
+	}
+
+	return roles[0], nil
+}
+
+func (p *postgresRoleDB) UpdateRole(ctx context.Context, role app.RoleDefinition) error {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		tx, err := p.pg.BeginTxx(ctx, &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := lockRoleHierarchy(ctx, tx); err != nil {
+			return err
+		}
+
+		var roleID int
+		err = tx.GetContext(ctx, &roleID, "select id from role where name = $1 for update", role.Name)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				outErr = app.ErrRoleNotFound
+				return nil
+			}
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "delete from role_permission where role_id = $1", roleID); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "delete from role_parent where role_id = $1", roleID); err != nil {
+			return err
+		}
+
+		if err := setRolePermissions(ctx, tx, roleID, role.Permissions); err != nil {
+			return err
+		}
+
+		if err := setRoleParents(ctx, tx, roleID, role.Parents); err != nil {
+			if err == app.ErrRoleParentNotFound || err == app.ErrRoleHierarchyCycle {
+				outErr = err
+				return nil
+			}
+			return err
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (p *postgresRoleDB) DeleteRole(ctx context.Context, name app.Role) error {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		res, err := p.pg.ExecContext(ctx, "delete from role where name = $1", name)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqForeignKeyViolation {
+				outErr = app.ErrRoleInUse
+				return nil
+			}
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			outErr = app.ErrRoleNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func setRolePermissions(ctx context.Context, tx *sqlx.Tx, roleID int, permissions []string) error {
+	for _, permission := range permissions {
+		_, err := tx.ExecContext(ctx, "insert into permission (name) values ($1) on conflict (name) do nothing", permission)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			insert into role_permission (role_id, permission_id)
+			select $1, id from permission where name = $2
+			on conflict do nothing`, roleID, permission)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func setRoleParents(ctx context.Context, tx *sqlx.Tx, roleID int, parents []app.Role) error {
+	seen := map[app.Role]struct{}{}
+	for _, parent := range parents {
+		if _, ok := seen[parent]; ok {
+			continue
+		}
+		seen[parent] = struct{}{}
+
+		res, err := tx.ExecContext(ctx, `
+			insert into role_parent (role_id, parent_id)
+			select $1, id from role where name = $2
+			on conflict do nothing`, roleID, parent)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			return app.ErrRoleParentNotFound
+		}
+	}
+
+	var cycle bool
+	err := tx.GetContext(ctx, &cycle, `
+		with recursive ancestor (id) as (
+			select parent_id from role_parent where role_id = $1
+			union
+			select rp.parent_id from role_parent rp inner join ancestor a on a.id = rp.role_id
+		)
+		select exists (select 1 from ancestor where id = $1)`, roleID)
+	if err != nil {
+		return err
+	}
+
+	if cycle {
+		return app.ErrRoleHierarchyCycle
+	}
+
+	return nil
+}
+
+// lockRoleHierarchy serializes transactions that change role parents, so the
+// cycle check in setRoleParents sees every parent committed before it.
+func lockRoleHierarchy(ctx context.Context, tx *sqlx.Tx) error {
+	_, err := tx.ExecContext(ctx, "lock table role_parent in share row exclusive mode")
+	return err
+}
+
+func groupRoleRows(rows []rolePermissionRow, parentRows []roleParentRow) []app.RoleDefinition {
+	indexByName := map[string]int{}
+	roles := []app.RoleDefinition{}
+	for _, row := range rows {
+		i, ok := indexByName[row.Name]
+		if !ok {
+			i = len(roles)
+			indexByName[row.Name] = i
+			roles = append(roles, app.RoleDefinition{Name: app.Role(row.Name), Parents: []app.Role{}, Permissions: []string{}})
+		}
+
+		if row.Permission.Valid {
+			roles[i].Permissions = append(roles[i].Permissions, row.Permission.String)
+		}
+	}
+
+	for _, row := range parentRows {
+		if i, ok := indexByName[row.Name]; ok {
+			roles[i].Parents = append(roles[i].Parents, app.Role(row.Parent))
+		}
+	}
+
+	for _, role := range roles {
+		sort.Strings(role.Permissions)
+	}
+
+	return roles
+}
@@ -3,12 +3,13 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"strconv"
+	"strings"
 
 	"github.com/cep21/circuit/v3"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	app "github.com/rislah/fakes/internal"
-	"github.com/rislah/fakes/internal/errors"
 )
 
 type postgresUserDB struct {
@@ -17,27 +18,35 @@ type postgresUserDB struct {
 }
 
 var _ app.UserDB = &postgresUserDB{}
+var _ app.UserBatchCreator = &postgresUserDB{}
 
 func NewUserDB(pg *sqlx.DB, cc *circuit.Circuit) (*postgresUserDB, error) {
 	pgUserDB := &postgresUserDB{pg: pg, circuit: cc}
 	return pgUserDB, nil
 }
 
+// CreateUser returns ErrUserAlreadyExists when the username is taken, which
+// callers that check for the user beforehand still get when they race
+// another registration.
 func (p *postgresUserDB) CreateUser(ctx context.Context, user app.User) error {
+	var outErr error
 	err := p.circuit.Run(ctx, func(c context.Context) error {
 		tx, err := p.pg.BeginTx(ctx, &sql.TxOptions{})
 		if err != nil {
 			return err
 		}
-
-		res := tx.QueryRowContext(ctx, "insert into users (username, password_hash) VALUES ($1, $2) RETURNING user_id", user.Username, user.Password)
-		if err != nil {
-			return err
-		}
+		defer tx.Rollback()
 
 		var userID string
-		err = res.Scan(&userID)
+		err = tx.QueryRowContext(ctx, `
+			insert into users (username, password_hash, display_name, avatar_url, locale, timezone, status)
+			values ($1, $2, $3, $4, $5, $6, coalesce(nullif($7, ''), 'active'))
+			returning user_id`, user.Username, user.Password, user.DisplayName, user.AvatarURL, user.Locale, user.Timezone, user.Status).Scan(&userID)
 		if err != nil {
+			if isUniqueViolation(err, pqUsersUsernameKey) {
+				outErr = app.ErrUserAlreadyExists
+				return nil
+			}
 			return err
 		}
 
@@ -46,25 +55,99 @@ func (p *postgresUserDB) CreateUser(ctx context.Context, user app.User) error {
 			return err
 		}
 
-		err = tx.Commit()
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+// CreateUsers inserts users in a single transaction. Each user is inserted
+// under a savepoint, so that a duplicate username or an unknown role only
+// skips that user.
+func (p *postgresUserDB) CreateUsers(ctx context.Context, users []app.User) ([]error, error) {
+	userErrs := make([]error, len(users))
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		tx, err := p.pg.BeginTxx(ctx, &sql.TxOptions{})
 		if err != nil {
 			return err
 		}
+		defer tx.Rollback()
 
-		return nil
+		for i, user := range users {
+			if _, err := tx.ExecContext(ctx, "savepoint create_user"); err != nil {
+				return err
+			}
+
+			role := user.Role
+			if role == "" {
+				role = app.GuestRole
+			}
+
+			var userID string
+			err := tx.GetContext(ctx, &userID, `
+				with u as (
+					insert into users (username, password_hash, display_name, avatar_url, locale, timezone, status)
+					values ($1, $2, $3, $4, $5, $6, coalesce(nullif($7, ''), 'active'))
+					returning user_id
+				)
+				insert into user_role (user_id, role_id)
+				select u.user_id, r.id from u, role r where r.name = $8
+				returning user_id`, user.Username, user.Password, user.DisplayName, user.AvatarURL, user.Locale, user.Timezone, user.Status, role)
+
+			switch {
+			case err == nil:
+				_, err = tx.ExecContext(ctx, "release savepoint create_user")
+				if err != nil {
+					return err
+				}
+				continue
+			case err == sql.ErrNoRows:
+				userErrs[i] = app.ErrRoleNotFound
+			default:
+				if !isUniqueViolation(err, pqUsersUsernameKey) {
+					return err
+				}
+				userErrs[i] = app.ErrUserAlreadyExists
+			}
+
+			if _, err := tx.ExecContext(ctx, "rollback to savepoint create_user"); err != nil {
+				return err
+			}
+		}
+
+		return tx.Commit()
 	})
-	return errors.New(err)
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return userErrs, nil
 }
 
 func (p *postgresUserDB) GetUsers(ctx context.Context) ([]app.User, error) {
 	var users []app.User
 
 	err := p.circuit.Run(ctx, func(c context.Context) error {
-		err := p.pg.SelectContext(ctx, &users, `
-				select u.user_id, u.username, u.password_hash, r.name as role
+		var err error
+		if orgID := app.OrganizationFromContext(ctx); orgID != "" {
+			err = p.pg.SelectContext(ctx, &users, `
+				select u.user_id, u.username, u.password_hash, u.display_name, u.avatar_url, u.locale, u.timezone, u.status, u.status_reason, u.status_changed_at, r.name as role
+				from users u
+				inner join organization_member om on u.user_id = om.user_id
+				inner join role r on om.role_id = r.id
+				where om.org_id = $1`, orgID)
+		} else {
+			err = p.pg.SelectContext(ctx, &users, `
+				select u.user_id, u.username, u.password_hash, u.display_name, u.avatar_url, u.locale, u.timezone, u.status, u.status_reason, u.status_changed_at, r.name as role
 				from users u 
 				inner join user_role ur on u.user_id = ur.user_id
 				inner join role r on ur.role_id = r.id`)
+		}
 		if err != nil {
 			return err
 		}
@@ -73,26 +156,155 @@ func (p *postgresUserDB) GetUsers(ctx context.Context) ([]app.User, error) {
 	})
 
 	if err != nil {
-		return nil, errors.New(err)
+		return nil, storageError(err)
+	}
+
+	return users, nil
+}
+
+func (p *postgresUserDB) ListUsers(ctx context.Context, filter app.UserFilter) ([]app.User, error) {
+	from, args := usersMatching(ctx, filter)
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	// Usernames are compared bytewise so that the keyset cursor doesn't
+	// depend on the database collation.
+	query := `select u.user_id, u.username, u.password_hash, u.display_name, u.avatar_url, u.locale, u.timezone, u.status, u.status_reason, u.status_changed_at, r.name as role` + from
+
+	order := "asc"
+	if filter.After != "" {
+		operator := ">"
+		if filter.Descending {
+			operator = "<"
+		}
+		query += ` and u.username collate "C" ` + operator + " " + arg(filter.After)
+	}
+	if filter.Descending {
+		order = "desc"
+	}
+	query += ` order by u.username collate "C" ` + order
+
+	if filter.Limit > 0 {
+		query += " limit " + arg(filter.Limit)
+	}
+
+	if filter.Offset > 0 {
+		query += " offset " + arg(filter.Offset)
+	}
+
+	users := []app.User{}
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		return p.pg.SelectContext(ctx, &users, query, args...)
+	})
+
+	if err != nil {
+		return nil, storageError(err)
 	}
 
 	return users, nil
 }
 
+func (p *postgresUserDB) CountUsers(ctx context.Context, filter app.UserFilter) (int, error) {
+	from, args := usersMatching(ctx, filter)
+
+	var count int
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		return p.pg.GetContext(ctx, &count, "select count(*)"+from, args...)
+	})
+
+	if err != nil {
+		return 0, storageError(err)
+	}
+
+	return count, nil
+}
+
+// usersMatching returns the from and where clauses selecting the users of the
+// organization in ctx, or all users, that have filter's role and username
+// prefix.
+func usersMatching(ctx context.Context, filter app.UserFilter) (string, []interface{}) {
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	var query string
+	if orgID := app.OrganizationFromContext(ctx); orgID != "" {
+		query = `
+			from users u
+			inner join organization_member om on u.user_id = om.user_id
+			inner join role r on om.role_id = r.id
+			where om.org_id = ` + arg(orgID)
+	} else {
+		query = `
+			from users u
+			inner join user_role ur on u.user_id = ur.user_id
+			inner join role r on ur.role_id = r.id
+			where true`
+	}
+
+	if filter.Role != "" {
+		query += " and r.name = " + arg(filter.Role)
+	}
+
+	if filter.UsernamePrefix != "" {
+		query += ` and u.username collate "C" like ` + arg(escapeLike(filter.UsernamePrefix)+"%")
+	}
+
+	return query, args
+}
+
+// escapeLike escapes the wildcards of a like pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 func (p *postgresUserDB) GetUserByUsername(ctx context.Context, username string) (app.User, error) {
+	return p.getUser(ctx, "u.username = $1", username)
+}
+
+func (p *postgresUserDB) GetUserByIdentifier(ctx context.Context, identifier string) (app.User, error) {
+	return p.getUser(ctx, `(u.username = $1
+		or u.user_id = (select ui.user_id from user_identifier ui where ui.value = $1 and ui.verified_at is not null))`, identifier)
+}
+
+func (p *postgresUserDB) GetUserByID(ctx context.Context, userID string) (app.User, error) {
+	return p.getUser(ctx, "u.user_id = $1", userID)
+}
+
+// getUser returns the user matching where. Scoped to an organization, only
+// its members match and the role is the one they hold in it.
+func (p *postgresUserDB) getUser(ctx context.Context, where string, arg string) (app.User, error) {
+	query := `
+		select u.user_id, u.username, u.password_hash, u.display_name, u.avatar_url, u.locale, u.timezone, u.status, u.status_reason, u.status_changed_at, r.name as role
+		from users u
+		inner join user_role ur on u.user_id = ur.user_id
+		inner join role r on ur.role_id = r.id
+		where ` + where
+	args := []interface{}{arg}
+	if orgID := app.OrganizationFromContext(ctx); orgID != "" {
+		query = `
+			select u.user_id, u.username, u.password_hash, u.display_name, u.avatar_url, u.locale, u.timezone, u.status, u.status_reason, u.status_changed_at, r.name as role
+			from users u
+			inner join organization_member om on u.user_id = om.user_id
+			inner join role r on om.role_id = r.id
+			where om.org_id = $2 and ` + where
+		args = append(args, orgID)
+	}
+
 	var user app.User
 	err := p.circuit.Run(ctx, func(c context.Context) error {
-		err := p.pg.GetContext(ctx, &user, `
-			SELECT u.user_id, u.username, u.password_hash, r.name as role
-			FROM users u
-			INNER JOIN user_role ur ON u.user_id = ur.user_id
-			INNER JOIN role r ON ur.role_id = r.id
-			WHERE username = $1
-		`, username)
+		err := p.pg.GetContext(ctx, &user, query, args...)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				return nil
 			}
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				return nil
+			}
 			return err
 		}
 
@@ -100,8 +312,223 @@ func (p *postgresUserDB) GetUserByUsername(ctx context.Context, username string)
 	})
 
 	if err != nil {
-		return app.User{}, errors.New(err)
+		return app.User{}, storageError(err)
 	}
 
 	return user, nil
 }
+
+func (p *postgresUserDB) UpdateUser(ctx context.Context, user app.User) error {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		res, err := p.pg.ExecContext(ctx, `
+			update users
+			set username = $1, password_hash = $2, display_name = $3, avatar_url = $4, locale = $5, timezone = $6
+			where user_id = $7`, user.Username, user.Password, user.DisplayName, user.AvatarURL, user.Locale, user.Timezone, user.UserID)
+		if err != nil {
+			if isUniqueViolation(err, pqUsersUsernameKey) {
+				outErr = app.ErrUserAlreadyExists
+				return nil
+			}
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				outErr = app.ErrUserNotFound
+				return nil
+			}
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			outErr = app.ErrUserNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (p *postgresUserDB) UpdateUserStatus(ctx context.Context, userID string, change app.StatusChange) error {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		res, err := p.pg.ExecContext(ctx, `
+			update users
+			set status = $1, status_reason = $2, status_changed_at = $3
+			where user_id = $4 and status = $5`, change.To, change.Reason, change.At, userID, change.From)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				outErr = app.ErrUserNotFound
+				return nil
+			}
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			var exists bool
+			if err := p.pg.GetContext(ctx, &exists, "select exists (select 1 from users where user_id = $1)", userID); err != nil {
+				return err
+			}
+
+			outErr = app.ErrAccountStatusConflict
+			if !exists {
+				outErr = app.ErrUserNotFound
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (p *postgresUserDB) DeleteUser(ctx context.Context, userID string) error {
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		tx, err := p.pg.BeginTxx(ctx, &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var role app.Role
+		err = tx.GetContext(ctx, &role, `
+			select r.name
+			from user_role ur
+			inner join role r on ur.role_id = r.id
+			where ur.user_id = $1
+			for update of ur`, userID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				outErr = app.ErrUserNotFound
+				return nil
+			}
+			if err == sql.ErrNoRows {
+				outErr = app.ErrUserNotFound
+				return nil
+			}
+			return err
+		}
+
+		if role == app.AdminRole {
+			var admins []string
+			err := tx.SelectContext(ctx, &admins, `
+				select ur.user_id
+				from user_role ur
+				inner join role r on ur.role_id = r.id
+				where r.name = $1
+				for update of ur`, app.AdminRole)
+			if err != nil {
+				return err
+			}
+
+			if len(admins) <= 1 {
+				outErr = app.ErrLastAdministrator
+				return nil
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, "delete from users where user_id = $1", userID); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (p *postgresUserDB) UpdateUserRole(ctx context.Context, userID string, role app.Role) (app.Role, error) {
+	var previous app.Role
+	var outErr error
+	err := p.circuit.Run(ctx, func(c context.Context) error {
+		tx, err := p.pg.BeginTxx(ctx, &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var roleID int
+		err = tx.GetContext(ctx, &roleID, "select id from role where name = $1", role)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				outErr = app.ErrRoleNotFound
+				return nil
+			}
+			return err
+		}
+
+		err = tx.GetContext(ctx, &previous, `
+			select r.name
+			from user_role ur
+			inner join role r on ur.role_id = r.id
+			where ur.user_id = $1
+			for update of ur`, userID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqInvalidTextRepresentation {
+				outErr = app.ErrUserNotFound
+				return nil
+			}
+			if err == sql.ErrNoRows {
+				outErr = app.ErrUserNotFound
+				return nil
+			}
+			return err
+		}
+
+		if previous == app.AdminRole && role != app.AdminRole {
+			var admins []string
+			err := tx.SelectContext(ctx, &admins, `
+				select ur.user_id
+				from user_role ur
+				inner join role r on ur.role_id = r.id
+				where r.name = $1
+				for update of ur`, app.AdminRole)
+			if err != nil {
+				return err
+			}
+
+			if len(admins) <= 1 {
+				outErr = app.ErrLastAdministrator
+				return nil
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, "update user_role set role_id = $1 where user_id = $2", roleID, userID)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return "", storageError(err)
+	}
+
+	if outErr != nil {
+		return "", outErr
+	}
+
+	return previous, nil
+}
@@ -0,0 +1,134 @@
+package pow
+
+import (
+	"context"
+	"math/bits"
+)
+
+// Signals counts the attempts that indicate abuse, such as registrations or
+// failed logins, over a sliding window.
+type Signals interface {
+	Record(ctx context.Context, scope Scope, ip string) error
+	// Count returns the attempts in the window from ip and from everyone.
+	Count(ctx context.Context, scope Scope, ip string) (fromIP int64, total int64, err error)
+}
+
+// Policy maps abuse signals to a difficulty. No challenge is required while
+// both counts are under their threshold. Past a threshold the difficulty
+// starts at BaseDifficulty and grows by a bit every time the count doubles,
+// up to MaxDifficulty.
+type Policy struct {
+	IPThreshold     int64
+	GlobalThreshold int64
+	BaseDifficulty  int
+	MaxDifficulty   int
+}
+
+// DefaultPolicy asks for about 65k hashes, well under a second in a browser,
+// and at most 16M.
+var DefaultPolicy = Policy{
+	IPThreshold:     5,
+	GlobalThreshold: 100,
+	BaseDifficulty:  16,
+	MaxDifficulty:   24,
+}
+
+// Difficulty returns 0 when no challenge is required.
+func (p Policy) Difficulty(fromIP int64, total int64) int {
+	times := timesReached(fromIP, p.IPThreshold)
+	if global := timesReached(total, p.GlobalThreshold); global > times {
+		times = global
+	}
+
+	if times == 0 {
+		return 0
+	}
+
+	difficulty := p.BaseDifficulty + bits.Len64(uint64(times)) - 1
+	if difficulty > p.MaxDifficulty {
+		difficulty = p.MaxDifficulty
+	}
+	return difficulty
+}
+
+// timesReached is how many times count reached threshold, with a threshold
+// of 0 never reached.
+func timesReached(count int64, threshold int64) int64 {
+	if threshold <= 0 {
+		return 0
+	}
+	return count / threshold
+}
+
+// Guard requires proofs of work when abuse signals are high.
+type Guard struct {
+	issuer  *Issuer
+	signals Signals
+	policy  Policy
+}
+
+func NewGuard(issuer *Issuer, signals Signals, policy Policy) *Guard {
+	if issuer == nil || signals == nil {
+		panic("issuer and signals are required")
+	}
+
+	return &Guard{issuer: issuer, signals: signals, policy: policy}
+}
+
+// Difficulty returns the difficulty required from ip for scope right now,
+// 0 when no proof of work is required.
+func (g *Guard) Difficulty(ctx context.Context, scope Scope, ip string) (int, error) {
+	fromIP, total, err := g.signals.Count(ctx, scope, ip)
+	if err != nil {
+		return 0, err
+	}
+
+	return g.policy.Difficulty(fromIP, total), nil
+}
+
+// Challenge issues a challenge at the required difficulty. When none is
+// required it is issued at BaseDifficulty, so that clients can solve one
+// ahead of time, and required is false.
+func (g *Guard) Challenge(ctx context.Context, scope Scope, ip string) (token string, challenge Challenge, required bool, err error) {
+	if !scope.Valid() {
+		return "", Challenge{}, false, ErrScopeInvalid
+	}
+
+	difficulty, err := g.Difficulty(ctx, scope, ip)
+	if err != nil {
+		return "", Challenge{}, false, err
+	}
+
+	required = difficulty > 0
+	if !required {
+		difficulty = g.policy.BaseDifficulty
+	}
+
+	token, challenge, err = g.issuer.Issue(scope, difficulty)
+	return token, challenge, required, err
+}
+
+// Check lets the attempt through when no proof of work is required, and
+// otherwise verifies and redeems the solution.
+func (g *Guard) Check(ctx context.Context, scope Scope, ip string, token string, nonce string) error {
+	difficulty, err := g.Difficulty(ctx, scope, ip)
+	if err != nil {
+		return err
+	}
+
+	if difficulty == 0 {
+		return nil
+	}
+
+	if token == "" {
+		return ErrChallengeRequired
+	}
+
+	_, err = g.issuer.Verify(ctx, token, nonce, scope, difficulty)
+	return err
+}
+
+// Record counts an attempt from ip towards the abuse signals of scope.
+func (g *Guard) Record(ctx context.Context, scope Scope, ip string) error {
+	return g.signals.Record(ctx, scope, ip)
+}
@@ -0,0 +1,248 @@
+// Package pow implements hashcash-style proof-of-work challenges. A challenge
+// is a token signed by the server; solving it means finding a nonce such that
+// the SHA-256 of "<token>:<nonce>" starts with Difficulty zero bits. Tokens
+// are verified without server-side state apart from the record of spent
+// challenges, which keeps each of them single-use.
+package pow
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rislah/fakes/internal/errors"
+)
+
+const (
+	// DefaultTTL is how long a challenge can be solved and redeemed.
+	DefaultTTL = 5 * time.Minute
+	// MaxDifficulty bounds the difficulty of issued challenges. Each bit
+	// doubles the expected work.
+	MaxDifficulty = 32
+)
+
+// Scope is the action a challenge is issued for. A solution only
+// counts for the scope of its challenge.
+type Scope string
+
+const (
+	ScopeRegister Scope = "register"
+	ScopeLogin    Scope = "login"
+)
+
+func (s Scope) Valid() bool {
+	return s == ScopeRegister || s == ScopeLogin
+}
+
+// Challenge is the signed content of a challenge token.
+type Challenge struct {
+	ID         string    `json:"id"`
+	Scope      Scope     `json:"scope"`
+	Difficulty int       `json:"difficulty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// SpentStore remembers redeemed challenges until they expire.
+type SpentStore interface {
+	// Spend marks the challenge as redeemed and reports whether it was
+	// redeemed before.
+	Spend(ctx context.Context, id string, expiresAt time.Time) (alreadySpent bool, err error)
+}
+
+// Issuer signs challenges and verifies their solutions.
+type Issuer struct {
+	key   []byte
+	ttl   time.Duration
+	spent SpentStore
+	now   func() time.Time
+}
+
+func NewIssuer(key []byte, ttl time.Duration, spent SpentStore) *Issuer {
+	if len(key) == 0 {
+		panic("key is required")
+	}
+
+	if spent == nil {
+		panic("spent store is required")
+	}
+
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+
+	return &Issuer{
+		key:   key,
+		ttl:   ttl,
+		spent: spent,
+		now:   time.Now,
+	}
+}
+
+// Issue returns a token for a new challenge of scope and difficulty.
+func (i *Issuer) Issue(scope Scope, difficulty int) (string, Challenge, error) {
+	if !scope.Valid() {
+		return "", Challenge{}, ErrScopeInvalid
+	}
+
+	if difficulty < 0 || difficulty > MaxDifficulty {
+		return "", Challenge{}, ErrDifficultyInvalid
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", Challenge{}, err
+	}
+
+	challenge := Challenge{
+		ID:         hex.EncodeToString(b),
+		Scope:      scope,
+		Difficulty: difficulty,
+		ExpiresAt:  i.now().Add(i.ttl).UTC().Truncate(time.Second),
+	}
+
+	payload, err := json.Marshal(challenge)
+	if err != nil {
+		return "", Challenge{}, err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(i.sign(encoded)), challenge, nil
+}
+
+// Verify checks that nonce solves the challenge of token, that the
+// challenge was issued for scope with at least minDifficulty and that it
+// hasn't expired or been redeemed, and then redeems it.
+func (i *Issuer) Verify(ctx context.Context, token string, nonce string, scope Scope, minDifficulty int) (Challenge, error) {
+	challenge, err := i.parse(token)
+	if err != nil {
+		return Challenge{}, err
+	}
+
+	switch {
+	case challenge.Scope != scope:
+		return Challenge{}, ErrChallengeInvalid
+	case !i.now().Before(challenge.ExpiresAt):
+		return Challenge{}, ErrChallengeExpired
+	case challenge.Difficulty < minDifficulty:
+		return Challenge{}, ErrDifficultyTooLow
+	case nonce == "" || !Solves(token, nonce, challenge.Difficulty):
+		return Challenge{}, ErrSolutionInvalid
+	}
+
+	alreadySpent, err := i.spent.Spend(ctx, challenge.ID, challenge.ExpiresAt)
+	if err != nil {
+		return Challenge{}, err
+	}
+
+	if alreadySpent {
+		return Challenge{}, ErrChallengeSpent
+	}
+
+	return challenge, nil
+}
+
+func (i *Issuer) parse(token string) (Challenge, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return Challenge{}, ErrChallengeInvalid
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, i.sign(parts[0])) {
+		return Challenge{}, ErrChallengeInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return Challenge{}, ErrChallengeInvalid
+	}
+
+	var challenge Challenge
+	if err := json.Unmarshal(payload, &challenge); err != nil {
+		return Challenge{}, ErrChallengeInvalid
+	}
+
+	return challenge, nil
+}
+
+func (i *Issuer) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, i.key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// Solves reports whether nonce solves the challenge of token at difficulty.
+func Solves(token string, nonce string, difficulty int) bool {
+	sum := sha256.Sum256([]byte(token + ":" + nonce))
+	return leadingZeroBits(sum[:]) >= difficulty
+}
+
+// Solve finds a nonce for the challenge of token, as a client would. It
+// gives up when ctx is done.
+func Solve(ctx context.Context, token string, difficulty int) (string, error) {
+	for n := uint64(0); ; n++ {
+		if n%4096 == 0 && ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		nonce := strconv.FormatUint(n, 10)
+		if Solves(token, nonce, difficulty) {
+			return nonce, nil
+		}
+	}
+}
+
+func leadingZeroBits(b []byte) int {
+	var n int
+	for _, c := range b {
+		if c != 0 {
+			return n + bits.LeadingZeros8(c)
+		}
+		n += 8
+	}
+	return n
+}
+
+var (
+	ErrScopeInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Scope must be either register or login",
+	}
+	ErrDifficultyInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Difficulty must be between 0 and 32",
+	}
+	ErrChallengeRequired = &errors.WrappedError{
+		Code: http.StatusPreconditionRequired,
+		Msg:  "Proof of work required",
+	}
+	ErrChallengeInvalid = &errors.WrappedError{
+		Code: http.StatusPreconditionRequired,
+		Msg:  "Invalid proof of work challenge",
+	}
+	ErrChallengeExpired = &errors.WrappedError{
+		Code: http.StatusPreconditionRequired,
+		Msg:  "Proof of work challenge has expired",
+	}
+	ErrChallengeSpent = &errors.WrappedError{
+		Code: http.StatusPreconditionRequired,
+		Msg:  "Proof of work challenge has already been used",
+	}
+	ErrDifficultyTooLow = &errors.WrappedError{
+		Code: http.StatusPreconditionRequired,
+		Msg:  "Proof of work challenge is too easy, request a new one",
+	}
+	ErrSolutionInvalid = &errors.WrappedError{
+		Code: http.StatusPreconditionRequired,
+		Msg:  "Invalid proof of work solution",
+	}
+)
@@ -0,0 +1,202 @@
+package pow_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/pow"
+	"github.com/stretchr/testify/assert"
+)
+
+// difficulty keeps solving fast while still rejecting most nonces.
+const difficulty = 8
+
+func newIssuer(t *testing.T, key string, ttl time.Duration) *pow.Issuer {
+	client, err := local.NewRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pow.NewIssuer([]byte(key), ttl, pow.NewRedisSpentStore(client))
+}
+
+func solve(t *testing.T, token string, difficulty int) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	nonce, err := pow.Solve(ctx, token, difficulty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nonce
+}
+
+func TestIssuer(t *testing.T) {
+	tests := []struct {
+		name string
+		test func(ctx context.Context, t *testing.T, issuer *pow.Issuer)
+	}{
+		{
+			name: "a solution redeems its challenge once",
+			test: func(ctx context.Context, t *testing.T, issuer *pow.Issuer) {
+				token, challenge, err := issuer.Issue(pow.ScopeRegister, difficulty)
+				assert.NoError(t, err)
+				assert.Equal(t, difficulty, challenge.Difficulty)
+				assert.NotEmpty(t, challenge.ID)
+
+				nonce := solve(t, token, difficulty)
+				res, err := issuer.Verify(ctx, token, nonce, pow.ScopeRegister, difficulty)
+				assert.NoError(t, err)
+				assert.Equal(t, challenge.ID, res.ID)
+
+				_, err = issuer.Verify(ctx, token, nonce, pow.ScopeRegister, difficulty)
+				assert.Equal(t, pow.ErrChallengeSpent, err)
+			},
+		},
+		{
+			name: "a wrong nonce is rejected without spending the challenge",
+			test: func(ctx context.Context, t *testing.T, issuer *pow.Issuer) {
+				token, _, err := issuer.Issue(pow.ScopeLogin, difficulty)
+				assert.NoError(t, err)
+
+				nonce := solve(t, token, difficulty)
+				wrong := nonce + "0"
+				for pow.Solves(token, wrong, difficulty) {
+					wrong += "0"
+				}
+
+				_, err = issuer.Verify(ctx, token, wrong, pow.ScopeLogin, difficulty)
+				assert.Equal(t, pow.ErrSolutionInvalid, err)
+				_, err = issuer.Verify(ctx, token, "", pow.ScopeLogin, difficulty)
+				assert.Equal(t, pow.ErrSolutionInvalid, err)
+
+				_, err = issuer.Verify(ctx, token, nonce, pow.ScopeLogin, difficulty)
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "a challenge only counts for its scope and difficulty",
+			test: func(ctx context.Context, t *testing.T, issuer *pow.Issuer) {
+				token, _, err := issuer.Issue(pow.ScopeLogin, difficulty)
+				assert.NoError(t, err)
+				nonce := solve(t, token, difficulty)
+
+				_, err = issuer.Verify(ctx, token, nonce, pow.ScopeRegister, difficulty)
+				assert.Equal(t, pow.ErrChallengeInvalid, err)
+				_, err = issuer.Verify(ctx, token, nonce, pow.ScopeLogin, difficulty+1)
+				assert.Equal(t, pow.ErrDifficultyTooLow, err)
+			},
+		},
+		{
+			name: "a tampered or foreign challenge is rejected",
+			test: func(ctx context.Context, t *testing.T, issuer *pow.Issuer) {
+				token, _, err := issuer.Issue(pow.ScopeLogin, difficulty)
+				assert.NoError(t, err)
+
+				// Lowering the difficulty in the payload breaks the signature.
+				easy, _, err := issuer.Issue(pow.ScopeLogin, 0)
+				assert.NoError(t, err)
+				forged := strings.Split(easy, ".")[0] + "." + strings.Split(token, ".")[1]
+				_, err = issuer.Verify(ctx, forged, "0", pow.ScopeLogin, 0)
+				assert.Equal(t, pow.ErrChallengeInvalid, err)
+
+				other := newIssuer(t, "other", pow.DefaultTTL)
+				foreign, _, err := other.Issue(pow.ScopeLogin, 0)
+				assert.NoError(t, err)
+				_, err = issuer.Verify(ctx, foreign, "0", pow.ScopeLogin, 0)
+				assert.Equal(t, pow.ErrChallengeInvalid, err)
+
+				_, err = issuer.Verify(ctx, "garbage", "0", pow.ScopeLogin, 0)
+				assert.Equal(t, pow.ErrChallengeInvalid, err)
+			},
+		},
+		{
+			name: "invalid challenges can't be issued",
+			test: func(ctx context.Context, t *testing.T, issuer *pow.Issuer) {
+				_, _, err := issuer.Issue("reset", difficulty)
+				assert.Equal(t, pow.ErrScopeInvalid, err)
+				_, _, err = issuer.Issue(pow.ScopeLogin, pow.MaxDifficulty+1)
+				assert.Equal(t, pow.ErrDifficultyInvalid, err)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.test(context.Background(), t, newIssuer(t, "secret", pow.DefaultTTL))
+		})
+	}
+}
+
+func TestIssuerExpiredChallenge(t *testing.T) {
+	issuer := newIssuer(t, "secret", time.Nanosecond)
+	token, _, err := issuer.Issue(pow.ScopeLogin, 0)
+	assert.NoError(t, err)
+
+	_, err = issuer.Verify(context.Background(), token, "0", pow.ScopeLogin, 0)
+	assert.Equal(t, pow.ErrChallengeExpired, err)
+}
+
+func TestPolicyDifficulty(t *testing.T) {
+	policy := pow.Policy{IPThreshold: 5, GlobalThreshold: 100, BaseDifficulty: 16, MaxDifficulty: 20}
+
+	tests := []struct {
+		fromIP     int64
+		total      int64
+		difficulty int
+	}{
+		{fromIP: 0, total: 0, difficulty: 0},
+		{fromIP: 4, total: 99, difficulty: 0},
+		{fromIP: 5, total: 5, difficulty: 16},
+		{fromIP: 1, total: 100, difficulty: 16},
+		{fromIP: 10, total: 10, difficulty: 17},
+		{fromIP: 19, total: 399, difficulty: 17},
+		{fromIP: 20, total: 20, difficulty: 18},
+		{fromIP: 1, total: 800, difficulty: 19},
+		{fromIP: 1000, total: 1000, difficulty: 20},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.difficulty, policy.Difficulty(test.fromIP, test.total), "fromIP=%d total=%d", test.fromIP, test.total)
+	}
+}
+
+func TestGuard(t *testing.T) {
+	ctx := context.Background()
+	client, err := local.NewRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := pow.NewIssuer([]byte("secret"), pow.DefaultTTL, pow.NewRedisSpentStore(client))
+	policy := pow.Policy{IPThreshold: 2, GlobalThreshold: 3, BaseDifficulty: difficulty, MaxDifficulty: difficulty + 2}
+	guard := pow.NewGuard(issuer, pow.NewRedisSignals(client, time.Minute), policy)
+
+	token, challenge, required, err := guard.Challenge(ctx, pow.ScopeRegister, "10.0.0.1")
+	assert.NoError(t, err)
+	assert.False(t, required)
+	assert.Equal(t, difficulty, challenge.Difficulty, "challenges can be solved ahead of time")
+	assert.NoError(t, guard.Check(ctx, pow.ScopeRegister, "10.0.0.1", "", ""))
+
+	assert.NoError(t, guard.Record(ctx, pow.ScopeRegister, "10.0.0.1"))
+	assert.NoError(t, guard.Record(ctx, pow.ScopeRegister, "10.0.0.1"))
+
+	assert.Equal(t, pow.ErrChallengeRequired, guard.Check(ctx, pow.ScopeRegister, "10.0.0.1", "", ""))
+	assert.NoError(t, guard.Check(ctx, pow.ScopeRegister, "10.0.0.2", "", ""), "other addresses are under both thresholds")
+	assert.NoError(t, guard.Check(ctx, pow.ScopeLogin, "10.0.0.1", "", ""), "scopes are counted apart")
+
+	assert.NoError(t, guard.Check(ctx, pow.ScopeRegister, "10.0.0.1", token, solve(t, token, difficulty)))
+
+	// A third registration from anywhere trips the global threshold.
+	assert.NoError(t, guard.Record(ctx, pow.ScopeRegister, "10.0.0.3"))
+	assert.Equal(t, pow.ErrChallengeRequired, guard.Check(ctx, pow.ScopeRegister, "10.0.0.2", "", ""))
+
+	token, challenge, required, err = guard.Challenge(ctx, pow.ScopeRegister, "10.0.0.2")
+	assert.NoError(t, err)
+	assert.True(t, required)
+	assert.Equal(t, difficulty, challenge.Difficulty)
+	assert.NoError(t, guard.Check(ctx, pow.ScopeRegister, "10.0.0.2", token, solve(t, token, difficulty)))
+}
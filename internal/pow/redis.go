@@ -0,0 +1,148 @@
+package pow
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/rislah/fakes/internal/errors"
+	"github.com/rislah/fakes/internal/redis"
+)
+
+const (
+	spentKeyPrefix   = "pow:spent:"
+	signalsKeyPrefix = "pow:signals:"
+
+	// DefaultWindow is the window abuse signals are counted over.
+	DefaultWindow = time.Minute
+)
+
+// spendScript sets the key unless it exists, returning 1 when it did.
+const spendScript = `
+if redis.call('SET', KEYS[1], '1', 'NX', 'PX', ARGV[1]) then
+	return 0
+end
+return 1`
+
+// recordScript increments the counters of the current window, keeping them
+// for one more window so that they can be weighted into the next one.
+const recordScript = `
+for _, key in ipairs(KEYS) do
+	redis.call('INCR', key)
+	redis.call('PEXPIRE', key, ARGV[1])
+end
+return 0`
+
+type redisSpentStore struct {
+	client redis.Client
+}
+
+func NewRedisSpentStore(client redis.Client) SpentStore {
+	if client == nil {
+		panic("redis client is required")
+	}
+
+	return &redisSpentStore{client: client}
+}
+
+func (r *redisSpentStore) Spend(ctx context.Context, id string, expiresAt time.Time) (bool, error) {
+	ttl := time.Until(expiresAt)
+	if ttl < time.Millisecond {
+		ttl = time.Millisecond
+	}
+
+	res, err := r.client.Eval(spendScript, []string{spentKeyPrefix + id}, []string{strconv.FormatInt(ttl.Milliseconds(), 10)})
+	if err != nil {
+		return false, errors.New(err)
+	}
+
+	spent, _ := res.(int64)
+	return spent == 1, nil
+}
+
+// redisSignals counts attempts in fixed windows and approximates a sliding
+// window by weighting the previous window by how much of it the sliding
+// window still covers.
+type redisSignals struct {
+	client redis.Client
+	window time.Duration
+	now    func() time.Time
+}
+
+func NewRedisSignals(client redis.Client, window time.Duration) Signals {
+	if client == nil {
+		panic("redis client is required")
+	}
+
+	if window == 0 {
+		window = DefaultWindow
+	}
+
+	return &redisSignals{client: client, window: window, now: time.Now}
+}
+
+func (r *redisSignals) Record(ctx context.Context, scope Scope, ip string) error {
+	current := r.now().UnixNano() / int64(r.window)
+	keys := []string{r.key(scope, ip, current), r.key(scope, "", current)}
+
+	ttl := strconv.FormatInt((2 * r.window).Milliseconds(), 10)
+	if _, err := r.client.Eval(recordScript, keys, []string{ttl}); err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+func (r *redisSignals) Count(ctx context.Context, scope Scope, ip string) (int64, int64, error) {
+	now := r.now().UnixNano()
+	current := now / int64(r.window)
+	// The share of the previous window still inside the sliding window.
+	weight := 1 - float64(now%int64(r.window))/float64(r.window)
+
+	count := func(ip string) (int64, error) {
+		currentCount, err := r.get(r.key(scope, ip, current))
+		if err != nil {
+			return 0, err
+		}
+
+		previousCount, err := r.get(r.key(scope, ip, current-1))
+		if err != nil {
+			return 0, err
+		}
+
+		return currentCount + int64(float64(previousCount)*weight), nil
+	}
+
+	fromIP, err := count(ip)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	total, err := count("")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return fromIP, total, nil
+}
+
+func (r *redisSignals) get(key string) (int64, error) {
+	n, err := r.client.GetInt64(key)
+	if err != nil {
+		if errors.IsWrappedRedisNilError(err) {
+			return 0, nil
+		}
+		return 0, errors.New(err)
+	}
+
+	return n, nil
+}
+
+// key returns the counter of ip in window, or of everyone when ip is empty.
+func (r *redisSignals) key(scope Scope, ip string, window int64) string {
+	who := "global"
+	if ip != "" {
+		who = "ip:" + ip
+	}
+	return signalsKeyPrefix + string(scope) + ":" + who + ":" + strconv.FormatInt(window, 10)
+}
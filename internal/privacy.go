@@ -0,0 +1,124 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/rislah/fakes/internal/audit"
+	"github.com/rislah/fakes/internal/errors"
+)
+
+// ErasureGracePeriod is how long an erasure request can be cancelled before
+// the data is erased.
+const ErasureGracePeriod = 30 * 24 * time.Hour
+
+// PrivacyBackend serves data subject requests: exporting the data stored
+// about a user and erasing it.
+type PrivacyBackend interface {
+	ExportUserData(ctx context.Context, userID string) (DataExport, error)
+	// RequestErasure schedules the erasure of the user's data after
+	// ErasureGracePeriod.
+	RequestErasure(ctx context.Context, userID string) (ErasureRequest, error)
+	// GetErasureRequest returns the pending erasure request of the user.
+	GetErasureRequest(ctx context.Context, userID string) (ErasureRequest, error)
+	CancelErasure(ctx context.Context, userID string) error
+	// ProcessDueErasures erases the data of the requests that were due at
+	// now and records their completion.
+	ProcessDueErasures(ctx context.Context, now time.Time) ([]ErasureResult, error)
+}
+
+type ErasureDB interface {
+	// CreateErasureRequest stores request, generating its ID. A user can have
+	// a single pending request.
+	CreateErasureRequest(ctx context.Context, request ErasureRequest) (ErasureRequest, error)
+	// GetPendingErasureRequest returns an empty ErasureRequest when the user
+	// has no pending request.
+	GetPendingErasureRequest(ctx context.Context, userID string) (ErasureRequest, error)
+	// DeletePendingErasureRequest removes the pending request of the user.
+	DeletePendingErasureRequest(ctx context.Context, userID string) error
+	// GetDueErasureRequests returns the pending requests scheduled at or
+	// before now, oldest first.
+	GetDueErasureRequests(ctx context.Context, now time.Time) ([]ErasureRequest, error)
+	// CompleteErasureRequest turns a pending request into the record of its
+	// completion.
+	CompleteErasureRequest(ctx context.Context, requestID string, completedAt time.Time) error
+}
+
+type ErasureStatus string
+
+const (
+	ErasurePending   ErasureStatus = "pending"
+	ErasureCompleted ErasureStatus = "completed"
+)
+
+// ErasureRequest is a request to erase the data of a user. Once completed it
+// only identifies the user by the ID it had, so that the record outlives the
+// data.
+type ErasureRequest struct {
+	RequestID    string        `db:"request_id"`
+	UserID       string        `db:"user_id"`
+	Status       ErasureStatus `db:"status"`
+	RequestedAt  time.Time     `db:"requested_at"`
+	ScheduledFor time.Time     `db:"scheduled_for"`
+	CompletedAt  *time.Time    `db:"completed_at"`
+}
+
+func (e ErasureRequest) IsEmpty() bool {
+	return e.RequestID == ""
+}
+
+// ErasureResult is the outcome of processing an erasure request. Err is set
+// when the request stays pending.
+type ErasureResult struct {
+	Request ErasureRequest
+	Err     error
+}
+
+type privacyImpl struct {
+	userDB       UserDB
+	orgDB        OrganizationDB
+	groupDB      GroupDB
+	erasureDB    ErasureDB
+	identifierDB IdentifierDB
+	sessions     SessionStore
+	auditLog     *audit.Log
+}
+
+func NewPrivacyBackend(userDB UserDB, orgDB OrganizationDB, groupDB GroupDB, erasureDB ErasureDB, identifierDB IdentifierDB, sessions SessionStore, auditLog *audit.Log) PrivacyBackend {
+	if userDB == nil || orgDB == nil || groupDB == nil || erasureDB == nil || identifierDB == nil {
+		panic("database is required")
+	}
+
+	if sessions == nil {
+		panic("session store is required")
+	}
+
+	if auditLog == nil {
+		panic("audit log is required")
+	}
+
+	return &privacyImpl{
+		userDB:       userDB,
+		orgDB:        orgDB,
+		groupDB:      groupDB,
+		erasureDB:    erasureDB,
+		identifierDB: identifierDB,
+		sessions:     sessions,
+		auditLog:     auditLog,
+	}
+}
+
+var (
+	ErrErasureAlreadyRequested = &errors.WrappedError{
+		Code: errors.ErrConflict,
+		Msg:  "Erasure has already been requested",
+	}
+	ErrErasureRequestNotFound = &errors.WrappedError{
+		Code: errors.ErrNotFound,
+		Msg:  "Erasure request not found",
+	}
+	ErrErasureLastAdministrator = &errors.WrappedError{
+		Code: errors.ErrConflict,
+		Msg:  "The last administrator can't request erasure",
+	}
+)
@@ -0,0 +1,210 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/audit"
+	"github.com/rislah/fakes/internal/local"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivacyImpl(t *testing.T) {
+	tests := []struct {
+		name string
+		test func(ctx context.Context, t *testing.T, privacy app.PrivacyBackend, db *privacyStores)
+	}{
+		{
+			name: "should export the data of every store",
+			test: func(ctx context.Context, t *testing.T, privacy app.PrivacyBackend, db *privacyStores) {
+				usr := db.createUser(ctx, t, "alice")
+
+				org, err := db.store.CreateOrganization(ctx, app.Organization{Name: "acme"})
+				assert.NoError(t, err)
+				assert.NoError(t, db.store.AddMember(ctx, app.Membership{OrgID: org.OrgID, UserID: usr.UserID, Role: app.DeveloperRole}))
+
+				group, err := db.store.CreateGroup(ctx, app.Group{Name: "support"})
+				assert.NoError(t, err)
+				assert.NoError(t, db.store.AddGroupMember(ctx, group.GroupID, usr.UserID))
+
+				email, err := db.store.CreateIdentifier(ctx, app.Identifier{UserID: usr.UserID, Type: app.IdentifierEmail, Value: "alice@example.com", CodeHash: "hash", CreatedAt: time.Now()})
+				assert.NoError(t, err)
+				assert.NoError(t, db.store.VerifyIdentifier(ctx, email.IdentifierID, "hash", time.Now()))
+				phone, err := db.store.CreateIdentifier(ctx, app.Identifier{UserID: usr.UserID, Type: app.IdentifierPhone, Value: "+3725551234", CodeHash: "hash", CreatedAt: time.Now()})
+				assert.NoError(t, err)
+
+				assert.NoError(t, db.sessions.RevokeUserSessions(ctx, usr.UserID))
+
+				request, err := privacy.RequestErasure(ctx, usr.UserID)
+				assert.NoError(t, err)
+
+				login, err := db.auditLog.Record(ctx, audit.Event{Action: audit.LoginSucceeded, ActorID: usr.UserID, TargetID: usr.UserID})
+				assert.NoError(t, err)
+				_, err = db.auditLog.Record(ctx, audit.Event{Action: audit.GroupCreated, TargetID: group.GroupID})
+				assert.NoError(t, err)
+				suspension, err := db.auditLog.Record(ctx, audit.Event{Action: audit.StatusChanged, ActorID: "22222222-2222-2222-2222-222222222222", TargetID: usr.UserID})
+				assert.NoError(t, err)
+
+				export, err := privacy.ExportUserData(ctx, usr.UserID)
+				assert.NoError(t, err)
+				assert.Equal(t, usr.UserID, export.Profile.UserID)
+				assert.Equal(t, "alice", export.Profile.Username)
+				assert.Equal(t, []app.ExportedMembership{{OrgID: org.OrgID, Role: app.DeveloperRole}}, export.Organizations)
+				assert.Equal(t, []app.ExportedGroup{{GroupID: group.GroupID, Name: "support"}}, export.Groups)
+				assert.Equal(t, []app.ExportedIdentifier{
+					{IdentifierID: email.IdentifierID, Type: app.IdentifierEmail, Value: "alice@example.com", Verified: true, CreatedAt: email.CreatedAt},
+					{IdentifierID: phone.IdentifierID, Type: app.IdentifierPhone, Value: "+3725551234", CreatedAt: phone.CreatedAt},
+				}, export.Identifiers)
+				assert.NotNil(t, export.Sessions.RevokedAt)
+				if assert.NotNil(t, export.Erasure) {
+					assert.Equal(t, request.RequestID, export.Erasure.RequestID)
+				}
+				assert.Equal(t, []audit.Event{login, suspension}, export.AuditEvents)
+
+				_, err = privacy.ExportUserData(ctx, "11111111-1111-1111-1111-111111111111")
+				assert.Equal(t, app.ErrUserNotFound, err)
+			},
+		},
+		{
+			name: "should schedule and cancel erasure",
+			test: func(ctx context.Context, t *testing.T, privacy app.PrivacyBackend, db *privacyStores) {
+				usr := db.createUser(ctx, t, "alice")
+
+				_, err := privacy.GetErasureRequest(ctx, usr.UserID)
+				assert.Equal(t, app.ErrErasureRequestNotFound, err)
+
+				request, err := privacy.RequestErasure(ctx, usr.UserID)
+				assert.NoError(t, err)
+				assert.Equal(t, app.ErasureGracePeriod, request.ScheduledFor.Sub(request.RequestedAt))
+
+				_, err = privacy.RequestErasure(ctx, usr.UserID)
+				assert.Equal(t, app.ErrErasureAlreadyRequested, err)
+
+				res, err := privacy.GetErasureRequest(ctx, usr.UserID)
+				assert.NoError(t, err)
+				assert.Equal(t, request.RequestID, res.RequestID)
+
+				assert.NoError(t, privacy.CancelErasure(ctx, usr.UserID))
+				assert.Equal(t, app.ErrErasureRequestNotFound, privacy.CancelErasure(ctx, usr.UserID))
+
+				results, err := privacy.ProcessDueErasures(ctx, time.Now().Add(2*app.ErasureGracePeriod))
+				assert.NoError(t, err)
+				assert.Empty(t, results, "cancelled requests aren't processed")
+			},
+		},
+		{
+			name: "should erase users once the grace period is over",
+			test: func(ctx context.Context, t *testing.T, privacy app.PrivacyBackend, db *privacyStores) {
+				usr := db.createUser(ctx, t, "alice")
+				group, err := db.store.CreateGroup(ctx, app.Group{Name: "support"})
+				assert.NoError(t, err)
+				assert.NoError(t, db.store.AddGroupMember(ctx, group.GroupID, usr.UserID))
+
+				request, err := privacy.RequestErasure(ctx, usr.UserID)
+				assert.NoError(t, err)
+
+				results, err := privacy.ProcessDueErasures(ctx, time.Now())
+				assert.NoError(t, err)
+				assert.Empty(t, results, "requests aren't processed during the grace period")
+
+				now := request.ScheduledFor.Add(time.Minute)
+				results, err = privacy.ProcessDueErasures(ctx, now)
+				assert.NoError(t, err)
+				if assert.Len(t, results, 1) {
+					assert.NoError(t, results[0].Err)
+					assert.Equal(t, app.ErasureCompleted, results[0].Request.Status)
+					assert.NotNil(t, results[0].Request.CompletedAt)
+				}
+
+				res, err := db.store.GetUserByID(ctx, usr.UserID)
+				assert.NoError(t, err)
+				assert.True(t, res.IsEmpty())
+
+				members, err := db.store.GetGroupMembers(ctx, group.GroupID)
+				assert.NoError(t, err)
+				assert.Empty(t, members)
+
+				revoked, err := db.sessions.IsSessionRevoked(ctx, usr.UserID, time.Now().Add(-time.Minute))
+				assert.NoError(t, err)
+				assert.True(t, revoked)
+
+				results, err = privacy.ProcessDueErasures(ctx, now)
+				assert.NoError(t, err)
+				assert.Empty(t, results, "completed requests aren't processed again")
+			},
+		},
+		{
+			name: "should refuse erasure of the last administrator",
+			test: func(ctx context.Context, t *testing.T, privacy app.PrivacyBackend, db *privacyStores) {
+				usr := db.createUser(ctx, t, "admin")
+				_, err := db.store.UpdateUserRole(ctx, usr.UserID, app.AdminRole)
+				assert.NoError(t, err)
+
+				_, err = privacy.RequestErasure(ctx, usr.UserID)
+				assert.Equal(t, app.ErrErasureLastAdministrator, err)
+
+				other := db.createUser(ctx, t, "other_admin")
+				_, err = db.store.UpdateUserRole(ctx, other.UserID, app.AdminRole)
+				assert.NoError(t, err)
+
+				request, err := privacy.RequestErasure(ctx, usr.UserID)
+				assert.NoError(t, err)
+
+				_, err = db.store.UpdateUserRole(ctx, other.UserID, app.GuestRole)
+				assert.NoError(t, err)
+
+				results, err := privacy.ProcessDueErasures(ctx, request.ScheduledFor)
+				assert.NoError(t, err)
+				if assert.Len(t, results, 1) {
+					assert.Equal(t, app.ErrLastAdministrator, results[0].Err)
+				}
+
+				_, err = privacy.GetErasureRequest(ctx, usr.UserID)
+				assert.NoError(t, err, "a failed erasure stays pending")
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			redis, teardown, err := local.MakeRedis()
+			assert.NoError(t, err)
+
+			defer func() {
+				assert.NoError(t, teardown())
+			}()
+
+			db := &privacyStores{store: local.NewUserDB(), sessions: app.NewRedisSessionStore(redis), auditLog: audit.NewLog(local.NewAuditStore())}
+			privacy := app.NewPrivacyBackend(db.store, db.store, db.store, db.store, db.store, db.sessions, db.auditLog)
+			test.test(ctx, t, privacy, db)
+		})
+	}
+}
+
+// privacyStores is a single local store backing every database of the
+// privacy backend, so that memberships refer to its users.
+type privacyStores struct {
+	store interface {
+		app.UserDB
+		app.OrganizationDB
+		app.GroupDB
+		app.ErasureDB
+		app.IdentifierDB
+	}
+	sessions app.SessionStore
+	auditLog *audit.Log
+}
+
+func (p *privacyStores) createUser(ctx context.Context, t *testing.T, username string) app.User {
+	assert.NoError(t, p.store.CreateUser(ctx, app.User{Username: username, Password: "hash"}))
+	usr, err := p.store.GetUserByUsername(ctx, username)
+	assert.NoError(t, err)
+	return usr
+}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+	// Timezones are validated against the embedded database so that
+	// validation doesn't depend on the host.
+	_ "time/tzdata"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rislah/fakes/internal/errors"
+)
+
+const (
+	maxDisplayNameLength = 64
+	maxAvatarURLLength   = 2048
+)
+
+// localeRegex matches the language, script and region subtags of a BCP 47
+// language tag, e.g. "en", "en-US" or "zh-Hant-TW".
+var localeRegex = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z][a-z]{3})?(-([A-Z]{2}|[0-9]{3}))?$`)
+
+// ProfileUpdate changes the profile attributes that are set. An empty value
+// clears the attribute.
+type ProfileUpdate struct {
+	DisplayName *string
+	AvatarURL   *string
+	Locale      *string
+	Timezone    *string
+}
+
+func (u *userImpl) UpdateProfile(ctx context.Context, userID string, update ProfileUpdate) (User, error) {
+	if userID == "" {
+		return User{}, ErrUserNotFound
+	}
+
+	usr, err := u.userDB.GetUserByID(ctx, userID)
+	if err != nil {
+		return User{}, err
+	}
+
+	if usr.IsEmpty() {
+		return User{}, ErrUserNotFound
+	}
+
+	if update.DisplayName != nil {
+		usr.DisplayName = *update.DisplayName
+	}
+	if update.AvatarURL != nil {
+		usr.AvatarURL = *update.AvatarURL
+	}
+	if update.Locale != nil {
+		usr.Locale = *update.Locale
+	}
+	if update.Timezone != nil {
+		usr.Timezone = *update.Timezone
+	}
+
+	if err := ValidateProfile(usr); err != nil {
+		return User{}, err
+	}
+
+	if err := u.userDB.UpdateUser(ctx, usr); err != nil {
+		return User{}, err
+	}
+
+	return usr.Sanitize(), nil
+}
+
+// ValidateProfile checks the profile attributes of u that are set.
+func ValidateProfile(u User) error {
+	if u.DisplayName != "" {
+		if utf8.RuneCountInString(u.DisplayName) > maxDisplayNameLength {
+			return ErrDisplayNameInvalid
+		}
+		for _, r := range u.DisplayName {
+			if unicode.IsControl(r) {
+				return ErrDisplayNameInvalid
+			}
+		}
+	}
+
+	if u.AvatarURL != "" {
+		if len(u.AvatarURL) > maxAvatarURLLength {
+			return ErrAvatarURLInvalid
+		}
+
+		avatar, err := url.Parse(u.AvatarURL)
+		if err != nil || avatar.Scheme != "https" || avatar.Host == "" || avatar.User != nil {
+			return ErrAvatarURLInvalid
+		}
+	}
+
+	if u.Locale != "" && !localeRegex.MatchString(u.Locale) {
+		return ErrLocaleInvalid
+	}
+
+	if u.Timezone != "" {
+		// LoadLocation maps "" and "UTC" to UTC and "Local" to the host's zone.
+		if u.Timezone == "Local" {
+			return ErrTimezoneInvalid
+		}
+		if _, err := time.LoadLocation(u.Timezone); err != nil {
+			return ErrTimezoneInvalid
+		}
+	}
+
+	return nil
+}
+
+var (
+	ErrDisplayNameInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Display name must be at most 64 characters without control characters",
+	}
+	ErrAvatarURLInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Avatar URL must be an https URL of at most 2048 characters",
+	}
+	ErrLocaleInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Locale must be a language tag such as en or en-US",
+	}
+	ErrTimezoneInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Timezone must be an IANA time zone such as Europe/Tallinn",
+	}
+)
@@ -0,0 +1,85 @@
+package app_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/jwt"
+	"github.com/rislah/fakes/internal/local"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateProfile(t *testing.T) {
+	tests := []struct {
+		name string
+		user app.User
+		err  error
+	}{
+		{name: "empty profile", user: app.User{}},
+		{
+			name: "full profile",
+			user: app.User{
+				DisplayName: "Ülle Õun",
+				AvatarURL:   "https://cdn.example.com/u/1.png?size=64",
+				Locale:      "zh-Hant-TW",
+				Timezone:    "America/Argentina/Buenos_Aires",
+			},
+		},
+		{name: "display name too long", user: app.User{DisplayName: strings.Repeat("a", 65)}, err: app.ErrDisplayNameInvalid},
+		{name: "display name with control characters", user: app.User{DisplayName: "a\nb"}, err: app.ErrDisplayNameInvalid},
+		{name: "avatar over http", user: app.User{AvatarURL: "http://example.com/a.png"}, err: app.ErrAvatarURLInvalid},
+		{name: "relative avatar", user: app.User{AvatarURL: "/a.png"}, err: app.ErrAvatarURLInvalid},
+		{name: "avatar with credentials", user: app.User{AvatarURL: "https://user:pw@example.com/a.png"}, err: app.ErrAvatarURLInvalid},
+		{name: "malformed locale", user: app.User{Locale: "en_us"}, err: app.ErrLocaleInvalid},
+		{name: "unknown timezone", user: app.User{Timezone: "Mars/Olympus_Mons"}, err: app.ErrTimezoneInvalid},
+		{name: "host timezone", user: app.User{Timezone: "Local"}, err: app.ErrTimezoneInvalid},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.err, app.ValidateProfile(test.user))
+		})
+	}
+}
+
+func TestUserImpl_UpdateProfile(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	db, teardown, err := local.MakeUserDB()
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, teardown())
+	}()
+
+	userBackend := app.NewUserBackend(db, jwt.NewHS256Wrapper("wrapper"))
+	str := func(s string) *string { return &s }
+
+	_, err = userBackend.UpdateProfile(ctx, "11111111-1111-1111-1111-111111111111", app.ProfileUpdate{Locale: str("en")})
+	assert.Equal(t, app.ErrUserNotFound, err)
+
+	assert.NoError(t, db.CreateUser(ctx, app.User{Username: "asdf", Password: "hash"}))
+	usr, err := db.GetUserByUsername(ctx, "asdf")
+	assert.NoError(t, err)
+
+	res, err := userBackend.UpdateProfile(ctx, usr.UserID, app.ProfileUpdate{DisplayName: str("Asdf"), Locale: str("en-GB")})
+	assert.NoError(t, err)
+	assert.Equal(t, "Asdf", res.DisplayName)
+	assert.Empty(t, res.Password)
+
+	_, err = userBackend.UpdateProfile(ctx, usr.UserID, app.ProfileUpdate{Timezone: str("Nowhere")})
+	assert.Equal(t, app.ErrTimezoneInvalid, err)
+
+	res, err = userBackend.UpdateProfile(ctx, usr.UserID, app.ProfileUpdate{DisplayName: str("")})
+	assert.NoError(t, err)
+	assert.Empty(t, res.DisplayName)
+	assert.Equal(t, "en-GB", res.Locale, "unset attributes are left alone")
+
+	stored, err := db.GetUserByID(ctx, usr.UserID)
+	assert.NoError(t, err)
+	assert.Equal(t, "hash", stored.Password)
+	assert.Equal(t, "en-GB", stored.Locale)
+}
@@ -1,7 +1,300 @@
 package app
 
-import "context"
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rislah/fakes/internal/errors"
+)
+
+const defaultRBACCacheTTL = 1 * time.Minute
+
+var validRoleNameRegex = regexp.MustCompile("^[a-z][a-z0-9_]*$")
 
 type RBAC interface {
-	CreateRole(ctx context.Context)
+	CreateRole(ctx context.Context, role RoleDefinition) error
+	GetRoles(ctx context.Context) ([]RoleDefinition, error)
+	UpdateRole(ctx context.Context, role RoleDefinition) error
+	DeleteRole(ctx context.Context, role Role) error
+	DoesRoleHavePermission(ctx context.Context, role Role, permission string) (bool, error)
+	EffectivePermissions(ctx context.Context, role Role) ([]string, error)
+	InheritedRoles(ctx context.Context, role Role) ([]Role, error)
+	// GrantedPermissions returns the effective permissions of role, of every
+	// role in grants and the permissions granted directly.
+	GrantedPermissions(ctx context.Context, role Role, grants Grants) ([]string, error)
+	DoesUserHavePermission(ctx context.Context, role Role, grants Grants, permission string) (bool, error)
+}
+
+// RoleDB stores role definitions. CreateRole and UpdateRole reject unknown
+// parents and hierarchy cycles atomically with the write, and CreateRole
+// returns ErrRoleAlreadyExists for a taken name.
+type RoleDB interface {
+	CreateRole(ctx context.Context, role RoleDefinition) error
+	GetRoles(ctx context.Context) ([]RoleDefinition, error)
+	GetRoleByName(ctx context.Context, role Role) (RoleDefinition, error)
+	UpdateRole(ctx context.Context, role RoleDefinition) error
+	DeleteRole(ctx context.Context, role Role) error
+}
+
+type RoleDefinition struct {
+	Name        Role     `json:"name"`
+	Parents     []Role   `json:"parents"`
+	Permissions []string `json:"permissions"`
+}
+
+func (r RoleDefinition) IsEmpty() bool {
+	return r.Name == ""
+}
+
+func (r RoleDefinition) Valid() error {
+	if !validRoleNameRegex.MatchString(r.Name.String()) {
+		return ErrRoleNameInvalid
+	}
+
+	for _, permission := range r.Permissions {
+		if permission == "" {
+			return ErrPermissionNameInvalid
+		}
+	}
+
+	for _, parent := range r.Parents {
+		if parent == r.Name {
+			return ErrRoleHierarchyCycle
+		}
+	}
+
+	return nil
+}
+
+type rbacImpl struct {
+	roleDB RoleDB
+	ttl    time.Duration
+
+	mu                sync.RWMutex
+	hierarchy         RoleHierarchy
+	permissionsByRole map[Role][]string
+	loadedAt          time.Time
+}
+
+func NewRBAC(db RoleDB) RBAC {
+	if db == nil {
+		panic("database is required")
+	}
+
+	return &rbacImpl{
+		roleDB: db,
+		ttl:    defaultRBACCacheTTL,
+	}
+}
+
+func (r *rbacImpl) CreateRole(ctx context.Context, role RoleDefinition) error {
+	if err := role.Valid(); err != nil {
+		return err
+	}
+
+	// The role database rejects existing names, unknown parents and cycles
+	// in the same transaction that writes the role.
+	if err := r.roleDB.CreateRole(ctx, role); err != nil {
+		return err
+	}
+
+	r.invalidate()
+	return nil
+}
+
+func (r *rbacImpl) GetRoles(ctx context.Context) ([]RoleDefinition, error) {
+	return r.roleDB.GetRoles(ctx)
+}
+
+func (r *rbacImpl) UpdateRole(ctx context.Context, role RoleDefinition) error {
+	if err := role.Valid(); err != nil {
+		return err
+	}
+
+	if err := r.roleDB.UpdateRole(ctx, role); err != nil {
+		return err
+	}
+
+	r.invalidate()
+	return nil
+}
+
+func (r *rbacImpl) DeleteRole(ctx context.Context, role Role) error {
+	if role == GuestRole {
+		return ErrRoleProtected
+	}
+
+	roles, err := r.roleDB.GetRoles(ctx)
+	if err != nil {
+		return err
+	}
+
+	hierarchy, err := NewRoleHierarchy(roles)
+	if err != nil {
+		return err
+	}
+
+	if len(hierarchy.Children(role)) != 0 {
+		return ErrRoleHasChildren
+	}
+
+	if err := r.roleDB.DeleteRole(ctx, role); err != nil {
+		return err
+	}
+
+	r.invalidate()
+	return nil
+}
+
+func (r *rbacImpl) DoesRoleHavePermission(ctx context.Context, role Role, permission string) (bool, error) {
+	permissionsByRole, err := r.permissions(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	permissions, ok := permissionsByRole[role]
+	if !ok {
+		return false, nil
+	}
+
+	i := sort.SearchStrings(permissions, permission)
+	return i < len(permissions) && permissions[i] == permission, nil
+}
+
+func (r *rbacImpl) EffectivePermissions(ctx context.Context, role Role) ([]string, error) {
+	permissionsByRole, err := r.permissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]string{}, permissionsByRole[role]...), nil
+}
+
+func (r *rbacImpl) GrantedPermissions(ctx context.Context, role Role, grants Grants) ([]string, error) {
+	permissionsByRole, err := r.permissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]struct{}{}
+	permissions := []string{}
+	add := func(permission string) {
+		if _, ok := seen[permission]; !ok {
+			seen[permission] = struct{}{}
+			permissions = append(permissions, permission)
+		}
+	}
+
+	for _, permission := range permissionsByRole[role] {
+		add(permission)
+	}
+	for _, granted := range grants.Roles {
+		for _, permission := range permissionsByRole[granted] {
+			add(permission)
+		}
+	}
+	for _, permission := range grants.Permissions {
+		add(permission)
+	}
+
+	sort.Strings(permissions)
+	return permissions, nil
+}
+
+func (r *rbacImpl) DoesUserHavePermission(ctx context.Context, role Role, grants Grants, permission string) (bool, error) {
+	permissions, err := r.GrantedPermissions(ctx, role, grants)
+	if err != nil {
+		return false, err
+	}
+
+	i := sort.SearchStrings(permissions, permission)
+	return i < len(permissions) && permissions[i] == permission, nil
 }
+
+func (r *rbacImpl) InheritedRoles(ctx context.Context, role Role) ([]Role, error) {
+	hierarchy, _, err := r.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return hierarchy.Ancestors(role), nil
+}
+
+func (r *rbacImpl) permissions(ctx context.Context) (map[Role][]string, error) {
+	_, permissionsByRole, err := r.load(ctx)
+	return permissionsByRole, err
+}
+
+func (r *rbacImpl) load(ctx context.Context) (RoleHierarchy, map[Role][]string, error) {
+	r.mu.RLock()
+	hierarchy, permissionsByRole, loadedAt := r.hierarchy, r.permissionsByRole, r.loadedAt
+	r.mu.RUnlock()
+
+	if permissionsByRole != nil && time.Since(loadedAt) < r.ttl {
+		return hierarchy, permissionsByRole, nil
+	}
+
+	roles, err := r.roleDB.GetRoles(ctx)
+	if err != nil {
+		return RoleHierarchy{}, nil, err
+	}
+
+	hierarchy, err = NewRoleHierarchy(roles)
+	if err != nil {
+		return RoleHierarchy{}, nil, err
+	}
+
+	permissionsByRole = make(map[Role][]string, len(roles))
+	for _, role := range roles {
+		permissionsByRole[role.Name] = hierarchy.EffectivePermissions(role.Name)
+	}
+
+	r.mu.Lock()
+	r.hierarchy = hierarchy
+	r.permissionsByRole = permissionsByRole
+	r.loadedAt = time.Now()
+	r.mu.Unlock()
+
+	return hierarchy, permissionsByRole, nil
+}
+
+func (r *rbacImpl) invalidate() {
+	r.mu.Lock()
+	r.permissionsByRole = nil
+	r.mu.Unlock()
+}
+
+var (
+	ErrRoleNotFound = &errors.WrappedError{
+		Code: errors.ErrNotFound,
+		Msg:  "Role not found",
+	}
+	ErrRoleAlreadyExists = &errors.WrappedError{
+		Code: errors.ErrConflict,
+		Msg:  "Role already exists",
+	}
+	ErrRoleInUse = &errors.WrappedError{
+		Code: errors.ErrConflict,
+		Msg:  "Role is assigned to users",
+	}
+	ErrRoleHasChildren = &errors.WrappedError{
+		Code: errors.ErrConflict,
+		Msg:  "Role is a parent of other roles",
+	}
+	ErrRoleProtected = &errors.WrappedError{
+		Code: errors.ErrConflict,
+		Msg:  "Role cannot be deleted",
+	}
+	ErrRoleNameInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Role name must start with a lowercase letter and contain only lowercase letters, digits or underscores",
+	}
+	ErrPermissionNameInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Permission name must not be empty",
+	}
+)
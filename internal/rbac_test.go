@@ -0,0 +1,176 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/local"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRBAC(t *testing.T) {
+	tests := []struct {
+		name string
+		test func(ctx context.Context, t *testing.T, rbac app.RBAC, db app.RoleDB)
+	}{
+		{
+			name: "default role permission",
+			test: func(ctx context.Context, t *testing.T, rbac app.RBAC, db app.RoleDB) {
+				ok, err := rbac.DoesRoleHavePermission(ctx, app.GuestRole, app.ViewTest)
+				assert.NoError(t, err)
+				assert.True(t, ok)
+			},
+		},
+		{
+			name: "inherited role permission",
+			test: func(ctx context.Context, t *testing.T, rbac app.RBAC, db app.RoleDB) {
+				ok, err := rbac.DoesRoleHavePermission(ctx, app.DeveloperRole, app.ViewTest)
+				assert.NoError(t, err)
+				assert.True(t, ok)
+
+				ok, err = rbac.DoesRoleHavePermission(ctx, app.DeveloperRole, app.ManageRoles)
+				assert.NoError(t, err)
+				assert.False(t, ok)
+
+				permissions, err := rbac.EffectivePermissions(ctx, app.DeveloperRole)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{app.ManageUsers, app.ViewTest}, permissions)
+			},
+		},
+		{
+			name: "granted roles and permissions are combined",
+			test: func(ctx context.Context, t *testing.T, rbac app.RBAC, db app.RoleDB) {
+				grants := app.Grants{Roles: []app.Role{app.DeveloperRole}, Permissions: []string{"exportData"}}
+
+				ok, err := rbac.DoesUserHavePermission(ctx, app.GuestRole, grants, app.ManageUsers)
+				assert.NoError(t, err)
+				assert.True(t, ok)
+
+				ok, err = rbac.DoesUserHavePermission(ctx, app.GuestRole, grants, app.AssignRoles)
+				assert.NoError(t, err)
+				assert.False(t, ok)
+
+				permissions, err := rbac.GrantedPermissions(ctx, app.GuestRole, grants)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"exportData", app.ManageUsers, app.ViewTest}, permissions)
+			},
+		},
+		{
+			name: "update that introduces a cycle is rejected",
+			test: func(ctx context.Context, t *testing.T, rbac app.RBAC, db app.RoleDB) {
+				err := rbac.UpdateRole(ctx, app.RoleDefinition{Name: app.GuestRole, Parents: []app.Role{app.DeveloperRole}})
+				assert.Equal(t, app.ErrRoleHierarchyCycle, err)
+			},
+		},
+		{
+			name: "create with unknown parent is rejected",
+			test: func(ctx context.Context, t *testing.T, rbac app.RBAC, db app.RoleDB) {
+				err := rbac.CreateRole(ctx, app.RoleDefinition{Name: "moderator", Parents: []app.Role{"doesnotexist"}})
+				assert.Equal(t, app.ErrRoleParentNotFound, err)
+			},
+		},
+		{
+			name: "parent role cannot be deleted",
+			test: func(ctx context.Context, t *testing.T, rbac app.RBAC, db app.RoleDB) {
+				err := rbac.DeleteRole(ctx, app.UserRole)
+				assert.Equal(t, app.ErrRoleHasChildren, err)
+			},
+		},
+		{
+			name: "unknown role has no permissions",
+			test: func(ctx context.Context, t *testing.T, rbac app.RBAC, db app.RoleDB) {
+				ok, err := rbac.DoesRoleHavePermission(ctx, "doesnotexist", app.ViewTest)
+				assert.NoError(t, err)
+				assert.False(t, ok)
+			},
+		},
+		{
+			name: "created role is visible to permission checks",
+			test: func(ctx context.Context, t *testing.T, rbac app.RBAC, db app.RoleDB) {
+				ok, err := rbac.DoesRoleHavePermission(ctx, "moderator", "editPosts")
+				assert.NoError(t, err)
+				assert.False(t, ok)
+
+				err = rbac.CreateRole(ctx, app.RoleDefinition{Name: "moderator", Permissions: []string{"editPosts"}})
+				assert.NoError(t, err)
+
+				ok, err = rbac.DoesRoleHavePermission(ctx, "moderator", "editPosts")
+				assert.NoError(t, err)
+				assert.True(t, ok)
+			},
+		},
+		{
+			name: "updated role is visible to permission checks",
+			test: func(ctx context.Context, t *testing.T, rbac app.RBAC, db app.RoleDB) {
+				err := rbac.CreateRole(ctx, app.RoleDefinition{Name: "moderator", Permissions: []string{"editPosts"}})
+				assert.NoError(t, err)
+
+				ok, err := rbac.DoesRoleHavePermission(ctx, "moderator", "editPosts")
+				assert.NoError(t, err)
+				assert.True(t, ok)
+
+				err = rbac.UpdateRole(ctx, app.RoleDefinition{Name: "moderator", Permissions: []string{"pinPosts"}})
+				assert.NoError(t, err)
+
+				ok, err = rbac.DoesRoleHavePermission(ctx, "moderator", "editPosts")
+				assert.NoError(t, err)
+				assert.False(t, ok)
+			},
+		},
+		{
+			name: "permission checks are served from cache",
+			test: func(ctx context.Context, t *testing.T, rbac app.RBAC, db app.RoleDB) {
+				ok, err := rbac.DoesRoleHavePermission(ctx, app.GuestRole, app.ViewTest)
+				assert.NoError(t, err)
+				assert.True(t, ok)
+
+				err = db.UpdateRole(ctx, app.RoleDefinition{Name: app.GuestRole})
+				assert.NoError(t, err)
+
+				ok, err = rbac.DoesRoleHavePermission(ctx, app.GuestRole, app.ViewTest)
+				assert.NoError(t, err)
+				assert.True(t, ok)
+			},
+		},
+		{
+			name: "create existing role",
+			test: func(ctx context.Context, t *testing.T, rbac app.RBAC, db app.RoleDB) {
+				err := rbac.CreateRole(ctx, app.RoleDefinition{Name: app.GuestRole})
+				assert.Equal(t, app.ErrRoleAlreadyExists, err)
+			},
+		},
+		{
+			name: "invalid role name",
+			test: func(ctx context.Context, t *testing.T, rbac app.RBAC, db app.RoleDB) {
+				err := rbac.CreateRole(ctx, app.RoleDefinition{Name: "Bad Name"})
+				assert.Equal(t, app.ErrRoleNameInvalid, err)
+			},
+		},
+		{
+			name: "guest role cannot be deleted",
+			test: func(ctx context.Context, t *testing.T, rbac app.RBAC, db app.RoleDB) {
+				err := rbac.DeleteRole(ctx, app.GuestRole)
+				assert.Equal(t, app.ErrRoleProtected, err)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			db, teardown, err := local.MakeRoleDB()
+			assert.NoError(t, err)
+
+			defer func() {
+				assert.NoError(t, teardown())
+			}()
+
+			test.test(ctx, t, app.NewRBAC(db), db)
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/rislah/fakes/internal/errors"
+)
+
+// RoleHierarchy is a validated DAG of role definitions. Every role inherits
+// the permissions of all of its ancestors.
+type RoleHierarchy struct {
+	parents     map[Role][]Role
+	permissions map[Role][]string
+}
+
+func NewRoleHierarchy(roles []RoleDefinition) (RoleHierarchy, error) {
+	h := RoleHierarchy{
+		parents:     make(map[Role][]Role, len(roles)),
+		permissions: make(map[Role][]string, len(roles)),
+	}
+
+	for _, role := range roles {
+		h.parents[role.Name] = role.Parents
+		h.permissions[role.Name] = role.Permissions
+	}
+
+	for _, role := range roles {
+		for _, parent := range role.Parents {
+			if _, ok := h.parents[parent]; !ok {
+				return RoleHierarchy{}, ErrRoleParentNotFound
+			}
+		}
+	}
+
+	if err := h.detectCycle(); err != nil {
+		return RoleHierarchy{}, err
+	}
+
+	return h, nil
+}
+
+// Ancestors returns every role the given role inherits from, sorted by name.
+func (h RoleHierarchy) Ancestors(role Role) []Role {
+	seen := map[Role]struct{}{}
+	h.walk(role, seen)
+	delete(seen, role)
+
+	ancestors := make([]Role, 0, len(seen))
+	for r := range seen {
+		ancestors = append(ancestors, r)
+	}
+
+	sort.Slice(ancestors, func(i, j int) bool {
+		return ancestors[i] < ancestors[j]
+	})
+
+	return ancestors
+}
+
+// EffectivePermissions returns the sorted, de-duplicated union of the role's
+// own permissions and the permissions of all of its ancestors.
+func (h RoleHierarchy) EffectivePermissions(role Role) []string {
+	if _, ok := h.parents[role]; !ok {
+		return []string{}
+	}
+
+	seen := map[Role]struct{}{}
+	h.walk(role, seen)
+
+	set := map[string]struct{}{}
+	for r := range seen {
+		for _, permission := range h.permissions[r] {
+			set[permission] = struct{}{}
+		}
+	}
+
+	permissions := make([]string, 0, len(set))
+	for permission := range set {
+		permissions = append(permissions, permission)
+	}
+	sort.Strings(permissions)
+
+	return permissions
+}
+
+// Children returns the roles that list the given role as a direct parent,
+// sorted by name.
+func (h RoleHierarchy) Children(role Role) []Role {
+	children := []Role{}
+	for r, parents := range h.parents {
+		for _, parent := range parents {
+			if parent == role {
+				children = append(children, r)
+				break
+			}
+		}
+	}
+
+	sort.Slice(children, func(i, j int) bool {
+		return children[i] < children[j]
+	})
+
+	return children
+}
+
+func (h RoleHierarchy) walk(role Role, seen map[Role]struct{}) {
+	if _, ok := seen[role]; ok {
+		return
+	}
+
+	seen[role] = struct{}{}
+	for _, parent := range h.parents[role] {
+		h.walk(parent, seen)
+	}
+}
+
+func (h RoleHierarchy) detectCycle() error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[Role]int, len(h.parents))
+
+	var visit func(role Role) bool
+	visit = func(role Role) bool {
+		switch state[role] {
+		case visiting:
+			return true
+		case visited:
+			return false
+		}
+
+		state[role] = visiting
+		for _, parent := range h.parents[role] {
+			if visit(parent) {
+				return true
+			}
+		}
+		state[role] = visited
+
+		return false
+	}
+
+	for role := range h.parents {
+		if visit(role) {
+			return ErrRoleHierarchyCycle
+		}
+	}
+
+	return nil
+}
+
+var (
+	ErrRoleParentNotFound = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Parent role not found",
+	}
+	ErrRoleHierarchyCycle = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Role hierarchy must not contain cycles",
+	}
+)
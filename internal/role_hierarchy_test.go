@@ -0,0 +1,112 @@
+package app_test
+
+import (
+	"testing"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultRoleHierarchyEffectivePermissions(t *testing.T) {
+	hierarchy, err := app.NewRoleHierarchy(app.DefaultRoles())
+	assert.NoError(t, err)
+
+	tests := []struct {
+		role        app.Role
+		ancestors   []app.Role
+		permissions []string
+	}{
+		{
+			role:        app.GuestRole,
+			ancestors:   []app.Role{},
+			permissions: []string{app.ViewTest},
+		},
+		{
+			role:        app.UserRole,
+			ancestors:   []app.Role{app.GuestRole},
+			permissions: []string{app.ViewTest},
+		},
+		{
+			role:        app.DeveloperRole,
+			ancestors:   []app.Role{app.GuestRole, app.UserRole},
+			permissions: []string{app.ManageUsers, app.ViewTest},
+		},
+		{
+			role:        app.AdminRole,
+			ancestors:   []app.Role{app.DeveloperRole, app.GuestRole, app.UserRole},
+			permissions: []string{app.AssignRoles, app.ExplainAuthorization, app.ManageAccountStatus, app.ManageGroups, app.ManageInvites, app.ManageMembers, app.ManageOrganizations, app.ManageRoles, app.ManageUsers, app.ViewAuditLog, app.ViewRouteManifest, app.ViewTest},
+		},
+		{
+			role:        "doesnotexist",
+			ancestors:   []app.Role{},
+			permissions: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.role.String(), func(t *testing.T) {
+			assert.Equal(t, test.ancestors, hierarchy.Ancestors(test.role))
+			assert.Equal(t, test.permissions, hierarchy.EffectivePermissions(test.role))
+		})
+	}
+}
+
+func TestRoleHierarchy(t *testing.T) {
+	tests := []struct {
+		name  string
+		roles []app.RoleDefinition
+		test  func(t *testing.T, hierarchy app.RoleHierarchy, err error)
+	}{
+		{
+			name: "diamond inherits each permission once",
+			roles: []app.RoleDefinition{
+				{Name: "base", Permissions: []string{"read"}},
+				{Name: "left", Parents: []app.Role{"base"}, Permissions: []string{"write"}},
+				{Name: "right", Parents: []app.Role{"base"}, Permissions: []string{"delete", "write"}},
+				{Name: "top", Parents: []app.Role{"right", "left"}},
+			},
+			test: func(t *testing.T, hierarchy app.RoleHierarchy, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"delete", "read", "write"}, hierarchy.EffectivePermissions("top"))
+				assert.Equal(t, []app.Role{"base", "left", "right"}, hierarchy.Ancestors("top"))
+				assert.Equal(t, []app.Role{"left", "right"}, hierarchy.Children("base"))
+			},
+		},
+		{
+			name: "self cycle",
+			roles: []app.RoleDefinition{
+				{Name: "a", Parents: []app.Role{"a"}},
+			},
+			test: func(t *testing.T, hierarchy app.RoleHierarchy, err error) {
+				assert.Equal(t, app.ErrRoleHierarchyCycle, err)
+			},
+		},
+		{
+			name: "indirect cycle",
+			roles: []app.RoleDefinition{
+				{Name: "a", Parents: []app.Role{"c"}},
+				{Name: "b", Parents: []app.Role{"a"}},
+				{Name: "c", Parents: []app.Role{"b"}},
+			},
+			test: func(t *testing.T, hierarchy app.RoleHierarchy, err error) {
+				assert.Equal(t, app.ErrRoleHierarchyCycle, err)
+			},
+		},
+		{
+			name: "unknown parent",
+			roles: []app.RoleDefinition{
+				{Name: "a", Parents: []app.Role{"missing"}},
+			},
+			test: func(t *testing.T, hierarchy app.RoleHierarchy, err error) {
+				assert.Equal(t, app.ErrRoleParentNotFound, err)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hierarchy, err := app.NewRoleHierarchy(test.roles)
+			test.test(t, hierarchy, err)
+		})
+	}
+}
@@ -7,6 +7,7 @@ func (r Role) String() string {
 }
 
 const (
+	AdminRole     Role = "admin"
 	DeveloperRole Role = "developer"
 	UserRole      Role = "user"
 	GuestRole     Role = "guest"
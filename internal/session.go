@@ -0,0 +1,109 @@
+package app
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/rislah/fakes/internal/errors"
+	"github.com/rislah/fakes/internal/jwt"
+	"github.com/rislah/fakes/internal/redis"
+)
+
+const (
+	sessionsRevokedKeyPrefix = "sessions:revoked:"
+	accountStatusKeyPrefix   = "sessions:status:"
+)
+
+// SessionStore revokes issued tokens. Tokens are stateless, so revoking the
+// sessions of a user rejects every token issued to it up to that moment.
+type SessionStore interface {
+	RevokeUserSessions(ctx context.Context, userID string) error
+	// IsSessionRevoked reports whether a token issued to userID at issuedAt
+	// has been revoked.
+	IsSessionRevoked(ctx context.Context, userID string, issuedAt time.Time) (bool, error)
+	// SessionsRevokedAt returns when the sessions of userID were last
+	// revoked, or the zero time when no revocation is in effect.
+	SessionsRevokedAt(ctx context.Context, userID string) (time.Time, error)
+	// SetAccountStatus records the status of an account so that tokens can
+	// be checked without loading the user.
+	SetAccountStatus(ctx context.Context, userID string, status AccountStatus) error
+	// AccountStatus returns StatusActive unless another status was set.
+	AccountStatus(ctx context.Context, userID string) (AccountStatus, error)
+}
+
+type redisSessionStore struct {
+	client redis.Client
+}
+
+// NewRedisSessionStore keeps revocations for as long as the tokens they
+// apply to could still be valid.
+func NewRedisSessionStore(client redis.Client) SessionStore {
+	if client == nil {
+		panic("redis client is required")
+	}
+
+	return &redisSessionStore{client: client}
+}
+
+func (r *redisSessionStore) RevokeUserSessions(ctx context.Context, userID string) error {
+	revokedAt := strconv.FormatInt(time.Now().Unix(), 10)
+	if err := r.client.Set(sessionsRevokedKeyPrefix+userID, revokedAt, jwt.ExpiresIn); err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+// IsSessionRevoked compares whole seconds, the precision of token issue
+// times, so tokens issued within the second of a revocation are rejected too.
+func (r *redisSessionStore) IsSessionRevoked(ctx context.Context, userID string, issuedAt time.Time) (bool, error) {
+	revokedAt, err := r.SessionsRevokedAt(ctx, userID)
+	if err != nil || revokedAt.IsZero() {
+		return false, err
+	}
+
+	return issuedAt.Unix() <= revokedAt.Unix(), nil
+}
+
+func (r *redisSessionStore) SessionsRevokedAt(ctx context.Context, userID string) (time.Time, error) {
+	revokedAt, err := r.client.GetInt64(sessionsRevokedKeyPrefix + userID)
+	if err != nil {
+		if errors.IsWrappedRedisNilError(err) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, errors.New(err)
+	}
+
+	return time.Unix(revokedAt, 0).UTC(), nil
+}
+
+// SetAccountStatus only keeps statuses other than active, for as long as
+// tokens issued before the change could still be valid. Accounts that aren't
+// active can't get new ones.
+func (r *redisSessionStore) SetAccountStatus(ctx context.Context, userID string, status AccountStatus) error {
+	var err error
+	if status == StatusActive {
+		err = r.client.Del(accountStatusKeyPrefix + userID)
+	} else {
+		err = r.client.Set(accountStatusKeyPrefix+userID, status.String(), jwt.ExpiresIn)
+	}
+
+	if err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+func (r *redisSessionStore) AccountStatus(ctx context.Context, userID string) (AccountStatus, error) {
+	status, err := r.client.Get(accountStatusKeyPrefix + userID)
+	if err != nil {
+		if errors.IsWrappedRedisNilError(err) {
+			return StatusActive, nil
+		}
+		return "", errors.New(err)
+	}
+
+	return AccountStatus(status), nil
+}
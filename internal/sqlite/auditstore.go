@@ -0,0 +1,117 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rislah/fakes/internal/audit"
+)
+
+type sqliteAuditStore struct {
+	db *sqlx.DB
+}
+
+var _ audit.Store = &sqliteAuditStore{}
+
+func NewAuditStore(db *sqlx.DB) (*sqliteAuditStore, error) {
+	return &sqliteAuditStore{db: db}, nil
+}
+
+// MakeAuditStore opens a migrated in-memory database for tests. Events
+// can't be deleted, so every test gets a database of its own.
+func MakeAuditStore() (audit.Store, func() error, error) {
+	client, err := NewClient(":memory:")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	store, err := NewAuditStore(client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return store, client.Close, nil
+}
+
+const auditEventColumns = "seq, occurred_at, action, actor_id, actor, target_id, ip, details, prev_hash, hash"
+
+// Append reads the last event and inserts its successor in one transaction.
+// The client has a single connection, so concurrent appends can't fork the
+// chain.
+func (s *sqliteAuditStore) Append(ctx context.Context, event audit.Event) (audit.Event, error) {
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return audit.Event{}, storageError(err)
+	}
+	defer tx.Rollback()
+
+	var last audit.Event
+	err = tx.GetContext(ctx, &last, "select seq, hash from audit_event order by seq desc limit 1")
+	if err != nil && err != sql.ErrNoRows {
+		return audit.Event{}, storageError(err)
+	}
+
+	event = audit.Chain(last, event)
+	_, err = tx.ExecContext(ctx, `
+		insert into audit_event (`+auditEventColumns+`)
+		values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		event.Seq, formatTime(event.OccurredAt), event.Action, event.ActorID, event.Actor, event.TargetID, event.IP, event.Details, event.PrevHash, event.Hash)
+	if err != nil {
+		return audit.Event{}, storageError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return audit.Event{}, storageError(err)
+	}
+
+	return event, nil
+}
+
+func (s *sqliteAuditStore) Search(ctx context.Context, query audit.Query) ([]audit.Event, error) {
+	var args []interface{}
+	sqlQuery := "select " + auditEventColumns + " from audit_event where true"
+	if query.ActorID != "" {
+		sqlQuery += " and actor_id = ?"
+		args = append(args, query.ActorID)
+	}
+	if query.TargetID != "" {
+		sqlQuery += " and target_id = ?"
+		args = append(args, query.TargetID)
+	}
+	if query.Action != "" {
+		sqlQuery += " and action = ?"
+		args = append(args, query.Action)
+	}
+	if !query.From.IsZero() {
+		sqlQuery += " and occurred_at >= ?"
+		args = append(args, formatTime(query.From))
+	}
+	if !query.To.IsZero() {
+		sqlQuery += " and occurred_at < ?"
+		args = append(args, formatTime(query.To))
+	}
+	if query.Before != 0 {
+		sqlQuery += " and seq < ?"
+		args = append(args, query.Before)
+	}
+	sqlQuery += " order by seq desc limit ?"
+	args = append(args, query.Limit)
+
+	events := []audit.Event{}
+	if err := s.db.SelectContext(ctx, &events, sqlQuery, args...); err != nil {
+		return nil, storageError(err)
+	}
+
+	return events, nil
+}
+
+func (s *sqliteAuditStore) Events(ctx context.Context, after int64, limit int) ([]audit.Event, error) {
+	events := []audit.Event{}
+	err := s.db.SelectContext(ctx, &events, "select "+auditEventColumns+" from audit_event where seq > ? order by seq limit ?", after, limit)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,99 @@
+package sqlite
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratesqlite "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jmoiron/sqlx"
+	"modernc.org/sqlite"
+)
+
+// driverName is the modernc driver with foreign keys enforced, which SQLite
+// leaves off unless enabled on every connection.
+const driverName = "sqlite_fakes"
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+func init() {
+	sql.Register(driverName, pragmaDriver{})
+}
+
+type pragmaDriver struct{}
+
+func (pragmaDriver) Open(name string) (driver.Conn, error) {
+	conn, err := (&sqlite.Driver{}).Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pragma := range []string{"pragma foreign_keys = on", "pragma busy_timeout = 5000"} {
+		if _, err := conn.(driver.Execer).Exec(pragma, nil); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// NewClient opens the database file at path, creating it if needed, and
+// migrates it to the latest schema. ":memory:" opens a database that lives
+// as long as the client.
+//
+// SQLite allows a single writer, so the client uses a single connection and
+// queries wait for each other instead of failing with SQLITE_BUSY.
+func NewClient(path string) (*sqlx.DB, error) {
+	db, err := sqlx.Open(driverName, path)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+
+	if !strings.Contains(path, ":memory:") {
+		if _, err := db.Exec("pragma journal_mode = wal"); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	if err := Migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Migrate applies the migrations in the migrations directory, which mirror
+// the Postgres ones version for version in the SQLite dialect.
+func Migrate(db *sqlx.DB) error {
+	source, err := iofs.New(migrations, "migrations")
+	if err != nil {
+		return err
+	}
+
+	// The migrate instance isn't closed, as that would close db.
+	target, err := migratesqlite.WithInstance(db.DB, &migratesqlite.Config{})
+	if err != nil {
+		return err
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "sqlite", target)
+	if err != nil {
+		return err
+	}
+
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	app "github.com/rislah/fakes/internal"
+)
+
+type sqliteErasureDB struct {
+	db *sqlx.DB
+}
+
+var _ app.ErasureDB = &sqliteErasureDB{}
+
+func NewErasureDB(db *sqlx.DB) (*sqliteErasureDB, error) {
+	return &sqliteErasureDB{db: db}, nil
+}
+
+// MakeErasureDB opens a migrated in-memory database for tests.
+func MakeErasureDB() (app.ErasureDB, func() error, error) {
+	client, err := NewClient(":memory:")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := NewErasureDB(client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return db, client.Close, nil
+}
+
+const erasureRequestColumns = "request_id, user_id, status, requested_at, scheduled_for, completed_at"
+
+func (s *sqliteErasureDB) CreateErasureRequest(ctx context.Context, request app.ErasureRequest) (app.ErasureRequest, error) {
+	requestID, err := newUUID()
+	if err != nil {
+		return app.ErasureRequest{}, err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		insert into erasure_request (request_id, user_id, requested_at, scheduled_for)
+		values (?, ?, ?, ?)`, requestID, request.UserID, formatTime(request.RequestedAt), formatTime(request.ScheduledFor))
+	if err != nil {
+		if isUniqueViolation(err, sqliteErasureRequestUserID) {
+			return app.ErasureRequest{}, app.ErrErasureAlreadyRequested
+		}
+		return app.ErasureRequest{}, storageError(err)
+	}
+
+	request.RequestID = requestID
+	request.Status = app.ErasurePending
+	return request, nil
+}
+
+func (s *sqliteErasureDB) GetPendingErasureRequest(ctx context.Context, userID string) (app.ErasureRequest, error) {
+	var request app.ErasureRequest
+	err := s.db.GetContext(ctx, &request, "select "+erasureRequestColumns+" from erasure_request where user_id = ? and status = 'pending'", userID)
+	if err != nil && err != sql.ErrNoRows {
+		return app.ErasureRequest{}, storageError(err)
+	}
+
+	return request, nil
+}
+
+func (s *sqliteErasureDB) DeletePendingErasureRequest(ctx context.Context, userID string) error {
+	res, err := s.db.ExecContext(ctx, "delete from erasure_request where user_id = ? and status = 'pending'", userID)
+	if err != nil {
+		return storageError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return storageError(err)
+	}
+
+	if affected == 0 {
+		return app.ErrErasureRequestNotFound
+	}
+
+	return nil
+}
+
+func (s *sqliteErasureDB) GetDueErasureRequests(ctx context.Context, now time.Time) ([]app.ErasureRequest, error) {
+	requests := []app.ErasureRequest{}
+	err := s.db.SelectContext(ctx, &requests, `
+		select `+erasureRequestColumns+`
+		from erasure_request
+		where status = 'pending' and scheduled_for <= ?
+		order by scheduled_for, id`, formatTime(now))
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return requests, nil
+}
+
+func (s *sqliteErasureDB) CompleteErasureRequest(ctx context.Context, requestID string, completedAt time.Time) error {
+	res, err := s.db.ExecContext(ctx, `
+		update erasure_request
+		set status = 'completed', completed_at = ?
+		where request_id = ? and status = 'pending'`, formatTime(completedAt), requestID)
+	if err != nil {
+		return storageError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return storageError(err)
+	}
+
+	if affected == 0 {
+		return app.ErrErasureRequestNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package sqlite
+
+import (
+	"strings"
+
+	"github.com/rislah/fakes/internal/errors"
+	"modernc.org/sqlite"
+)
+
+const (
+	sqliteBusy     = 5
+	sqliteLocked   = 6
+	sqliteIOErr    = 10
+	sqliteFull     = 13
+	sqliteCantOpen = 14
+
+	sqliteConstraintForeignKey = 787
+	sqliteConstraintPrimaryKey = 1555
+	sqliteConstraintUnique     = 2067
+
+	sqliteUsersUsername = "users.username"
+	sqliteRoleName      = "role.name"
+
+	sqliteErasureRequestUserID = "erasure_request.user_id"
+)
+
+// storageError wraps err in the storage error it corresponds to.
+func storageError(err error) error {
+	return errors.Storage(err, classifySQLiteError)
+}
+
+// classifySQLiteError maps SQLite result codes that aren't handled by the
+// queries themselves onto storage errors. Busy and locked mean that the
+// busy timeout ran out waiting for another writer. As writers are
+// serialized, nothing is a conflict; constraint violations the queries don't
+// expect are internal errors.
+func classifySQLiteError(err error) *errors.WrappedError {
+	sqliteErr, ok := err.(*sqlite.Error)
+	if !ok {
+		return nil
+	}
+
+	switch sqliteErr.Code() & 0xff {
+	case sqliteBusy, sqliteLocked:
+		return errors.ErrStorageTimeout
+	case sqliteIOErr, sqliteFull, sqliteCantOpen:
+		return errors.ErrStorageUnavailable
+	}
+
+	return nil
+}
+
+func isConstraintError(err error, code int) bool {
+	sqliteErr, ok := err.(*sqlite.Error)
+	return ok && sqliteErr.Code() == code
+}
+
+// isUniqueViolation reports whether err violates the unique constraint on
+// column, given as table.column. SQLite names the columns of the constraint
+// rather than the constraint in its message.
+func isUniqueViolation(err error, column string) bool {
+	return isConstraintError(err, sqliteConstraintUnique) && strings.Contains(err.Error(), "UNIQUE constraint failed: "+column+" ")
+}
@@ -0,0 +1,32 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageError(t *testing.T) {
+	db, err := NewClient(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	insert := "insert into users (user_id, username, password_hash) values (?, ?, 'hash')"
+	_, err = db.Exec(insert, "11111111-1111-1111-1111-111111111111", "alice")
+	assert.NoError(t, err)
+
+	_, err = db.Exec(insert, "22222222-2222-2222-2222-222222222222", "alice")
+	assert.True(t, isUniqueViolation(err, sqliteUsersUsername))
+
+	_, err = db.Exec(insert, "11111111-1111-1111-1111-111111111111", "bob")
+	assert.False(t, isUniqueViolation(err, sqliteUsersUsername), "only the username constraint means the username is taken")
+	assert.Equal(t, err, errors.Unwrap(storageError(err)), "unexpected constraint violations aren't conflicts to retry")
+
+	_, err = db.Exec("insert into user_role (user_id) values ('33333333-3333-3333-3333-333333333333')")
+	assert.Error(t, err)
+	assert.False(t, isUniqueViolation(err, sqliteUsersUsername))
+	assert.Equal(t, err, errors.Unwrap(storageError(err)), "foreign key violations aren't conflicts to retry")
+}
@@ -0,0 +1,321 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	app "github.com/rislah/fakes/internal"
+)
+
+type sqliteGroupDB struct {
+	db *sqlx.DB
+}
+
+var _ app.GroupDB = &sqliteGroupDB{}
+
+func NewGroupDB(db *sqlx.DB) (*sqliteGroupDB, error) {
+	return &sqliteGroupDB{db: db}, nil
+}
+
+// MakeGroupDB opens a migrated in-memory database for tests, shared by the
+// user and group databases.
+func MakeGroupDB() (app.UserDB, app.GroupDB, func() error, error) {
+	client, err := NewClient(":memory:")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	userDB, err := NewUserDB(client)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	db, err := NewGroupDB(client)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return userDB, db, client.Close, nil
+}
+
+// groupRow is a group, or one of its roles or permissions, by name.
+type groupRow struct {
+	GroupID string `db:"group_id"`
+	Name    string `db:"name"`
+}
+
+func (s *sqliteGroupDB) CreateGroup(ctx context.Context, group app.Group) (app.Group, error) {
+	if group.GroupID == "" {
+		groupID, err := newUUID()
+		if err != nil {
+			return app.Group{}, err
+		}
+		group.GroupID = groupID
+	}
+
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return app.Group{}, storageError(err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "insert into user_group (group_id, name) values (?, ?)", group.GroupID, group.Name)
+	if err != nil {
+		if isConstraintError(err, sqliteConstraintUnique) {
+			return app.Group{}, app.ErrGroupAlreadyExists
+		}
+		return app.Group{}, storageError(err)
+	}
+
+	if err := setGroupGrants(ctx, tx, group); err != nil {
+		return app.Group{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return app.Group{}, storageError(err)
+	}
+
+	group.Roles = append([]app.Role{}, group.Roles...)
+	group.Permissions = append([]string{}, group.Permissions...)
+	return group, nil
+}
+
+func (s *sqliteGroupDB) GetGroups(ctx context.Context) ([]app.Group, error) {
+	return s.selectGroups(ctx, "true")
+}
+
+func (s *sqliteGroupDB) GetGroup(ctx context.Context, groupID string) (app.Group, error) {
+	groups, err := s.selectGroups(ctx, "g.group_id = ?", groupID)
+	if err != nil {
+		return app.Group{}, err
+	}
+
+	if len(groups) == 0 {
+		return app.Group{}, nil
+	}
+
+	return groups[0], nil
+}
+
+func (s *sqliteGroupDB) GetUserGroups(ctx context.Context, userID string) ([]app.Group, error) {
+	return s.selectGroups(ctx, "g.group_id in (select group_id from group_member where user_id = ?)", userID)
+}
+
+func (s *sqliteGroupDB) UpdateGroup(ctx context.Context, group app.Group) error {
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return storageError(err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "update user_group set name = ? where group_id = ?", group.Name, group.GroupID)
+	if err != nil {
+		if isConstraintError(err, sqliteConstraintUnique) {
+			return app.ErrGroupAlreadyExists
+		}
+		return storageError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return storageError(err)
+	}
+
+	if affected == 0 {
+		return app.ErrGroupNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, "delete from group_role where group_id = ?", group.GroupID); err != nil {
+		return storageError(err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "delete from group_permission where group_id = ?", group.GroupID); err != nil {
+		return storageError(err)
+	}
+
+	if err := setGroupGrants(ctx, tx, group); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return storageError(err)
+	}
+
+	return nil
+}
+
+func (s *sqliteGroupDB) DeleteGroup(ctx context.Context, groupID string) error {
+	res, err := s.db.ExecContext(ctx, "delete from user_group where group_id = ?", groupID)
+	if err != nil {
+		return storageError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return storageError(err)
+	}
+
+	if affected == 0 {
+		return app.ErrGroupNotFound
+	}
+
+	return nil
+}
+
+func (s *sqliteGroupDB) AddGroupMember(ctx context.Context, groupID string, userID string) error {
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return storageError(err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.GetContext(ctx, &exists, "select exists (select 1 from user_group where group_id = ?)", groupID); err != nil {
+		return storageError(err)
+	}
+
+	if !exists {
+		return app.ErrGroupNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, "insert into group_member (group_id, user_id) values (?, ?)", groupID, userID)
+	if err != nil {
+		switch {
+		case isConstraintError(err, sqliteConstraintPrimaryKey):
+			return app.ErrGroupMemberAlreadyExists
+		case isConstraintError(err, sqliteConstraintForeignKey):
+			return app.ErrUserNotFound
+		}
+		return storageError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return storageError(err)
+	}
+
+	return nil
+}
+
+func (s *sqliteGroupDB) RemoveGroupMember(ctx context.Context, groupID string, userID string) error {
+	res, err := s.db.ExecContext(ctx, "delete from group_member where group_id = ? and user_id = ?", groupID, userID)
+	if err != nil {
+		return storageError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return storageError(err)
+	}
+
+	if affected == 0 {
+		return app.ErrGroupMemberNotFound
+	}
+
+	return nil
+}
+
+func (s *sqliteGroupDB) GetGroupMembers(ctx context.Context, groupID string) ([]string, error) {
+	members := []string{}
+	err := s.db.SelectContext(ctx, &members, "select user_id from group_member where group_id = ? order by user_id", groupID)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return members, nil
+}
+
+// selectGroups loads the groups matching condition, a predicate on the
+// user_group table aliased as g, together with their roles and permissions.
+func (s *sqliteGroupDB) selectGroups(ctx context.Context, condition string, args ...interface{}) ([]app.Group, error) {
+	var rows []groupRow
+	err := s.db.SelectContext(ctx, &rows, "select g.group_id, g.name from user_group g where "+condition+" order by g.name", args...)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	var roleRows []groupRow
+	err = s.db.SelectContext(ctx, &roleRows, `
+		select g.group_id, r.name
+		from user_group g
+		inner join group_role gr on gr.group_id = g.group_id
+		inner join role r on r.id = gr.role_id
+		where `+condition+`
+		order by r.name`, args...)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	var permissionRows []groupRow
+	err = s.db.SelectContext(ctx, &permissionRows, `
+		select g.group_id, pm.name
+		from user_group g
+		inner join group_permission gp on gp.group_id = g.group_id
+		inner join permission pm on pm.id = gp.permission_id
+		where `+condition+`
+		order by pm.name`, args...)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	indexByID := map[string]int{}
+	groups := make([]app.Group, 0, len(rows))
+	for _, row := range rows {
+		indexByID[row.GroupID] = len(groups)
+		groups = append(groups, app.Group{GroupID: row.GroupID, Name: row.Name, Roles: []app.Role{}, Permissions: []string{}})
+	}
+
+	for _, row := range roleRows {
+		if i, ok := indexByID[row.GroupID]; ok {
+			groups[i].Roles = append(groups[i].Roles, app.Role(row.Name))
+		}
+	}
+
+	for _, row := range permissionRows {
+		if i, ok := indexByID[row.GroupID]; ok {
+			groups[i].Permissions = append(groups[i].Permissions, row.Name)
+		}
+	}
+
+	return groups, nil
+}
+
+func setGroupGrants(ctx context.Context, tx *sqlx.Tx, group app.Group) error {
+	for _, role := range group.Roles {
+		var roleID int
+		if err := tx.GetContext(ctx, &roleID, "select id from role where name = ?", role); err != nil {
+			if err == sql.ErrNoRows {
+				return app.ErrRoleNotFound
+			}
+			return storageError(err)
+		}
+
+		_, err := tx.ExecContext(ctx, "insert or ignore into group_role (group_id, role_id) values (?, ?)", group.GroupID, roleID)
+		if err != nil {
+			return storageError(err)
+		}
+	}
+
+	for _, permission := range group.Permissions {
+		if err := insertPermission(ctx, tx, permission); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			insert or ignore into group_permission (group_id, permission_id)
+			select ?, id from permission where name = ?`, group.GroupID, permission)
+		if err != nil {
+			return storageError(err)
+		}
+	}
+
+	return nil
+}
+
+func insertPermission(ctx context.Context, tx *sqlx.Tx, permission string) error {
+	if _, err := tx.ExecContext(ctx, "insert or ignore into permission (name) values (?)", permission); err != nil {
+		return storageError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	app "github.com/rislah/fakes/internal"
+)
+
+type sqliteIdentifierDB struct {
+	db *sqlx.DB
+}
+
+var _ app.IdentifierDB = &sqliteIdentifierDB{}
+
+func NewIdentifierDB(db *sqlx.DB) (*sqliteIdentifierDB, error) {
+	return &sqliteIdentifierDB{db: db}, nil
+}
+
+// MakeIdentifierDB opens a migrated in-memory database for tests, shared by
+// the user and identifier databases.
+func MakeIdentifierDB() (app.UserDB, app.IdentifierDB, func() error, error) {
+	client, err := NewClient(":memory:")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	userDB, err := NewUserDB(client)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	db, err := NewIdentifierDB(client)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return userDB, db, client.Close, nil
+}
+
+const identifierColumns = "identifier_id, user_id, type, value, verified_at is not null as verified, code_hash, attempts, created_at"
+
+func (s *sqliteIdentifierDB) CreateIdentifier(ctx context.Context, identifier app.Identifier) (app.Identifier, error) {
+	identifierID, err := newUUID()
+	if err != nil {
+		return app.Identifier{}, err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		insert into user_identifier (identifier_id, user_id, type, value, code_hash, created_at)
+		values (?, ?, ?, ?, ?, ?)`, identifierID, identifier.UserID, identifier.Type, identifier.Value, identifier.CodeHash, formatTime(identifier.CreatedAt))
+	if err != nil {
+		switch {
+		case isConstraintError(err, sqliteConstraintUnique):
+			return app.Identifier{}, app.ErrIdentifierTaken
+		case isConstraintError(err, sqliteConstraintForeignKey):
+			return app.Identifier{}, app.ErrUserNotFound
+		}
+		return app.Identifier{}, storageError(err)
+	}
+
+	identifier.IdentifierID = identifierID
+	identifier.Verified = false
+	identifier.Attempts = 0
+	return identifier, nil
+}
+
+func (s *sqliteIdentifierDB) ListIdentifiers(ctx context.Context, userID string) ([]app.Identifier, error) {
+	identifiers := []app.Identifier{}
+	err := s.db.SelectContext(ctx, &identifiers, `
+		select `+identifierColumns+`
+		from user_identifier
+		where user_id = ?
+		order by created_at, id`, userID)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return identifiers, nil
+}
+
+func (s *sqliteIdentifierDB) GetIdentifier(ctx context.Context, identifierID string) (app.Identifier, error) {
+	var identifier app.Identifier
+	err := s.db.GetContext(ctx, &identifier, `
+		select `+identifierColumns+`
+		from user_identifier
+		where identifier_id = ?`, identifierID)
+	if err != nil && err != sql.ErrNoRows {
+		return app.Identifier{}, storageError(err)
+	}
+
+	return identifier, nil
+}
+
+// VerifyIdentifier counts the attempt and verifies in one transaction. The
+// client has a single connection, so concurrent attempts are all counted.
+// The attempt is kept even when the value turns out to be taken.
+func (s *sqliteIdentifierDB) VerifyIdentifier(ctx context.Context, identifierID string, codeHash string, now time.Time) error {
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return storageError(err)
+	}
+	defer tx.Rollback()
+
+	var identifier app.Identifier
+	err = tx.GetContext(ctx, &identifier, `
+		select `+identifierColumns+`
+		from user_identifier
+		where identifier_id = ?`, identifierID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return app.ErrIdentifierNotFound
+		}
+		return storageError(err)
+	}
+
+	if identifier.Verified {
+		return nil
+	}
+
+	if identifier.Attempts >= app.MaxVerificationAttempts {
+		return app.ErrVerificationCodeInvalid
+	}
+
+	if _, err := tx.ExecContext(ctx, "update user_identifier set attempts = attempts + 1 where identifier_id = ?", identifierID); err != nil {
+		return storageError(err)
+	}
+
+	var outErr error
+	if identifier.CodeHash != codeHash {
+		outErr = app.ErrVerificationCodeInvalid
+	} else {
+		_, err = tx.ExecContext(ctx, "update user_identifier set verified_at = ? where identifier_id = ?", formatTime(now), identifierID)
+		if err != nil {
+			if !isConstraintError(err, sqliteConstraintUnique) {
+				return storageError(err)
+			}
+			outErr = app.ErrIdentifierTaken
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return storageError(err)
+	}
+
+	return outErr
+}
+
+func (s *sqliteIdentifierDB) DeleteIdentifier(ctx context.Context, identifierID string) error {
+	res, err := s.db.ExecContext(ctx, "delete from user_identifier where identifier_id = ?", identifierID)
+	if err != nil {
+		return storageError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return storageError(err)
+	}
+
+	if affected == 0 {
+		return app.ErrIdentifierNotFound
+	}
+
+	return nil
+}
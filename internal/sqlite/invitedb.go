@@ -0,0 +1,135 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	app "github.com/rislah/fakes/internal"
+)
+
+type sqliteInviteDB struct {
+	db *sqlx.DB
+}
+
+var _ app.InviteDB = &sqliteInviteDB{}
+
+func NewInviteDB(db *sqlx.DB) (*sqliteInviteDB, error) {
+	return &sqliteInviteDB{db: db}, nil
+}
+
+// MakeInviteDB opens a migrated in-memory database for tests.
+func MakeInviteDB() (app.InviteDB, func() error, error) {
+	client, err := NewClient(":memory:")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := NewInviteDB(client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return db, client.Close, nil
+}
+
+const inviteColumns = "i.invite_id, i.code_hash, i.email, r.name as role, i.max_uses, i.uses, i.expires_at, i.created_by, i.created_at"
+
+func (s *sqliteInviteDB) CreateInvite(ctx context.Context, invite app.Invite) (app.Invite, error) {
+	inviteID, err := newUUID()
+	if err != nil {
+		return app.Invite{}, err
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+		insert into invite (invite_id, code_hash, email, role_id, max_uses, expires_at, created_by, created_at)
+		select ?, ?, ?, r.id, ?, ?, ?, ?
+		from role r
+		where r.name = ?`, inviteID, invite.CodeHash, invite.Email, invite.MaxUses, formatTime(invite.ExpiresAt), invite.CreatedBy, formatTime(invite.CreatedAt), invite.Role)
+	if err != nil {
+		return app.Invite{}, storageError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return app.Invite{}, storageError(err)
+	}
+
+	if affected == 0 {
+		return app.Invite{}, app.ErrRoleNotFound
+	}
+
+	invite.InviteID = inviteID
+	invite.Code = ""
+	return invite, nil
+}
+
+func (s *sqliteInviteDB) GetInviteByCodeHash(ctx context.Context, codeHash string) (app.Invite, error) {
+	var invite app.Invite
+	err := s.db.GetContext(ctx, &invite, `
+		select `+inviteColumns+`
+		from invite i
+		join role r on r.id = i.role_id
+		where i.code_hash = ?`, codeHash)
+	if err != nil && err != sql.ErrNoRows {
+		return app.Invite{}, storageError(err)
+	}
+
+	return invite, nil
+}
+
+func (s *sqliteInviteDB) ListPendingInvites(ctx context.Context, now time.Time) ([]app.Invite, error) {
+	invites := []app.Invite{}
+	err := s.db.SelectContext(ctx, &invites, `
+		select `+inviteColumns+`
+		from invite i
+		join role r on r.id = i.role_id
+		where i.uses < i.max_uses and i.expires_at > ?
+		order by i.created_at, i.id`, formatTime(now))
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return invites, nil
+}
+
+// RedeemInvite checks and takes a use in a single statement, so that
+// concurrent registrations can't use an invite more than MaxUses times.
+func (s *sqliteInviteDB) RedeemInvite(ctx context.Context, inviteID string, now time.Time) error {
+	return s.updateInvite(ctx, app.ErrInviteInvalid, `
+		update invite
+		set uses = uses + 1
+		where invite_id = ? and uses < max_uses and expires_at > ?`, inviteID, formatTime(now))
+}
+
+func (s *sqliteInviteDB) ReleaseInvite(ctx context.Context, inviteID string) error {
+	return s.updateInvite(ctx, app.ErrInviteNotFound, `
+		update invite
+		set uses = max(uses - 1, 0)
+		where invite_id = ?`, inviteID)
+}
+
+func (s *sqliteInviteDB) DeleteInvite(ctx context.Context, inviteID string) error {
+	return s.updateInvite(ctx, app.ErrInviteNotFound, "delete from invite where invite_id = ?", inviteID)
+}
+
+// updateInvite runs query, which changes a single invite, and returns
+// notFound when it changed none.
+func (s *sqliteInviteDB) updateInvite(ctx context.Context, notFound error, query string, args ...interface{}) error {
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return storageError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return storageError(err)
+	}
+
+	if affected == 0 {
+		return notFound
+	}
+
+	return nil
+}
@@ -0,0 +1,3 @@
+DROP TABLE user_role;
+DROP TABLE users;
+DROP TABLE role;
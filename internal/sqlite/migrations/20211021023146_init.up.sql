@@ -0,0 +1,22 @@
+-- UUIDs are generated by the application, as SQLite has no gen_random_uuid().
+-- Timestamps are stored as UTC text, which sorts chronologically.
+CREATE TABLE role (
+    id   INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT    NOT NULL
+);
+
+INSERT INTO role (name) VALUES ('guest');
+
+CREATE TABLE users (
+    id            INTEGER   PRIMARY KEY AUTOINCREMENT,
+    user_id       TEXT      NOT NULL UNIQUE,
+    username      TEXT      NOT NULL UNIQUE,
+    password_hash TEXT      NOT NULL,
+    created_at    TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE TABLE user_role (
+    id      INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT    NOT NULL UNIQUE REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    role_id INTEGER NOT NULL DEFAULT 1 REFERENCES role(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
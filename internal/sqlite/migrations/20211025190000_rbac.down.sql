@@ -0,0 +1,16 @@
+CREATE TABLE user_role_new (
+    id      INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT    NOT NULL UNIQUE REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    role_id INTEGER NOT NULL DEFAULT 1 REFERENCES role(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+INSERT INTO user_role_new (id, user_id, role_id) SELECT id, user_id, role_id FROM user_role;
+DROP TABLE user_role;
+ALTER TABLE user_role_new RENAME TO user_role;
+
+DROP TABLE role_permission;
+DROP TABLE permission;
+
+DELETE FROM role WHERE name IN ('user', 'developer');
+
+DROP INDEX role_name_key;
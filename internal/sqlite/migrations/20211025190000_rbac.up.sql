@@ -0,0 +1,33 @@
+CREATE UNIQUE INDEX role_name_key ON role (name);
+
+INSERT INTO role (name) VALUES ('user'), ('developer');
+
+CREATE TABLE permission (
+    id   INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT    NOT NULL UNIQUE
+);
+
+CREATE TABLE role_permission (
+    role_id       INTEGER NOT NULL REFERENCES role(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    permission_id INTEGER NOT NULL REFERENCES permission(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+INSERT INTO permission (name) VALUES ('viewTest'), ('manageRoles');
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM role r, permission p
+WHERE (r.name, p.name) IN (VALUES ('guest', 'viewTest'), ('developer', 'manageRoles'));
+
+-- SQLite can't alter constraints, so user_role is rebuilt to restrict
+-- deleting roles that are assigned.
+CREATE TABLE user_role_new (
+    id      INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT    NOT NULL UNIQUE REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    role_id INTEGER NOT NULL DEFAULT 1 REFERENCES role(id) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+
+INSERT INTO user_role_new (id, user_id, role_id) SELECT id, user_id, role_id FROM user_role;
+DROP TABLE user_role;
+ALTER TABLE user_role_new RENAME TO user_role;
@@ -0,0 +1,13 @@
+INSERT INTO role (name) VALUES ('admin');
+
+INSERT INTO permission (name) VALUES ('assignRoles');
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM role r, permission p
+WHERE r.name = 'admin' AND p.name = 'assignRoles';
+
+INSERT INTO role_parent (role_id, parent_id)
+SELECT r.id, p.id
+FROM role r, role p
+WHERE r.name = 'admin' AND p.name = 'developer';
@@ -0,0 +1,22 @@
+CREATE TABLE organization (
+    id         INTEGER   PRIMARY KEY AUTOINCREMENT,
+    org_id     TEXT      NOT NULL UNIQUE,
+    name       TEXT      NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE TABLE organization_member (
+    org_id  TEXT    NOT NULL REFERENCES organization(org_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    user_id TEXT    NOT NULL REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    role_id INTEGER NOT NULL REFERENCES role(id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX organization_member_user_id_idx ON organization_member (user_id);
+
+INSERT INTO permission (name) VALUES ('manageOrganizations'), ('manageMembers');
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM role r, permission p
+WHERE r.name = 'admin' AND p.name IN ('manageOrganizations', 'manageMembers');
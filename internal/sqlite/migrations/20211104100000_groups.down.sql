@@ -0,0 +1,6 @@
+DELETE FROM permission WHERE name = 'manageGroups';
+
+DROP TABLE group_member;
+DROP TABLE group_permission;
+DROP TABLE group_role;
+DROP TABLE user_group;
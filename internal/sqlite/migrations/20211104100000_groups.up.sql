@@ -0,0 +1,33 @@
+CREATE TABLE user_group (
+    id         INTEGER   PRIMARY KEY AUTOINCREMENT,
+    group_id   TEXT      NOT NULL UNIQUE,
+    name       TEXT      NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE TABLE group_role (
+    group_id TEXT    NOT NULL REFERENCES user_group(group_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    role_id  INTEGER NOT NULL REFERENCES role(id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    PRIMARY KEY (group_id, role_id)
+);
+
+CREATE TABLE group_permission (
+    group_id      TEXT    NOT NULL REFERENCES user_group(group_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    permission_id INTEGER NOT NULL REFERENCES permission(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    PRIMARY KEY (group_id, permission_id)
+);
+
+CREATE TABLE group_member (
+    group_id TEXT NOT NULL REFERENCES user_group(group_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    user_id  TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX group_member_user_id_idx ON group_member (user_id);
+
+INSERT INTO permission (name) VALUES ('manageGroups');
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM role r, permission p
+WHERE r.name = 'admin' AND p.name = 'manageGroups';
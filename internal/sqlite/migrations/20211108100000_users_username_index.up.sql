@@ -0,0 +1,3 @@
+-- Serves the ordering, prefix filter and keyset cursor of paginated user
+-- listings. SQLite compares text bytewise by default.
+CREATE INDEX users_username_c_idx ON users (username COLLATE BINARY);
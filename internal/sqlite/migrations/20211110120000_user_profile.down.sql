@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN display_name;
+ALTER TABLE users DROP COLUMN avatar_url;
+ALTER TABLE users DROP COLUMN locale;
+ALTER TABLE users DROP COLUMN timezone;
@@ -0,0 +1,8 @@
+DELETE FROM permission WHERE name = 'manageAccountStatus';
+
+DROP TRIGGER users_status_check_insert;
+DROP TRIGGER users_status_check_update;
+
+ALTER TABLE users DROP COLUMN status;
+ALTER TABLE users DROP COLUMN status_reason;
+ALTER TABLE users DROP COLUMN status_changed_at;
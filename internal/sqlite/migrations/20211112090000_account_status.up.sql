@@ -0,0 +1,30 @@
+-- Added columns can't default to the current time, so existing users are
+-- backfilled and new ones always get status_changed_at from the application.
+-- The status check is a pair of triggers, as a CHECK constraint would keep the
+-- column from being dropped.
+ALTER TABLE users ADD COLUMN status            TEXT      NOT NULL DEFAULT 'active';
+ALTER TABLE users ADD COLUMN status_reason     TEXT      NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN status_changed_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00';
+
+UPDATE users SET status_changed_at = strftime('%Y-%m-%d %H:%M:%f', 'now');
+
+CREATE TRIGGER users_status_check_insert
+    BEFORE INSERT ON users
+    WHEN NEW.status NOT IN ('pending', 'active', 'suspended', 'deactivated')
+BEGIN
+    SELECT RAISE(ABORT, 'CHECK constraint failed: users_status_check');
+END;
+
+CREATE TRIGGER users_status_check_update
+    BEFORE UPDATE OF status ON users
+    WHEN NEW.status NOT IN ('pending', 'active', 'suspended', 'deactivated')
+BEGIN
+    SELECT RAISE(ABORT, 'CHECK constraint failed: users_status_check');
+END;
+
+INSERT INTO permission (name) VALUES ('manageAccountStatus');
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM role r, permission p
+WHERE r.name = 'admin' AND p.name = 'manageAccountStatus';
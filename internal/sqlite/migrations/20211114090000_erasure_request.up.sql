@@ -0,0 +1,14 @@
+-- Erasure requests don't reference users, as the record of a completed
+-- erasure outlives the user it erased.
+CREATE TABLE erasure_request (
+    id            INTEGER   PRIMARY KEY AUTOINCREMENT,
+    request_id    TEXT      NOT NULL UNIQUE,
+    user_id       TEXT      NOT NULL,
+    status        TEXT      NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed')),
+    requested_at  TIMESTAMP NOT NULL,
+    scheduled_for TIMESTAMP NOT NULL,
+    completed_at  TIMESTAMP
+);
+
+CREATE UNIQUE INDEX erasure_request_pending_user_id_idx ON erasure_request (user_id) WHERE status = 'pending';
+CREATE INDEX erasure_request_pending_scheduled_for_idx ON erasure_request (scheduled_for) WHERE status = 'pending';
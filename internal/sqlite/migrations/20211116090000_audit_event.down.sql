@@ -0,0 +1,3 @@
+DELETE FROM permission WHERE name = 'viewAuditLog';
+
+DROP TABLE audit_event;
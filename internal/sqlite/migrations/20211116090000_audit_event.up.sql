@@ -0,0 +1,40 @@
+-- Audit events are chained by hash, and the triggers below make the table
+-- append-only. Actor and target IDs aren't foreign keys, as the record of an
+-- action outlives the users it involves.
+CREATE TABLE audit_event (
+    seq         INTEGER   PRIMARY KEY,
+    occurred_at TIMESTAMP NOT NULL,
+    action      TEXT      NOT NULL,
+    actor_id    TEXT      NOT NULL DEFAULT '',
+    actor       TEXT      NOT NULL DEFAULT '',
+    target_id   TEXT      NOT NULL DEFAULT '',
+    ip          TEXT      NOT NULL DEFAULT '',
+    details     TEXT      NOT NULL DEFAULT '{}' CHECK (json_valid(details)),
+    prev_hash   TEXT      NOT NULL,
+    hash        TEXT      NOT NULL UNIQUE
+);
+
+CREATE INDEX audit_event_actor_id_idx ON audit_event (actor_id, seq) WHERE actor_id <> '';
+CREATE INDEX audit_event_target_id_idx ON audit_event (target_id, seq) WHERE target_id <> '';
+CREATE INDEX audit_event_action_idx ON audit_event (action, seq);
+CREATE INDEX audit_event_occurred_at_idx ON audit_event (occurred_at);
+
+-- SQLite has no TRUNCATE; a DELETE without WHERE fires the delete trigger.
+CREATE TRIGGER audit_event_no_update
+    BEFORE UPDATE ON audit_event
+BEGIN
+    SELECT RAISE(ABORT, 'audit_event is append-only');
+END;
+
+CREATE TRIGGER audit_event_no_delete
+    BEFORE DELETE ON audit_event
+BEGIN
+    SELECT RAISE(ABORT, 'audit_event is append-only');
+END;
+
+INSERT INTO permission (name) VALUES ('viewAuditLog');
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM role r, permission p
+WHERE r.name = 'admin' AND p.name = 'viewAuditLog';
@@ -0,0 +1,17 @@
+-- Emails and phone numbers users can log in with besides their username.
+-- Verified values are unique across users and identifier types, unverified
+-- ones only per user, so that nobody can hold an address they don't control.
+CREATE TABLE user_identifier (
+    id            INTEGER   PRIMARY KEY AUTOINCREMENT,
+    identifier_id TEXT      NOT NULL UNIQUE,
+    user_id       TEXT      NOT NULL REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    type          TEXT      NOT NULL CHECK (type IN ('email', 'phone')),
+    value         TEXT      NOT NULL,
+    code_hash     TEXT      NOT NULL,
+    attempts      INTEGER   NOT NULL DEFAULT 0 CHECK (attempts >= 0),
+    verified_at   TIMESTAMP,
+    created_at    TIMESTAMP NOT NULL,
+    UNIQUE (user_id, value)
+);
+
+CREATE UNIQUE INDEX user_identifier_verified_value_idx ON user_identifier (value) WHERE verified_at IS NOT NULL;
@@ -0,0 +1,145 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	app "github.com/rislah/fakes/internal"
+)
+
+type sqliteOrganizationDB struct {
+	db *sqlx.DB
+}
+
+var _ app.OrganizationDB = &sqliteOrganizationDB{}
+
+func NewOrganizationDB(db *sqlx.DB) (*sqliteOrganizationDB, error) {
+	return &sqliteOrganizationDB{db: db}, nil
+}
+
+// MakeOrganizationDB opens a migrated in-memory database for tests, shared by
+// the user and organization databases.
+func MakeOrganizationDB() (app.UserDB, app.OrganizationDB, func() error, error) {
+	client, err := NewClient(":memory:")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	userDB, err := NewUserDB(client)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	db, err := NewOrganizationDB(client)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return userDB, db, client.Close, nil
+}
+
+func (s *sqliteOrganizationDB) CreateOrganization(ctx context.Context, org app.Organization) (app.Organization, error) {
+	if org.OrgID == "" {
+		orgID, err := newUUID()
+		if err != nil {
+			return app.Organization{}, err
+		}
+		org.OrgID = orgID
+	}
+
+	_, err := s.db.ExecContext(ctx, "insert into organization (org_id, name) values (?, ?)", org.OrgID, org.Name)
+	if err != nil {
+		if isConstraintError(err, sqliteConstraintUnique) {
+			return app.Organization{}, app.ErrOrganizationAlreadyExists
+		}
+		return app.Organization{}, storageError(err)
+	}
+
+	return org, nil
+}
+
+func (s *sqliteOrganizationDB) GetOrganization(ctx context.Context, orgID string) (app.Organization, error) {
+	var org app.Organization
+	err := s.db.GetContext(ctx, &org, "select org_id, name from organization where org_id = ?", orgID)
+	if err != nil && err != sql.ErrNoRows {
+		return app.Organization{}, storageError(err)
+	}
+
+	return org, nil
+}
+
+func (s *sqliteOrganizationDB) AddMember(ctx context.Context, membership app.Membership) error {
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return storageError(err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.GetContext(ctx, &exists, "select exists (select 1 from organization where org_id = ?)", membership.OrgID); err != nil {
+		return storageError(err)
+	}
+
+	if !exists {
+		return app.ErrOrganizationNotFound
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		insert into organization_member (org_id, user_id, role_id)
+		select ?, ?, r.id from role r where r.name = ?`,
+		membership.OrgID, membership.UserID, membership.Role)
+	if err != nil {
+		switch {
+		case isConstraintError(err, sqliteConstraintPrimaryKey):
+			return app.ErrMemberAlreadyExists
+		case isConstraintError(err, sqliteConstraintForeignKey):
+			return app.ErrUserNotFound
+		}
+		return storageError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return storageError(err)
+	}
+
+	if affected == 0 {
+		return app.ErrRoleNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return storageError(err)
+	}
+
+	return nil
+}
+
+func (s *sqliteOrganizationDB) GetMembership(ctx context.Context, orgID string, userID string) (app.Membership, error) {
+	var membership app.Membership
+	err := s.db.GetContext(ctx, &membership, `
+		select om.org_id, om.user_id, r.name as role
+		from organization_member om
+		inner join role r on om.role_id = r.id
+		where om.org_id = ? and om.user_id = ?`, orgID, userID)
+	if err != nil && err != sql.ErrNoRows {
+		return app.Membership{}, storageError(err)
+	}
+
+	return membership, nil
+}
+
+func (s *sqliteOrganizationDB) GetMemberships(ctx context.Context, userID string) ([]app.Membership, error) {
+	memberships := []app.Membership{}
+	err := s.db.SelectContext(ctx, &memberships, `
+		select om.org_id, om.user_id, r.name as role
+		from organization_member om
+		inner join role r on om.role_id = r.id
+		where om.user_id = ?
+		order by om.org_id`, userID)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return memberships, nil
+}
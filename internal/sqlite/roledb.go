@@ -0,0 +1,271 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/jmoiron/sqlx"
+	app "github.com/rislah/fakes/internal"
+)
+
+type sqliteRoleDB struct {
+	db *sqlx.DB
+}
+
+var _ app.RoleDB = &sqliteRoleDB{}
+
+func NewRoleDB(db *sqlx.DB) (*sqliteRoleDB, error) {
+	return &sqliteRoleDB{db: db}, nil
+}
+
+// MakeRoleDB opens a migrated in-memory database for tests.
+func MakeRoleDB() (app.RoleDB, func() error, error) {
+	client, err := NewClient(":memory:")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := NewRoleDB(client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return db, client.Close, nil
+}
+
+type rolePermissionRow struct {
+	Name       string         `db:"name"`
+	Permission sql.NullString `db:"permission"`
+}
+
+type roleParentRow struct {
+	Name   string `db:"name"`
+	Parent string `db:"parent"`
+}
+
+// CreateRole and UpdateRole check the hierarchy inside their transaction.
+// The client has a single connection, so no other write can interleave.
+func (s *sqliteRoleDB) CreateRole(ctx context.Context, role app.RoleDefinition) error {
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return storageError(err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "insert into role (name) values (?)", role.Name)
+	if err != nil {
+		if isUniqueViolation(err, sqliteRoleName) {
+			return app.ErrRoleAlreadyExists
+		}
+		return storageError(err)
+	}
+
+	roleID, err := res.LastInsertId()
+	if err != nil {
+		return storageError(err)
+	}
+
+	if err := setRoleGrants(ctx, tx, roleID, role); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return storageError(err)
+	}
+
+	return nil
+}
+
+func (s *sqliteRoleDB) GetRoles(ctx context.Context) ([]app.RoleDefinition, error) {
+	var rows []rolePermissionRow
+	err := s.db.SelectContext(ctx, &rows, `
+		select r.name, p.name as permission
+		from role r
+		left join role_permission rp on rp.role_id = r.id
+		left join permission p on p.id = rp.permission_id
+		order by r.name, p.name`)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	var parentRows []roleParentRow
+	err = s.db.SelectContext(ctx, &parentRows, `
+		select r.name, p.name as parent
+		from role_parent rp
+		inner join role r on r.id = rp.role_id
+		inner join role p on p.id = rp.parent_id
+		order by r.name, p.name`)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return groupRoleRows(rows, parentRows), nil
+}
+
+func (s *sqliteRoleDB) GetRoleByName(ctx context.Context, name app.Role) (app.RoleDefinition, error) {
+	var rows []rolePermissionRow
+	err := s.db.SelectContext(ctx, &rows, `
+		select r.name, p.name as permission
+		from role r
+		left join role_permission rp on rp.role_id = r.id
+		left join permission p on p.id = rp.permission_id
+		where r.name = ?
+		order by p.name`, name)
+	if err != nil {
+		return app.RoleDefinition{}, storageError(err)
+	}
+
+	var parentRows []roleParentRow
+	err = s.db.SelectContext(ctx, &parentRows, `
+		select r.name, p.name as parent
+		from role_parent rp
+		inner join role r on r.id = rp.role_id
+		inner join role p on p.id = rp.parent_id
+		where r.name = ?
+		order by p.name`, name)
+	if err != nil {
+		return app.RoleDefinition{}, storageError(err)
+	}
+
+	roles := groupRoleRows(rows, parentRows)
+	if len(roles) == 0 {
+		return app.RoleDefinition{}, nil
+	}
+
+	return roles[0], nil
+}
+
+func (s *sqliteRoleDB) UpdateRole(ctx context.Context, role app.RoleDefinition) error {
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return storageError(err)
+	}
+	defer tx.Rollback()
+
+	var roleID int64
+	if err := tx.GetContext(ctx, &roleID, "select id from role where name = ?", role.Name); err != nil {
+		if err == sql.ErrNoRows {
+			return app.ErrRoleNotFound
+		}
+		return storageError(err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "delete from role_permission where role_id = ?", roleID); err != nil {
+		return storageError(err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "delete from role_parent where role_id = ?", roleID); err != nil {
+		return storageError(err)
+	}
+
+	if err := setRoleGrants(ctx, tx, roleID, role); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return storageError(err)
+	}
+
+	return nil
+}
+
+func (s *sqliteRoleDB) DeleteRole(ctx context.Context, name app.Role) error {
+	res, err := s.db.ExecContext(ctx, "delete from role where name = ?", name)
+	if err != nil {
+		if isConstraintError(err, sqliteConstraintForeignKey) {
+			return app.ErrRoleInUse
+		}
+		return storageError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return storageError(err)
+	}
+
+	if affected == 0 {
+		return app.ErrRoleNotFound
+	}
+
+	return nil
+}
+
+// setRoleGrants adds the permissions and parents of role, and rejects
+// parents that don't exist or that inherit from the role.
+func setRoleGrants(ctx context.Context, tx *sqlx.Tx, roleID int64, role app.RoleDefinition) error {
+	for _, permission := range role.Permissions {
+		if err := insertPermission(ctx, tx, permission); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			insert or ignore into role_permission (role_id, permission_id)
+			select ?, id from permission where name = ?`, roleID, permission)
+		if err != nil {
+			return storageError(err)
+		}
+	}
+
+	for _, parent := range role.Parents {
+		var parentID int64
+		if err := tx.GetContext(ctx, &parentID, "select id from role where name = ?", parent); err != nil {
+			if err == sql.ErrNoRows {
+				return app.ErrRoleParentNotFound
+			}
+			return storageError(err)
+		}
+
+		_, err := tx.ExecContext(ctx, "insert or ignore into role_parent (role_id, parent_id) values (?, ?)", roleID, parentID)
+		if err != nil {
+			return storageError(err)
+		}
+	}
+
+	var cycle bool
+	err := tx.GetContext(ctx, &cycle, `
+		with recursive ancestor (id) as (
+			select parent_id from role_parent where role_id = ?1
+			union
+			select rp.parent_id from role_parent rp inner join ancestor a on a.id = rp.role_id
+		)
+		select exists (select 1 from ancestor where id = ?1)`, roleID)
+	if err != nil {
+		return storageError(err)
+	}
+
+	if cycle {
+		return app.ErrRoleHierarchyCycle
+	}
+
+	return nil
+}
+
+func groupRoleRows(rows []rolePermissionRow, parentRows []roleParentRow) []app.RoleDefinition {
+	indexByName := map[string]int{}
+	roles := []app.RoleDefinition{}
+	for _, row := range rows {
+		i, ok := indexByName[row.Name]
+		if !ok {
+			i = len(roles)
+			indexByName[row.Name] = i
+			roles = append(roles, app.RoleDefinition{Name: app.Role(row.Name), Parents: []app.Role{}, Permissions: []string{}})
+		}
+
+		if row.Permission.Valid {
+			roles[i].Permissions = append(roles[i].Permissions, row.Permission.String)
+		}
+	}
+
+	for _, row := range parentRows {
+		if i, ok := indexByName[row.Name]; ok {
+			roles[i].Parents = append(roles[i].Parents, app.Role(row.Parent))
+		}
+	}
+
+	for _, role := range roles {
+		sort.Strings(role.Permissions)
+	}
+
+	return roles
+}
@@ -0,0 +1,491 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	app "github.com/rislah/fakes/internal"
+)
+
+// timeFormat stores times as UTC text that sorts chronologically and that
+// the driver parses back into time.Time for TIMESTAMP columns.
+const timeFormat = "2006-01-02 15:04:05.000000"
+
+type sqliteUserDB struct {
+	db *sqlx.DB
+}
+
+var _ app.UserDB = &sqliteUserDB{}
+var _ app.UserBatchCreator = &sqliteUserDB{}
+
+func NewUserDB(db *sqlx.DB) (*sqliteUserDB, error) {
+	return &sqliteUserDB{db: db}, nil
+}
+
+// MakeUserDB opens a migrated in-memory database for tests.
+func MakeUserDB() (app.UserDB, func() error, error) {
+	client, err := NewClient(":memory:")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := NewUserDB(client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return db, client.Close, nil
+}
+
+func (s *sqliteUserDB) CreateUser(ctx context.Context, user app.User) error {
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return storageError(err)
+	}
+	defer tx.Rollback()
+
+	userID, err := insertUser(ctx, tx, user)
+	if err != nil {
+		if isUniqueViolation(err, sqliteUsersUsername) {
+			return app.ErrUserAlreadyExists
+		}
+		return storageError(err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "insert into user_role (user_id) values (?)", userID); err != nil {
+		return storageError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return storageError(err)
+	}
+
+	return nil
+}
+
+// CreateUsers inserts users in a single transaction. Each user is inserted
+// under a savepoint, so that a duplicate username or an unknown role only
+// skips that user.
+func (s *sqliteUserDB) CreateUsers(ctx context.Context, users []app.User) ([]error, error) {
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return nil, storageError(err)
+	}
+	defer tx.Rollback()
+
+	userErrs := make([]error, len(users))
+	for i, user := range users {
+		if _, err := tx.ExecContext(ctx, "savepoint create_user"); err != nil {
+			return nil, storageError(err)
+		}
+
+		role := user.Role
+		if role == "" {
+			role = app.GuestRole
+		}
+
+		userID, err := insertUser(ctx, tx, user)
+		switch {
+		case err == nil:
+			res, err := tx.ExecContext(ctx, `
+				insert into user_role (user_id, role_id)
+				select ?, r.id from role r where r.name = ?`, userID, role)
+			if err != nil {
+				return nil, storageError(err)
+			}
+
+			affected, err := res.RowsAffected()
+			if err != nil {
+				return nil, storageError(err)
+			}
+
+			if affected > 0 {
+				if _, err := tx.ExecContext(ctx, "release savepoint create_user"); err != nil {
+					return nil, storageError(err)
+				}
+				continue
+			}
+
+			userErrs[i] = app.ErrRoleNotFound
+		case isUniqueViolation(err, sqliteUsersUsername):
+			userErrs[i] = app.ErrUserAlreadyExists
+		default:
+			return nil, storageError(err)
+		}
+
+		// Rolling back to a savepoint keeps it open, so it is released
+		// before the next user.
+		if _, err := tx.ExecContext(ctx, "rollback to savepoint create_user"); err != nil {
+			return nil, storageError(err)
+		}
+		if _, err := tx.ExecContext(ctx, "release savepoint create_user"); err != nil {
+			return nil, storageError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, storageError(err)
+	}
+
+	return userErrs, nil
+}
+
+func insertUser(ctx context.Context, tx *sqlx.Tx, user app.User) (string, error) {
+	userID, err := newUUID()
+	if err != nil {
+		return "", err
+	}
+
+	status := user.Status
+	if status == "" {
+		status = app.StatusActive
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		insert into users (user_id, username, password_hash, display_name, avatar_url, locale, timezone, status, status_changed_at)
+		values (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		userID, user.Username, user.Password, user.DisplayName, user.AvatarURL, user.Locale, user.Timezone, status, formatTime(time.Now()))
+	if err != nil {
+		return "", err
+	}
+
+	return userID, nil
+}
+
+func (s *sqliteUserDB) GetUsers(ctx context.Context) ([]app.User, error) {
+	var users []app.User
+
+	var err error
+	if orgID := app.OrganizationFromContext(ctx); orgID != "" {
+		err = s.db.SelectContext(ctx, &users, `
+			select u.user_id, u.username, u.password_hash, u.display_name, u.avatar_url, u.locale, u.timezone, u.status, u.status_reason, u.status_changed_at, r.name as role
+			from users u
+			inner join organization_member om on u.user_id = om.user_id
+			inner join role r on om.role_id = r.id
+			where om.org_id = ?`, orgID)
+	} else {
+		err = s.db.SelectContext(ctx, &users, `
+			select u.user_id, u.username, u.password_hash, u.display_name, u.avatar_url, u.locale, u.timezone, u.status, u.status_reason, u.status_changed_at, r.name as role
+			from users u
+			inner join user_role ur on u.user_id = ur.user_id
+			inner join role r on ur.role_id = r.id`)
+	}
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return users, nil
+}
+
+func (s *sqliteUserDB) ListUsers(ctx context.Context, filter app.UserFilter) ([]app.User, error) {
+	from, args := usersMatching(ctx, filter)
+
+	// Text is compared bytewise by default, like the "C" collation the
+	// Postgres listing uses.
+	query := "select u.user_id, u.username, u.password_hash, u.display_name, u.avatar_url, u.locale, u.timezone, u.status, u.status_reason, u.status_changed_at, r.name as role" + from
+
+	order := "asc"
+	if filter.After != "" {
+		operator := ">"
+		if filter.Descending {
+			operator = "<"
+		}
+		query += " and u.username " + operator + " ?"
+		args = append(args, filter.After)
+	}
+	if filter.Descending {
+		order = "desc"
+	}
+	query += " order by u.username " + order
+
+	if filter.Limit > 0 {
+		query += " limit ?"
+		args = append(args, filter.Limit)
+	}
+
+	// SQLite only takes an offset after a limit, and a negative one is
+	// unlimited.
+	if filter.Offset > 0 {
+		if filter.Limit <= 0 {
+			query += " limit -1"
+		}
+		query += " offset ?"
+		args = append(args, filter.Offset)
+	}
+
+	users := []app.User{}
+	if err := s.db.SelectContext(ctx, &users, query, args...); err != nil {
+		return nil, storageError(err)
+	}
+
+	return users, nil
+}
+
+func (s *sqliteUserDB) CountUsers(ctx context.Context, filter app.UserFilter) (int, error) {
+	from, args := usersMatching(ctx, filter)
+
+	var count int
+	if err := s.db.GetContext(ctx, &count, "select count(*)"+from, args...); err != nil {
+		return 0, storageError(err)
+	}
+
+	return count, nil
+}
+
+// usersMatching returns the from and where clauses selecting the users of the
+// organization in ctx, or all users, that have filter's role and username
+// prefix.
+func usersMatching(ctx context.Context, filter app.UserFilter) (string, []interface{}) {
+	var args []interface{}
+
+	var query string
+	if orgID := app.OrganizationFromContext(ctx); orgID != "" {
+		query = `
+			from users u
+			inner join organization_member om on u.user_id = om.user_id
+			inner join role r on om.role_id = r.id
+			where om.org_id = ?`
+		args = append(args, orgID)
+	} else {
+		query = `
+			from users u
+			inner join user_role ur on u.user_id = ur.user_id
+			inner join role r on ur.role_id = r.id
+			where true`
+	}
+
+	if filter.Role != "" {
+		query += " and r.name = ?"
+		args = append(args, filter.Role)
+	}
+
+	// SQLite's like ignores ASCII case, so the prefix is compared directly.
+	if filter.UsernamePrefix != "" {
+		query += " and substr(u.username, 1, length(?)) = ?"
+		args = append(args, filter.UsernamePrefix, filter.UsernamePrefix)
+	}
+
+	return query, args
+}
+
+func (s *sqliteUserDB) GetUserByUsername(ctx context.Context, username string) (app.User, error) {
+	return s.getUser(ctx, "u.username = ?", username)
+}
+
+func (s *sqliteUserDB) GetUserByIdentifier(ctx context.Context, identifier string) (app.User, error) {
+	return s.getUser(ctx, `
+		u.username = ?1
+		or u.user_id = (select ui.user_id from user_identifier ui where ui.value = ?1 and ui.verified_at is not null)`, identifier)
+}
+
+func (s *sqliteUserDB) GetUserByID(ctx context.Context, userID string) (app.User, error) {
+	return s.getUser(ctx, "u.user_id = ?", userID)
+}
+
+// getUser returns the user matching where. Scoped to an organization, only
+// its members match and the role is the one they hold in it.
+func (s *sqliteUserDB) getUser(ctx context.Context, where string, arg interface{}) (app.User, error) {
+	query := `
+		select u.user_id, u.username, u.password_hash, u.display_name, u.avatar_url, u.locale, u.timezone, u.status, u.status_reason, u.status_changed_at, r.name as role
+		from users u
+		inner join user_role ur on u.user_id = ur.user_id
+		inner join role r on ur.role_id = r.id
+		where ` + where
+	args := []interface{}{arg}
+	if orgID := app.OrganizationFromContext(ctx); orgID != "" {
+		query = `
+			select u.user_id, u.username, u.password_hash, u.display_name, u.avatar_url, u.locale, u.timezone, u.status, u.status_reason, u.status_changed_at, r.name as role
+			from users u
+			inner join organization_member om on u.user_id = om.user_id
+			inner join role r on om.role_id = r.id
+			where (` + where + `) and om.org_id = ?`
+		args = append(args, orgID)
+	}
+
+	var user app.User
+	err := s.db.GetContext(ctx, &user, query, args...)
+	if err != nil && err != sql.ErrNoRows {
+		return app.User{}, storageError(err)
+	}
+
+	return user, nil
+}
+
+func (s *sqliteUserDB) UpdateUser(ctx context.Context, user app.User) error {
+	res, err := s.db.ExecContext(ctx, `
+		update users
+		set username = ?, password_hash = ?, display_name = ?, avatar_url = ?, locale = ?, timezone = ?
+		where user_id = ?`, user.Username, user.Password, user.DisplayName, user.AvatarURL, user.Locale, user.Timezone, user.UserID)
+	if err != nil {
+		if isUniqueViolation(err, sqliteUsersUsername) {
+			return app.ErrUserAlreadyExists
+		}
+		return storageError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return storageError(err)
+	}
+
+	if affected == 0 {
+		return app.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (s *sqliteUserDB) UpdateUserStatus(ctx context.Context, userID string, change app.StatusChange) error {
+	res, err := s.db.ExecContext(ctx, `
+		update users
+		set status = ?, status_reason = ?, status_changed_at = ?
+		where user_id = ? and status = ?`, change.To, change.Reason, formatTime(change.At), userID, change.From)
+	if err != nil {
+		return storageError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return storageError(err)
+	}
+
+	if affected > 0 {
+		return nil
+	}
+
+	var exists bool
+	if err := s.db.GetContext(ctx, &exists, "select exists (select 1 from users where user_id = ?)", userID); err != nil {
+		return storageError(err)
+	}
+
+	if !exists {
+		return app.ErrUserNotFound
+	}
+
+	return app.ErrAccountStatusConflict
+}
+
+// DeleteUser and UpdateUserRole count the administrators inside their
+// transaction. The client has a single connection, so no other write can
+// interleave.
+func (s *sqliteUserDB) DeleteUser(ctx context.Context, userID string) error {
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return storageError(err)
+	}
+	defer tx.Rollback()
+
+	role, err := userRole(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+
+	if role == app.AdminRole {
+		if err := ensureAnotherAdmin(ctx, tx); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "delete from users where user_id = ?", userID); err != nil {
+		return storageError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return storageError(err)
+	}
+
+	return nil
+}
+
+func (s *sqliteUserDB) UpdateUserRole(ctx context.Context, userID string, role app.Role) (app.Role, error) {
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return "", storageError(err)
+	}
+	defer tx.Rollback()
+
+	var roleID int
+	if err := tx.GetContext(ctx, &roleID, "select id from role where name = ?", role); err != nil {
+		if err == sql.ErrNoRows {
+			return "", app.ErrRoleNotFound
+		}
+		return "", storageError(err)
+	}
+
+	previous, err := userRole(ctx, tx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if previous == app.AdminRole && role != app.AdminRole {
+		if err := ensureAnotherAdmin(ctx, tx); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "update user_role set role_id = ? where user_id = ?", roleID, userID); err != nil {
+		return "", storageError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", storageError(err)
+	}
+
+	return previous, nil
+}
+
+func userRole(ctx context.Context, tx *sqlx.Tx, userID string) (app.Role, error) {
+	var role app.Role
+	err := tx.GetContext(ctx, &role, `
+		select r.name
+		from user_role ur
+		inner join role r on ur.role_id = r.id
+		where ur.user_id = ?`, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", app.ErrUserNotFound
+		}
+		return "", storageError(err)
+	}
+
+	return role, nil
+}
+
+func ensureAnotherAdmin(ctx context.Context, tx *sqlx.Tx) error {
+	var admins int
+	err := tx.GetContext(ctx, &admins, `
+		select count(*)
+		from user_role ur
+		inner join role r on ur.role_id = r.id
+		where r.name = ?`, app.AdminRole)
+	if err != nil {
+		return storageError(err)
+	}
+
+	if admins <= 1 {
+		return app.ErrLastAdministrator
+	}
+
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+// newUUID generates the random IDs Postgres generates with gen_random_uuid.
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
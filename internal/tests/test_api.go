@@ -4,19 +4,25 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	stderrors "errors"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
 	jwtPkg "github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
 	"github.com/rislah/fakes/api"
 	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/audit"
 	"github.com/rislah/fakes/internal/credentials"
 	"github.com/rislah/fakes/internal/errors"
 	"github.com/rislah/fakes/internal/geoip"
 	"github.com/rislah/fakes/internal/jwt"
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/pow"
 	"github.com/rislah/fakes/internal/redis"
 	"github.com/stretchr/testify/assert"
 )
@@ -69,10 +75,50 @@ func TestAPIGetUsers(t *testing.T, makeUserDB MakeUserDB, makeRedis MakeRedis) {
 				assert.NoError(t, err)
 				assert.Equal(t, rr.Result().StatusCode, 200)
 
-				var users []app.User
-				err = json.NewDecoder(rr.Body).Decode(&users)
+				var page api.GetUsersPageResponse
+				err = json.NewDecoder(rr.Body).Decode(&page)
 				assert.NoError(t, err)
-				assert.NotEmpty(t, users)
+				assert.NotEmpty(t, page.Users)
+				assert.Empty(t, page.NextCursor)
+			},
+		},
+		{
+			name: "should paginate users",
+			test: func(ctx context.Context, apiTestCase apiTestCase) {
+				for _, username := range []string{"carol", "alice", "bob"} {
+					err := apiTestCase.db.CreateUser(ctx, app.User{Username: username, Password: "pass"})
+					assert.NoError(t, err)
+				}
+
+				rr := serveJSON(t, apiTestCase.am, "GET", "/users?limit=2&sort=username", "", nil)
+				assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode)
+
+				token, err := jwt.NewHS256Wrapper("secret").Encode(jwt.NewUserClaims("dev", app.DeveloperRole.String()))
+				assert.NoError(t, err)
+
+				rr = serveJSON(t, apiTestCase.am, "GET", "/users?limit=2&sort=username", token, nil)
+				assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+				var page api.GetUsersPageResponse
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&page))
+				assert.Len(t, page.Users, 2)
+				assert.Equal(t, "alice", page.Users[0].Username)
+				assert.NotEmpty(t, page.NextCursor)
+
+				next := "/users?cursor=" + page.NextCursor + "&limit=2&sort=username"
+				assert.Equal(t, "<"+next+`>; rel="next"`, rr.Result().Header.Get("Link"))
+
+				rr = serveJSON(t, apiTestCase.am, "GET", next, token, nil)
+				assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+				assert.Empty(t, rr.Result().Header.Get("Link"))
+
+				page = api.GetUsersPageResponse{}
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&page))
+				assert.Equal(t, []api.GetUsersResponse{{UserID: page.Users[0].UserID, Username: "carol", Role: app.GuestRole}}, page.Users)
+				assert.Empty(t, page.NextCursor)
+
+				rr = serveJSON(t, apiTestCase.am, "GET", "/users?limit=abc", token, nil)
+				assert.Equal(t, int(app.ErrInvalidPageSize.Code), rr.Result().StatusCode)
 			},
 		},
 	}
@@ -93,13 +139,14 @@ func TestAPIGetUsers(t *testing.T, makeUserDB MakeUserDB, makeRedis MakeRedis) {
 			assert.NoError(t, err)
 
 			authenticator := app.NewAuthenticator(db, jwtWrapper)
+			rbac := app.NewRBAC(local.NewRoleDB())
 
 			redis, teardown, err := makeRedis()
 			assert.NoError(t, err)
 
 			defer teardown()
 
-			apiMux := api.NewMux(usr, authenticator, jwtWrapper, geoip.GeoIP{}, redis, nil)
+			apiMux := api.NewMux(usr, app.NewOrganizationBackend(local.NewUserDB()), app.NewGroupBackend(local.NewUserDB()), nil, authenticator, rbac, jwtWrapper, geoip.GeoIP{}, redis, nil)
 			test.test(ctx, apiTestCase{
 				am:          apiMux,
 				db:          db,
@@ -239,13 +286,14 @@ func TestAPILogin(t *testing.T, makeUserDB MakeUserDB, makeRedis MakeRedis) {
 			assert.NoError(t, err)
 
 			authenticator := app.NewAuthenticator(db, jwtWrapper)
+			rbac := app.NewRBAC(local.NewRoleDB())
 
 			redis, teardown, err := makeRedis()
 			assert.NoError(t, err)
 
 			defer teardown()
 
-			apiMux := api.NewMux(usr, authenticator, jwtWrapper, geoip.GeoIP{}, redis, nil)
+			apiMux := api.NewMux(usr, app.NewOrganizationBackend(local.NewUserDB()), app.NewGroupBackend(local.NewUserDB()), nil, authenticator, rbac, jwtWrapper, geoip.GeoIP{}, redis, nil)
 			test.test(ctx, apiTestCase{
 				am:          apiMux,
 				db:          db,
@@ -256,6 +304,1508 @@ func TestAPILogin(t *testing.T, makeUserDB MakeUserDB, makeRedis MakeRedis) {
 		})
 	}
 }
+
+func TestAPIUpdateUserRole(t *testing.T, makeUserDB MakeUserDB, makeRedis MakeRedis) {
+	tests := []struct {
+		name string
+		test func(ctx context.Context, apiTestCase apiTestCase)
+	}{
+		{
+			name: "should update role",
+			test: func(ctx context.Context, apiTestCase apiTestCase) {
+				err := apiTestCase.db.CreateUser(ctx, app.User{Username: "user", Password: "pass"})
+				assert.NoError(t, err)
+
+				usr, err := apiTestCase.db.GetUserByUsername(ctx, "user")
+				assert.NoError(t, err)
+
+				rr := updateUserRole(t, ctx, apiTestCase, usr.UserID, app.DeveloperRole)
+				assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+				var res api.UpdateUserRoleResponse
+				err = json.NewDecoder(rr.Body).Decode(&res)
+				assert.NoError(t, err)
+				assert.Equal(t, api.UpdateUserRoleResponse{UserID: usr.UserID, Role: app.DeveloperRole}, res)
+
+				usr, err = apiTestCase.db.GetUserByUsername(ctx, "user")
+				assert.NoError(t, err)
+				assert.Equal(t, app.DeveloperRole, usr.Role)
+			},
+		},
+		{
+			name: "should return not found for unknown user",
+			test: func(ctx context.Context, apiTestCase apiTestCase) {
+				rr := updateUserRole(t, ctx, apiTestCase, "11111111-1111-1111-1111-111111111111", app.DeveloperRole)
+				assert.Equal(t, int(app.ErrUserNotFound.Code), rr.Result().StatusCode)
+			},
+		},
+		{
+			name: "should not demote last administrator",
+			test: func(ctx context.Context, apiTestCase apiTestCase) {
+				err := apiTestCase.db.CreateUser(ctx, app.User{Username: "admin", Password: "pass"})
+				assert.NoError(t, err)
+
+				usr, err := apiTestCase.db.GetUserByUsername(ctx, "admin")
+				assert.NoError(t, err)
+
+				rr := updateUserRole(t, ctx, apiTestCase, usr.UserID, app.AdminRole)
+				assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+				rr = updateUserRole(t, ctx, apiTestCase, usr.UserID, app.GuestRole)
+				assert.Equal(t, http.StatusConflict, rr.Result().StatusCode)
+
+				var errResponse errors.ErrorResponse
+				err = json.NewDecoder(rr.Body).Decode(&errResponse)
+				assert.NoError(t, err)
+				assert.Equal(t, app.ErrLastAdministrator.Msg, errResponse.Message)
+			},
+		},
+		{
+			name: "should revoke sessions of a demoted user",
+			test: func(ctx context.Context, apiTestCase apiTestCase) {
+				authenticator := app.NewAuthenticator(apiTestCase.db, jwt.NewHS256Wrapper("secret"))
+
+				admin := createUser(ctx, t, apiTestCase.db, "admin")
+				_, err := apiTestCase.db.UpdateUserRole(ctx, admin.UserID, app.AdminRole)
+				assert.NoError(t, err)
+				admin.Role = app.AdminRole
+
+				dev := createUser(ctx, t, apiTestCase.db, "dev")
+				_, err = apiTestCase.db.UpdateUserRole(ctx, dev.UserID, app.DeveloperRole)
+				assert.NoError(t, err)
+				dev.Role = app.DeveloperRole
+
+				adminToken, err := authenticator.GenerateJWT(admin, app.Grants{})
+				assert.NoError(t, err)
+				devToken, err := authenticator.GenerateJWT(dev, app.Grants{})
+				assert.NoError(t, err)
+
+				rr := serveJSON(t, apiTestCase.am, "GET", "/users", devToken, nil)
+				assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+				rr = serveJSON(t, apiTestCase.am, "PUT", "/users/"+dev.UserID+"/role", adminToken, api.UpdateUserRoleRequest{Role: app.GuestRole})
+				assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+				rr = serveJSON(t, apiTestCase.am, "GET", "/users", devToken, nil)
+				assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode, "tokens issued before the demotion carry the old role")
+
+				var errResponse errors.ErrorResponse
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&errResponse))
+				assert.Equal(t, api.ErrAuthSessionRevoked.Msg, errResponse.Message)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			testCase, teardown := newAPITestCase(t, makeUserDB, makeRedis)
+			defer teardown()
+
+			test.test(ctx, testCase)
+		})
+	}
+}
+
+func updateUserRole(t *testing.T, ctx context.Context, apiTestCase apiTestCase, userID string, role app.Role) *httptest.ResponseRecorder {
+	b, err := json.Marshal(api.UpdateUserRoleRequest{Role: role})
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest("PUT", "/users/"+userID+"/role", bytes.NewBuffer(b))
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	resp := &api.Response{ResponseWriter: rr}
+	ctxIP := addIPToContext(ctx, net.ParseIP("127.0.0.1"))
+	req = mux.SetURLVars(req.WithContext(ctxIP), map[string]string{"user_id": userID})
+
+	err = apiTestCase.am.UpdateUserRole(ctxIP, resp, req)
+	assert.NoError(t, err)
+
+	return rr
+}
+
+func TestAPIExplainAuthorization(t *testing.T, makeUserDB MakeUserDB, makeRedis MakeRedis) {
+	jwtWrapper := jwt.NewHS256Wrapper("secret")
+
+	tests := []struct {
+		name string
+		test func(ctx context.Context, apiTestCase apiTestCase)
+	}{
+		{
+			name: "should explain missing permission",
+			test: func(ctx context.Context, apiTestCase apiTestCase) {
+				err := apiTestCase.db.CreateUser(ctx, app.User{Username: "user", Password: "pass"})
+				assert.NoError(t, err)
+
+				rr := explainAuthorization(t, ctx, apiTestCase, api.ExplainAuthorizationRequest{
+					Username: "user",
+					Method:   "put",
+					Path:     "/users/11111111-1111-1111-1111-111111111111/role",
+				})
+				assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+				var res api.ExplainAuthorizationResponse
+				err = json.NewDecoder(rr.Body).Decode(&res)
+				assert.NoError(t, err)
+				assert.Equal(t, "PUT", res.Method)
+				assert.Equal(t, "/users/{user_id}/role", res.Route)
+				assert.Equal(t, "user", res.Username)
+				assert.False(t, res.Allowed)
+				assert.Equal(t, app.GuestRole, res.Role)
+				assert.Equal(t, []app.Role{}, res.InheritedRoles)
+				assert.Equal(t, []string{app.ViewTest}, res.EffectivePermissions)
+				assert.Equal(t, []string{app.AssignRoles}, res.MissingPermissions)
+				assert.Equal(t, []string{"missing permission assignRoles"}, res.Reasons)
+			},
+		},
+		{
+			name: "should explain allowed token",
+			test: func(ctx context.Context, apiTestCase apiTestCase) {
+				token, err := jwtWrapper.Encode(jwt.NewUserClaims("dev", app.DeveloperRole.String()))
+				assert.NoError(t, err)
+
+				rr := explainAuthorization(t, ctx, apiTestCase, api.ExplainAuthorizationRequest{
+					Token:  token,
+					Method: "GET",
+					Path:   "/users",
+				})
+				assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+				var res api.ExplainAuthorizationResponse
+				err = json.NewDecoder(rr.Body).Decode(&res)
+				assert.NoError(t, err)
+				assert.True(t, res.Allowed)
+				assert.Empty(t, res.TokenError)
+				assert.Equal(t, []app.Role{app.GuestRole, app.UserRole}, res.InheritedRoles)
+				assert.Equal(t, []string{app.ManageUsers}, res.RequiredPermissions)
+				assert.Empty(t, res.MissingPermissions)
+			},
+		},
+		{
+			name: "should explain expired token",
+			test: func(ctx context.Context, apiTestCase apiTestCase) {
+				claims := jwt.NewUserClaims("dev", app.DeveloperRole.String())
+				claims.ExpiresAt = jwtPkg.NewNumericDate(time.Now().Add(-time.Minute))
+				token, err := jwtWrapper.Encode(claims)
+				assert.NoError(t, err)
+
+				rr := explainAuthorization(t, ctx, apiTestCase, api.ExplainAuthorizationRequest{
+					Token:  token,
+					Method: "GET",
+					Path:   "/roles",
+				})
+				assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+				var res api.ExplainAuthorizationResponse
+				err = json.NewDecoder(rr.Body).Decode(&res)
+				assert.NoError(t, err)
+				assert.False(t, res.Allowed)
+				assert.Equal(t, jwt.ErrJWTExpired.Msg, res.TokenError)
+			},
+		},
+		{
+			name: "should explain token with invalid signature",
+			test: func(ctx context.Context, apiTestCase apiTestCase) {
+				token, err := jwt.NewHS256Wrapper("other").Encode(jwt.NewUserClaims("dev", app.DeveloperRole.String()))
+				assert.NoError(t, err)
+
+				rr := explainAuthorization(t, ctx, apiTestCase, api.ExplainAuthorizationRequest{
+					Token:  token,
+					Method: "GET",
+					Path:   "/roles",
+				})
+				assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+				var res api.ExplainAuthorizationResponse
+				err = json.NewDecoder(rr.Body).Decode(&res)
+				assert.NoError(t, err)
+				assert.False(t, res.Allowed)
+				assert.Equal(t, jwtPkg.ErrSignatureInvalid.Error(), res.TokenError)
+			},
+		},
+		{
+			name: "should allow public route",
+			test: func(ctx context.Context, apiTestCase apiTestCase) {
+				rr := explainAuthorization(t, ctx, apiTestCase, api.ExplainAuthorizationRequest{
+					Username: "nobody",
+					Method:   "POST",
+					Path:     "/login",
+				})
+				assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+				var res api.ExplainAuthorizationResponse
+				err := json.NewDecoder(rr.Body).Decode(&res)
+				assert.NoError(t, err)
+				assert.True(t, res.Allowed)
+			},
+		},
+		{
+			name: "should return not found for unknown route",
+			test: func(ctx context.Context, apiTestCase apiTestCase) {
+				rr := explainAuthorization(t, ctx, apiTestCase, api.ExplainAuthorizationRequest{
+					Username: "user",
+					Method:   "PATCH",
+					Path:     "/roles",
+				})
+				assert.Equal(t, int(api.ErrExplainRouteNotFound.Code), rr.Result().StatusCode)
+			},
+		},
+		{
+			name: "should return not found for unknown user",
+			test: func(ctx context.Context, apiTestCase apiTestCase) {
+				rr := explainAuthorization(t, ctx, apiTestCase, api.ExplainAuthorizationRequest{
+					Username: "nobody",
+					Method:   "GET",
+					Path:     "/roles",
+				})
+				assert.Equal(t, int(app.ErrUserNotFound.Code), rr.Result().StatusCode)
+			},
+		},
+		{
+			name: "should require a subject",
+			test: func(ctx context.Context, apiTestCase apiTestCase) {
+				rr := explainAuthorization(t, ctx, apiTestCase, api.ExplainAuthorizationRequest{
+					Method: "GET",
+					Path:   "/roles",
+				})
+				assert.Equal(t, http.StatusBadRequest, rr.Result().StatusCode)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			testCase, teardown := newAPITestCase(t, makeUserDB, makeRedis)
+			defer teardown()
+
+			test.test(ctx, testCase)
+		})
+	}
+}
+
+func explainAuthorization(t *testing.T, ctx context.Context, apiTestCase apiTestCase, explainReq api.ExplainAuthorizationRequest) *httptest.ResponseRecorder {
+	b, err := json.Marshal(explainReq)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest("POST", "/authz/explain", bytes.NewBuffer(b))
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	resp := &api.Response{ResponseWriter: rr}
+	ctxIP := addIPToContext(ctx, net.ParseIP("127.0.0.1"))
+
+	err = apiTestCase.am.ExplainAuthorization(ctxIP, resp, req.WithContext(ctxIP))
+	assert.NoError(t, err)
+
+	return rr
+}
+
+func TestAPIOrganizations(t *testing.T, makeOrganizationDB MakeOrganizationDB, makeRedis MakeRedis) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	userDB, orgDB, teardownDB, err := makeOrganizationDB()
+	assert.NoError(t, err)
+	defer teardownDB()
+
+	redis, teardownRedis, err := makeRedis()
+	assert.NoError(t, err)
+	defer teardownRedis()
+
+	jwtWrapper := jwt.NewHS256Wrapper("secret")
+	usr := app.NewUserBackend(userDB, jwtWrapper)
+	authenticator := app.NewAuthenticator(userDB, jwtWrapper)
+	rbac := app.NewRBAC(local.NewRoleDB())
+	apiMux := api.NewMux(usr, app.NewOrganizationBackend(orgDB), app.NewGroupBackend(local.NewUserDB()), nil, authenticator, rbac, jwtWrapper, geoip.GeoIP{}, redis, nil)
+
+	admin := createUser(ctx, t, userDB, "admin")
+	_, err = userDB.UpdateUserRole(ctx, admin.UserID, app.AdminRole)
+	assert.NoError(t, err)
+	admin.Role = app.AdminRole
+	member := createUser(ctx, t, userDB, "member")
+
+	adminToken, err := authenticator.GenerateJWT(admin, app.Grants{})
+	assert.NoError(t, err)
+	memberToken, err := authenticator.GenerateJWT(member, app.Grants{})
+	assert.NoError(t, err)
+
+	rr := serveJSON(t, apiMux, "POST", "/organizations", memberToken, api.CreateOrganizationRequest{Name: "acme"})
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "POST", "/organizations", adminToken, api.CreateOrganizationRequest{Name: "acme"})
+	assert.Equal(t, http.StatusCreated, rr.Result().StatusCode)
+
+	var org api.OrganizationResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&org))
+	assert.Equal(t, "acme", org.Name)
+
+	membersPath := "/organizations/" + org.OrgID + "/members"
+	addMember := api.AddOrganizationMemberRequest{UserID: member.UserID, Role: app.DeveloperRole}
+
+	rr = serveJSON(t, apiMux, "POST", membersPath, adminToken, addMember)
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode, "members can only be managed from within the organization")
+
+	adminOrgToken := switchOrganization(t, apiMux, adminToken, org.OrgID)
+	rr = serveJSON(t, apiMux, "POST", membersPath, adminOrgToken, addMember)
+	assert.Equal(t, http.StatusCreated, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "GET", "/users", memberToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode)
+
+	memberOrgToken := switchOrganization(t, apiMux, memberToken, org.OrgID)
+	rr = serveJSON(t, apiMux, "GET", "/users", memberOrgToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode, "developer role within the organization grants manageUsers")
+
+	rr = serveJSON(t, apiMux, "GET", "/organizations", memberOrgToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	var memberships []api.MembershipResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&memberships))
+	assert.Equal(t, []api.MembershipResponse{{OrgID: org.OrgID, UserID: member.UserID, Role: app.DeveloperRole}}, memberships)
+
+	rr = serveJSON(t, apiMux, "POST", "/organizations", adminToken, api.CreateOrganizationRequest{Name: "globex"})
+	assert.Equal(t, http.StatusCreated, rr.Result().StatusCode)
+
+	var otherOrg api.OrganizationResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&otherOrg))
+
+	outsider := createUser(ctx, t, userDB, "outsider")
+	rr = serveJSON(t, apiMux, "POST", "/organizations/"+otherOrg.OrgID+"/members", switchOrganization(t, apiMux, adminToken, otherOrg.OrgID), api.AddOrganizationMemberRequest{UserID: outsider.UserID, Role: app.GuestRole})
+	assert.Equal(t, http.StatusCreated, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "GET", "/users", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "GET", "/users", memberOrgToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	var page api.GetUsersPageResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&page))
+	assert.NotEmpty(t, page.Users)
+	for _, listed := range page.Users {
+		assert.NotEqual(t, outsider.UserID, listed.UserID, "users of another organization are not listed")
+	}
+
+	rr = serveJSON(t, apiMux, "GET", "/users/"+member.UserID, memberOrgToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "GET", "/users/"+outsider.UserID, memberOrgToken, nil)
+	assert.Equal(t, int(app.ErrUserNotFound.Code), rr.Result().StatusCode, "users of another organization can't be looked up")
+
+	globalToken := switchOrganization(t, apiMux, memberOrgToken, "")
+	rr = serveJSON(t, apiMux, "GET", "/users", globalToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "POST", "/organizations/switch", memberToken, api.SwitchOrganizationRequest{OrgID: "11111111-1111-1111-1111-111111111111"})
+	assert.Equal(t, int(app.ErrMembershipNotFound.Code), rr.Result().StatusCode)
+}
+
+func TestAPIGroups(t *testing.T, makeGroupDB MakeGroupDB, makeRedis MakeRedis) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	userDB, groupDB, teardownDB, err := makeGroupDB()
+	assert.NoError(t, err)
+	defer teardownDB()
+
+	redis, teardownRedis, err := makeRedis()
+	assert.NoError(t, err)
+	defer teardownRedis()
+
+	jwtWrapper := jwt.NewHS256Wrapper("secret")
+	usr := app.NewUserBackend(userDB, jwtWrapper)
+	authenticator := app.NewAuthenticator(userDB, jwtWrapper)
+	rbac := app.NewRBAC(local.NewRoleDB())
+	apiMux := api.NewMux(usr, app.NewOrganizationBackend(local.NewUserDB()), app.NewGroupBackend(groupDB), nil, authenticator, rbac, jwtWrapper, geoip.GeoIP{}, redis, nil)
+
+	admin := createUser(ctx, t, userDB, "admin")
+	_, err = userDB.UpdateUserRole(ctx, admin.UserID, app.AdminRole)
+	assert.NoError(t, err)
+	admin.Role = app.AdminRole
+
+	adminToken, err := authenticator.GenerateJWT(admin, app.Grants{})
+	assert.NoError(t, err)
+
+	hashedPassword, err := credentials.NewPassword("member_password").GenerateBCrypt()
+	assert.NoError(t, err)
+	err = userDB.CreateUser(ctx, app.User{Username: "member", Password: hashedPassword, Role: app.GuestRole})
+	assert.NoError(t, err)
+	member, err := usr.GetUserByUsername(ctx, "member")
+	assert.NoError(t, err)
+
+	login := api.LoginRequest{Username: "member", Password: "member_password"}
+	rr := serveJSON(t, apiMux, "POST", "/login", "", login)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	var loginRes api.LoginResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&loginRes))
+
+	rr = serveJSON(t, apiMux, "GET", "/users", loginRes.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode)
+
+	groupReq := api.GroupRequest{Name: "support", Roles: []app.Role{app.DeveloperRole}, Permissions: []string{"exportData"}}
+	rr = serveJSON(t, apiMux, "POST", "/groups", loginRes.Token, groupReq)
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "POST", "/groups", adminToken, groupReq)
+	assert.Equal(t, http.StatusCreated, rr.Result().StatusCode)
+
+	var group api.GroupResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&group))
+	assert.Equal(t, "support", group.Name)
+
+	memberPath := "/groups/" + group.GroupID + "/members/" + member.UserID
+	rr = serveJSON(t, apiMux, "PUT", memberPath, adminToken, nil)
+	assert.Equal(t, http.StatusNoContent, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "GET", "/groups/"+group.GroupID+"/members", adminToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	var members api.GroupMembersResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&members))
+	assert.Equal(t, []string{member.UserID}, members.UserIDs)
+
+	rr = serveJSON(t, apiMux, "POST", "/login", "", login)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&loginRes))
+
+	decoded, err := jwtWrapper.Decode(loginRes.Token, &jwt.UserClaims{})
+	assert.NoError(t, err)
+	claims := decoded.Claims.(*jwt.UserClaims)
+	assert.Equal(t, []string{app.DeveloperRole.String()}, claims.Roles)
+	assert.Equal(t, []string{"exportData"}, claims.Permissions)
+
+	rr = serveJSON(t, apiMux, "GET", "/users", loginRes.Token, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode, "developer role granted by the group grants manageUsers")
+
+	other := createUser(ctx, t, userDB, "other")
+	rr = serveJSON(t, apiMux, "PUT", "/groups/"+group.GroupID+"/members/"+other.UserID, adminToken, nil)
+	assert.Equal(t, http.StatusNoContent, rr.Result().StatusCode)
+	otherToken, err := authenticator.GenerateJWT(other, app.Grants{Roles: []app.Role{app.DeveloperRole}, Permissions: []string{"exportData"}})
+	assert.NoError(t, err)
+
+	rr = serveJSON(t, apiMux, "DELETE", memberPath, adminToken, nil)
+	assert.Equal(t, http.StatusNoContent, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "GET", "/users", loginRes.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode, "tokens of a removed member carry the grants of the group")
+
+	var errResponse errors.ErrorResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&errResponse))
+	assert.Equal(t, api.ErrAuthSessionRevoked.Msg, errResponse.Message)
+
+	rr = serveJSON(t, apiMux, "GET", "/users", otherToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode, "the remaining members keep their sessions")
+
+	rr = serveJSON(t, apiMux, "DELETE", memberPath, adminToken, nil)
+	assert.Equal(t, int(app.ErrGroupMemberNotFound.Code), rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "DELETE", "/groups/"+group.GroupID, adminToken, nil)
+	assert.Equal(t, http.StatusNoContent, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "GET", "/users", otherToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode, "tokens of the members of a deleted group are revoked")
+
+	rr = serveJSON(t, apiMux, "GET", "/groups", adminToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	var groups []api.GroupResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&groups))
+	assert.Empty(t, groups)
+
+	rr = serveJSON(t, apiMux, "POST", "/groups", adminToken, api.GroupRequest{Name: "billing", Roles: []app.Role{app.DeveloperRole}})
+	assert.Equal(t, http.StatusCreated, rr.Result().StatusCode)
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&group))
+
+	billing := createUser(ctx, t, userDB, "billing")
+	rr = serveJSON(t, apiMux, "PUT", "/groups/"+group.GroupID+"/members/"+billing.UserID, adminToken, nil)
+	assert.Equal(t, http.StatusNoContent, rr.Result().StatusCode)
+	billingToken, err := authenticator.GenerateJWT(billing, app.Grants{Roles: []app.Role{app.DeveloperRole}})
+	assert.NoError(t, err)
+
+	rr = serveJSON(t, apiMux, "PUT", "/groups/"+group.GroupID, adminToken, api.GroupRequest{Name: "billing", Roles: []app.Role{app.DeveloperRole}, Permissions: []string{"exportData"}})
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "GET", "/users", billingToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode, "granting more keeps the sessions")
+
+	rr = serveJSON(t, apiMux, "PUT", "/groups/"+group.GroupID, adminToken, api.GroupRequest{Name: "billing", Permissions: []string{"exportData"}})
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "GET", "/users", billingToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode, "tokens carrying a revoked grant are rejected")
+}
+
+func TestAPIUserCRUD(t *testing.T, makeUserDB MakeUserDB, makeRedis MakeRedis) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	userDB, teardownDB, err := makeUserDB()
+	assert.NoError(t, err)
+	defer teardownDB()
+
+	redis, teardownRedis, err := makeRedis()
+	assert.NoError(t, err)
+	defer teardownRedis()
+
+	jwtWrapper := jwt.NewHS256Wrapper("secret")
+	usr := app.NewUserBackend(userDB, jwtWrapper)
+	authenticator := app.NewAuthenticator(userDB, jwtWrapper)
+	rbac := app.NewRBAC(local.NewRoleDB())
+	apiMux := api.NewMux(usr, app.NewOrganizationBackend(local.NewUserDB()), app.NewGroupBackend(local.NewUserDB()), nil, authenticator, rbac, jwtWrapper, geoip.GeoIP{}, redis, nil)
+
+	admin := createUser(ctx, t, userDB, "admin")
+	_, err = userDB.UpdateUserRole(ctx, admin.UserID, app.AdminRole)
+	assert.NoError(t, err)
+	admin.Role = app.AdminRole
+	alice := createUser(ctx, t, userDB, "alice")
+	alice.Password, err = credentials.NewPassword("alice_password").GenerateBCrypt()
+	assert.NoError(t, err)
+	assert.NoError(t, userDB.UpdateUser(ctx, alice))
+	bob := createUser(ctx, t, userDB, "bobby")
+	dev := createUser(ctx, t, userDB, "devon")
+	_, err = userDB.UpdateUserRole(ctx, dev.UserID, app.DeveloperRole)
+	assert.NoError(t, err)
+	dev.Role = app.DeveloperRole
+
+	adminToken, err := authenticator.GenerateJWT(admin, app.Grants{})
+	assert.NoError(t, err)
+	aliceToken, err := authenticator.GenerateJWT(alice, app.Grants{})
+	assert.NoError(t, err)
+	bobToken, err := authenticator.GenerateJWT(bob, app.Grants{})
+	assert.NoError(t, err)
+	devToken, err := authenticator.GenerateJWT(dev, app.Grants{})
+	assert.NoError(t, err)
+
+	rr := serveJSON(t, apiMux, "GET", "/users/"+alice.UserID, aliceToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	var res api.GetUsersResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&res))
+	assert.Equal(t, api.GetUsersResponse{UserID: alice.UserID, Username: "alice", Role: app.GuestRole}, res)
+
+	rr = serveJSON(t, apiMux, "GET", "/users/"+alice.UserID, bobToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode, "users can only read themselves")
+
+	rr = serveJSON(t, apiMux, "GET", "/users/11111111-1111-1111-1111-111111111111", adminToken, nil)
+	assert.Equal(t, int(app.ErrUserNotFound.Code), rr.Result().StatusCode)
+
+	password := "Vq7#rLm2!xTz9pWk"
+	rr = serveJSON(t, apiMux, "PATCH", "/users/"+admin.UserID, devToken, api.UpdateUserRequest{Password: &password})
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode, "users outranking the caller keep their credentials")
+
+	rr = serveJSON(t, apiMux, "PATCH", "/users/"+dev.UserID, adminToken, api.UpdateUserRequest{Password: &password})
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode, "callers can change the credentials of users below them")
+
+	taken := "bobby"
+	rr = serveJSON(t, apiMux, "PATCH", "/users/"+alice.UserID, aliceToken, api.UpdateUserRequest{Username: &taken})
+	assert.Equal(t, int(api.ErrCurrentPasswordRequired.Code), rr.Result().StatusCode)
+
+	wrong := "wrong_password"
+	rr = serveJSON(t, apiMux, "PATCH", "/users/"+alice.UserID, aliceToken, api.UpdateUserRequest{Password: &password, CurrentPassword: &wrong})
+	assert.Equal(t, int(credentials.ErrPasswordMismatch.Code), rr.Result().StatusCode)
+
+	current := "alice_password"
+	rr = serveJSON(t, apiMux, "PATCH", "/users/"+alice.UserID, aliceToken, api.UpdateUserRequest{Username: &taken, CurrentPassword: &current})
+	assert.Equal(t, int(app.ErrUserAlreadyExists.Code), rr.Result().StatusCode)
+
+	renamed := "alice_renamed"
+	rr = serveJSON(t, apiMux, "PATCH", "/users/"+alice.UserID, aliceToken, api.UpdateUserRequest{Username: &renamed, CurrentPassword: &current})
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&res))
+	assert.Equal(t, renamed, res.Username)
+
+	rr = serveJSON(t, apiMux, "GET", "/users/"+alice.UserID, aliceToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode, "renaming revokes existing sessions")
+
+	var errResponse errors.ErrorResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&errResponse))
+	assert.Equal(t, api.ErrAuthSessionRevoked.Msg, errResponse.Message)
+
+	rr = serveJSON(t, apiMux, "DELETE", "/users/"+bob.UserID, adminToken, nil)
+	assert.Equal(t, http.StatusNoContent, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "GET", "/users/"+bob.UserID, bobToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode, "deleting revokes existing sessions")
+
+	rr = serveJSON(t, apiMux, "GET", "/users/"+bob.UserID, adminToken, nil)
+	assert.Equal(t, int(app.ErrUserNotFound.Code), rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "DELETE", "/users/"+admin.UserID, adminToken, nil)
+	assert.Equal(t, int(app.ErrLastAdministrator.Code), rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "GET", "/users/"+admin.UserID, adminToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode, "a refused deletion doesn't revoke sessions")
+}
+
+// failingUserDB fails the user lookups and inserts of db with the errors it
+// is given, the way a backend reports storage errors.
+type failingUserDB struct {
+	app.UserDB
+	lookupErr error
+	insertErr error
+}
+
+func (f *failingUserDB) GetUserByUsername(ctx context.Context, username string) (app.User, error) {
+	if f.lookupErr != nil {
+		return app.User{}, f.lookupErr
+	}
+	return f.UserDB.GetUserByUsername(ctx, username)
+}
+
+func (f *failingUserDB) ListUsers(ctx context.Context, filter app.UserFilter) ([]app.User, error) {
+	if f.lookupErr != nil {
+		return nil, f.lookupErr
+	}
+	return f.UserDB.ListUsers(ctx, filter)
+}
+
+func (f *failingUserDB) CountUsers(ctx context.Context, filter app.UserFilter) (int, error) {
+	if f.lookupErr != nil {
+		return 0, f.lookupErr
+	}
+	return f.UserDB.CountUsers(ctx, filter)
+}
+
+func (f *failingUserDB) CreateUser(ctx context.Context, user app.User) error {
+	if f.insertErr != nil {
+		return f.insertErr
+	}
+	return f.UserDB.CreateUser(ctx, user)
+}
+
+func TestAPIStorageErrors(t *testing.T, makeUserDB MakeUserDB, makeRedis MakeRedis) {
+	db := &failingUserDB{}
+	apiTestCase, teardown := newAPITestCase(t, func() (app.UserDB, func() error, error) {
+		userDB, teardownDB, err := makeUserDB()
+		db.UserDB = userDB
+		return db, teardownDB, err
+	}, makeRedis)
+	defer teardown()
+
+	storageError := func(kind *errors.WrappedError) error {
+		return errors.Storage(stderrors.New("driver error"), func(error) *errors.WrappedError {
+			return kind
+		})
+	}
+
+	register := func() *httptest.ResponseRecorder {
+		return serveJSON(t, apiTestCase.am, "POST", "/register", "", api.CreateUserRequest{Username: "alice", Password: "parool123!"})
+	}
+
+	db.lookupErr = storageError(errors.ErrStorageUnavailable)
+	rr := register()
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Result().StatusCode)
+
+	var errResponse errors.ErrorResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&errResponse))
+	assert.Equal(t, errors.ErrStorageUnavailable.Msg, errResponse.Message)
+
+	db.lookupErr = storageError(errors.ErrStorageTimeout)
+	rr = register()
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Result().StatusCode)
+
+	req := httptest.NewRequest("GET", "/users", nil)
+	rr = httptest.NewRecorder()
+	err := apiTestCase.am.GetUsers(req.Context(), &api.Response{ResponseWriter: rr}, req)
+	assert.Error(t, err, "server errors are returned to be logged")
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Result().StatusCode)
+
+	db.lookupErr = nil
+	db.insertErr = app.ErrUserAlreadyExists
+	rr = register()
+	assert.Equal(t, int(app.ErrUserAlreadyExists.Code), rr.Result().StatusCode, "a registration that lost a race is a conflict")
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&errResponse))
+	assert.Equal(t, app.ErrUserAlreadyExists.Msg, errResponse.Message)
+
+	db.insertErr = storageError(errors.ErrStorageConflict)
+	rr = register()
+	assert.Equal(t, http.StatusConflict, rr.Result().StatusCode)
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&errResponse))
+	assert.Equal(t, errors.ErrStorageConflict.Msg, errResponse.Message, "other conflicts are retryable, not taken usernames")
+
+	db.insertErr = errors.Storage(stderrors.New("foreign key violation"), nil)
+	rr = register()
+	assert.Equal(t, http.StatusInternalServerError, rr.Result().StatusCode)
+
+	db.insertErr = nil
+	rr = register()
+	assert.Equal(t, http.StatusCreated, rr.Result().StatusCode)
+}
+
+func TestAPIUpdateProfile(t *testing.T, makeUserDB MakeUserDB, makeRedis MakeRedis) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	apiTestCase, teardown := newAPITestCase(t, makeUserDB, makeRedis)
+	defer teardown()
+
+	usr := createUser(ctx, t, apiTestCase.db, "alice")
+	token, err := app.NewAuthenticator(apiTestCase.db, jwt.NewHS256Wrapper("secret")).GenerateJWT(usr, app.Grants{})
+	assert.NoError(t, err)
+
+	displayName, locale := "Alice", "en-US"
+	rr := serveJSON(t, apiTestCase.am, "PATCH", "/me/profile", "", api.UpdateProfileRequest{DisplayName: &displayName})
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiTestCase.am, "PATCH", "/me/profile", token, api.UpdateProfileRequest{DisplayName: &displayName, Locale: &locale})
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	var res api.GetUsersResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&res))
+	assert.Equal(t, api.GetUsersResponse{UserID: usr.UserID, Username: "alice", Role: app.GuestRole, DisplayName: "Alice", Locale: "en-US"}, res)
+
+	timezone := "Not/A_Zone"
+	rr = serveJSON(t, apiTestCase.am, "PATCH", "/me/profile", token, api.UpdateProfileRequest{Timezone: &timezone})
+	assert.Equal(t, int(app.ErrTimezoneInvalid.Code), rr.Result().StatusCode)
+
+	adminToken, err := jwt.NewHS256Wrapper("secret").Encode(jwt.NewUserClaims("dev", app.DeveloperRole.String()))
+	assert.NoError(t, err)
+
+	rr = serveJSON(t, apiTestCase.am, "GET", "/users?username_prefix=ali", adminToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	var page api.GetUsersPageResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&page))
+	assert.Equal(t, []api.GetUsersResponse{res}, page.Users)
+}
+
+func TestAPIAccountStatus(t *testing.T, makeUserDB MakeUserDB, makeRedis MakeRedis) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	apiTestCase, teardown := newAPITestCase(t, makeUserDB, makeRedis)
+	defer teardown()
+
+	authenticator := app.NewAuthenticator(apiTestCase.db, jwt.NewHS256Wrapper("secret"))
+
+	admin := createUser(ctx, t, apiTestCase.db, "admin")
+	_, err := apiTestCase.db.UpdateUserRole(ctx, admin.UserID, app.AdminRole)
+	assert.NoError(t, err)
+	admin.Role = app.AdminRole
+	alice := createUser(ctx, t, apiTestCase.db, "alice")
+
+	adminToken, err := authenticator.GenerateJWT(admin, app.Grants{})
+	assert.NoError(t, err)
+	aliceToken, err := authenticator.GenerateJWT(alice, app.Grants{})
+	assert.NoError(t, err)
+
+	rr := serveJSON(t, apiTestCase.am, "POST", "/users/"+alice.UserID+"/suspend", aliceToken, api.SuspendUserRequest{Reason: "spam"})
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode, "users can't suspend themselves")
+
+	rr = serveJSON(t, apiTestCase.am, "POST", "/users/"+alice.UserID+"/suspend", adminToken, api.SuspendUserRequest{})
+	assert.Equal(t, int(app.ErrStatusReasonInvalid.Code), rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiTestCase.am, "POST", "/users/"+alice.UserID+"/suspend", adminToken, api.SuspendUserRequest{Reason: "spam"})
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	var res api.AccountStatusResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&res))
+	assert.Equal(t, alice.UserID, res.UserID)
+	assert.Equal(t, app.StatusSuspended, res.Status)
+	assert.Equal(t, "spam", res.Reason)
+	assert.False(t, res.ChangedAt.IsZero())
+
+	rr = serveJSON(t, apiTestCase.am, "GET", "/users/"+alice.UserID, aliceToken, nil)
+	assert.Equal(t, int(app.ErrAccountSuspended.Code), rr.Result().StatusCode)
+
+	var errResponse errors.ErrorResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&errResponse))
+	assert.Equal(t, app.ErrAccountSuspended.Msg, errResponse.Message)
+
+	rr = serveJSON(t, apiTestCase.am, "POST", "/users/"+alice.UserID+"/suspend", adminToken, api.SuspendUserRequest{Reason: "spam"})
+	assert.Equal(t, int(app.ErrAccountStatusTransition.Code), rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiTestCase.am, "POST", "/users/"+alice.UserID+"/reinstate", adminToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+	var reinstated api.AccountStatusResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&reinstated))
+	assert.Equal(t, app.StatusActive, reinstated.Status)
+	assert.Empty(t, reinstated.Reason)
+
+	rr = serveJSON(t, apiTestCase.am, "GET", "/users/"+alice.UserID, aliceToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode, "tokens issued before the suspension stay revoked")
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&errResponse))
+	assert.Equal(t, api.ErrAuthSessionRevoked.Msg, errResponse.Message)
+
+	rr = serveJSON(t, apiTestCase.am, "POST", "/users/11111111-1111-1111-1111-111111111111/reinstate", adminToken, nil)
+	assert.Equal(t, int(app.ErrUserNotFound.Code), rr.Result().StatusCode)
+}
+
+func TestAPIPrivacy(t *testing.T, makeUserDB MakeUserDB, makeRedis MakeRedis) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	apiTestCase, teardown := newAPITestCase(t, makeUserDB, makeRedis)
+	defer teardown()
+
+	usr := createUser(ctx, t, apiTestCase.db, "alice")
+	token, err := app.NewAuthenticator(apiTestCase.db, jwt.NewHS256Wrapper("secret")).GenerateJWT(usr, app.Grants{})
+	assert.NoError(t, err)
+
+	rr := serveJSON(t, apiTestCase.am, "GET", "/me/export", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiTestCase.am, "GET", "/me/export", token, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+	assert.Contains(t, rr.Header().Get("Content-Disposition"), "attachment; filename=\"alice-")
+	assert.NotContains(t, rr.Body.String(), "password")
+
+	var export app.DataExport
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&export))
+	assert.Equal(t, usr.UserID, export.Profile.UserID)
+	assert.Equal(t, "alice", export.Profile.Username)
+	assert.Nil(t, export.Erasure)
+
+	rr = serveJSON(t, apiTestCase.am, "GET", "/me/erasure", token, nil)
+	assert.Equal(t, int(app.ErrErasureRequestNotFound.Code), rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiTestCase.am, "POST", "/me/erasure", token, nil)
+	assert.Equal(t, http.StatusAccepted, rr.Result().StatusCode)
+
+	var erasure api.ErasureResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&erasure))
+	assert.NotEmpty(t, erasure.RequestID)
+	assert.Equal(t, app.ErasureGracePeriod, erasure.ScheduledFor.Sub(erasure.RequestedAt))
+
+	rr = serveJSON(t, apiTestCase.am, "POST", "/me/erasure", token, nil)
+	assert.Equal(t, int(app.ErrErasureAlreadyRequested.Code), rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiTestCase.am, "GET", "/me/export", token, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&export))
+	if assert.NotNil(t, export.Erasure) {
+		assert.Equal(t, erasure.RequestID, export.Erasure.RequestID)
+	}
+
+	rr = serveJSON(t, apiTestCase.am, "DELETE", "/me/erasure", token, nil)
+	assert.Equal(t, http.StatusNoContent, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiTestCase.am, "DELETE", "/me/erasure", token, nil)
+	assert.Equal(t, int(app.ErrErasureRequestNotFound.Code), rr.Result().StatusCode)
+}
+
+func TestAPISCIM(t *testing.T, makeGroupDB MakeGroupDB, makeRedis MakeRedis) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	userDB, groupDB, teardownDB, err := makeGroupDB()
+	assert.NoError(t, err)
+	defer teardownDB()
+
+	redis, teardownRedis, err := makeRedis()
+	assert.NoError(t, err)
+	defer teardownRedis()
+
+	jwtWrapper := jwt.NewHS256Wrapper("secret")
+	usr := app.NewUserBackend(userDB, jwtWrapper)
+	authenticator := app.NewAuthenticator(userDB, jwtWrapper)
+	rbac := app.NewRBAC(local.NewRoleDB())
+	newMux := func() *api.Mux {
+		return api.NewMux(usr, app.NewOrganizationBackend(local.NewUserDB()), app.NewGroupBackend(groupDB), nil, authenticator, rbac, jwtWrapper, geoip.GeoIP{}, redis, nil)
+	}
+
+	rr := serveJSON(t, newMux(), "GET", "/scim/v2/Users", "provisioning_token", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Result().StatusCode, "SCIM is disabled without a token")
+
+	const token = "provisioning_token"
+	apiMux := newMux().WithSCIMToken(token)
+
+	rr = serveJSON(t, apiMux, "GET", "/scim/v2/Users", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode)
+	assert.Contains(t, rr.Header().Get("Content-Type"), "application/scim+json")
+
+	rr = serveJSON(t, apiMux, "GET", "/scim/v2/Users", "wrong_token", nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode)
+
+	admin := createUser(ctx, t, userDB, "admin")
+	adminToken, err := authenticator.GenerateJWT(admin, app.Grants{})
+	assert.NoError(t, err)
+	rr = serveJSON(t, apiMux, "GET", "/scim/v2/Users", adminToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode, "user tokens aren't provisioning tokens")
+
+	createSCIMUser := func(userName string, displayName string) api.SCIMUser {
+		rr := serveJSON(t, apiMux, "POST", "/scim/v2/Users", token, api.SCIMUser{
+			Schemas:     []string{api.SCIMUserSchema},
+			UserName:    userName,
+			DisplayName: displayName,
+		})
+		assert.Equal(t, http.StatusCreated, rr.Result().StatusCode)
+
+		var res api.SCIMUser
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&res))
+		assert.Equal(t, "/scim/v2/Users/"+res.ID, rr.Header().Get("Location"))
+		return res
+	}
+
+	alice := createSCIMUser("Alice", "Alice")
+	assert.Equal(t, "alice", alice.UserName)
+	assert.Equal(t, "Alice", alice.DisplayName)
+	if assert.NotNil(t, alice.Active) {
+		assert.True(t, *alice.Active)
+	}
+	bobby := createSCIMUser("bobby", "Bobby")
+	createSCIMUser("carol", "")
+
+	rr = serveJSON(t, apiMux, "POST", "/scim/v2/Users", token, api.SCIMUser{UserName: "alice"})
+	assert.Equal(t, http.StatusConflict, rr.Result().StatusCode)
+
+	var scimErr api.SCIMError
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&scimErr))
+	assert.Equal(t, []string{api.SCIMErrorSchema}, scimErr.Schemas)
+	assert.Equal(t, "409", scimErr.Status)
+	assert.Equal(t, "uniqueness", scimErr.SCIMType)
+
+	var list struct {
+		api.SCIMListResponse
+		Resources []api.SCIMUser `json:"Resources"`
+	}
+	rr = serveJSON(t, apiMux, "GET", "/scim/v2/Users?filter="+url.QueryEscape(`userName eq "ALICE"`), token, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	assert.Equal(t, 1, list.TotalResults)
+	if assert.Len(t, list.Resources, 1) {
+		assert.Equal(t, alice.ID, list.Resources[0].ID)
+	}
+
+	rr = serveJSON(t, apiMux, "GET", "/scim/v2/Users?filter="+url.QueryEscape(`userName co "a"`), token, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Result().StatusCode)
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&scimErr))
+	assert.Equal(t, "invalidFilter", scimErr.SCIMType)
+
+	rr = serveJSON(t, apiMux, "GET", "/scim/v2/Users?startIndex=3&count=1", token, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	assert.Equal(t, 4, list.TotalResults)
+	assert.Equal(t, 3, list.StartIndex)
+	assert.Equal(t, 1, list.ItemsPerPage)
+	if assert.Len(t, list.Resources, 1) {
+		assert.Equal(t, "bobby", list.Resources[0].UserName)
+	}
+
+	rr = serveJSON(t, apiMux, "GET", "/scim/v2/Users?startIndex=10", token, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+	list.Resources = nil
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	assert.Equal(t, 4, list.TotalResults)
+	assert.Equal(t, 0, list.ItemsPerPage)
+	assert.Empty(t, list.Resources)
+
+	patch := api.SCIMPatchRequest{
+		Schemas: []string{api.SCIMPatchOpSchema},
+		Operations: []api.SCIMPatchOperation{
+			{Op: "Replace", Path: "displayName", Value: json.RawMessage(`"Alice Liddell"`)},
+			{Op: "replace", Value: json.RawMessage(`{"active": "False", "externalId": "ignored"}`)},
+		},
+	}
+	rr = serveJSON(t, apiMux, "PATCH", "/scim/v2/Users/"+alice.ID, token, patch)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	var patched api.SCIMUser
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&patched))
+	assert.Equal(t, "Alice Liddell", patched.DisplayName)
+	if assert.NotNil(t, patched.Active) {
+		assert.False(t, *patched.Active)
+	}
+
+	stored, err := usr.GetUser(ctx, alice.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, app.StatusDeactivated, stored.Status)
+
+	status, err := app.NewRedisSessionStore(redis).AccountStatus(ctx, alice.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, app.StatusDeactivated, status)
+
+	patch.Operations = []api.SCIMPatchOperation{{Op: "remove", Path: "userName"}}
+	rr = serveJSON(t, apiMux, "PATCH", "/scim/v2/Users/"+alice.ID, token, patch)
+	assert.Equal(t, http.StatusBadRequest, rr.Result().StatusCode)
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&scimErr))
+	assert.Equal(t, "mutability", scimErr.SCIMType)
+
+	active := true
+	rr = serveJSON(t, apiMux, "PUT", "/scim/v2/Users/"+alice.ID, token, api.SCIMUser{UserName: "alice_liddell", Locale: "en-GB", Active: &active})
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	var replaced api.SCIMUser
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&replaced))
+	assert.Equal(t, "alice_liddell", replaced.UserName)
+	assert.Equal(t, "en-GB", replaced.Locale)
+	assert.Empty(t, replaced.DisplayName, "replacing the user clears attributes left out")
+	if assert.NotNil(t, replaced.Active) {
+		assert.True(t, *replaced.Active)
+	}
+
+	group := api.SCIMGroup{
+		Schemas:     []string{api.SCIMGroupSchema},
+		DisplayName: "engineering",
+		Members:     []api.SCIMMember{{Value: "11111111-1111-1111-1111-111111111111"}},
+	}
+	rr = serveJSON(t, apiMux, "POST", "/scim/v2/Groups", token, group)
+	assert.Equal(t, http.StatusBadRequest, rr.Result().StatusCode, "members must exist")
+
+	group.Members = []api.SCIMMember{{Value: alice.ID}}
+	rr = serveJSON(t, apiMux, "POST", "/scim/v2/Groups", token, group)
+	assert.Equal(t, http.StatusCreated, rr.Result().StatusCode)
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&group))
+	assert.NotEmpty(t, group.ID)
+
+	patch.Operations = []api.SCIMPatchOperation{
+		{Op: "add", Path: "members", Value: json.RawMessage(`[{"value": "` + bobby.ID + `"}]`)},
+		{Op: "remove", Path: `members[value eq "` + alice.ID + `"]`},
+		{Op: "replace", Path: "displayName", Value: json.RawMessage(`"platform"`)},
+	}
+	rr = serveJSON(t, apiMux, "PATCH", "/scim/v2/Groups/"+group.ID, token, patch)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	members, err := groupDB.GetGroupMembers(ctx, group.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{bobby.ID}, members)
+
+	var groups struct {
+		api.SCIMListResponse
+		Resources []api.SCIMGroup `json:"Resources"`
+	}
+	rr = serveJSON(t, apiMux, "GET", "/scim/v2/Groups?filter="+url.QueryEscape(`displayName eq "Platform"`), token, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&groups))
+	if assert.Len(t, groups.Resources, 1) {
+		assert.Equal(t, "platform", groups.Resources[0].DisplayName)
+		assert.Equal(t, []api.SCIMMember{{Value: bobby.ID, Ref: "/scim/v2/Users/" + bobby.ID}}, groups.Resources[0].Members)
+	}
+
+	sessions := app.NewRedisSessionStore(redis)
+	revokedAt, err := sessions.SessionsRevokedAt(ctx, bobby.ID)
+	assert.NoError(t, err)
+	assert.True(t, revokedAt.IsZero())
+
+	rr = serveJSON(t, apiMux, "PUT", "/scim/v2/Groups/"+group.ID, token, api.SCIMGroup{DisplayName: "platform"})
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	members, err = groupDB.GetGroupMembers(ctx, group.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, members)
+
+	revokedAt, err = sessions.SessionsRevokedAt(ctx, bobby.ID)
+	assert.NoError(t, err)
+	assert.False(t, revokedAt.IsZero(), "removed members lose the sessions carrying the grants of the group")
+
+	rr = serveJSON(t, apiMux, "DELETE", "/scim/v2/Groups/"+group.ID, token, nil)
+	assert.Equal(t, http.StatusNoContent, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "DELETE", "/scim/v2/Users/"+bobby.ID, token, nil)
+	assert.Equal(t, http.StatusNoContent, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "GET", "/scim/v2/Users/"+bobby.ID, token, nil)
+	assert.Equal(t, http.StatusNotFound, rr.Result().StatusCode)
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&scimErr))
+	assert.Equal(t, "404", scimErr.Status)
+}
+
+func TestAPIAuditLog(t *testing.T, makeUserDB MakeUserDB, makeRedis MakeRedis) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	apiTestCase, teardown := newAPITestCase(t, makeUserDB, makeRedis)
+	defer teardown()
+
+	authenticator := app.NewAuthenticator(apiTestCase.db, jwt.NewHS256Wrapper("secret"))
+
+	admin := createUser(ctx, t, apiTestCase.db, "admin")
+	_, err := apiTestCase.db.UpdateUserRole(ctx, admin.UserID, app.AdminRole)
+	assert.NoError(t, err)
+	admin.Role = app.AdminRole
+	alice := createUser(ctx, t, apiTestCase.db, "alice")
+
+	adminToken, err := authenticator.GenerateJWT(admin, app.Grants{})
+	assert.NoError(t, err)
+	aliceToken, err := authenticator.GenerateJWT(alice, app.Grants{})
+	assert.NoError(t, err)
+
+	rr := serveJSON(t, apiTestCase.am, "GET", "/audit/events", adminToken, nil)
+	assert.Equal(t, int(api.ErrAuditLogDisabled.Code), rr.Result().StatusCode)
+
+	store := local.NewAuditStore()
+	apiMux := apiTestCase.am.WithAuditLog(audit.NewLog(store))
+
+	rr = serveJSON(t, apiMux, "POST", "/users/"+alice.UserID+"/suspend", adminToken, api.SuspendUserRequest{Reason: "spam"})
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "GET", "/audit/events", aliceToken, nil)
+	assert.Equal(t, http.StatusForbidden, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "GET", "/audit/events?target_id="+alice.UserID+"&action="+string(audit.StatusChanged), adminToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	var res api.AuditEventsResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&res))
+	if assert.Len(t, res.Events, 1) {
+		assert.Equal(t, admin.UserID, res.Events[0].ActorID)
+		assert.Empty(t, res.Events[0].Actor, "users are recorded by ID only")
+		assert.Empty(t, res.Events[0].IP)
+		assert.Equal(t, "spam", res.Events[0].Details["reason"])
+		assert.NotEmpty(t, res.Events[0].Hash)
+	}
+	assert.Empty(t, res.NextCursor)
+
+	rr = serveJSON(t, apiMux, "GET", "/audit/events?actor_id="+admin.UserID+"&limit=1", adminToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	var page api.AuditEventsResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&page))
+	assert.Len(t, page.Events, 1)
+	assert.NotEmpty(t, page.NextCursor)
+	assert.Contains(t, rr.Header().Get("Link"), "cursor="+page.NextCursor)
+
+	rr = serveJSON(t, apiMux, "GET", "/audit/events?from=yesterday", adminToken, nil)
+	assert.Equal(t, int(api.ErrAuditTimeInvalid.Code), rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "GET", "/audit/events?action=user.teleported", adminToken, nil)
+	assert.Equal(t, int(audit.ErrActionInvalid.Code), rr.Result().StatusCode)
+
+	verification, err := audit.Verify(ctx, store)
+	assert.NoError(t, err)
+	assert.True(t, verification.Valid())
+}
+
+func TestAPIInvites(t *testing.T, makeUserDB MakeUserDB, makeRedis MakeRedis) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	apiTestCase, teardown := newAPITestCase(t, makeUserDB, makeRedis)
+	defer teardown()
+
+	authenticator := app.NewAuthenticator(apiTestCase.db, jwt.NewHS256Wrapper("secret"))
+
+	admin := createUser(ctx, t, apiTestCase.db, "admin")
+	_, err := apiTestCase.db.UpdateUserRole(ctx, admin.UserID, app.AdminRole)
+	assert.NoError(t, err)
+	admin.Role = app.AdminRole
+
+	adminToken, err := authenticator.GenerateJWT(admin, app.Grants{})
+	assert.NoError(t, err)
+
+	rr := serveJSON(t, apiTestCase.am, "GET", "/invites", adminToken, nil)
+	assert.Equal(t, int(api.ErrInvitesDisabled.Code), rr.Result().StatusCode)
+
+	inviteDB, teardownInviteDB, err := local.MakeInviteDB()
+	assert.NoError(t, err)
+	defer teardownInviteDB()
+
+	apiMux := apiTestCase.am.WithInvites(app.NewInviteBackend(apiTestCase.userBackend, inviteDB), true)
+
+	rr = serveJSON(t, apiMux, "POST", "/register", "", api.CreateUserRequest{Username: "alice", Password: "parool123!"})
+	assert.Equal(t, int(app.ErrInviteRequired.Code), rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "POST", "/invites", adminToken, api.CreateInviteRequest{Email: "alice@example.com", Role: app.DeveloperRole})
+	assert.Equal(t, http.StatusCreated, rr.Result().StatusCode)
+
+	var invite api.InviteResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&invite))
+	assert.NotEmpty(t, invite.Code)
+	assert.Equal(t, admin.UserID, invite.CreatedBy)
+	assert.Equal(t, 1, invite.MaxUses)
+
+	rr = serveJSON(t, apiMux, "POST", "/invites", adminToken, api.CreateInviteRequest{MaxUses: app.MaxInviteUses + 1})
+	assert.Equal(t, int(app.ErrInviteMaxUsesInvalid.Code), rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "GET", "/invites", adminToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	var pending []api.InviteResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&pending))
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, invite.InviteID, pending[0].InviteID)
+		assert.Empty(t, pending[0].Code)
+	}
+
+	rr = serveJSON(t, apiMux, "POST", "/register", "", api.CreateUserRequest{Username: "alice", Password: "parool123!", InviteCode: invite.Code, Email: "bob@example.com"})
+	assert.Equal(t, int(app.ErrInviteEmailMismatch.Code), rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "POST", "/register", "", api.CreateUserRequest{Username: "alice", Password: "parool123!", InviteCode: invite.Code, Email: "alice@example.com"})
+	assert.Equal(t, http.StatusCreated, rr.Result().StatusCode)
+
+	usr, err := apiTestCase.db.GetUserByUsername(ctx, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, app.DeveloperRole, usr.Role)
+
+	rr = serveJSON(t, apiMux, "POST", "/register", "", api.CreateUserRequest{Username: "bob_the_user", Password: "parool123!", InviteCode: invite.Code, Email: "alice@example.com"})
+	assert.Equal(t, int(app.ErrInviteInvalid.Code), rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "GET", "/invites", adminToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+	var remaining []api.InviteResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&remaining))
+	assert.Empty(t, remaining)
+
+	rr = serveJSON(t, apiMux, "DELETE", "/invites/"+invite.InviteID, adminToken, nil)
+	assert.Equal(t, http.StatusNoContent, rr.Result().StatusCode)
+	rr = serveJSON(t, apiMux, "DELETE", "/invites/"+invite.InviteID, adminToken, nil)
+	assert.Equal(t, int(app.ErrInviteNotFound.Code), rr.Result().StatusCode)
+}
+
+func TestAPIProofOfWork(t *testing.T, makeUserDB MakeUserDB, makeRedis MakeRedis) {
+	apiTestCase, teardown := newAPITestCase(t, makeUserDB, makeRedis)
+	defer teardown()
+
+	rr := serveJSON(t, apiTestCase.am, "GET", "/pow/challenge?scope=register", "", nil)
+	assert.Equal(t, int(api.ErrProofOfWorkDisabled.Code), rr.Result().StatusCode)
+
+	const difficulty = 8
+	issuer := pow.NewIssuer([]byte("secret"), pow.DefaultTTL, pow.NewRedisSpentStore(apiTestCase.redis))
+	policy := pow.Policy{IPThreshold: 1, GlobalThreshold: 100, BaseDifficulty: difficulty, MaxDifficulty: difficulty}
+	apiMux := apiTestCase.am.WithProofOfWork(pow.NewGuard(issuer, pow.NewRedisSignals(apiTestCase.redis, time.Minute), policy))
+
+	challenge := func(scope pow.Scope) api.PowChallengeResponse {
+		rr := serveJSON(t, apiMux, "GET", "/pow/challenge?scope="+string(scope), "", nil)
+		assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+		var res api.PowChallengeResponse
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&res))
+		return res
+	}
+	post := func(path string, body interface{}, token string, nonce string) *httptest.ResponseRecorder {
+		b, err := json.Marshal(body)
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest("POST", path, bytes.NewBuffer(b))
+		if token != "" {
+			req.Header.Set(api.PowChallengeHeader, token)
+			req.Header.Set(api.PowNonceHeader, nonce)
+		}
+
+		rr := httptest.NewRecorder()
+		apiMux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr = serveJSON(t, apiMux, "GET", "/pow/challenge?scope=reset", "", nil)
+	assert.Equal(t, int(pow.ErrScopeInvalid.Code), rr.Result().StatusCode)
+
+	res := challenge(pow.ScopeRegister)
+	assert.False(t, res.Required)
+	assert.Equal(t, difficulty, res.Difficulty)
+
+	rr = post("/register", api.CreateUserRequest{Username: "alice", Password: "parool123!"}, "", "")
+	assert.Equal(t, http.StatusCreated, rr.Result().StatusCode)
+
+	rr = post("/register", api.CreateUserRequest{Username: "bob_the_user", Password: "parool123!"}, "", "")
+	assert.Equal(t, int(pow.ErrChallengeRequired.Code), rr.Result().StatusCode)
+
+	var errResponse errors.ErrorResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&errResponse))
+	assert.Equal(t, pow.ErrChallengeRequired.Msg, errResponse.Message)
+
+	res = challenge(pow.ScopeRegister)
+	assert.True(t, res.Required)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	nonce, err := pow.Solve(ctx, res.Challenge, res.Difficulty)
+	assert.NoError(t, err)
+
+	rr = post("/login", api.LoginRequest{Username: "alice", Password: "parool123!"}, res.Challenge, nonce)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode, "login isn't under abuse, so the solution isn't checked")
+
+	rr = post("/register", api.CreateUserRequest{Username: "bob_the_user", Password: "parool123!"}, res.Challenge, nonce)
+	assert.Equal(t, http.StatusCreated, rr.Result().StatusCode)
+
+	rr = post("/register", api.CreateUserRequest{Username: "carol", Password: "parool123!"}, res.Challenge, nonce)
+	assert.Equal(t, int(pow.ErrChallengeSpent.Code), rr.Result().StatusCode)
+
+	rr = post("/login", api.LoginRequest{Username: "alice", Password: "wrong_password1!"}, "", "")
+	assert.Equal(t, int(credentials.ErrPasswordMismatch.Code), rr.Result().StatusCode)
+
+	rr = post("/login", api.LoginRequest{Username: "alice", Password: "parool123!"}, "", "")
+	assert.Equal(t, int(pow.ErrChallengeRequired.Code), rr.Result().StatusCode, "failed logins raise the signals of login")
+}
+
+func switchOrganization(t *testing.T, handler http.Handler, token string, orgID string) string {
+	rr := serveJSON(t, handler, "POST", "/organizations/switch", token, api.SwitchOrganizationRequest{OrgID: orgID})
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	var res api.SwitchOrganizationResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&res))
+
+	return res.Token
+}
+
+func TestAPIUsernameAvailability(t *testing.T, makeUserDB MakeUserDB, makeRedis MakeRedis) {
+	apiTestCase, teardown := newAPITestCase(t, makeUserDB, makeRedis)
+	defer teardown()
+	ctx := context.Background()
+
+	rr := serveJSON(t, apiTestCase.am, "GET", "/usernames/alice/availability", "", nil)
+	assert.Equal(t, int(api.ErrUsernamesDisabled.Code), rr.Result().StatusCode)
+
+	createUser(ctx, t, apiTestCase.db, "alice")
+	apiMux := apiTestCase.am.WithUsernames(app.NewUsernameBackend(apiTestCase.db, nil))
+
+	tests := []struct {
+		username string
+		code     int
+		res      api.UsernameAvailabilityResponse
+	}{
+		{username: "alice", code: http.StatusOK, res: api.UsernameAvailabilityResponse{Username: "alice", Reason: app.UsernameTaken}},
+		{username: "admin", code: http.StatusOK, res: api.UsernameAvailabilityResponse{Username: "admin", Reason: app.UsernameReserved}},
+		{username: "carol", code: http.StatusOK, res: api.UsernameAvailabilityResponse{Username: "carol", Available: true}},
+		{username: "bob", code: int(credentials.ErrUsernameLength.Code)},
+	}
+
+	for _, test := range tests {
+		rr := serveJSON(t, apiMux, "GET", "/usernames/"+test.username+"/availability", "", nil)
+		assert.Equal(t, test.code, rr.Result().StatusCode, test.username)
+		if test.code != http.StatusOK {
+			continue
+		}
+
+		assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+		var res api.UsernameAvailabilityResponse
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&res))
+		assert.Equal(t, test.res, res)
+	}
+
+	for i := len(tests); i < 20; i++ {
+		rr := serveJSON(t, apiMux, "GET", "/usernames/carol/availability", "", nil)
+		assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+	}
+
+	rr = serveJSON(t, apiMux, "GET", "/usernames/carol/availability", "", nil)
+	assert.Equal(t, int(api.ErrUsernameAvailabilityThrottled.Code), rr.Result().StatusCode)
+}
+
+func TestAPIIdentifiers(t *testing.T, makeIdentifierDB MakeIdentifierDB, makeRedis MakeRedis) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	userDB, identifierDB, teardownDB, err := makeIdentifierDB()
+	assert.NoError(t, err)
+	apiTestCase, teardown := newAPITestCase(t, func() (app.UserDB, func() error, error) {
+		return userDB, teardownDB, nil
+	}, makeRedis)
+	defer teardown()
+
+	rr := serveJSON(t, apiTestCase.am, "POST", "/register", "", api.CreateUserRequest{Username: "alice", Password: "parool123!"})
+	assert.Equal(t, http.StatusCreated, rr.Result().StatusCode)
+
+	usr, err := userDB.GetUserByUsername(ctx, "alice")
+	assert.NoError(t, err)
+	token, err := app.NewAuthenticator(userDB, jwt.NewHS256Wrapper("secret")).GenerateJWT(usr, app.Grants{})
+	assert.NoError(t, err)
+
+	rr = serveJSON(t, apiTestCase.am, "GET", "/me/identifiers", token, nil)
+	assert.Equal(t, int(api.ErrIdentifiersDisabled.Code), rr.Result().StatusCode)
+
+	sender := local.NewVerificationSender()
+	apiMux := apiTestCase.am.WithIdentifiers(app.NewIdentifierBackend(userDB, identifierDB, sender))
+
+	rr = serveJSON(t, apiMux, "GET", "/me/identifiers", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "POST", "/me/identifiers", token, api.AddIdentifierRequest{Value: "alice@"})
+	assert.Equal(t, int(app.ErrIdentifierInvalid.Code), rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "POST", "/me/identifiers", token, api.AddIdentifierRequest{Value: "Alice@Example.com"})
+	assert.Equal(t, http.StatusCreated, rr.Result().StatusCode)
+
+	var added api.IdentifierResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&added))
+	assert.NotEmpty(t, added.IdentifierID)
+	assert.Equal(t, app.IdentifierEmail, added.Type)
+	assert.Equal(t, "alice@example.com", added.Value)
+	assert.False(t, added.Verified)
+
+	login := func(req api.LoginRequest) int {
+		return serveJSON(t, apiMux, "POST", "/login", "", req).Result().StatusCode
+	}
+	assert.Equal(t, int(app.ErrUserNotFound.Code), login(api.LoginRequest{Identifier: "alice@example.com", Password: "parool123!"}))
+
+	rr = serveJSON(t, apiMux, "POST", "/me/identifiers/"+added.IdentifierID+"/verify", token, api.VerifyIdentifierRequest{})
+	assert.Equal(t, int(app.ErrVerificationCodeInvalid.Code), rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "POST", "/me/identifiers/"+added.IdentifierID+"/verify", token, api.VerifyIdentifierRequest{Code: sender.Code("alice@example.com")})
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	var verified api.IdentifierResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&verified))
+	assert.True(t, verified.Verified)
+
+	rr = serveJSON(t, apiMux, "GET", "/me/identifiers", token, nil)
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	var identifiers []api.IdentifierResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&identifiers))
+	assert.Equal(t, []api.IdentifierResponse{
+		{Type: app.IdentifierUsername, Value: "alice", Verified: true},
+		{IdentifierID: added.IdentifierID, Type: app.IdentifierEmail, Value: "alice@example.com", Verified: true},
+	}, identifiers)
+
+	assert.Equal(t, http.StatusOK, login(api.LoginRequest{Identifier: "alice@example.com", Password: "parool123!"}))
+	assert.Equal(t, http.StatusOK, login(api.LoginRequest{Username: "ALICE@example.com", Password: "parool123!"}))
+	assert.Equal(t, http.StatusOK, login(api.LoginRequest{Identifier: "alice", Password: "parool123!"}))
+	assert.Equal(t, int(credentials.ErrPasswordMismatch.Code), login(api.LoginRequest{Identifier: "alice@example.com", Password: "wr0ng-p@ssw0rd"}))
+
+	rr = serveJSON(t, apiMux, "DELETE", "/me/identifiers/"+added.IdentifierID, token, nil)
+	assert.Equal(t, http.StatusNoContent, rr.Result().StatusCode)
+
+	rr = serveJSON(t, apiMux, "DELETE", "/me/identifiers/"+added.IdentifierID, token, nil)
+	assert.Equal(t, int(app.ErrIdentifierNotFound.Code), rr.Result().StatusCode)
+
+	assert.Equal(t, int(app.ErrUserNotFound.Code), login(api.LoginRequest{Identifier: "alice@example.com", Password: "parool123!"}))
+}
+
+func serveJSON(t *testing.T, handler http.Handler, method string, path string, token string, body interface{}) *httptest.ResponseRecorder {
+	var b []byte
+	if body != nil {
+		var err error
+		b, err = json.Marshal(body)
+		assert.NoError(t, err)
+	}
+
+	req := httptest.NewRequest(method, path, bytes.NewBuffer(b))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	return rr
+}
+
+func newAPITestCase(t *testing.T, makeUserDB MakeUserDB, makeRedis MakeRedis) (apiTestCase, func()) {
+	db, teardownDB, err := makeUserDB()
+	assert.NoError(t, err)
+
+	jwtWrapper := jwt.NewHS256Wrapper("secret")
+	usr := app.NewUserBackend(db, jwtWrapper)
+	authenticator := app.NewAuthenticator(db, jwtWrapper)
+	rbac := app.NewRBAC(local.NewRoleDB())
+
+	redis, teardownRedis, err := makeRedis()
+	assert.NoError(t, err)
+
+	store := local.NewUserDB()
+	privacy := app.NewPrivacyBackend(db, store, store, store, store, app.NewRedisSessionStore(redis), audit.NewLog(local.NewAuditStore()))
+	apiMux := api.NewMux(usr, app.NewOrganizationBackend(local.NewUserDB()), app.NewGroupBackend(local.NewUserDB()), privacy, authenticator, rbac, jwtWrapper, geoip.GeoIP{}, redis, nil)
+	teardown := func() {
+		assert.NoError(t, teardownRedis())
+		assert.NoError(t, teardownDB())
+	}
+
+	return apiTestCase{
+		am:          apiMux,
+		db:          db,
+		userBackend: usr,
+		redis:       redis,
+	}, teardown
+}
+
 func addIPToContext(ctx context.Context, ip net.IP) context.Context {
 	return context.WithValue(ctx, api.RemoteIPContextKey, ip)
 }
@@ -0,0 +1,204 @@
+package tests
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rislah/fakes/internal/audit"
+	"github.com/stretchr/testify/assert"
+)
+
+type MakeAuditStore func() (audit.Store, func() error, error)
+
+// tamperedStore alters the event with seq when it's read back, the way an
+// edit made directly in the database would.
+type tamperedStore struct {
+	audit.Store
+	seq    int64
+	tamper func(*audit.Event)
+}
+
+func (s tamperedStore) Events(ctx context.Context, after int64, limit int) ([]audit.Event, error) {
+	events, err := s.Store.Events(ctx, after, limit)
+	for i := range events {
+		if events[i].Seq == s.seq {
+			s.tamper(&events[i])
+		}
+	}
+	return events, err
+}
+
+func TestAuditStore(t *testing.T, makeAuditStore MakeAuditStore) {
+	const (
+		adminID = "11111111-1111-1111-1111-111111111111"
+		userID  = "22222222-2222-2222-2222-222222222222"
+	)
+
+	appendEvents := func(ctx context.Context, t *testing.T, log *audit.Log, n int) []audit.Event {
+		var events []audit.Event
+		for i := 0; i < n; i++ {
+			event, err := log.Record(ctx, audit.Event{
+				Action:   audit.UserUpdated,
+				ActorID:  adminID,
+				TargetID: userID,
+				Details:  audit.Details{"n": strconv.Itoa(i)},
+			})
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			events = append(events, event)
+		}
+		return events
+	}
+
+	tests := []struct {
+		name string
+		test func(ctx context.Context, t *testing.T, store audit.Store)
+	}{
+		{
+			name: "appended events are chained",
+			test: func(ctx context.Context, t *testing.T, store audit.Store) {
+				log := audit.NewLog(store)
+				events := appendEvents(ctx, t, log, 3)
+
+				assert.Equal(t, int64(1), events[0].Seq)
+				assert.Empty(t, events[0].PrevHash)
+				for i := 1; i < len(events); i++ {
+					assert.Equal(t, events[i-1].Seq+1, events[i].Seq)
+					assert.Equal(t, events[i-1].Hash, events[i].PrevHash)
+				}
+
+				stored, err := store.Events(ctx, 0, 10)
+				assert.NoError(t, err)
+				if assert.Len(t, stored, 3) {
+					assert.Equal(t, events[2].Hash, stored[2].Hash)
+					assert.True(t, events[2].OccurredAt.Equal(stored[2].OccurredAt))
+					assert.Equal(t, audit.Details{"n": "2"}, stored[2].Details)
+				}
+
+				res, err := log.Verify(ctx)
+				assert.NoError(t, err)
+				assert.True(t, res.Valid())
+				assert.Equal(t, int64(3), res.Events)
+				assert.Equal(t, events[2].Hash, res.Head.Hash)
+			},
+		},
+		{
+			name: "an unknown action is rejected",
+			test: func(ctx context.Context, t *testing.T, store audit.Store) {
+				_, err := audit.NewLog(store).Record(ctx, audit.Event{Action: "user.teleported"})
+				assert.Equal(t, audit.ErrActionInvalid, err)
+			},
+		},
+		{
+			name: "search filters and pages newest first",
+			test: func(ctx context.Context, t *testing.T, store audit.Store) {
+				log := audit.NewLog(store)
+				events := appendEvents(ctx, t, log, 5)
+				login, err := log.Record(ctx, audit.Event{Action: audit.LoginSucceeded, ActorID: userID, TargetID: userID, IP: "127.0.0.1"})
+				assert.NoError(t, err)
+
+				page, err := log.Search(ctx, audit.SearchQuery{ActorID: userID})
+				assert.NoError(t, err)
+				if assert.Len(t, page.Events, 1) {
+					assert.Equal(t, login.Seq, page.Events[0].Seq)
+					assert.Equal(t, "127.0.0.1", page.Events[0].IP)
+				}
+				assert.Empty(t, page.NextCursor)
+
+				page, err = log.Search(ctx, audit.SearchQuery{Action: audit.UserUpdated, TargetID: userID, Limit: 2})
+				assert.NoError(t, err)
+				if assert.Len(t, page.Events, 2) {
+					assert.Equal(t, events[4].Seq, page.Events[0].Seq)
+					assert.Equal(t, events[3].Seq, page.Events[1].Seq)
+				}
+
+				page, err = log.Search(ctx, audit.SearchQuery{Action: audit.UserUpdated, TargetID: userID, Limit: 2, Cursor: page.NextCursor})
+				assert.NoError(t, err)
+				if assert.Len(t, page.Events, 2) {
+					assert.Equal(t, events[2].Seq, page.Events[0].Seq)
+					assert.Equal(t, events[1].Seq, page.Events[1].Seq)
+				}
+
+				page, err = log.Search(ctx, audit.SearchQuery{Action: audit.UserUpdated, TargetID: userID, Limit: 2, Cursor: page.NextCursor})
+				assert.NoError(t, err)
+				if assert.Len(t, page.Events, 1) {
+					assert.Equal(t, events[0].Seq, page.Events[0].Seq)
+				}
+				assert.Empty(t, page.NextCursor)
+
+				page, err = log.Search(ctx, audit.SearchQuery{From: login.OccurredAt.Add(time.Microsecond)})
+				assert.NoError(t, err)
+				assert.Empty(t, page.Events)
+
+				page, err = log.Search(ctx, audit.SearchQuery{From: login.OccurredAt, To: login.OccurredAt.Add(time.Second)})
+				assert.NoError(t, err)
+				assert.NotEmpty(t, page.Events)
+			},
+		},
+		{
+			name: "invalid searches are rejected",
+			test: func(ctx context.Context, t *testing.T, store audit.Store) {
+				log := audit.NewLog(store)
+				now := time.Now()
+
+				_, err := log.Search(ctx, audit.SearchQuery{Limit: audit.MaxPageSize + 1})
+				assert.Equal(t, audit.ErrPageSizeInvalid, err)
+				_, err = log.Search(ctx, audit.SearchQuery{Cursor: "abc"})
+				assert.Equal(t, audit.ErrCursorInvalid, err)
+				_, err = log.Search(ctx, audit.SearchQuery{Action: "user.teleported"})
+				assert.Equal(t, audit.ErrActionInvalid, err)
+				_, err = log.Search(ctx, audit.SearchQuery{From: now, To: now})
+				assert.Equal(t, audit.ErrTimeRangeInvalid, err)
+			},
+		},
+		{
+			name: "verify detects altered and removed events",
+			test: func(ctx context.Context, t *testing.T, store audit.Store) {
+				appendEvents(ctx, t, audit.NewLog(store), 4)
+
+				tampers := []struct {
+					name   string
+					tamper func(*audit.Event)
+					// verified is the number of events before the break.
+					verified int64
+				}{
+					{"changed details", func(e *audit.Event) { e.Details = audit.Details{"n": "9"} }, 2},
+					{"changed actor", func(e *audit.Event) { e.ActorID = userID }, 2},
+					// A recomputed hash breaks the link from the next event.
+					{"rehashed", func(e *audit.Event) { e.TargetID = adminID; e.Hash = e.ComputeHash() }, 3},
+					{"removed", func(e *audit.Event) { e.Seq = 4 }, 2},
+				}
+
+				for _, tamper := range tampers {
+					res, err := audit.Verify(ctx, tamperedStore{Store: store, seq: 3, tamper: tamper.tamper})
+					assert.NoError(t, err, tamper.name)
+					if assert.False(t, res.Valid(), tamper.name) {
+						assert.Equal(t, tamper.verified, res.Events, tamper.name)
+						assert.NotEmpty(t, res.Reason, tamper.name)
+					}
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			store, teardown, err := makeAuditStore()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() {
+				assert.NoError(t, teardown())
+			}()
+
+			test.test(ctx, t, store)
+		})
+	}
+}
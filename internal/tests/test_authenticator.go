@@ -49,6 +49,17 @@ func TestAuthenticator(t *testing.T, makeUserDB MakeUserDB) {
 				assert.Equal(t, credentials.ErrPasswordLength, err)
 			},
 		},
+		{
+			scenario: "malformed identifier",
+			creds: credentials.Credentials{
+				Username: "+12ab",
+				Password: "p@r00l!2$",
+			},
+			test: func(ctx context.Context, testCase authenticatorTestCase) {
+				_, err := testCase.auth.AuthenticatePassword(ctx, testCase.creds)
+				assert.Equal(t, app.ErrIdentifierInvalid, err)
+			},
+		},
 		{
 			scenario: "creates valid jwt",
 			creds: credentials.Credentials{
@@ -57,12 +68,13 @@ func TestAuthenticator(t *testing.T, makeUserDB MakeUserDB) {
 			},
 			test: func(ctx context.Context, testCase authenticatorTestCase) {
 				user := app.User{
+					UserID:   "11111111-1111-1111-1111-111111111111",
 					Username: testCase.creds.Username.String(),
 					Password: testCase.creds.Password.String(),
 					Role:     app.GuestRole,
 				}
 
-				tokenStr, err := testCase.auth.GenerateJWT(user)
+				tokenStr, err := testCase.auth.GenerateJWT(user, app.Grants{})
 				assert.NoError(t, err)
 				assert.NotEmpty(t, tokenStr)
 
@@ -73,6 +85,66 @@ func TestAuthenticator(t *testing.T, makeUserDB MakeUserDB) {
 				assert.True(t, ok)
 				assert.Equal(t, testCase.creds.Username.String(), tokenUsrClaims.Username)
 				assert.Equal(t, app.GuestRole.String(), tokenUsrClaims.Role)
+				assert.Equal(t, user.UserID, tokenUsrClaims.Subject)
+			},
+		},
+		{
+			scenario: "suspended account",
+			creds: credentials.Credentials{
+				Username: "test_username",
+				Password: "p@r00l!2$",
+			},
+			test: func(ctx context.Context, testCase authenticatorTestCase) {
+				hash, err := testCase.creds.Password.GenerateBCrypt()
+				assert.NoError(t, err)
+				assert.NoError(t, testCase.db.CreateUser(ctx, app.User{Username: testCase.creds.Username.String(), Password: hash}))
+
+				usr, err := testCase.db.GetUserByUsername(ctx, testCase.creds.Username.String())
+				assert.NoError(t, err)
+				assert.Equal(t, app.StatusActive, usr.Status)
+
+				_, err = testCase.auth.AuthenticatePassword(ctx, testCase.creds)
+				assert.NoError(t, err)
+
+				err = testCase.db.UpdateUserStatus(ctx, usr.UserID, app.StatusChange{From: app.StatusActive, To: app.StatusSuspended, Reason: "spam", At: time.Now()})
+				assert.NoError(t, err)
+
+				_, err = testCase.auth.AuthenticatePassword(ctx, testCase.creds)
+				assert.Equal(t, app.ErrAccountSuspended, err)
+
+				_, err = testCase.auth.AuthenticatePassword(ctx, credentials.Credentials{Username: testCase.creds.Username, Password: "wr0ng-p@ssw0rd"})
+				assert.NotEqual(t, app.ErrAccountSuspended, err, "status isn't revealed without the password")
+			},
+		},
+		{
+			scenario: "creates organization jwt",
+			creds: credentials.Credentials{
+				Username: "test_username",
+				Password: "p@r00l!2$",
+			},
+			test: func(ctx context.Context, testCase authenticatorTestCase) {
+				user := app.User{
+					UserID:   "11111111-1111-1111-1111-111111111111",
+					Username: testCase.creds.Username.String(),
+					Role:     app.GuestRole,
+				}
+				membership := app.Membership{
+					OrgID:  "22222222-2222-2222-2222-222222222222",
+					UserID: user.UserID,
+					Role:   app.DeveloperRole,
+				}
+
+				tokenStr, err := testCase.auth.GenerateOrganizationJWT(user, membership)
+				assert.NoError(t, err)
+
+				token, err := testCase.jwtWrapper.Decode(tokenStr, &jwt.UserClaims{})
+				assert.NoError(t, err)
+
+				tokenUsrClaims, ok := token.Claims.(*jwt.UserClaims)
+				assert.True(t, ok)
+				assert.Equal(t, app.GuestRole.String(), tokenUsrClaims.Role)
+				assert.Equal(t, membership.OrgID, tokenUsrClaims.Organization)
+				assert.Equal(t, app.DeveloperRole.String(), tokenUsrClaims.OrganizationRole)
 			},
 		},
 	}
@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+type MakeErasureDB func() (app.ErasureDB, func() error, error)
+
+func TestErasureDB(t *testing.T, makeErasureDB MakeErasureDB) {
+	const (
+		userID      = "11111111-1111-1111-1111-111111111111"
+		otherUserID = "22222222-2222-2222-2222-222222222222"
+	)
+
+	requestedAt := time.Date(2021, 11, 14, 9, 0, 0, 0, time.UTC)
+	newRequest := func(userID string, scheduledFor time.Time) app.ErasureRequest {
+		return app.ErasureRequest{UserID: userID, Status: app.ErasurePending, RequestedAt: requestedAt, ScheduledFor: scheduledFor}
+	}
+
+	tests := []struct {
+		name string
+		test func(ctx context.Context, t *testing.T, db app.ErasureDB)
+	}{
+		{
+			name: "create a request and read it back",
+			test: func(ctx context.Context, t *testing.T, db app.ErasureDB) {
+				request, err := db.CreateErasureRequest(ctx, newRequest(userID, requestedAt.Add(time.Hour)))
+				assert.NoError(t, err)
+				assert.NotEmpty(t, request.RequestID)
+				assert.Equal(t, app.ErasurePending, request.Status)
+
+				res, err := db.GetPendingErasureRequest(ctx, userID)
+				assert.NoError(t, err)
+				assert.Equal(t, request.RequestID, res.RequestID)
+				assert.True(t, request.ScheduledFor.Equal(res.ScheduledFor))
+				assert.Nil(t, res.CompletedAt)
+
+				res, err = db.GetPendingErasureRequest(ctx, otherUserID)
+				assert.NoError(t, err)
+				assert.True(t, res.IsEmpty())
+
+				_, err = db.CreateErasureRequest(ctx, newRequest(userID, requestedAt.Add(time.Hour)))
+				assert.Equal(t, app.ErrErasureAlreadyRequested, err)
+			},
+		},
+		{
+			name: "delete a pending request",
+			test: func(ctx context.Context, t *testing.T, db app.ErasureDB) {
+				_, err := db.CreateErasureRequest(ctx, newRequest(userID, requestedAt.Add(time.Hour)))
+				assert.NoError(t, err)
+
+				assert.NoError(t, db.DeletePendingErasureRequest(ctx, userID))
+				assert.Equal(t, app.ErrErasureRequestNotFound, db.DeletePendingErasureRequest(ctx, userID))
+
+				res, err := db.GetPendingErasureRequest(ctx, userID)
+				assert.NoError(t, err)
+				assert.True(t, res.IsEmpty())
+			},
+		},
+		{
+			name: "complete due requests",
+			test: func(ctx context.Context, t *testing.T, db app.ErasureDB) {
+				late, err := db.CreateErasureRequest(ctx, newRequest(userID, requestedAt.Add(2*time.Hour)))
+				assert.NoError(t, err)
+				early, err := db.CreateErasureRequest(ctx, newRequest(otherUserID, requestedAt.Add(time.Hour)))
+				assert.NoError(t, err)
+
+				due, err := db.GetDueErasureRequests(ctx, requestedAt)
+				assert.NoError(t, err)
+				assert.Empty(t, due)
+
+				due, err = db.GetDueErasureRequests(ctx, requestedAt.Add(2*time.Hour))
+				assert.NoError(t, err)
+				if assert.Len(t, due, 2) {
+					assert.Equal(t, early.RequestID, due[0].RequestID)
+					assert.Equal(t, late.RequestID, due[1].RequestID)
+				}
+
+				completedAt := requestedAt.Add(3 * time.Hour)
+				assert.NoError(t, db.CompleteErasureRequest(ctx, early.RequestID, completedAt))
+				assert.Equal(t, app.ErrErasureRequestNotFound, db.CompleteErasureRequest(ctx, early.RequestID, completedAt))
+
+				due, err = db.GetDueErasureRequests(ctx, completedAt)
+				assert.NoError(t, err)
+				if assert.Len(t, due, 1) {
+					assert.Equal(t, late.RequestID, due[0].RequestID)
+				}
+
+				res, err := db.GetPendingErasureRequest(ctx, otherUserID)
+				assert.NoError(t, err)
+				assert.True(t, res.IsEmpty(), "completed requests aren't pending")
+
+				_, err = db.CreateErasureRequest(ctx, newRequest(otherUserID, completedAt))
+				assert.NoError(t, err, "a completed request doesn't block a new one")
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			db, teardown, err := makeErasureDB()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() {
+				assert.NoError(t, teardown())
+			}()
+
+			test.test(ctx, t, db)
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+type MakeGroupDB func() (app.UserDB, app.GroupDB, func() error, error)
+
+func TestGroupDB(t *testing.T, makeGroupDB MakeGroupDB) {
+	tests := []struct {
+		name string
+		test func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.GroupDB)
+	}{
+		{
+			name: "create a group and read it back",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.GroupDB) {
+				group, err := db.CreateGroup(ctx, app.Group{
+					Name:        "support",
+					Roles:       []app.Role{app.DeveloperRole},
+					Permissions: []string{"exportData"},
+				})
+				assert.NoError(t, err)
+				assert.NotEmpty(t, group.GroupID)
+
+				res, err := db.GetGroup(ctx, group.GroupID)
+				assert.NoError(t, err)
+				assert.Equal(t, group, res)
+
+				groups, err := db.GetGroups(ctx)
+				assert.NoError(t, err)
+				assert.Equal(t, []app.Group{group}, groups)
+			},
+		},
+		{
+			name: "create a group with a taken name",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.GroupDB) {
+				_, err := db.CreateGroup(ctx, app.Group{Name: "support"})
+				assert.NoError(t, err)
+
+				_, err = db.CreateGroup(ctx, app.Group{Name: "support"})
+				assert.Equal(t, app.ErrGroupAlreadyExists, err)
+			},
+		},
+		{
+			name: "create a group with an unknown role",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.GroupDB) {
+				_, err := db.CreateGroup(ctx, app.Group{Name: "support", Roles: []app.Role{"doesnotexist"}})
+				assert.Equal(t, app.ErrRoleNotFound, err)
+			},
+		},
+		{
+			name: "update a group",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.GroupDB) {
+				group, err := db.CreateGroup(ctx, app.Group{Name: "support", Roles: []app.Role{app.UserRole}})
+				assert.NoError(t, err)
+
+				group.Name = "helpdesk"
+				group.Roles = []app.Role{app.DeveloperRole}
+				group.Permissions = []string{"exportData"}
+				err = db.UpdateGroup(ctx, group)
+				assert.NoError(t, err)
+
+				res, err := db.GetGroup(ctx, group.GroupID)
+				assert.NoError(t, err)
+				assert.Equal(t, group, res)
+
+				err = db.UpdateGroup(ctx, app.Group{GroupID: "11111111-1111-1111-1111-111111111111", Name: "nope"})
+				assert.Equal(t, app.ErrGroupNotFound, err)
+			},
+		},
+		{
+			name: "delete a group",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.GroupDB) {
+				usr := createUser(ctx, t, userDB, "member")
+				group, err := db.CreateGroup(ctx, app.Group{Name: "support"})
+				assert.NoError(t, err)
+				assert.NoError(t, db.AddGroupMember(ctx, group.GroupID, usr.UserID))
+
+				err = db.DeleteGroup(ctx, group.GroupID)
+				assert.NoError(t, err)
+
+				res, err := db.GetGroup(ctx, group.GroupID)
+				assert.NoError(t, err)
+				assert.True(t, res.IsEmpty())
+
+				groups, err := db.GetUserGroups(ctx, usr.UserID)
+				assert.NoError(t, err)
+				assert.Empty(t, groups)
+
+				err = db.DeleteGroup(ctx, group.GroupID)
+				assert.Equal(t, app.ErrGroupNotFound, err)
+			},
+		},
+		{
+			name: "manage members",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.GroupDB) {
+				usr := createUser(ctx, t, userDB, "member")
+				group, err := db.CreateGroup(ctx, app.Group{Name: "support", Roles: []app.Role{app.DeveloperRole}})
+				assert.NoError(t, err)
+
+				err = db.AddGroupMember(ctx, group.GroupID, usr.UserID)
+				assert.NoError(t, err)
+
+				err = db.AddGroupMember(ctx, group.GroupID, usr.UserID)
+				assert.Equal(t, app.ErrGroupMemberAlreadyExists, err)
+
+				members, err := db.GetGroupMembers(ctx, group.GroupID)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{usr.UserID}, members)
+
+				groups, err := db.GetUserGroups(ctx, usr.UserID)
+				assert.NoError(t, err)
+				assert.Equal(t, []app.Group{group}, groups)
+
+				err = db.RemoveGroupMember(ctx, group.GroupID, usr.UserID)
+				assert.NoError(t, err)
+
+				err = db.RemoveGroupMember(ctx, group.GroupID, usr.UserID)
+				assert.Equal(t, app.ErrGroupMemberNotFound, err)
+			},
+		},
+		{
+			name: "add a member with unknown references",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.GroupDB) {
+				usr := createUser(ctx, t, userDB, "member")
+				group, err := db.CreateGroup(ctx, app.Group{Name: "support"})
+				assert.NoError(t, err)
+
+				err = db.AddGroupMember(ctx, "11111111-1111-1111-1111-111111111111", usr.UserID)
+				assert.Equal(t, app.ErrGroupNotFound, err)
+
+				err = db.AddGroupMember(ctx, group.GroupID, "11111111-1111-1111-1111-111111111111")
+				assert.Equal(t, app.ErrUserNotFound, err)
+			},
+		},
+		{
+			name: "user grants are the union of its groups",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.GroupDB) {
+				usr := createUser(ctx, t, userDB, "member")
+				backend := app.NewGroupBackend(db)
+
+				support, err := backend.CreateGroup(ctx, app.Group{Name: "support", Roles: []app.Role{app.UserRole}, Permissions: []string{"exportData"}})
+				assert.NoError(t, err)
+				ops, err := backend.CreateGroup(ctx, app.Group{Name: "ops", Roles: []app.Role{app.DeveloperRole, app.UserRole}, Permissions: []string{"deploy"}})
+				assert.NoError(t, err)
+
+				assert.NoError(t, backend.AddGroupMember(ctx, support.GroupID, usr.UserID))
+				assert.NoError(t, backend.AddGroupMember(ctx, ops.GroupID, usr.UserID))
+
+				grants, err := backend.GetUserGrants(ctx, usr.UserID)
+				assert.NoError(t, err)
+				assert.Equal(t, app.Grants{
+					Roles:       []app.Role{app.DeveloperRole, app.UserRole},
+					Permissions: []string{"deploy", "exportData"},
+				}, grants)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			userDB, db, teardown, err := makeGroupDB()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() {
+				err := teardown()
+				assert.NoError(t, err)
+			}()
+
+			test.test(ctx, t, userDB, db)
+		})
+	}
+}
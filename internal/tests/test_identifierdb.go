@@ -0,0 +1,221 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+type MakeIdentifierDB func() (app.UserDB, app.IdentifierDB, func() error, error)
+
+func TestIdentifierDB(t *testing.T, makeIdentifierDB MakeIdentifierDB) {
+	createdAt := time.Date(2021, 11, 20, 9, 0, 0, 0, time.UTC)
+	newIdentifier := func(userID string, value string, codeHash string) app.Identifier {
+		return app.Identifier{
+			UserID:    userID,
+			Type:      app.IdentifierEmail,
+			Value:     value,
+			CodeHash:  codeHash,
+			CreatedAt: createdAt,
+		}
+	}
+
+	tests := []struct {
+		name string
+		test func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.IdentifierDB)
+	}{
+		{
+			name: "create identifiers and read them back",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.IdentifierDB) {
+				alice := createUser(ctx, t, userDB, "alice")
+
+				email, err := db.CreateIdentifier(ctx, newIdentifier(alice.UserID, "alice@example.com", "hash"))
+				assert.NoError(t, err)
+				assert.NotEmpty(t, email.IdentifierID)
+				assert.False(t, email.Verified)
+
+				spec := newIdentifier(alice.UserID, "+3725551234", "hash")
+				spec.Type = app.IdentifierPhone
+				spec.CreatedAt = createdAt.Add(time.Minute)
+				phone, err := db.CreateIdentifier(ctx, spec)
+				assert.NoError(t, err)
+
+				res, err := db.GetIdentifier(ctx, email.IdentifierID)
+				assert.NoError(t, err)
+				assert.Equal(t, alice.UserID, res.UserID)
+				assert.Equal(t, app.IdentifierEmail, res.Type)
+				assert.Equal(t, "alice@example.com", res.Value)
+				assert.Equal(t, "hash", res.CodeHash)
+				assert.Equal(t, 0, res.Attempts)
+				assert.True(t, createdAt.Equal(res.CreatedAt))
+
+				identifiers, err := db.ListIdentifiers(ctx, alice.UserID)
+				assert.NoError(t, err)
+				if assert.Len(t, identifiers, 2) {
+					assert.Equal(t, email.IdentifierID, identifiers[0].IdentifierID)
+					assert.Equal(t, phone.IdentifierID, identifiers[1].IdentifierID)
+				}
+
+				res, err = db.GetIdentifier(ctx, "11111111-1111-1111-1111-111111111111")
+				assert.NoError(t, err)
+				assert.True(t, res.IsEmpty())
+
+				identifiers, err = db.ListIdentifiers(ctx, "11111111-1111-1111-1111-111111111111")
+				assert.NoError(t, err)
+				assert.Empty(t, identifiers)
+			},
+		},
+		{
+			name: "a user can't add the same identifier twice",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.IdentifierDB) {
+				alice := createUser(ctx, t, userDB, "alice")
+
+				_, err := db.CreateIdentifier(ctx, newIdentifier(alice.UserID, "alice@example.com", "hash"))
+				assert.NoError(t, err)
+
+				_, err = db.CreateIdentifier(ctx, newIdentifier(alice.UserID, "alice@example.com", "other"))
+				assert.Equal(t, app.ErrIdentifierTaken, err)
+
+				_, err = db.CreateIdentifier(ctx, newIdentifier("11111111-1111-1111-1111-111111111111", "bob@example.com", "hash"))
+				assert.Equal(t, app.ErrUserNotFound, err)
+			},
+		},
+		{
+			name: "verified identifiers resolve to their user",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.IdentifierDB) {
+				alice := createUser(ctx, t, userDB, "alice")
+
+				email, err := db.CreateIdentifier(ctx, newIdentifier(alice.UserID, "alice@example.com", "hash"))
+				assert.NoError(t, err)
+
+				usr, err := userDB.GetUserByIdentifier(ctx, "alice@example.com")
+				assert.NoError(t, err)
+				assert.True(t, usr.IsEmpty(), "unverified identifiers can't be logged in with")
+
+				err = db.VerifyIdentifier(ctx, email.IdentifierID, "wrong", createdAt)
+				assert.Equal(t, app.ErrVerificationCodeInvalid, err)
+
+				err = db.VerifyIdentifier(ctx, email.IdentifierID, "hash", createdAt)
+				assert.NoError(t, err)
+
+				res, err := db.GetIdentifier(ctx, email.IdentifierID)
+				assert.NoError(t, err)
+				assert.True(t, res.Verified)
+				assert.Equal(t, 2, res.Attempts)
+
+				assert.NoError(t, db.VerifyIdentifier(ctx, email.IdentifierID, "hash", createdAt), "verifying again is a no-op")
+
+				usr, err = userDB.GetUserByIdentifier(ctx, "alice@example.com")
+				assert.NoError(t, err)
+				assert.Equal(t, alice.UserID, usr.UserID)
+				assert.Equal(t, "alice", usr.Username)
+
+				usr, err = userDB.GetUserByIdentifier(ctx, "alice")
+				assert.NoError(t, err)
+				assert.Equal(t, alice.UserID, usr.UserID)
+
+				err = db.VerifyIdentifier(ctx, "11111111-1111-1111-1111-111111111111", "hash", createdAt)
+				assert.Equal(t, app.ErrIdentifierNotFound, err)
+			},
+		},
+		{
+			name: "attempts run out",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.IdentifierDB) {
+				alice := createUser(ctx, t, userDB, "alice")
+
+				email, err := db.CreateIdentifier(ctx, newIdentifier(alice.UserID, "alice@example.com", "hash"))
+				assert.NoError(t, err)
+
+				for i := 0; i < app.MaxVerificationAttempts; i++ {
+					err := db.VerifyIdentifier(ctx, email.IdentifierID, "wrong", createdAt)
+					assert.Equal(t, app.ErrVerificationCodeInvalid, err)
+				}
+
+				err = db.VerifyIdentifier(ctx, email.IdentifierID, "hash", createdAt)
+				assert.Equal(t, app.ErrVerificationCodeInvalid, err)
+
+				res, err := db.GetIdentifier(ctx, email.IdentifierID)
+				assert.NoError(t, err)
+				assert.False(t, res.Verified)
+				assert.Equal(t, app.MaxVerificationAttempts, res.Attempts)
+			},
+		},
+		{
+			name: "a value can only be verified for one user",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.IdentifierDB) {
+				alice := createUser(ctx, t, userDB, "alice")
+				bob := createUser(ctx, t, userDB, "bob_the_user")
+
+				aliceEmail, err := db.CreateIdentifier(ctx, newIdentifier(alice.UserID, "shared@example.com", "hash"))
+				assert.NoError(t, err)
+
+				bobEmail, err := db.CreateIdentifier(ctx, newIdentifier(bob.UserID, "shared@example.com", "hash"))
+				assert.NoError(t, err, "unverified identifiers don't reserve the value")
+
+				assert.NoError(t, db.VerifyIdentifier(ctx, aliceEmail.IdentifierID, "hash", createdAt))
+
+				err = db.VerifyIdentifier(ctx, bobEmail.IdentifierID, "hash", createdAt)
+				assert.Equal(t, app.ErrIdentifierTaken, err)
+
+				res, err := db.GetIdentifier(ctx, bobEmail.IdentifierID)
+				assert.NoError(t, err)
+				assert.False(t, res.Verified)
+				assert.Equal(t, 1, res.Attempts, "the attempt still counts")
+
+				usr, err := userDB.GetUserByIdentifier(ctx, "shared@example.com")
+				assert.NoError(t, err)
+				assert.Equal(t, alice.UserID, usr.UserID)
+			},
+		},
+		{
+			name: "delete identifiers",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.IdentifierDB) {
+				alice := createUser(ctx, t, userDB, "alice")
+				bob := createUser(ctx, t, userDB, "bob_the_user")
+
+				aliceEmail, err := db.CreateIdentifier(ctx, newIdentifier(alice.UserID, "alice@example.com", "hash"))
+				assert.NoError(t, err)
+				assert.NoError(t, db.VerifyIdentifier(ctx, aliceEmail.IdentifierID, "hash", createdAt))
+
+				bobEmail, err := db.CreateIdentifier(ctx, newIdentifier(bob.UserID, "bob@example.com", "hash"))
+				assert.NoError(t, err)
+				assert.NoError(t, db.VerifyIdentifier(ctx, bobEmail.IdentifierID, "hash", createdAt))
+
+				assert.NoError(t, db.DeleteIdentifier(ctx, aliceEmail.IdentifierID))
+				assert.Equal(t, app.ErrIdentifierNotFound, db.DeleteIdentifier(ctx, aliceEmail.IdentifierID))
+				assert.Equal(t, app.ErrIdentifierNotFound, db.DeleteIdentifier(ctx, "not-a-uuid"))
+
+				usr, err := userDB.GetUserByIdentifier(ctx, "alice@example.com")
+				assert.NoError(t, err)
+				assert.True(t, usr.IsEmpty())
+
+				assert.NoError(t, userDB.DeleteUser(ctx, bob.UserID))
+
+				res, err := db.GetIdentifier(ctx, bobEmail.IdentifierID)
+				assert.NoError(t, err)
+				assert.True(t, res.IsEmpty(), "deleting a user deletes its identifiers")
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			userDB, db, teardown, err := makeIdentifierDB()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() {
+				assert.NoError(t, teardown())
+			}()
+
+			test.test(ctx, t, userDB, db)
+		})
+	}
+}
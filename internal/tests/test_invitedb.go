@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+type MakeInviteDB func() (app.InviteDB, func() error, error)
+
+func TestInviteDB(t *testing.T, makeInviteDB MakeInviteDB) {
+	createdAt := time.Date(2021, 11, 18, 9, 0, 0, 0, time.UTC)
+	newInvite := func(codeHash string, maxUses int, expiresAt time.Time) app.Invite {
+		return app.Invite{
+			CodeHash:  codeHash,
+			Role:      app.UserRole,
+			MaxUses:   maxUses,
+			ExpiresAt: expiresAt,
+			CreatedAt: createdAt,
+		}
+	}
+
+	tests := []struct {
+		name string
+		test func(ctx context.Context, t *testing.T, db app.InviteDB)
+	}{
+		{
+			name: "create an invite and read it back by its code hash",
+			test: func(ctx context.Context, t *testing.T, db app.InviteDB) {
+				spec := newInvite(app.HashInviteCode("code"), 2, createdAt.Add(time.Hour))
+				spec.Email = "alice@example.com"
+				spec.Role = app.DeveloperRole
+				spec.CreatedBy = "11111111-1111-1111-1111-111111111111"
+
+				invite, err := db.CreateInvite(ctx, spec)
+				assert.NoError(t, err)
+				assert.NotEmpty(t, invite.InviteID)
+
+				res, err := db.GetInviteByCodeHash(ctx, app.HashInviteCode("code"))
+				assert.NoError(t, err)
+				assert.Equal(t, invite.InviteID, res.InviteID)
+				assert.Equal(t, "alice@example.com", res.Email)
+				assert.Equal(t, app.DeveloperRole, res.Role)
+				assert.Equal(t, 2, res.MaxUses)
+				assert.Equal(t, 0, res.Uses)
+				assert.Equal(t, spec.CreatedBy, res.CreatedBy)
+				assert.True(t, spec.ExpiresAt.Equal(res.ExpiresAt))
+
+				res, err = db.GetInviteByCodeHash(ctx, app.HashInviteCode("other"))
+				assert.NoError(t, err)
+				assert.True(t, res.IsEmpty())
+			},
+		},
+		{
+			name: "an invite for an unknown role is rejected",
+			test: func(ctx context.Context, t *testing.T, db app.InviteDB) {
+				spec := newInvite(app.HashInviteCode("code"), 1, createdAt.Add(time.Hour))
+				spec.Role = "doesnotexist"
+
+				_, err := db.CreateInvite(ctx, spec)
+				assert.Equal(t, app.ErrRoleNotFound, err)
+			},
+		},
+		{
+			name: "redeem until used up and release",
+			test: func(ctx context.Context, t *testing.T, db app.InviteDB) {
+				invite, err := db.CreateInvite(ctx, newInvite(app.HashInviteCode("code"), 2, createdAt.Add(time.Hour)))
+				assert.NoError(t, err)
+
+				assert.NoError(t, db.RedeemInvite(ctx, invite.InviteID, createdAt))
+				assert.NoError(t, db.RedeemInvite(ctx, invite.InviteID, createdAt))
+				assert.Equal(t, app.ErrInviteInvalid, db.RedeemInvite(ctx, invite.InviteID, createdAt))
+
+				assert.NoError(t, db.ReleaseInvite(ctx, invite.InviteID))
+				res, err := db.GetInviteByCodeHash(ctx, invite.CodeHash)
+				assert.NoError(t, err)
+				assert.Equal(t, 1, res.Uses)
+
+				assert.NoError(t, db.RedeemInvite(ctx, invite.InviteID, createdAt))
+				assert.Equal(t, app.ErrInviteInvalid, db.RedeemInvite(ctx, "11111111-1111-1111-1111-111111111111", createdAt))
+			},
+		},
+		{
+			name: "an expired invite can't be redeemed",
+			test: func(ctx context.Context, t *testing.T, db app.InviteDB) {
+				invite, err := db.CreateInvite(ctx, newInvite(app.HashInviteCode("code"), 1, createdAt.Add(time.Hour)))
+				assert.NoError(t, err)
+
+				assert.Equal(t, app.ErrInviteInvalid, db.RedeemInvite(ctx, invite.InviteID, createdAt.Add(time.Hour)))
+			},
+		},
+		{
+			name: "list pending invites",
+			test: func(ctx context.Context, t *testing.T, db app.InviteDB) {
+				_, err := db.CreateInvite(ctx, newInvite(app.HashInviteCode("expired"), 1, createdAt.Add(time.Minute)))
+				assert.NoError(t, err)
+				usedUp, err := db.CreateInvite(ctx, newInvite(app.HashInviteCode("used"), 1, createdAt.Add(time.Hour)))
+				assert.NoError(t, err)
+				assert.NoError(t, db.RedeemInvite(ctx, usedUp.InviteID, createdAt))
+
+				later := newInvite(app.HashInviteCode("later"), 1, createdAt.Add(time.Hour))
+				later.CreatedAt = createdAt.Add(time.Second)
+				second, err := db.CreateInvite(ctx, later)
+				assert.NoError(t, err)
+				first, err := db.CreateInvite(ctx, newInvite(app.HashInviteCode("first"), 3, createdAt.Add(time.Hour)))
+				assert.NoError(t, err)
+
+				invites, err := db.ListPendingInvites(ctx, createdAt.Add(2*time.Minute))
+				assert.NoError(t, err)
+				if assert.Len(t, invites, 2) {
+					assert.Equal(t, first.InviteID, invites[0].InviteID)
+					assert.Equal(t, second.InviteID, invites[1].InviteID)
+				}
+
+				invites, err = db.ListPendingInvites(ctx, createdAt)
+				assert.NoError(t, err)
+				assert.Len(t, invites, 3, "the expired invite is pending until it expires")
+				for _, invite := range invites {
+					assert.NotEqual(t, usedUp.InviteID, invite.InviteID)
+				}
+			},
+		},
+		{
+			name: "delete an invite",
+			test: func(ctx context.Context, t *testing.T, db app.InviteDB) {
+				invite, err := db.CreateInvite(ctx, newInvite(app.HashInviteCode("code"), 1, createdAt.Add(time.Hour)))
+				assert.NoError(t, err)
+
+				assert.NoError(t, db.DeleteInvite(ctx, invite.InviteID))
+				assert.Equal(t, app.ErrInviteNotFound, db.DeleteInvite(ctx, invite.InviteID))
+				assert.Equal(t, app.ErrInviteNotFound, db.DeleteInvite(ctx, "not-a-uuid"))
+
+				res, err := db.GetInviteByCodeHash(ctx, invite.CodeHash)
+				assert.NoError(t, err)
+				assert.True(t, res.IsEmpty())
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			db, teardown, err := makeInviteDB()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() {
+				assert.NoError(t, teardown())
+			}()
+
+			test.test(ctx, t, db)
+		})
+	}
+}
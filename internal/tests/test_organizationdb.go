@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+type MakeOrganizationDB func() (app.UserDB, app.OrganizationDB, func() error, error)
+
+func TestOrganizationDB(t *testing.T, makeOrganizationDB MakeOrganizationDB) {
+	tests := []struct {
+		name string
+		test func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.OrganizationDB)
+	}{
+		{
+			name: "create an organization and read it back",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.OrganizationDB) {
+				org, err := db.CreateOrganization(ctx, app.Organization{Name: "acme"})
+				assert.NoError(t, err)
+				assert.NotEmpty(t, org.OrgID)
+
+				res, err := db.GetOrganization(ctx, org.OrgID)
+				assert.NoError(t, err)
+				assert.Equal(t, org, res)
+			},
+		},
+		{
+			name: "create an organization with a taken name",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.OrganizationDB) {
+				_, err := db.CreateOrganization(ctx, app.Organization{Name: "acme"})
+				assert.NoError(t, err)
+
+				_, err = db.CreateOrganization(ctx, app.Organization{Name: "acme"})
+				assert.Equal(t, app.ErrOrganizationAlreadyExists, err)
+			},
+		},
+		{
+			name: "get an organization that doesnt exist",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.OrganizationDB) {
+				res, err := db.GetOrganization(ctx, "11111111-1111-1111-1111-111111111111")
+				assert.NoError(t, err)
+				assert.True(t, res.IsEmpty())
+			},
+		},
+		{
+			name: "add a member and read memberships",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.OrganizationDB) {
+				usr := createUser(ctx, t, userDB, "member")
+				org, err := db.CreateOrganization(ctx, app.Organization{Name: "acme"})
+				assert.NoError(t, err)
+
+				membership := app.Membership{OrgID: org.OrgID, UserID: usr.UserID, Role: app.DeveloperRole}
+				err = db.AddMember(ctx, membership)
+				assert.NoError(t, err)
+
+				res, err := db.GetMembership(ctx, org.OrgID, usr.UserID)
+				assert.NoError(t, err)
+				assert.Equal(t, membership, res)
+
+				memberships, err := db.GetMemberships(ctx, usr.UserID)
+				assert.NoError(t, err)
+				assert.Equal(t, []app.Membership{membership}, memberships)
+
+				err = db.AddMember(ctx, membership)
+				assert.Equal(t, app.ErrMemberAlreadyExists, err)
+			},
+		},
+		{
+			name: "add a member with unknown references",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.OrganizationDB) {
+				usr := createUser(ctx, t, userDB, "member")
+				org, err := db.CreateOrganization(ctx, app.Organization{Name: "acme"})
+				assert.NoError(t, err)
+
+				err = db.AddMember(ctx, app.Membership{OrgID: "11111111-1111-1111-1111-111111111111", UserID: usr.UserID, Role: app.UserRole})
+				assert.Equal(t, app.ErrOrganizationNotFound, err)
+
+				err = db.AddMember(ctx, app.Membership{OrgID: org.OrgID, UserID: "11111111-1111-1111-1111-111111111111", Role: app.UserRole})
+				assert.Equal(t, app.ErrUserNotFound, err)
+
+				err = db.AddMember(ctx, app.Membership{OrgID: org.OrgID, UserID: usr.UserID, Role: "doesnotexist"})
+				assert.Equal(t, app.ErrRoleNotFound, err)
+			},
+		},
+		{
+			name: "get users is scoped to the organization in context",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.OrganizationDB) {
+				member := createUser(ctx, t, userDB, "member")
+				createUser(ctx, t, userDB, "outsider")
+
+				org, err := db.CreateOrganization(ctx, app.Organization{Name: "acme"})
+				assert.NoError(t, err)
+
+				err = db.AddMember(ctx, app.Membership{OrgID: org.OrgID, UserID: member.UserID, Role: app.DeveloperRole})
+				assert.NoError(t, err)
+
+				users, err := userDB.GetUsers(ctx)
+				assert.NoError(t, err)
+				assert.Len(t, users, 2)
+
+				users, err = userDB.GetUsers(app.WithOrganization(ctx, org.OrgID))
+				assert.NoError(t, err)
+				assert.Len(t, users, 1)
+				assert.Equal(t, member.UserID, users[0].UserID)
+				assert.Equal(t, app.DeveloperRole, users[0].Role)
+			},
+		},
+		{
+			name: "user lookups are scoped to the organization in context",
+			test: func(ctx context.Context, t *testing.T, userDB app.UserDB, db app.OrganizationDB) {
+				member := createUser(ctx, t, userDB, "member")
+				outsider := createUser(ctx, t, userDB, "outsider")
+
+				org, err := db.CreateOrganization(ctx, app.Organization{Name: "acme"})
+				assert.NoError(t, err)
+
+				err = db.AddMember(ctx, app.Membership{OrgID: org.OrgID, UserID: member.UserID, Role: app.DeveloperRole})
+				assert.NoError(t, err)
+
+				scoped := app.WithOrganization(ctx, org.OrgID)
+
+				usr, err := userDB.GetUserByID(scoped, member.UserID)
+				assert.NoError(t, err)
+				assert.Equal(t, member.UserID, usr.UserID)
+				assert.Equal(t, app.DeveloperRole, usr.Role)
+
+				usr, err = userDB.GetUserByUsername(scoped, "member")
+				assert.NoError(t, err)
+				assert.Equal(t, member.UserID, usr.UserID)
+
+				usr, err = userDB.GetUserByID(scoped, outsider.UserID)
+				assert.NoError(t, err)
+				assert.True(t, usr.IsEmpty())
+
+				usr, err = userDB.GetUserByUsername(scoped, "outsider")
+				assert.NoError(t, err)
+				assert.True(t, usr.IsEmpty())
+
+				usr, err = userDB.GetUserByUsername(app.WithoutOrganization(scoped), "outsider")
+				assert.NoError(t, err)
+				assert.Equal(t, outsider.UserID, usr.UserID)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			userDB, db, teardown, err := makeOrganizationDB()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() {
+				err := teardown()
+				assert.NoError(t, err)
+			}()
+
+			test.test(ctx, t, userDB, db)
+		})
+	}
+}
+
+func createUser(ctx context.Context, t *testing.T, db app.UserDB, username string) app.User {
+	err := db.CreateUser(ctx, app.User{Username: username, Password: "pass"})
+	assert.NoError(t, err)
+
+	usr, err := db.GetUserByUsername(ctx, username)
+	assert.NoError(t, err)
+
+	return usr
+}
@@ -0,0 +1,219 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+type MakeRoleDB func() (app.RoleDB, func() error, error)
+
+func TestRoleDB(t *testing.T, makeRoleDB MakeRoleDB) {
+	tests := []struct {
+		name string
+		role app.RoleDefinition
+		test func(ctx context.Context, t *testing.T, db app.RoleDB, role app.RoleDefinition)
+	}{
+		{
+			name: "default roles are present",
+			test: func(ctx context.Context, t *testing.T, db app.RoleDB, role app.RoleDefinition) {
+				roles, err := db.GetRoles(ctx)
+				assert.NoError(t, err)
+
+				var names []app.Role
+				for _, r := range roles {
+					names = append(names, r.Name)
+				}
+				assert.Subset(t, names, []app.Role{app.GuestRole, app.UserRole, app.DeveloperRole, app.AdminRole})
+			},
+		},
+		{
+			name: "create a role and read it back",
+			role: app.RoleDefinition{
+				Name:        "moderator",
+				Permissions: []string{"editPosts", "deletePosts"},
+			},
+			test: func(ctx context.Context, t *testing.T, db app.RoleDB, role app.RoleDefinition) {
+				err := db.CreateRole(ctx, role)
+				assert.NoError(t, err)
+
+				res, err := db.GetRoleByName(ctx, role.Name)
+				assert.NoError(t, err)
+				assert.Equal(t, role.Name, res.Name)
+				assert.ElementsMatch(t, role.Permissions, res.Permissions)
+			},
+		},
+		{
+			name: "create a role with parents",
+			role: app.RoleDefinition{
+				Name:        "moderator",
+				Parents:     []app.Role{app.UserRole},
+				Permissions: []string{"editPosts"},
+			},
+			test: func(ctx context.Context, t *testing.T, db app.RoleDB, role app.RoleDefinition) {
+				err := db.CreateRole(ctx, role)
+				assert.NoError(t, err)
+
+				res, err := db.GetRoleByName(ctx, role.Name)
+				assert.NoError(t, err)
+				assert.Equal(t, []app.Role{app.UserRole}, res.Parents)
+
+				roles, err := db.GetRoles(ctx)
+				assert.NoError(t, err)
+
+				hierarchy, err := app.NewRoleHierarchy(roles)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"editPosts", app.ViewTest}, hierarchy.EffectivePermissions(role.Name))
+			},
+		},
+		{
+			name: "create a role that exists",
+			role: app.RoleDefinition{
+				Name: app.UserRole,
+			},
+			test: func(ctx context.Context, t *testing.T, db app.RoleDB, role app.RoleDefinition) {
+				err := db.CreateRole(ctx, role)
+				assert.Equal(t, app.ErrRoleAlreadyExists, err)
+			},
+		},
+		{
+			name: "create a role with an unknown parent",
+			role: app.RoleDefinition{
+				Name:    "moderator",
+				Parents: []app.Role{"doesnotexist"},
+			},
+			test: func(ctx context.Context, t *testing.T, db app.RoleDB, role app.RoleDefinition) {
+				err := db.CreateRole(ctx, role)
+				assert.Equal(t, app.ErrRoleParentNotFound, err)
+
+				res, err := db.GetRoleByName(ctx, role.Name)
+				assert.NoError(t, err)
+				assert.True(t, res.IsEmpty())
+			},
+		},
+		{
+			name: "create a role without permissions",
+			role: app.RoleDefinition{
+				Name: "empty",
+			},
+			test: func(ctx context.Context, t *testing.T, db app.RoleDB, role app.RoleDefinition) {
+				err := db.CreateRole(ctx, role)
+				assert.NoError(t, err)
+
+				res, err := db.GetRoleByName(ctx, role.Name)
+				assert.NoError(t, err)
+				assert.Equal(t, role.Name, res.Name)
+				assert.Empty(t, res.Permissions)
+			},
+		},
+		{
+			name: "getbyname when doesnt exist",
+			test: func(ctx context.Context, t *testing.T, db app.RoleDB, role app.RoleDefinition) {
+				res, err := db.GetRoleByName(ctx, "doesnotexist")
+				assert.NoError(t, err)
+				assert.True(t, res.IsEmpty())
+			},
+		},
+		{
+			name: "update replaces permissions",
+			role: app.RoleDefinition{
+				Name:        "moderator",
+				Permissions: []string{"editPosts", "deletePosts"},
+			},
+			test: func(ctx context.Context, t *testing.T, db app.RoleDB, role app.RoleDefinition) {
+				err := db.CreateRole(ctx, role)
+				assert.NoError(t, err)
+
+				role.Parents = []app.Role{app.GuestRole}
+				role.Permissions = []string{"editPosts", "pinPosts"}
+				err = db.UpdateRole(ctx, role)
+				assert.NoError(t, err)
+
+				res, err := db.GetRoleByName(ctx, role.Name)
+				assert.NoError(t, err)
+				assert.Equal(t, []app.Role{app.GuestRole}, res.Parents)
+				assert.ElementsMatch(t, []string{"editPosts", "pinPosts"}, res.Permissions)
+			},
+		},
+		{
+			name: "update that introduces a cycle",
+			role: app.RoleDefinition{
+				Name:    "moderator",
+				Parents: []app.Role{app.UserRole},
+			},
+			test: func(ctx context.Context, t *testing.T, db app.RoleDB, role app.RoleDefinition) {
+				err := db.CreateRole(ctx, role)
+				assert.NoError(t, err)
+
+				user, err := db.GetRoleByName(ctx, app.UserRole)
+				assert.NoError(t, err)
+
+				cyclic := user
+				cyclic.Parents = []app.Role{role.Name}
+				err = db.UpdateRole(ctx, cyclic)
+				assert.Equal(t, app.ErrRoleHierarchyCycle, err)
+
+				res, err := db.GetRoleByName(ctx, app.UserRole)
+				assert.NoError(t, err)
+				assert.Equal(t, user.Parents, res.Parents)
+			},
+		},
+		{
+			name: "update when doesnt exist",
+			role: app.RoleDefinition{
+				Name: "doesnotexist",
+			},
+			test: func(ctx context.Context, t *testing.T, db app.RoleDB, role app.RoleDefinition) {
+				err := db.UpdateRole(ctx, role)
+				assert.Equal(t, app.ErrRoleNotFound, err)
+			},
+		},
+		{
+			name: "delete a role",
+			role: app.RoleDefinition{
+				Name:        "moderator",
+				Permissions: []string{"editPosts"},
+			},
+			test: func(ctx context.Context, t *testing.T, db app.RoleDB, role app.RoleDefinition) {
+				err := db.CreateRole(ctx, role)
+				assert.NoError(t, err)
+
+				err = db.DeleteRole(ctx, role.Name)
+				assert.NoError(t, err)
+
+				res, err := db.GetRoleByName(ctx, role.Name)
+				assert.NoError(t, err)
+				assert.True(t, res.IsEmpty())
+			},
+		},
+		{
+			name: "delete when doesnt exist",
+			test: func(ctx context.Context, t *testing.T, db app.RoleDB, role app.RoleDefinition) {
+				err := db.DeleteRole(ctx, "doesnotexist")
+				assert.Equal(t, app.ErrRoleNotFound, err)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			db, teardown, err := makeRoleDB()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() {
+				err := teardown()
+				assert.NoError(t, err)
+			}()
+
+			test.test(ctx, t, db, test.role)
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/credentials"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/argon2"
+)
+
+func TestUserImport(t *testing.T, makeUserDB MakeUserDB) {
+	salt := []byte("importsaltimport")
+	argon2Hash := fmt.Sprintf("$argon2id$v=%d$m=64,t=1,p=1$%s$%s", argon2.Version,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(argon2.IDKey([]byte("argon2-p@ssw0rd"), salt, 1, 64, 1, 32)))
+
+	tests := []struct {
+		name string
+		test func(ctx context.Context, t *testing.T, db app.UserDB)
+	}{
+		{
+			name: "import csv with per row errors",
+			test: func(ctx context.Context, t *testing.T, db app.UserDB) {
+				createUser(ctx, t, db, "existing")
+
+				csv := strings.Join([]string{
+					"username,password,password_hash,role,display_name",
+					"alice,p@r00l!23x,,developer,Alice",
+					"bobby,,\"" + argon2Hash + "\",,",
+					"existing,p@r00l!23x,,,",
+					"alice,p@r00l!23x,,,",
+					"carol,p@r00l!23x,,nosuchrole,",
+					"dave,p@r00l!23x,$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy,,",
+					"erin,,plaintext,,",
+					"frank,p@r00l!23x",
+				}, "\n")
+
+				report, err := app.ImportUsers(ctx, db, strings.NewReader(csv), app.ImportOptions{Format: app.FormatCSV, BatchSize: 2})
+				assert.NoError(t, err)
+				assert.Equal(t, 8, report.Records)
+				assert.Equal(t, 2, report.Imported)
+
+				rowErrs := map[int]error{}
+				for _, rowErr := range report.Errors {
+					rowErrs[rowErr.Line] = rowErr.Err
+				}
+				assert.Equal(t, map[int]error{
+					4: app.ErrUserAlreadyExists,
+					5: app.ErrUserAlreadyExists,
+					6: app.ErrRoleNotFound,
+					7: app.ErrImportPasswordRequired,
+					8: credentials.ErrPasswordHashUnsupported,
+					9: app.ErrImportRecordInvalid,
+				}, rowErrs)
+
+				alice, err := db.GetUserByUsername(ctx, "alice")
+				assert.NoError(t, err)
+				assert.Equal(t, app.DeveloperRole, alice.Role)
+				assert.Equal(t, "Alice", alice.DisplayName)
+				assert.Equal(t, app.StatusActive, alice.Status)
+				assert.NoError(t, credentials.ComparePassword(alice.Password, "p@r00l!23x"))
+
+				bobby, err := db.GetUserByUsername(ctx, "bobby")
+				assert.NoError(t, err)
+				assert.Equal(t, app.GuestRole, bobby.Role)
+				assert.NoError(t, credentials.ComparePassword(bobby.Password, "argon2-p@ssw0rd"))
+
+				carol, err := db.GetUserByUsername(ctx, "carol")
+				assert.NoError(t, err)
+				assert.True(t, carol.IsEmpty(), "users with unknown roles aren't created")
+			},
+		},
+		{
+			name: "import jsonl dry run",
+			test: func(ctx context.Context, t *testing.T, db app.UserDB) {
+				jsonl := `{"username": "alice", "password": "p@r00l!23x", "status": "suspended"}
+
+{"username": "bobby", "password": "p@r00l!23x", "unknown": true}
+{"username": "carol", "password": "p@r00l!23x", "timezone": "Nowhere/Town"}
+not json
+`
+				report, err := app.ImportUsers(ctx, db, strings.NewReader(jsonl), app.ImportOptions{Format: app.FormatJSONL, DryRun: true})
+				assert.NoError(t, err)
+				assert.Equal(t, 4, report.Records)
+				assert.Equal(t, 1, report.Imported)
+				assert.Equal(t, []app.ImportRowError{
+					{Line: 3, Err: app.ErrImportRecordInvalid},
+					{Line: 4, Username: "carol", Err: app.ErrTimezoneInvalid},
+					{Line: 5, Err: app.ErrImportRecordInvalid},
+				}, report.Errors)
+
+				users, err := db.ListUsers(ctx, app.UserFilter{Limit: 10})
+				assert.NoError(t, err)
+				assert.Empty(t, users, "a dry run doesn't create users")
+			},
+		},
+		{
+			name: "invalid csv header",
+			test: func(ctx context.Context, t *testing.T, db app.UserDB) {
+				_, err := app.ImportUsers(ctx, db, strings.NewReader("username,email\n"), app.ImportOptions{Format: app.FormatCSV})
+				assert.Equal(t, app.ErrImportHeaderInvalid, err)
+
+				_, err = app.ImportUsers(ctx, db, strings.NewReader("password\n"), app.ImportOptions{Format: app.FormatCSV})
+				assert.Equal(t, app.ErrImportHeaderInvalid, err)
+
+				_, err = app.ImportUsers(ctx, db, strings.NewReader(""), app.ImportOptions{Format: "xml"})
+				assert.Equal(t, app.ErrUserFormatInvalid, err)
+			},
+		},
+		{
+			name: "export and import round trip",
+			test: func(ctx context.Context, t *testing.T, db app.UserDB) {
+				jsonl := `{"username": "alice", "password": "p@r00l!23x", "role": "admin", "locale": "et-EE"}
+{"username": "bobby", "password": "p@r00l!23x", "status": "pending"}
+`
+				report, err := app.ImportUsers(ctx, db, strings.NewReader(jsonl), app.ImportOptions{Format: app.FormatJSONL})
+				assert.NoError(t, err)
+				assert.Empty(t, report.Errors)
+
+				originals := map[string]app.User{}
+				for _, username := range []string{"alice", "bobby"} {
+					usr, err := db.GetUserByUsername(ctx, username)
+					assert.NoError(t, err)
+					originals[username] = usr
+				}
+
+				exports := map[app.UserFormat]*bytes.Buffer{}
+				for _, format := range []app.UserFormat{app.FormatCSV, app.FormatJSONL} {
+					exports[format] = &bytes.Buffer{}
+					exported, err := app.ExportUsers(ctx, db, exports[format], format)
+					assert.NoError(t, err)
+					assert.Equal(t, 2, exported)
+				}
+
+				for format, export := range exports {
+					// The target is emptied first, as integration databases
+					// may share their storage with db.
+					target, reset, err := makeUserDB()
+					assert.NoError(t, err)
+					assert.NoError(t, reset())
+
+					report, err := app.ImportUsers(ctx, target, export, app.ImportOptions{Format: format})
+					assert.NoError(t, err)
+					assert.Empty(t, report.Errors)
+					assert.Equal(t, 2, report.Imported)
+
+					for username, original := range originals {
+						imported, err := target.GetUserByUsername(ctx, username)
+						assert.NoError(t, err)
+
+						assert.Equal(t, original.Password, imported.Password, format)
+						assert.Equal(t, original.Role, imported.Role, format)
+						assert.Equal(t, original.Status, imported.Status, format)
+						assert.Equal(t, original.Locale, imported.Locale, format)
+					}
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			db, teardown, err := makeUserDB()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() {
+				assert.NoError(t, teardown())
+			}()
+
+			test.test(ctx, t, db)
+		})
+	}
+}
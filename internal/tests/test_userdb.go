@@ -111,6 +111,324 @@ func TestUserDB(t *testing.T, makeUserDB MakeUserDB) {
 				assert.Empty(t, res)
 			},
 		},
+		{
+			name: "getbyidentifier resolves usernames",
+			users: []app.User{
+				{
+					Username: "user1",
+					Password: "pw",
+					Role:     "guest",
+				},
+			},
+			test: func(ctx context.Context, t *testing.T, db app.UserDB, users ...app.User) {
+				err := db.CreateUser(ctx, users[0])
+				assert.NoError(t, err)
+
+				res, err := db.GetUserByIdentifier(ctx, users[0].Username)
+				assert.NoError(t, err)
+				assert.Equal(t, users[0].Username, res.Username)
+				assert.Equal(t, users[0].Role, res.Role)
+				assert.Equal(t, users[0].Password, res.Password)
+
+				res, err = db.GetUserByIdentifier(ctx, "user1@example.com")
+				assert.NoError(t, err)
+				assert.Empty(t, res)
+			},
+		},
+		{
+			name: "update user role",
+			users: []app.User{
+				{
+					Username: "user1",
+					Password: "pw",
+				},
+			},
+			test: func(ctx context.Context, t *testing.T, db app.UserDB, users ...app.User) {
+				err := db.CreateUser(ctx, users[0])
+				assert.NoError(t, err)
+
+				usr, err := db.GetUserByUsername(ctx, users[0].Username)
+				assert.NoError(t, err)
+				assert.NotEmpty(t, usr.UserID)
+
+				previous, err := db.UpdateUserRole(ctx, usr.UserID, app.DeveloperRole)
+				assert.NoError(t, err)
+				assert.Equal(t, app.GuestRole, previous)
+
+				usr, err = db.GetUserByUsername(ctx, users[0].Username)
+				assert.NoError(t, err)
+				assert.Equal(t, app.DeveloperRole, usr.Role)
+			},
+		},
+		{
+			name: "update role of user that doesnt exist",
+			test: func(ctx context.Context, t *testing.T, db app.UserDB, users ...app.User) {
+				_, err := db.UpdateUserRole(ctx, "11111111-1111-1111-1111-111111111111", app.DeveloperRole)
+				assert.Equal(t, app.ErrUserNotFound, err)
+			},
+		},
+		{
+			name: "update user to role that doesnt exist",
+			users: []app.User{
+				{
+					Username: "user1",
+					Password: "pw",
+				},
+			},
+			test: func(ctx context.Context, t *testing.T, db app.UserDB, users ...app.User) {
+				err := db.CreateUser(ctx, users[0])
+				assert.NoError(t, err)
+
+				usr, err := db.GetUserByUsername(ctx, users[0].Username)
+				assert.NoError(t, err)
+
+				_, err = db.UpdateUserRole(ctx, usr.UserID, "doesnotexist")
+				assert.Equal(t, app.ErrRoleNotFound, err)
+			},
+		},
+		{
+			name: "last administrator cannot be demoted",
+			users: []app.User{
+				{
+					Username: "admin1",
+					Password: "pw",
+				},
+				{
+					Username: "admin2",
+					Password: "pw",
+				},
+			},
+			test: func(ctx context.Context, t *testing.T, db app.UserDB, users ...app.User) {
+				var ids []string
+				for _, u := range users {
+					err := db.CreateUser(ctx, u)
+					assert.NoError(t, err)
+
+					usr, err := db.GetUserByUsername(ctx, u.Username)
+					assert.NoError(t, err)
+
+					_, err = db.UpdateUserRole(ctx, usr.UserID, app.AdminRole)
+					assert.NoError(t, err)
+					ids = append(ids, usr.UserID)
+				}
+
+				previous, err := db.UpdateUserRole(ctx, ids[0], app.UserRole)
+				assert.NoError(t, err)
+				assert.Equal(t, app.AdminRole, previous)
+
+				_, err = db.UpdateUserRole(ctx, ids[1], app.UserRole)
+				assert.Equal(t, app.ErrLastAdministrator, err)
+
+				_, err = db.UpdateUserRole(ctx, ids[1], app.AdminRole)
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "get user by id",
+			users: []app.User{
+				{Username: "user1", Password: "pw"},
+			},
+			test: func(ctx context.Context, t *testing.T, db app.UserDB, users ...app.User) {
+				assert.NoError(t, db.CreateUser(ctx, users[0]))
+
+				usr, err := db.GetUserByUsername(ctx, users[0].Username)
+				assert.NoError(t, err)
+
+				res, err := db.GetUserByID(ctx, usr.UserID)
+				assert.NoError(t, err)
+				assert.Equal(t, usr, res)
+
+				res, err = db.GetUserByID(ctx, "11111111-1111-1111-1111-111111111111")
+				assert.NoError(t, err)
+				assert.True(t, res.IsEmpty())
+			},
+		},
+		{
+			name: "update user",
+			users: []app.User{
+				{Username: "user1", Password: "pw"},
+				{Username: "user2", Password: "pw"},
+			},
+			test: func(ctx context.Context, t *testing.T, db app.UserDB, users ...app.User) {
+				for _, usr := range users {
+					assert.NoError(t, db.CreateUser(ctx, usr))
+				}
+
+				usr, err := db.GetUserByUsername(ctx, "user1")
+				assert.NoError(t, err)
+
+				usr.Username = "renamed"
+				usr.Password = "pw2"
+				usr.DisplayName = "Renamed User"
+				usr.AvatarURL = "https://example.com/avatar.png"
+				usr.Locale = "et-EE"
+				usr.Timezone = "Europe/Tallinn"
+				assert.NoError(t, db.UpdateUser(ctx, usr))
+
+				res, err := db.GetUserByID(ctx, usr.UserID)
+				assert.NoError(t, err)
+				assert.Equal(t, usr, res)
+
+				usr.Username = "user2"
+				assert.Equal(t, app.ErrUserAlreadyExists, db.UpdateUser(ctx, usr))
+
+				err = db.UpdateUser(ctx, app.User{UserID: "11111111-1111-1111-1111-111111111111", Username: "nobody", Password: "pw"})
+				assert.Equal(t, app.ErrUserNotFound, err)
+			},
+		},
+		{
+			name: "delete user",
+			users: []app.User{
+				{Username: "admin1", Password: "pw"},
+				{Username: "admin2", Password: "pw"},
+			},
+			test: func(ctx context.Context, t *testing.T, db app.UserDB, users ...app.User) {
+				var ids []string
+				for _, u := range users {
+					assert.NoError(t, db.CreateUser(ctx, u))
+
+					usr, err := db.GetUserByUsername(ctx, u.Username)
+					assert.NoError(t, err)
+
+					_, err = db.UpdateUserRole(ctx, usr.UserID, app.AdminRole)
+					assert.NoError(t, err)
+					ids = append(ids, usr.UserID)
+				}
+
+				assert.NoError(t, db.DeleteUser(ctx, ids[0]))
+
+				res, err := db.GetUserByID(ctx, ids[0])
+				assert.NoError(t, err)
+				assert.True(t, res.IsEmpty())
+
+				all, err := db.GetUsers(ctx)
+				assert.NoError(t, err)
+				assert.Len(t, all, 1)
+
+				assert.Equal(t, app.ErrUserNotFound, db.DeleteUser(ctx, ids[0]))
+				assert.Equal(t, app.ErrLastAdministrator, db.DeleteUser(ctx, ids[1]))
+			},
+		},
+		{
+			name: "update user status",
+			users: []app.User{
+				{Username: "user1", Password: "pw"},
+			},
+			test: func(ctx context.Context, t *testing.T, db app.UserDB, users ...app.User) {
+				assert.NoError(t, db.CreateUser(ctx, users[0]))
+
+				usr, err := db.GetUserByUsername(ctx, "user1")
+				assert.NoError(t, err)
+				assert.Equal(t, app.StatusActive, usr.Status)
+				assert.False(t, usr.StatusChangedAt.IsZero())
+
+				change := app.StatusChange{
+					From:   app.StatusActive,
+					To:     app.StatusSuspended,
+					Reason: "chargeback",
+					At:     time.Now().UTC().Truncate(time.Microsecond),
+				}
+				assert.NoError(t, db.UpdateUserStatus(ctx, usr.UserID, change))
+
+				res, err := db.GetUserByID(ctx, usr.UserID)
+				assert.NoError(t, err)
+				assert.Equal(t, app.StatusSuspended, res.Status)
+				assert.Equal(t, "chargeback", res.StatusReason)
+				assert.True(t, change.At.Equal(res.StatusChangedAt))
+
+				assert.Equal(t, app.ErrAccountStatusConflict, db.UpdateUserStatus(ctx, usr.UserID, change), "the account is no longer active")
+
+				res.Username = "renamed"
+				assert.NoError(t, db.UpdateUser(ctx, res))
+				res, err = db.GetUserByID(ctx, usr.UserID)
+				assert.NoError(t, err)
+				assert.Equal(t, app.StatusSuspended, res.Status, "UpdateUser doesn't change the status")
+
+				err = db.UpdateUserStatus(ctx, "11111111-1111-1111-1111-111111111111", change)
+				assert.Equal(t, app.ErrUserNotFound, err)
+			},
+		},
+		{
+			name: "list users page by page",
+			users: []app.User{
+				{Username: "carol", Password: "pw"},
+				{Username: "alice", Password: "pw"},
+				{Username: "bob", Password: "pw"},
+				{Username: "alfred", Password: "pw"},
+			},
+			test: func(ctx context.Context, t *testing.T, db app.UserDB, users ...app.User) {
+				for _, usr := range users {
+					assert.NoError(t, db.CreateUser(ctx, usr))
+				}
+
+				page, err := db.ListUsers(ctx, app.UserFilter{Limit: 2})
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"alfred", "alice"}, usernames(page))
+
+				page, err = db.ListUsers(ctx, app.UserFilter{After: "alice", Limit: 2})
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"bob", "carol"}, usernames(page))
+
+				page, err = db.ListUsers(ctx, app.UserFilter{After: "carol", Limit: 2})
+				assert.NoError(t, err)
+				assert.Empty(t, page)
+
+				page, err = db.ListUsers(ctx, app.UserFilter{Descending: true, After: "bob"})
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"alice", "alfred"}, usernames(page))
+
+				page, err = db.ListUsers(ctx, app.UserFilter{Offset: 1, Limit: 2})
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"alice", "bob"}, usernames(page))
+
+				page, err = db.ListUsers(ctx, app.UserFilter{Offset: 3})
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"carol"}, usernames(page))
+
+				count, err := db.CountUsers(ctx, app.UserFilter{})
+				assert.NoError(t, err)
+				assert.Equal(t, 4, count)
+			},
+		},
+		{
+			name: "list users by role and username prefix",
+			users: []app.User{
+				{Username: "al_ice", Password: "pw"},
+				{Username: "alfred", Password: "pw"},
+				{Username: "bob", Password: "pw"},
+			},
+			test: func(ctx context.Context, t *testing.T, db app.UserDB, users ...app.User) {
+				for _, usr := range users {
+					assert.NoError(t, db.CreateUser(ctx, usr))
+				}
+
+				bob, err := db.GetUserByUsername(ctx, "bob")
+				assert.NoError(t, err)
+				_, err = db.UpdateUserRole(ctx, bob.UserID, app.DeveloperRole)
+				assert.NoError(t, err)
+
+				page, err := db.ListUsers(ctx, app.UserFilter{UsernamePrefix: "al"})
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"al_ice", "alfred"}, usernames(page))
+
+				page, err = db.ListUsers(ctx, app.UserFilter{UsernamePrefix: "al_"})
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"al_ice"}, usernames(page), "like wildcards in the prefix are literal")
+
+				page, err = db.ListUsers(ctx, app.UserFilter{Role: app.DeveloperRole})
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"bob"}, usernames(page))
+				assert.Equal(t, app.DeveloperRole, page[0].Role)
+
+				count, err := db.CountUsers(ctx, app.UserFilter{Role: app.DeveloperRole})
+				assert.NoError(t, err)
+				assert.Equal(t, 1, count)
+
+				count, err = db.CountUsers(ctx, app.UserFilter{UsernamePrefix: "al_"})
+				assert.NoError(t, err)
+				assert.Equal(t, 1, count)
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -132,3 +450,12 @@ func TestUserDB(t *testing.T, makeUserDB MakeUserDB) {
 		})
 	}
 }
+
+func usernames(users []app.User) []string {
+	res := []string{}
+	for _, usr := range users {
+		res = append(res, usr.Username)
+	}
+
+	return res
+}
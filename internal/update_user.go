@@ -0,0 +1,61 @@
+package app
+
+import (
+	"context"
+
+	"github.com/rislah/fakes/internal/credentials"
+)
+
+func (u *userImpl) UpdateUser(ctx context.Context, userID string, update UserUpdate) (User, error) {
+	if userID == "" {
+		return User{}, ErrUserNotFound
+	}
+
+	usr, err := u.userDB.GetUserByID(ctx, userID)
+	if err != nil {
+		return User{}, err
+	}
+
+	if usr.IsEmpty() {
+		return User{}, ErrUserNotFound
+	}
+
+	if update.CurrentPassword != nil {
+		if err := credentials.ComparePassword(usr.Password, credentials.Password(*update.CurrentPassword)); err != nil {
+			return User{}, err
+		}
+	}
+
+	if update.Username != nil && *update.Username != usr.Username {
+		username := credentials.NewUsername(*update.Username)
+		if err := username.Validate(); err != nil {
+			return User{}, err
+		}
+		if username.IsReserved() {
+			return User{}, credentials.ErrUsernameReserved
+		}
+
+		usr.Username = username.String()
+	}
+
+	if update.Password != nil {
+		creds := credentials.New(usr.Username, *update.Password)
+		if err := creds.Valid(); err != nil {
+			return User{}, err
+		}
+
+		if _, err := creds.Password.ValidateStrength(usr.Username); err != nil {
+			return User{}, err
+		}
+
+		if usr.Password, err = creds.Password.GenerateBCrypt(); err != nil {
+			return User{}, err
+		}
+	}
+
+	if err := u.userDB.UpdateUser(ctx, usr); err != nil {
+		return User{}, err
+	}
+
+	return usr.Sanitize(), nil
+}
@@ -0,0 +1,17 @@
+package app
+
+import (
+	"context"
+)
+
+func (u *userImpl) UpdateUserRole(ctx context.Context, userID string, role Role) (Role, error) {
+	if userID == "" {
+		return "", ErrUserNotFound
+	}
+
+	if err := (RoleDefinition{Name: role}).Valid(); err != nil {
+		return "", err
+	}
+
+	return u.userDB.UpdateUserRole(ctx, userID, role)
+}
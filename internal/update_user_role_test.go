@@ -0,0 +1,67 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/jwt"
+	"github.com/rislah/fakes/internal/local"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserImpl_UpdateUserRole(t *testing.T) {
+	tests := []struct {
+		name string
+		test func(ctx context.Context, t *testing.T, userBackend app.UserBackend, db app.UserDB)
+	}{
+		{
+			name: "should return error if user id is missing",
+			test: func(ctx context.Context, t *testing.T, userBackend app.UserBackend, db app.UserDB) {
+				_, err := userBackend.UpdateUserRole(ctx, "", app.UserRole)
+				assert.Equal(t, app.ErrUserNotFound, err)
+			},
+		},
+		{
+			name: "should return error if role name is invalid",
+			test: func(ctx context.Context, t *testing.T, userBackend app.UserBackend, db app.UserDB) {
+				_, err := userBackend.UpdateUserRole(ctx, "id", "")
+				assert.Equal(t, app.ErrRoleNameInvalid, err)
+			},
+		},
+		{
+			name: "should update role",
+			test: func(ctx context.Context, t *testing.T, userBackend app.UserBackend, db app.UserDB) {
+				err := db.CreateUser(ctx, app.User{Username: "asd", Password: "asd"})
+				assert.NoError(t, err)
+
+				usr, err := db.GetUserByUsername(ctx, "asd")
+				assert.NoError(t, err)
+
+				previous, err := userBackend.UpdateUserRole(ctx, usr.UserID, app.UserRole)
+				assert.NoError(t, err)
+				assert.Equal(t, app.GuestRole, previous)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			db, teardown, err := local.MakeUserDB()
+			assert.NoError(t, err)
+
+			defer func() {
+				assert.NoError(t, teardown())
+			}()
+
+			userBackend := app.NewUserBackend(db, jwt.NewHS256Wrapper("wrapper"))
+			test.test(ctx, t, userBackend, db)
+		})
+	}
+}
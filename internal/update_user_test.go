@@ -0,0 +1,84 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/credentials"
+	"github.com/rislah/fakes/internal/jwt"
+	"github.com/rislah/fakes/internal/local"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserImpl_UpdateUser(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name string
+		test func(ctx context.Context, t *testing.T, userBackend app.UserBackend, db app.UserDB)
+	}{
+		{
+			name: "should return error if user doesnt exist",
+			test: func(ctx context.Context, t *testing.T, userBackend app.UserBackend, db app.UserDB) {
+				_, err := userBackend.UpdateUser(ctx, "11111111-1111-1111-1111-111111111111", app.UserUpdate{Username: str("someone")})
+				assert.Equal(t, app.ErrUserNotFound, err)
+			},
+		},
+		{
+			name: "should validate username",
+			test: func(ctx context.Context, t *testing.T, userBackend app.UserBackend, db app.UserDB) {
+				assert.NoError(t, db.CreateUser(ctx, app.User{Username: "asdf", Password: "hash"}))
+				usr, err := db.GetUserByUsername(ctx, "asdf")
+				assert.NoError(t, err)
+
+				_, err = userBackend.UpdateUser(ctx, usr.UserID, app.UserUpdate{Username: str("Not Valid")})
+				assert.Equal(t, credentials.ErrUsernameRegexFail, err)
+			},
+		},
+		{
+			name: "should update username and password",
+			test: func(ctx context.Context, t *testing.T, userBackend app.UserBackend, db app.UserDB) {
+				assert.NoError(t, db.CreateUser(ctx, app.User{Username: "asdf", Password: "hash"}))
+				usr, err := db.GetUserByUsername(ctx, "asdf")
+				assert.NoError(t, err)
+
+				res, err := userBackend.UpdateUser(ctx, usr.UserID, app.UserUpdate{
+					Username: str("renamed"),
+					Password: str("c0rrect-h0rse-battery"),
+				})
+				assert.NoError(t, err)
+				assert.Equal(t, "renamed", res.Username)
+				assert.Empty(t, res.Password)
+
+				stored, err := db.GetUserByID(ctx, usr.UserID)
+				assert.NoError(t, err)
+				assert.Equal(t, "renamed", stored.Username)
+
+				ok, err := credentials.NewPassword("c0rrect-h0rse-battery").CompareBCrypt(stored.Password)
+				assert.NoError(t, err)
+				assert.True(t, ok)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			db, teardown, err := local.MakeUserDB()
+			assert.NoError(t, err)
+
+			defer func() {
+				assert.NoError(t, teardown())
+			}()
+
+			userBackend := app.NewUserBackend(db, jwt.NewHS256Wrapper("wrapper"))
+			test.test(ctx, t, userBackend, db)
+		})
+	}
+}
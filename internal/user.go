@@ -2,6 +2,8 @@ package app
 
 import (
 	"context"
+	"net/http"
+	"time"
 
 	"github.com/rislah/fakes/internal/credentials"
 
@@ -14,12 +16,50 @@ const JWTSecret = "secret"
 type UserBackend interface {
 	CreateUser(ctx context.Context, creds credentials.Credentials) error
 	GetUsers(ctx context.Context) ([]User, error)
+	// ListUsers returns a page of users and the cursor of the next one.
+	ListUsers(ctx context.Context, query UserQuery) (UserPage, error)
+	// ListUsersAt returns up to limit users ordered by username, starting
+	// at offset, and the number of users there are in total.
+	ListUsersAt(ctx context.Context, offset, limit int) ([]User, int, error)
+	GetUser(ctx context.Context, userID string) (User, error)
+	GetUserByUsername(ctx context.Context, username string) (User, error)
+	UpdateUser(ctx context.Context, userID string, update UserUpdate) (User, error)
+	UpdateProfile(ctx context.Context, userID string, update ProfileUpdate) (User, error)
+	// ChangeUserStatus moves the account to status if its current status
+	// allows it. A reason is required unless the account is activated.
+	ChangeUserStatus(ctx context.Context, userID string, status AccountStatus, reason string) (User, error)
+	UpdateUserRole(ctx context.Context, userID string, role Role) (Role, error)
+	DeleteUser(ctx context.Context, userID string) error
 }
 
 type UserDB interface {
 	CreateUser(ctx context.Context, user User) error
 	GetUsers(ctx context.Context) ([]User, error)
+	// ListUsers returns up to filter.Limit users matching filter, ordered
+	// by username.
+	ListUsers(ctx context.Context, filter UserFilter) ([]User, error)
+	// CountUsers returns the number of users with filter's role and
+	// username prefix.
+	CountUsers(ctx context.Context, filter UserFilter) (int, error)
+	// GetUserByID returns an empty User when it doesn't exist.
+	GetUserByID(ctx context.Context, userID string) (User, error)
 	GetUserByUsername(ctx context.Context, username string) (User, error)
+	// GetUserByIdentifier resolves a username, or an email or phone number
+	// verified for the user, to the user. Identifier must be normalized by
+	// ParseIdentifier. It returns an empty User when none matches.
+	GetUserByIdentifier(ctx context.Context, identifier string) (User, error)
+	// UpdateUser replaces the username, password hash and profile of the
+	// user.
+	UpdateUser(ctx context.Context, user User) error
+	// UpdateUserRole assigns role to the user and returns the role it
+	// replaced. It refuses to demote the last remaining administrator.
+	UpdateUserRole(ctx context.Context, userID string, role Role) (Role, error)
+	// UpdateUserStatus applies change, failing with ErrAccountStatusConflict
+	// when the account no longer has status change.From.
+	UpdateUserStatus(ctx context.Context, userID string, change StatusChange) error
+	// DeleteUser removes the user along with its memberships. Like
+	// UpdateUserRole, it refuses to remove the last administrator.
+	DeleteUser(ctx context.Context, userID string) error
 }
 
 type User struct {
@@ -27,6 +67,39 @@ type User struct {
 	Username string `db:"username"`
 	Password string `db:"password_hash"`
 	Role     Role   `db:"role"`
+
+	// Profile attributes are empty when unset.
+	DisplayName string `db:"display_name"`
+	AvatarURL   string `db:"avatar_url"`
+	Locale      string `db:"locale"`
+	Timezone    string `db:"timezone"`
+
+	Status          AccountStatus `db:"status"`
+	StatusReason    string        `db:"status_reason"`
+	StatusChangedAt time.Time     `db:"status_changed_at"`
+}
+
+// UserUpdate changes the fields that are set.
+type UserUpdate struct {
+	Username *string
+	Password *string
+	// CurrentPassword, when set, must match the user's password for the
+	// update to apply.
+	CurrentPassword *string
+}
+
+// UserFilter selects users for UserDB.ListUsers. Like GetUsers, it is
+// scoped to the organization in ctx, if any, and matches Role against the
+// organization role.
+type UserFilter struct {
+	Role           Role
+	UsernamePrefix string
+	// After skips usernames up to and including it in the sort order.
+	After      string
+	Descending bool
+	Limit      int
+	// Offset skips that many users after After.
+	Offset int
 }
 
 func (u User) IsEmpty() bool {
@@ -67,4 +140,20 @@ var (
 		Code: errors.ErrConflict,
 		Msg:  "User already exists",
 	}
+	ErrInvalidCursor = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Invalid cursor",
+	}
+	ErrInvalidPageSize = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Limit must be between 1 and 100",
+	}
+	ErrInvalidUserSort = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Sort must be either username or -username",
+	}
+	ErrLastAdministrator = &errors.WrappedError{
+		Code: errors.ErrConflict,
+		Msg:  "Cannot demote the last administrator",
+	}
 )
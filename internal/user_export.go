@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+)
+
+// exportPageSize is how many users ExportUsers loads at a time.
+const exportPageSize = 500
+
+// ExportUsers writes every user in db to w, ordered by username, and returns
+// how many were written. Users are read a page at a time, so the export
+// doesn't hold all of them in memory. Password hashes are exported as they
+// are stored, so that ImportUsers can restore them.
+func ExportUsers(ctx context.Context, db UserDB, w io.Writer, format UserFormat) (int, error) {
+	var write func(UserRecord) error
+	var flush func() error
+
+	switch format {
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(userRecordColumns); err != nil {
+			return 0, err
+		}
+
+		write = func(record UserRecord) error {
+			return writer.Write(record.columns())
+		}
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	case FormatJSONL:
+		encoder := json.NewEncoder(w)
+		write = func(record UserRecord) error {
+			return encoder.Encode(record)
+		}
+		flush = func() error {
+			return nil
+		}
+	default:
+		return 0, ErrUserFormatInvalid
+	}
+
+	var exported int
+	filter := UserFilter{Limit: exportPageSize}
+	for {
+		users, err := db.ListUsers(ctx, filter)
+		if err != nil {
+			return exported, err
+		}
+
+		for _, usr := range users {
+			if err := write(recordFromUser(usr)); err != nil {
+				return exported, err
+			}
+			exported++
+		}
+
+		if len(users) < exportPageSize {
+			break
+		}
+		filter.After = users[len(users)-1].Username
+	}
+
+	return exported, flush()
+}
+
+func recordFromUser(usr User) UserRecord {
+	return UserRecord{
+		UserID:       usr.UserID,
+		Username:     usr.Username,
+		PasswordHash: usr.Password,
+		Role:         usr.Role,
+		Status:       usr.Status,
+		DisplayName:  usr.DisplayName,
+		AvatarURL:    usr.AvatarURL,
+		Locale:       usr.Locale,
+		Timezone:     usr.Timezone,
+	}
+}
@@ -0,0 +1,467 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/rislah/fakes/internal/credentials"
+	"github.com/rislah/fakes/internal/errors"
+)
+
+const DefaultImportBatchSize = 500
+
+// maxJSONLRecordSize bounds a single JSONL line.
+const maxJSONLRecordSize = 1 << 20
+
+// UserFormat is a file format users are imported from and exported to.
+type UserFormat string
+
+const (
+	FormatCSV   UserFormat = "csv"
+	FormatJSONL UserFormat = "jsonl"
+)
+
+func (f UserFormat) Valid() bool {
+	return f == FormatCSV || f == FormatJSONL
+}
+
+// UserRecord is a user as it appears in an import or export file. Exactly
+// one of Password, which is hashed with bcrypt, and PasswordHash, an existing
+// bcrypt or argon2 hash, is required on import. UserID is exported for
+// reference and ignored on import.
+type UserRecord struct {
+	UserID       string        `json:"user_id,omitempty"`
+	Username     string        `json:"username"`
+	Password     string        `json:"password,omitempty"`
+	PasswordHash string        `json:"password_hash,omitempty"`
+	Role         Role          `json:"role,omitempty"`
+	Status       AccountStatus `json:"status,omitempty"`
+	DisplayName  string        `json:"display_name,omitempty"`
+	AvatarURL    string        `json:"avatar_url,omitempty"`
+	Locale       string        `json:"locale,omitempty"`
+	Timezone     string        `json:"timezone,omitempty"`
+}
+
+// userRecordColumns are the CSV columns, in the order they are exported.
+var userRecordColumns = []string{"user_id", "username", "password", "password_hash", "role", "status", "display_name", "avatar_url", "locale", "timezone"}
+
+func (r UserRecord) columns() []string {
+	return []string{r.UserID, r.Username, r.Password, r.PasswordHash, r.Role.String(), r.Status.String(), r.DisplayName, r.AvatarURL, r.Locale, r.Timezone}
+}
+
+func (r *UserRecord) setColumn(column string, value string) {
+	switch column {
+	case "user_id":
+		r.UserID = value
+	case "username":
+		r.Username = value
+	case "password":
+		r.Password = value
+	case "password_hash":
+		r.PasswordHash = value
+	case "role":
+		r.Role = Role(value)
+	case "status":
+		r.Status = AccountStatus(value)
+	case "display_name":
+		r.DisplayName = value
+	case "avatar_url":
+		r.AvatarURL = value
+	case "locale":
+		r.Locale = value
+	case "timezone":
+		r.Timezone = value
+	}
+}
+
+// UserBatchCreator is implemented by UserDBs that can create many users at
+// once. ImportUsers uses it when it's available.
+type UserBatchCreator interface {
+	// CreateUsers creates the users that can be created and returns the
+	// error of each user, nil for those that were created. Unlike
+	// CreateUser, it assigns the role of each user.
+	CreateUsers(ctx context.Context, users []User) ([]error, error)
+}
+
+type ImportOptions struct {
+	Format UserFormat
+	// DryRun validates the file and checks for existing users without
+	// creating any.
+	DryRun    bool
+	BatchSize int
+}
+
+// ImportRowError is why the record on Line wasn't imported.
+type ImportRowError struct {
+	Line     int
+	Username string
+	Err      error
+}
+
+func (e ImportRowError) Error() string {
+	msg := e.Err.Error()
+	if wrapped, ok := errors.Unwrap(e.Err).(*errors.WrappedError); ok {
+		msg = wrapped.Msg
+	}
+
+	if e.Username == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, msg)
+	}
+
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Username, msg)
+}
+
+// ImportReport counts the records read and imported, or that would have been
+// imported in a dry run, and lists the ones that weren't.
+type ImportReport struct {
+	Records  int
+	Imported int
+	Errors   []ImportRowError
+}
+
+var (
+	ErrUserFormatInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Format must be either csv or jsonl",
+	}
+	ErrImportPasswordRequired = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Exactly one of password or password_hash is required",
+	}
+	ErrImportHeaderInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "CSV header must name known columns, including username",
+	}
+	ErrImportRecordInvalid = &errors.WrappedError{
+		Code: http.StatusBadRequest,
+		Msg:  "Record is malformed",
+	}
+)
+
+// importRow is a record that passed validation, waiting to be created.
+type importRow struct {
+	line int
+	user User
+}
+
+// ImportUsers streams records from r into db, batching the writes when db is
+// a UserBatchCreator. Records that fail validation or can't be created are
+// reported without stopping the import. The returned error is set only when
+// the import couldn't continue; the report then covers the records handled
+// until then.
+func ImportUsers(ctx context.Context, db UserDB, r io.Reader, opts ImportOptions) (ImportReport, error) {
+	var report ImportReport
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultImportBatchSize
+	}
+
+	records, err := newUserRecordReader(r, opts.Format)
+	if err != nil {
+		return report, err
+	}
+
+	seen := map[string]struct{}{}
+	batch := make([]importRow, 0, opts.BatchSize)
+	for {
+		record, line, err := records.read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			if err != ErrImportRecordInvalid {
+				return report, err
+			}
+			report.Records++
+			report.Errors = append(report.Errors, ImportRowError{Line: line, Err: err})
+			continue
+		}
+		report.Records++
+
+		usr, err := userFromRecord(record)
+		if err == nil {
+			if _, ok := seen[usr.Username]; ok {
+				err = ErrUserAlreadyExists
+			}
+		}
+
+		if err != nil {
+			report.Errors = append(report.Errors, ImportRowError{Line: line, Username: record.Username, Err: err})
+			continue
+		}
+		seen[usr.Username] = struct{}{}
+
+		batch = append(batch, importRow{line: line, user: usr})
+		if len(batch) == opts.BatchSize {
+			if err := importBatch(ctx, db, batch, opts.DryRun, &report); err != nil {
+				return report, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := importBatch(ctx, db, batch, opts.DryRun, &report); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+func importBatch(ctx context.Context, db UserDB, batch []importRow, dryRun bool, report *ImportReport) error {
+	rowErrs := make([]error, len(batch))
+
+	batcher, ok := db.(UserBatchCreator)
+	switch {
+	case dryRun:
+		for i, row := range batch {
+			existing, err := db.GetUserByUsername(ctx, row.user.Username)
+			if err != nil {
+				return err
+			}
+			if !existing.IsEmpty() {
+				rowErrs[i] = ErrUserAlreadyExists
+			}
+		}
+	case ok:
+		users := make([]User, len(batch))
+		for i, row := range batch {
+			users[i] = row.user
+		}
+
+		var err error
+		if rowErrs, err = batcher.CreateUsers(ctx, users); err != nil {
+			return err
+		}
+	default:
+		for i, row := range batch {
+			err := createImportedUser(ctx, db, row.user)
+			if _, ok := errors.IsWrappedError(ctx, err); err != nil && !ok {
+				return err
+			}
+			rowErrs[i] = err
+		}
+	}
+
+	for i, row := range batch {
+		if rowErrs[i] != nil {
+			report.Errors = append(report.Errors, ImportRowError{Line: row.line, Username: row.user.Username, Err: rowErrs[i]})
+			continue
+		}
+		report.Imported++
+	}
+
+	return nil
+}
+
+// createImportedUser creates usr with CreateUser, which leaves new users with
+// the default role, and then assigns its role. A user whose role doesn't
+// exist is removed again.
+func createImportedUser(ctx context.Context, db UserDB, usr User) error {
+	existing, err := db.GetUserByUsername(ctx, usr.Username)
+	if err != nil {
+		return err
+	}
+
+	if !existing.IsEmpty() {
+		return ErrUserAlreadyExists
+	}
+
+	role := usr.Role
+	usr.Role = ""
+	if err := db.CreateUser(ctx, usr); err != nil {
+		return err
+	}
+
+	if role == "" || role == GuestRole {
+		return nil
+	}
+
+	created, err := db.GetUserByUsername(ctx, usr.Username)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.UpdateUserRole(ctx, created.UserID, role); err != nil {
+		if err == ErrRoleNotFound {
+			if err := db.DeleteUser(ctx, created.UserID); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+func userFromRecord(record UserRecord) (User, error) {
+	username := credentials.NewUsername(record.Username)
+	if err := username.ValidateLength(); err != nil {
+		return User{}, err
+	}
+
+	if err := username.ValidateRegex(); err != nil {
+		return User{}, err
+	}
+
+	if (record.Password == "") == (record.PasswordHash == "") {
+		return User{}, ErrImportPasswordRequired
+	}
+
+	hash := record.PasswordHash
+	if record.Password != "" {
+		password := credentials.NewPassword(record.Password)
+		if err := password.ValidateLength(); err != nil {
+			return User{}, err
+		}
+
+		var err error
+		if hash, err = password.GenerateBCrypt(); err != nil {
+			return User{}, err
+		}
+	} else if err := credentials.ValidateHash(hash); err != nil {
+		return User{}, err
+	}
+
+	if record.Status == "" {
+		record.Status = StatusActive
+	}
+	if !record.Status.Valid() {
+		return User{}, ErrAccountStatusInvalid
+	}
+
+	usr := User{
+		Username:    username.String(),
+		Password:    hash,
+		Role:        record.Role,
+		DisplayName: record.DisplayName,
+		AvatarURL:   record.AvatarURL,
+		Locale:      record.Locale,
+		Timezone:    record.Timezone,
+		Status:      record.Status,
+	}
+
+	if err := ValidateProfile(usr); err != nil {
+		return User{}, err
+	}
+
+	return usr, nil
+}
+
+type userRecordReader interface {
+	// read returns the next record and the line it starts on. It returns
+	// ErrImportRecordInvalid for records that can be skipped.
+	read() (UserRecord, int, error)
+}
+
+func newUserRecordReader(r io.Reader, format UserFormat) (userRecordReader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVRecordReader(r)
+	case FormatJSONL:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxJSONLRecordSize)
+		return &jsonlRecordReader{scanner: scanner}, nil
+	default:
+		return nil, ErrUserFormatInvalid
+	}
+}
+
+type csvRecordReader struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+func newCSVRecordReader(r io.Reader) (*csvRecordReader, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, ErrImportHeaderInvalid
+		}
+		return nil, err
+	}
+
+	known := map[string]bool{}
+	for _, column := range userRecordColumns {
+		known[column] = true
+	}
+
+	columns := make([]string, len(header))
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+		if !known[column] {
+			return nil, ErrImportHeaderInvalid
+		}
+		known[column] = false
+		columns[i] = column
+	}
+
+	if known["username"] {
+		return nil, ErrImportHeaderInvalid
+	}
+
+	return &csvRecordReader{reader: reader, columns: columns}, nil
+}
+
+func (c *csvRecordReader) read() (UserRecord, int, error) {
+	fields, err := c.reader.Read()
+	if err != nil {
+		if parseErr, ok := err.(*csv.ParseError); ok {
+			return UserRecord{}, parseErr.StartLine, ErrImportRecordInvalid
+		}
+		return UserRecord{}, 0, err
+	}
+
+	line, _ := c.reader.FieldPos(0)
+
+	var record UserRecord
+	for i, value := range fields {
+		record.setColumn(c.columns[i], value)
+	}
+
+	return record, line, nil
+}
+
+type jsonlRecordReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (j *jsonlRecordReader) read() (UserRecord, int, error) {
+	for j.scanner.Scan() {
+		j.line++
+
+		b := bytes.TrimSpace(j.scanner.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		decoder.DisallowUnknownFields()
+
+		var record UserRecord
+		if err := decoder.Decode(&record); err != nil || decoder.More() {
+			return UserRecord{}, j.line, ErrImportRecordInvalid
+		}
+
+		return record, j.line, nil
+	}
+
+	if err := j.scanner.Err(); err != nil {
+		return UserRecord{}, j.line + 1, err
+	}
+
+	return UserRecord{}, 0, io.EOF
+}
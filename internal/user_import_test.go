@@ -0,0 +1,12 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/tests"
+)
+
+func TestLocalUserImport(t *testing.T) {
+	tests.TestUserImport(t, local.MakeUserDB)
+}
@@ -1,12 +1,125 @@
 package app_test
 
 import (
+	"context"
+	"fmt"
+	"sync"
 	"testing"
 
+	app "github.com/rislah/fakes/internal"
 	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/sqlite"
 	"github.com/rislah/fakes/internal/tests"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestLocalUserDB(t *testing.T) {
 	tests.TestUserDB(t, local.MakeUserDB)
 }
+
+func TestSQLiteUserDB(t *testing.T) {
+	tests.TestUserDB(t, sqlite.MakeUserDB)
+}
+
+// TestLocalUserDBConcurrentAccess is meant to be run with -race: the local
+// store is shared by the user, organization and group databases, and
+// handlers use it from many goroutines at once.
+func TestLocalUserDBConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	db := local.NewUserDB()
+
+	org, err := db.CreateOrganization(ctx, app.Organization{Name: "org"})
+	assert.NoError(t, err)
+	group, err := db.CreateGroup(ctx, app.Group{Name: "group"})
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			username := fmt.Sprintf("user%d", i)
+			assert.NoError(t, db.CreateUser(ctx, app.User{Username: username, Password: "pw"}))
+			assert.Equal(t, app.ErrUserAlreadyExists, db.CreateUser(ctx, app.User{Username: username, Password: "pw"}))
+
+			usr, err := db.GetUserByUsername(ctx, username)
+			assert.NoError(t, err)
+			assert.Equal(t, app.GuestRole, usr.Role)
+
+			assert.NoError(t, db.AddMember(ctx, app.Membership{OrgID: org.OrgID, UserID: usr.UserID, Role: app.GuestRole}))
+			assert.NoError(t, db.AddGroupMember(ctx, group.GroupID, usr.UserID))
+
+			_, err = db.UpdateUserRole(ctx, usr.UserID, app.DeveloperRole)
+			assert.NoError(t, err)
+
+			_, err = db.ListUsers(app.WithOrganization(ctx, org.OrgID), app.UserFilter{})
+			assert.NoError(t, err)
+			_, err = db.GetGroupMembers(ctx, group.GroupID)
+			assert.NoError(t, err)
+
+			if i%2 == 0 {
+				assert.NoError(t, db.DeleteUser(ctx, usr.UserID))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	users, err := db.GetUsers(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, users, 8)
+	for _, usr := range users {
+		assert.Equal(t, app.DeveloperRole, usr.Role)
+	}
+
+	members, err := db.GetGroupMembers(ctx, group.GroupID)
+	assert.NoError(t, err)
+	assert.Len(t, members, 8)
+}
+
+func TestLocalUserDBConcurrentRegistrations(t *testing.T) {
+	ctx := context.Background()
+	db := local.NewUserDB()
+
+	errs := make(chan error, 8)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- db.CreateUser(ctx, app.User{Username: "user", Password: "pw"})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		if err == nil {
+			created++
+		} else {
+			assert.Equal(t, app.ErrUserAlreadyExists, err)
+		}
+	}
+	assert.Equal(t, 1, created)
+}
+
+func TestLocalUserDBRolesFromRoleDB(t *testing.T) {
+	ctx := context.Background()
+	db := local.NewUserDB()
+
+	assert.NoError(t, db.CreateUser(ctx, app.User{Username: "user", Password: "pw"}))
+	usr, err := db.GetUserByUsername(ctx, "user")
+	assert.NoError(t, err)
+
+	_, err = db.UpdateUserRole(ctx, usr.UserID, "support")
+	assert.Equal(t, app.ErrRoleNotFound, err)
+
+	assert.NoError(t, db.CreateRole(ctx, app.RoleDefinition{Name: "support", Parents: []app.Role{app.GuestRole}}))
+	_, err = db.UpdateUserRole(ctx, usr.UserID, "support")
+	assert.NoError(t, err, "roles created in the role database can be assigned")
+
+	assert.NoError(t, db.DeleteRole(ctx, app.DeveloperRole))
+	_, err = db.UpdateUserRole(ctx, usr.UserID, app.DeveloperRole)
+	assert.Equal(t, app.ErrRoleNotFound, err, "deleted roles can't be assigned")
+}
@@ -0,0 +1,153 @@
+package app
+
+import (
+	"context"
+
+	"github.com/rislah/fakes/internal/credentials"
+)
+
+// UsernameBackend tells whether a username can be registered without
+// registering it.
+type UsernameBackend interface {
+	// CheckAvailability fails with the validation error of an invalid
+	// username, and otherwise reports whether it is reserved or taken.
+	CheckAvailability(ctx context.Context, username string) (UsernameAvailability, error)
+}
+
+type UnavailableReason string
+
+const (
+	UsernameTaken    UnavailableReason = "taken"
+	UsernameReserved UnavailableReason = "reserved"
+)
+
+type UsernameAvailability struct {
+	Username  string
+	Available bool
+	// Reason is empty when the username is available.
+	Reason UnavailableReason
+}
+
+// UsernameFilter is a probabilistic set of the usernames in use, consulted
+// before the database. It may report usernames that aren't in use, but
+// never misses one that is, unless an Add failed.
+type UsernameFilter interface {
+	// MightExist returns false when the username is certainly not in use.
+	MightExist(ctx context.Context, username string) (bool, error)
+	Add(ctx context.Context, username string) error
+}
+
+type usernameImpl struct {
+	userDB UserDB
+	filter UsernameFilter
+}
+
+// NewUsernameBackend checks availability against userDB, skipping it when
+// filter rules a username out. filter is optional.
+func NewUsernameBackend(userDB UserDB, filter UsernameFilter) UsernameBackend {
+	if userDB == nil {
+		panic("database is required")
+	}
+
+	return &usernameImpl{userDB: userDB, filter: filter}
+}
+
+func (u *usernameImpl) CheckAvailability(ctx context.Context, username string) (UsernameAvailability, error) {
+	name := credentials.NewUsername(username)
+	if err := name.Validate(); err != nil {
+		return UsernameAvailability{}, err
+	}
+
+	res := UsernameAvailability{Username: username}
+	if name.IsReserved() {
+		res.Reason = UsernameReserved
+		return res, nil
+	}
+
+	if u.filter != nil {
+		mightExist, err := u.filter.MightExist(ctx, username)
+		if err != nil {
+			return UsernameAvailability{}, err
+		}
+
+		if !mightExist {
+			res.Available = true
+			return res, nil
+		}
+	}
+
+	usr, err := u.userDB.GetUserByUsername(ctx, username)
+	if err != nil {
+		return UsernameAvailability{}, err
+	}
+
+	if !usr.IsEmpty() {
+		res.Reason = UsernameTaken
+		return res, nil
+	}
+
+	res.Available = true
+	return res, nil
+}
+
+type filteredUserDB struct {
+	UserDB
+	filter UsernameFilter
+}
+
+// filteredBatchUserDB is a filteredUserDB that keeps the batch creation of
+// the database it wraps.
+type filteredBatchUserDB struct {
+	*filteredUserDB
+	batcher UserBatchCreator
+}
+
+// NewFilteredUserDB adds the usernames of created and renamed users to
+// filter. A failed Add only makes the filter report a taken username as
+// available, which registration still rejects, so it doesn't fail the write.
+// The result is a UserBatchCreator when db is.
+func NewFilteredUserDB(db UserDB, filter UsernameFilter) UserDB {
+	if filter == nil {
+		return db
+	}
+
+	filtered := &filteredUserDB{UserDB: db, filter: filter}
+	if batcher, ok := db.(UserBatchCreator); ok {
+		return &filteredBatchUserDB{filteredUserDB: filtered, batcher: batcher}
+	}
+
+	return filtered
+}
+
+func (f *filteredUserDB) CreateUser(ctx context.Context, user User) error {
+	if err := f.UserDB.CreateUser(ctx, user); err != nil {
+		return err
+	}
+
+	_ = f.filter.Add(ctx, user.Username)
+	return nil
+}
+
+func (f *filteredUserDB) UpdateUser(ctx context.Context, user User) error {
+	if err := f.UserDB.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+
+	_ = f.filter.Add(ctx, user.Username)
+	return nil
+}
+
+func (f *filteredBatchUserDB) CreateUsers(ctx context.Context, users []User) ([]error, error) {
+	userErrs, err := f.batcher.CreateUsers(ctx, users)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, user := range users {
+		if userErrs[i] == nil {
+			_ = f.filter.Add(ctx, user.Username)
+		}
+	}
+
+	return userErrs, nil
+}
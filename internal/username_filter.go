@@ -0,0 +1,228 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/rislah/fakes/internal/errors"
+	"github.com/rislah/fakes/internal/redis"
+)
+
+const (
+	usernameFilterKey         = "usernames:bloom"
+	usernameFilterBuildingKey = "usernames:bloom:building"
+	usernameFilterRebuildKey  = "usernames:bloom:rebuild"
+
+	// rebuildLockTTL is how long a rebuild holds the rebuild lock without
+	// making progress before another instance may take over.
+	rebuildLockTTL = time.Minute
+
+	// rebuildPageSize is the number of users added to the filter at a time
+	// while rebuilding it.
+	rebuildPageSize = 500
+)
+
+// usernameFilterMightExistScript answers 1 until the filter has been built,
+// so that a missing or partial filter never rules a username out.
+const usernameFilterMightExistScript = `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 1
+end
+for _, offset in ipairs(ARGV) do
+	if redis.call('GETBIT', KEYS[1], offset) == 0 then
+		return 0
+	end
+end
+return 1`
+
+// usernameFilterAddScript sets the bits in the filters that exist, the live
+// one and the one being rebuilt, if any.
+const usernameFilterAddScript = `
+for _, key in ipairs(KEYS) do
+	if redis.call('EXISTS', key) == 1 then
+		for _, offset in ipairs(ARGV) do
+			redis.call('SETBIT', key, offset, 1)
+		end
+	end
+end
+return 0`
+
+// usernameFilterStartRebuildScript takes the rebuild lock and starts an
+// empty filter, unless another rebuild holds the lock.
+const usernameFilterStartRebuildScript = `
+if not redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return 0
+end
+redis.call('DEL', KEYS[2])
+redis.call('SETBIT', KEYS[2], 0, 0)
+return 1`
+
+// usernameFilterExtendRebuildScript extends the rebuild lock, unless it was
+// lost.
+const usernameFilterExtendRebuildScript = `
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1`
+
+// usernameFilterFinishRebuildScript replaces the live filter and releases
+// the rebuild lock, unless it was lost.
+const usernameFilterFinishRebuildScript = `
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call('RENAME', KEYS[2], KEYS[3])
+redis.call('DEL', KEYS[1])
+return 1`
+
+// errUsernameFilterRebuildLost is returned by a rebuild whose lock expired,
+// as another rebuild may have replaced its filter.
+var errUsernameFilterRebuildLost = errors.New("username filter rebuild lock lost")
+
+// RedisUsernameFilter is a Bloom filter kept in a Redis bitmap, so that it
+// is shared by every instance.
+type RedisUsernameFilter struct {
+	client redis.Client
+	bits   uint64
+	hashes int
+}
+
+// NewRedisUsernameFilter sizes the filter to report falsePositiveRate of
+// the usernames not in use as possibly in use while it holds expected
+// usernames.
+func NewRedisUsernameFilter(client redis.Client, expected int, falsePositiveRate float64) *RedisUsernameFilter {
+	if client == nil {
+		panic("redis client is required")
+	}
+
+	if expected < 1 || falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		panic("expected must be positive and falsePositiveRate between 0 and 1")
+	}
+
+	bits := math.Ceil(-float64(expected) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	// Redis bitmaps hold at most 2^32 bits.
+	bits = math.Min(bits, math.MaxUint32)
+	hashes := int(math.Max(1, math.Round(bits/float64(expected)*math.Ln2)))
+
+	return &RedisUsernameFilter{client: client, bits: uint64(bits), hashes: hashes}
+}
+
+func (r *RedisUsernameFilter) MightExist(ctx context.Context, username string) (bool, error) {
+	res, err := r.client.Eval(usernameFilterMightExistScript, []string{usernameFilterKey}, r.offsets(username))
+	if err != nil {
+		return false, errors.New(err)
+	}
+
+	mightExist, _ := res.(int64)
+	return mightExist == 1, nil
+}
+
+func (r *RedisUsernameFilter) Add(ctx context.Context, username string) error {
+	return r.add(ctx, []string{username})
+}
+
+func (r *RedisUsernameFilter) add(ctx context.Context, usernames []string) error {
+	var offsets []string
+	for _, username := range usernames {
+		offsets = append(offsets, r.offsets(username)...)
+	}
+
+	if _, err := r.client.Eval(usernameFilterAddScript, []string{usernameFilterKey, usernameFilterBuildingKey}, offsets); err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+// Rebuild fills a new filter with the usernames in db and replaces the live
+// filter with it. Usernames added meanwhile go to both filters. Only one
+// instance rebuilds at a time; Rebuild returns without doing anything while
+// another one is rebuilding.
+func (r *RedisUsernameFilter) Rebuild(ctx context.Context, db UserDB) error {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(b)
+	ttl := strconv.FormatInt(rebuildLockTTL.Milliseconds(), 10)
+
+	started, err := r.evalRebuild(usernameFilterStartRebuildScript, []string{usernameFilterRebuildKey, usernameFilterBuildingKey}, token, ttl)
+	if err != nil || !started {
+		return err
+	}
+
+	filter := UserFilter{Limit: rebuildPageSize}
+	for {
+		users, err := db.ListUsers(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		held, err := r.evalRebuild(usernameFilterExtendRebuildScript, []string{usernameFilterRebuildKey}, token, ttl)
+		if err != nil {
+			return err
+		}
+		if !held {
+			return errUsernameFilterRebuildLost
+		}
+
+		usernames := make([]string, 0, len(users))
+		for _, usr := range users {
+			usernames = append(usernames, usr.Username)
+		}
+
+		if len(usernames) > 0 {
+			if err := r.add(ctx, usernames); err != nil {
+				return err
+			}
+		}
+
+		if len(users) < filter.Limit {
+			break
+		}
+		filter.After = users[len(users)-1].Username
+	}
+
+	finished, err := r.evalRebuild(usernameFilterFinishRebuildScript, []string{usernameFilterRebuildKey, usernameFilterBuildingKey, usernameFilterKey}, token)
+	if err != nil {
+		return err
+	}
+	if !finished {
+		return errUsernameFilterRebuildLost
+	}
+
+	return nil
+}
+
+// evalRebuild runs a rebuild script, which returns 1 when the rebuild holds
+// the lock.
+func (r *RedisUsernameFilter) evalRebuild(script string, keys []string, args ...string) (bool, error) {
+	res, err := r.client.Eval(script, keys, args)
+	if err != nil {
+		return false, errors.New(err)
+	}
+
+	held, _ := res.(int64)
+	return held == 1, nil
+}
+
+// offsets derives the bits of username by double hashing the two halves of
+// its SHA-256.
+func (r *RedisUsernameFilter) offsets(username string) []string {
+	sum := sha256.Sum256([]byte(username))
+	h1 := binary.BigEndian.Uint64(sum[:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+
+	offsets := make([]string, r.hashes)
+	for i := range offsets {
+		offsets[i] = strconv.FormatUint((h1+uint64(i)*h2)%r.bits, 10)
+	}
+	return offsets
+}
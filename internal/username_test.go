@@ -0,0 +1,168 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/credentials"
+	"github.com/rislah/fakes/internal/local"
+	"github.com/rislah/fakes/internal/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsernameImpl(t *testing.T) {
+	ctx := context.Background()
+	db := local.NewUserDB()
+	assert.NoError(t, db.CreateUser(ctx, app.User{Username: "alice", Password: "pass"}))
+	usernames := app.NewUsernameBackend(db, nil)
+
+	tests := []struct {
+		username string
+		res      app.UsernameAvailability
+		err      error
+	}{
+		{username: "", err: credentials.ErrUsernameMissing},
+		{username: "bob", err: credentials.ErrUsernameLength},
+		{username: "Alice", err: credentials.ErrUsernameRegexFail},
+		{username: "support", res: app.UsernameAvailability{Username: "support", Reason: app.UsernameReserved}},
+		{username: "alice", res: app.UsernameAvailability{Username: "alice", Reason: app.UsernameTaken}},
+		{username: "carol", res: app.UsernameAvailability{Username: "carol", Available: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.username, func(t *testing.T) {
+			res, err := usernames.CheckAvailability(ctx, test.username)
+			assert.Equal(t, test.err, err)
+			assert.Equal(t, test.res, res)
+		})
+	}
+}
+
+func TestRedisUsernameFilter(t *testing.T) {
+	ctx := context.Background()
+	client, err := local.NewRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := local.NewUserDB()
+	filter := app.NewRedisUsernameFilter(client, 1000, 0.01)
+	filteredDB := app.NewFilteredUserDB(db, filter)
+
+	assert.NoError(t, filteredDB.CreateUser(ctx, app.User{Username: "alice", Password: "pass"}))
+	for _, username := range []string{"alice", "carol"} {
+		mightExist, err := filter.MightExist(ctx, username)
+		assert.NoError(t, err)
+		assert.True(t, mightExist, "%s: an unbuilt filter rules nothing out", username)
+	}
+
+	assert.NoError(t, filter.Rebuild(ctx, db))
+
+	mightExist, err := filter.MightExist(ctx, "alice")
+	assert.NoError(t, err)
+	assert.True(t, mightExist)
+
+	mightExist, err = filter.MightExist(ctx, "carol")
+	assert.NoError(t, err)
+	assert.False(t, mightExist)
+
+	assert.NoError(t, filteredDB.CreateUser(ctx, app.User{Username: "carol", Password: "pass"}))
+	mightExist, err = filter.MightExist(ctx, "carol")
+	assert.NoError(t, err)
+	assert.True(t, mightExist, "created users are added")
+
+	usr, err := db.GetUserByUsername(ctx, "carol")
+	assert.NoError(t, err)
+	usr.Username = "carol_renamed"
+	assert.NoError(t, filteredDB.UpdateUser(ctx, usr))
+	mightExist, err = filter.MightExist(ctx, "carol_renamed")
+	assert.NoError(t, err)
+	assert.True(t, mightExist, "renamed users are added")
+
+	// A user the filter doesn't know of shows the database is skipped.
+	assert.NoError(t, db.CreateUser(ctx, app.User{Username: "dave", Password: "pass"}))
+	usernames := app.NewUsernameBackend(db, filter)
+
+	res, err := usernames.CheckAvailability(ctx, "dave")
+	assert.NoError(t, err)
+	assert.True(t, res.Available)
+
+	res, err = usernames.CheckAvailability(ctx, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, app.UsernameTaken, res.Reason)
+
+	assert.NoError(t, filter.Rebuild(ctx, db))
+	res, err = usernames.CheckAvailability(ctx, "dave")
+	assert.NoError(t, err)
+	assert.Equal(t, app.UsernameTaken, res.Reason, "rebuilding catches up with the database")
+}
+
+func TestRedisUsernameFilterRebuildLock(t *testing.T) {
+	ctx := context.Background()
+	client, err := local.NewRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := local.NewUserDB()
+	assert.NoError(t, db.CreateUser(ctx, app.User{Username: "alice", Password: "pass"}))
+	filter := app.NewRedisUsernameFilter(client, 1000, 0.01)
+
+	// Another instance is rebuilding.
+	assert.NoError(t, client.Set("usernames:bloom:rebuild", "other", time.Minute))
+	assert.NoError(t, client.Set("usernames:bloom:building", "partial", time.Minute))
+	assert.NoError(t, filter.Rebuild(ctx, db))
+
+	building, err := client.Get("usernames:bloom:building")
+	assert.NoError(t, err)
+	assert.Equal(t, "partial", building, "the other rebuild is left alone")
+
+	mightExist, err := filter.MightExist(ctx, "carol")
+	assert.NoError(t, err)
+	assert.True(t, mightExist, "the filter is still unbuilt")
+
+	assert.NoError(t, client.Del("usernames:bloom:rebuild"))
+	assert.NoError(t, filter.Rebuild(ctx, db))
+
+	mightExist, err = filter.MightExist(ctx, "carol")
+	assert.NoError(t, err)
+	assert.False(t, mightExist)
+	assert.False(t, client.Exists("usernames:bloom:rebuild"), "the lock is released")
+}
+
+func TestFilteredUserDBCreateUsers(t *testing.T) {
+	ctx := context.Background()
+	client, err := local.NewRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, teardown, err := sqlite.MakeUserDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	filter := app.NewRedisUsernameFilter(client, 1000, 0.01)
+	assert.NoError(t, filter.Rebuild(ctx, db))
+
+	filteredDB := app.NewFilteredUserDB(db, filter)
+	batcher, ok := filteredDB.(app.UserBatchCreator)
+	if !assert.True(t, ok, "batch creation is kept") {
+		return
+	}
+
+	userErrs, err := batcher.CreateUsers(ctx, []app.User{{Username: "alice", Password: "pass"}, {Username: "carol", Password: "pass", Role: "nope"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []error{nil, app.ErrRoleNotFound}, userErrs)
+
+	mightExist, err := filter.MightExist(ctx, "alice")
+	assert.NoError(t, err)
+	assert.True(t, mightExist, "created users are added")
+
+	mightExist, err = filter.MightExist(ctx, "carol")
+	assert.NoError(t, err)
+	assert.False(t, mightExist, "users that weren't created aren't added")
+}
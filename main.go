@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -11,10 +12,15 @@ import (
 
 	"github.com/kelseyhightower/envconfig"
 	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/audit"
 	"github.com/rislah/fakes/internal/circuitbreaker"
 	"github.com/rislah/fakes/internal/geoip"
 	"github.com/rislah/fakes/internal/jwt"
+	"github.com/rislah/fakes/internal/mysql"
 	"github.com/rislah/fakes/internal/postgres"
+	"github.com/rislah/fakes/internal/pow"
+	"github.com/rislah/fakes/internal/sqlite"
+	"github.com/sirupsen/logrus"
 
 	"github.com/rislah/fakes/api"
 	"github.com/rislah/fakes/internal/redis"
@@ -34,6 +40,22 @@ type config struct {
 	PgDB        string `default:"user"`
 	RedisHost   string `default:"localhost"`
 	RedisPort   string `default:"6379"`
+	// SqlitePath is the database file of the "sqlite" environment, which
+	// stores everything in SQLite instead of Postgres and needs no Redis.
+	SqlitePath string `default:"fakes.db"`
+	// MysqlDSN is the database of the "mysql" environment, which stores
+	// everything in MySQL instead of Postgres.
+	MysqlDSN string `default:"user:parool@tcp(127.0.0.1:3306)/user"`
+	// ScimToken enables SCIM provisioning for requests bearing it.
+	ScimToken string
+	// InviteOnly closes registration to everyone without an invite.
+	InviteOnly bool
+	// PowSecret enables proof-of-work challenges on registration and login
+	// under abuse, and signs them. Instances sharing a Redis must share it.
+	PowSecret string
+	// UsernameFilter answers username availability checks from a Bloom
+	// filter in Redis where it can, rebuilding it on startup.
+	UsernameFilter bool
 }
 
 func main() {
@@ -44,17 +66,35 @@ func main() {
 	}
 
 	log := logger.New(conf.Environment)
+	if len(os.Args) > 1 && os.Args[1] == "users" {
+		os.Exit(runUsers(conf, log, os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		os.Exit(runAudit(conf, log, os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	geoIPDB := initGeoIPDB("./GeoLite2-Country.mmdb")
 	jwtWrapper := jwt.NewHS256Wrapper(app.JWTSecret)
-	userDB := initUserDB(conf, log)
+	stores := initStores(conf, log)
+	userDB, orgDB, groupDB := stores.userDB, stores.orgDB, stores.groupDB
 	authenticator := app.NewAuthenticator(userDB, jwtWrapper)
-	userBackend := app.NewUserBackend(userDB, jwtWrapper)
+	rbac := app.NewRBAC(stores.roleDB)
 	ratelimiterRedisCB, err := circuitbreaker.New("redis_ratelimiter", circuitbreaker.Config{})
 	if err != nil {
 		log.Fatal("error creating rate limiter cb", err)
 	}
 	ratelimiterRedis := initRedis(conf, ratelimiterRedisCB, log)
-	mux := api.NewMux(userBackend, authenticator, jwtWrapper, geoIPDB, ratelimiterRedis, log)
+	usernameFilter := initUsernameFilter(conf, log, ratelimiterRedis, userDB)
+	userBackend := app.NewUserBackend(app.NewFilteredUserDB(userDB, usernameFilter), jwtWrapper)
+	auditLog := audit.NewLog(stores.auditStore)
+	privacyBackend := app.NewPrivacyBackend(userDB, orgDB, groupDB, stores.erasureDB, stores.identifierDB, app.NewRedisSessionStore(ratelimiterRedis), auditLog)
+	mux := api.NewMux(userBackend, app.NewOrganizationBackend(orgDB), app.NewGroupBackend(groupDB), privacyBackend, authenticator, rbac, jwtWrapper, geoIPDB, ratelimiterRedis, log).
+		WithSCIMToken(conf.ScimToken).
+		WithInvites(app.NewInviteBackend(userBackend, stores.inviteDB), conf.InviteOnly).
+		WithProofOfWork(initPowGuard(conf, ratelimiterRedis)).
+		WithUsernames(app.NewUsernameBackend(userDB, usernameFilter)).
+		WithIdentifiers(app.NewIdentifierBackend(userDB, stores.identifierDB, logVerificationSender{log: log})).
+		WithAuditLog(auditLog)
 	httpSrv := initHTTPServer(conf.ListenAddr, mux)
 
 	stopCh := make(chan os.Signal, 1)
@@ -80,43 +120,287 @@ func initHTTPServer(addr string, handler http.Handler) *http.Server {
 	return httpSrv
 }
 
-func initUserDB(conf config, log *logger.Logger) app.UserDB {
+// stores are the databases of the service. Each environment opens a single
+// database client that all of them share.
+type stores struct {
+	userDB       app.UserDB
+	roleDB       app.RoleDB
+	orgDB        app.OrganizationDB
+	groupDB      app.GroupDB
+	erasureDB    app.ErasureDB
+	identifierDB app.IdentifierDB
+	inviteDB     app.InviteDB
+	auditStore   audit.Store
+}
+
+func initStores(conf config, log *logger.Logger) stores {
 	switch conf.Environment {
 	case "local":
-		return local.NewUserDB()
-	case "development":
-		opts := postgres.Options{
-			ConnectionString: fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", conf.PgHost, conf.PgPort, conf.PgUser, conf.PgPass, conf.PgDB),
-			MaxIdleConns:     100,
-			MaxOpenConns:     100,
+		// The local user database stores everything else too, so that
+		// tenant-scoped user queries see the memberships and roles are
+		// validated against the role database when assigned.
+		userDB := local.NewUserDB()
+		return stores{
+			userDB:       userDB,
+			roleDB:       userDB,
+			orgDB:        userDB,
+			groupDB:      userDB,
+			erasureDB:    userDB,
+			identifierDB: userDB,
+			inviteDB:     userDB,
+			auditStore:   local.NewAuditStore(),
 		}
+	case "sqlite":
+		return initSQLiteStores(conf, log)
+	case "mysql":
+		return initMySQLStores(conf, log)
+	case "development":
+		return initPostgresStores(conf, log)
+	default:
+		panic("unknown environment")
+	}
+}
 
-		client, err := postgres.NewClient(opts)
-		if err != nil {
-			log.Fatal("init postgres client", err)
-		}
+// initSQLiteStores needs no circuits, as the database is a file of the
+// process.
+func initSQLiteStores(conf config, log *logger.Logger) stores {
+	client, err := sqlite.NewClient(conf.SqlitePath)
+	if err != nil {
+		log.Fatal("init sqlite client", err)
+	}
 
-		userDBCircuit, err := circuitbreaker.New("postgres_userdb", circuitbreaker.Config{})
-		if err != nil {
-			log.Fatal("error creating userdb circuit", err)
-		}
+	userDB, err := sqlite.NewUserDB(client)
+	if err != nil {
+		log.Fatal("init sqlite userdb", err)
+	}
 
-		redisCircuit, err := circuitbreaker.New("redis_cache_userdb", circuitbreaker.Config{})
-		if err != nil {
-			log.Fatal("error creating redis cache circuit", err)
-		}
+	roleDB, err := sqlite.NewRoleDB(client)
+	if err != nil {
+		log.Fatal("init sqlite roledb", err)
+	}
 
-		rd := initRedis(conf, redisCircuit, log)
-		db, err := postgres.NewCachedUserDB(client, rd, userDBCircuit)
-		if err != nil {
-			log.Fatal("init cached userdb", err)
+	orgDB, err := sqlite.NewOrganizationDB(client)
+	if err != nil {
+		log.Fatal("init sqlite organizationdb", err)
+	}
+
+	groupDB, err := sqlite.NewGroupDB(client)
+	if err != nil {
+		log.Fatal("init sqlite groupdb", err)
+	}
+
+	erasureDB, err := sqlite.NewErasureDB(client)
+	if err != nil {
+		log.Fatal("init sqlite erasuredb", err)
+	}
+
+	identifierDB, err := sqlite.NewIdentifierDB(client)
+	if err != nil {
+		log.Fatal("init sqlite identifierdb", err)
+	}
+
+	inviteDB, err := sqlite.NewInviteDB(client)
+	if err != nil {
+		log.Fatal("init sqlite invitedb", err)
+	}
+
+	auditStore, err := sqlite.NewAuditStore(client)
+	if err != nil {
+		log.Fatal("init sqlite auditstore", err)
+	}
+
+	return stores{
+		userDB:       userDB,
+		roleDB:       roleDB,
+		orgDB:        orgDB,
+		groupDB:      groupDB,
+		erasureDB:    erasureDB,
+		identifierDB: identifierDB,
+		inviteDB:     inviteDB,
+		auditStore:   auditStore,
+	}
+}
+
+func initMySQLStores(conf config, log *logger.Logger) stores {
+	client, err := mysql.NewClient(mysql.Options{DSN: conf.MysqlDSN})
+	if err != nil {
+		log.Fatal("init mysql client", err)
+	}
+
+	userDB, err := mysql.NewUserDB(client, initCircuit("mysql_userdb", log))
+	if err != nil {
+		log.Fatal("init mysql userdb", err)
+	}
+
+	roleDB, err := mysql.NewRoleDB(client, initCircuit("mysql_roledb", log))
+	if err != nil {
+		log.Fatal("init mysql roledb", err)
+	}
+
+	orgDB, err := mysql.NewOrganizationDB(client, initCircuit("mysql_organizationdb", log))
+	if err != nil {
+		log.Fatal("init mysql organizationdb", err)
+	}
+
+	groupDB, err := mysql.NewGroupDB(client, initCircuit("mysql_groupdb", log))
+	if err != nil {
+		log.Fatal("init mysql groupdb", err)
+	}
+
+	erasureDB, err := mysql.NewErasureDB(client, initCircuit("mysql_erasuredb", log))
+	if err != nil {
+		log.Fatal("init mysql erasuredb", err)
+	}
+
+	identifierDB, err := mysql.NewIdentifierDB(client, initCircuit("mysql_identifierdb", log))
+	if err != nil {
+		log.Fatal("init mysql identifierdb", err)
+	}
+
+	inviteDB, err := mysql.NewInviteDB(client, initCircuit("mysql_invitedb", log))
+	if err != nil {
+		log.Fatal("init mysql invitedb", err)
+	}
+
+	auditStore, err := mysql.NewAuditStore(client, initCircuit("mysql_auditstore", log))
+	if err != nil {
+		log.Fatal("init mysql auditstore", err)
+	}
+
+	return stores{
+		userDB:       userDB,
+		roleDB:       roleDB,
+		orgDB:        orgDB,
+		groupDB:      groupDB,
+		erasureDB:    erasureDB,
+		identifierDB: identifierDB,
+		inviteDB:     inviteDB,
+		auditStore:   auditStore,
+	}
+}
+
+func initPostgresStores(conf config, log *logger.Logger) stores {
+	client, err := postgres.NewClient(postgresOptions(conf))
+	if err != nil {
+		log.Fatal("init postgres client", err)
+	}
+
+	rd := initRedis(conf, initCircuit("redis_cache_userdb", log), log)
+	userDB, err := postgres.NewCachedUserDB(client, rd, initCircuit("postgres_userdb", log))
+	if err != nil {
+		log.Fatal("init postgres userdb", err)
+	}
+
+	roleDB, err := postgres.NewRoleDB(client, initCircuit("postgres_roledb", log))
+	if err != nil {
+		log.Fatal("init postgres roledb", err)
+	}
+
+	orgDB, err := postgres.NewOrganizationDB(client, initCircuit("postgres_organizationdb", log))
+	if err != nil {
+		log.Fatal("init postgres organizationdb", err)
+	}
+
+	groupDB, err := postgres.NewGroupDB(client, initCircuit("postgres_groupdb", log))
+	if err != nil {
+		log.Fatal("init postgres groupdb", err)
+	}
+
+	erasureDB, err := postgres.NewErasureDB(client, initCircuit("postgres_erasuredb", log))
+	if err != nil {
+		log.Fatal("init postgres erasuredb", err)
+	}
+
+	identifierDB, err := postgres.NewIdentifierDB(client, initCircuit("postgres_identifierdb", log))
+	if err != nil {
+		log.Fatal("init postgres identifierdb", err)
+	}
+
+	inviteDB, err := postgres.NewInviteDB(client, initCircuit("postgres_invitedb", log))
+	if err != nil {
+		log.Fatal("init postgres invitedb", err)
+	}
+
+	auditStore, err := postgres.NewAuditStore(client, initCircuit("postgres_auditstore", log))
+	if err != nil {
+		log.Fatal("init postgres auditstore", err)
+	}
+
+	return stores{
+		userDB:       userDB,
+		roleDB:       roleDB,
+		orgDB:        orgDB,
+		groupDB:      groupDB,
+		erasureDB:    erasureDB,
+		identifierDB: identifierDB,
+		inviteDB:     inviteDB,
+		auditStore:   auditStore,
+	}
+}
+
+func initCircuit(name string, log *logger.Logger) *circuit.Circuit {
+	cb, err := circuitbreaker.New(name, circuitbreaker.Config{})
+	if err != nil {
+		log.Fatal("error creating "+name+" circuit", err)
+	}
+
+	return cb
+}
+
+// initUsernameFilter returns nil unless the filter is enabled. The filter is
+// rebuilt in the background, and availability checks use the database until
+// it is done.
+func initUsernameFilter(conf config, log *logger.Logger, client redis.Client, userDB app.UserDB) app.UsernameFilter {
+	if !conf.UsernameFilter {
+		return nil
+	}
+
+	filter := newUsernameFilter(client)
+	go func() {
+		if err := filter.Rebuild(context.Background(), userDB); err != nil {
+			log.Error("rebuilding username filter", err)
 		}
+	}()
 
-		return db
-	default:
-		panic("unknown environment")
+	return filter
+}
+
+// newUsernameFilter returns the username filter shared by the instances
+// using client.
+func newUsernameFilter(client redis.Client) *app.RedisUsernameFilter {
+	return app.NewRedisUsernameFilter(client, 1000000, 0.01)
+}
+
+func initPowGuard(conf config, client redis.Client) *pow.Guard {
+	if conf.PowSecret == "" {
+		return nil
 	}
 
+	issuer := pow.NewIssuer([]byte(conf.PowSecret), pow.DefaultTTL, pow.NewRedisSpentStore(client))
+	return pow.NewGuard(issuer, pow.NewRedisSignals(client, pow.DefaultWindow), pow.DefaultPolicy)
+}
+
+// logVerificationSender stands in for email and SMS delivery, which the
+// service doesn't have yet, by logging the codes.
+type logVerificationSender struct {
+	log *logger.Logger
+}
+
+func (s logVerificationSender) SendVerificationCode(ctx context.Context, identifier app.Identifier, code string) error {
+	s.log.InfoWithFields("verification code", logrus.Fields{
+		"identifier_id": identifier.IdentifierID,
+		"type":          identifier.Type,
+		"code":          code,
+	})
+	return nil
+}
+
+func postgresOptions(conf config) postgres.Options {
+	return postgres.Options{
+		ConnectionString: fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", conf.PgHost, conf.PgPort, conf.PgUser, conf.PgPass, conf.PgDB),
+		MaxIdleConns:     100,
+		MaxOpenConns:     100,
+	}
 }
 
 // func initMetrics(cm *circuit.Manager) metrics.Metrics {
@@ -132,7 +416,18 @@ func initUserDB(conf config, log *logger.Logger) app.UserDB {
 // 	return mtr
 // }
 
+// initRedis connects to Redis, except in the "sqlite" environment, which
+// runs as a single process and keeps rate limits, sessions and challenges in
+// an in-process Redis instead.
 func initRedis(conf config, cb *circuit.Circuit, log *logger.Logger) redis.Client {
+	if conf.Environment == "sqlite" {
+		client, err := local.NewRedis()
+		if err != nil {
+			log.Fatal("init in-process redis", err)
+		}
+		return client
+	}
+
 	redis, err := redis.NewClient(fmt.Sprintf("%s:%s", conf.RedisHost, conf.RedisPort), cb, log)
 	if err != nil {
 		log.Fatal("init redis", err)
@@ -0,0 +1,24 @@
+ALTER TABLE role ADD CONSTRAINT role_name_key UNIQUE (name);
+
+INSERT INTO role (name) VALUES ('user'), ('developer');
+
+CREATE TABLE permission (
+    id   SERIAL PRIMARY KEY,
+    name TEXT   NOT NULL UNIQUE
+);
+
+CREATE TABLE role_permission (
+    role_id       INTEGER REFERENCES role(id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    permission_id INTEGER REFERENCES permission(id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+INSERT INTO permission (name) VALUES ('viewTest'), ('manageRoles');
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM role r, permission p
+WHERE (r.name, p.name) IN (('guest', 'viewTest'), ('developer', 'manageRoles'));
+
+ALTER TABLE user_role DROP CONSTRAINT user_role_role_id_fkey;
+ALTER TABLE user_role ADD CONSTRAINT user_role_role_id_fkey FOREIGN KEY (role_id) REFERENCES role(id) ON DELETE RESTRICT ON UPDATE CASCADE;
@@ -0,0 +1,11 @@
+CREATE TABLE role_parent (
+    role_id   INTEGER REFERENCES role(id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    parent_id INTEGER REFERENCES role(id) ON DELETE RESTRICT ON UPDATE CASCADE NOT NULL,
+    PRIMARY KEY (role_id, parent_id),
+    CHECK (role_id <> parent_id)
+);
+
+INSERT INTO role_parent (role_id, parent_id)
+SELECT r.id, p.id
+FROM role r, role p
+WHERE (r.name, p.name) IN (('user', 'guest'), ('developer', 'user'));
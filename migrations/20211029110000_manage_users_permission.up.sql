@@ -0,0 +1,6 @@
+INSERT INTO permission (name) VALUES ('manageUsers');
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM role r, permission p
+WHERE r.name = 'developer' AND p.name = 'manageUsers';
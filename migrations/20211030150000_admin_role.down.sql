@@ -0,0 +1,5 @@
+UPDATE user_role SET role_id = (SELECT id FROM role WHERE name = 'guest')
+WHERE role_id = (SELECT id FROM role WHERE name = 'admin');
+
+DELETE FROM role WHERE name = 'admin';
+DELETE FROM permission WHERE name = 'assignRoles';
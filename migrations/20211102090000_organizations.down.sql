@@ -0,0 +1,4 @@
+DELETE FROM permission WHERE name IN ('manageOrganizations', 'manageMembers');
+
+DROP TABLE organization_member;
+DROP TABLE organization;
@@ -0,0 +1,22 @@
+CREATE TABLE organization (
+    id         SERIAL      PRIMARY KEY,
+    org_id     UUID        NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    name       TEXT        NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE organization_member (
+    org_id  UUID    REFERENCES organization(org_id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    user_id UUID    REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    role_id INTEGER REFERENCES role(id) ON DELETE RESTRICT ON UPDATE CASCADE NOT NULL,
+    PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX organization_member_user_id_idx ON organization_member (user_id);
+
+INSERT INTO permission (name) VALUES ('manageOrganizations'), ('manageMembers');
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM role r, permission p
+WHERE r.name = 'admin' AND p.name IN ('manageOrganizations', 'manageMembers');
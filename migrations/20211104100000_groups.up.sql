@@ -0,0 +1,33 @@
+CREATE TABLE user_group (
+    id         SERIAL      PRIMARY KEY,
+    group_id   UUID        NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    name       TEXT        NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE group_role (
+    group_id UUID    REFERENCES user_group(group_id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    role_id  INTEGER REFERENCES role(id) ON DELETE RESTRICT ON UPDATE CASCADE NOT NULL,
+    PRIMARY KEY (group_id, role_id)
+);
+
+CREATE TABLE group_permission (
+    group_id      UUID    REFERENCES user_group(group_id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    permission_id INTEGER REFERENCES permission(id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    PRIMARY KEY (group_id, permission_id)
+);
+
+CREATE TABLE group_member (
+    group_id UUID REFERENCES user_group(group_id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    user_id  UUID REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX group_member_user_id_idx ON group_member (user_id);
+
+INSERT INTO permission (name) VALUES ('manageGroups');
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM role r, permission p
+WHERE r.name = 'admin' AND p.name = 'manageGroups';
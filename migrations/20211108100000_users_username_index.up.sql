@@ -0,0 +1,3 @@
+-- Serves the bytewise ordering, prefix filter and keyset cursor of paginated
+-- user listings.
+CREATE INDEX users_username_c_idx ON users (username COLLATE "C");
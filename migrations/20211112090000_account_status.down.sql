@@ -0,0 +1,7 @@
+DELETE FROM permission WHERE name = 'manageAccountStatus';
+
+ALTER TABLE users
+    DROP CONSTRAINT users_status_check,
+    DROP COLUMN status,
+    DROP COLUMN status_reason,
+    DROP COLUMN status_changed_at;
@@ -0,0 +1,12 @@
+ALTER TABLE users
+    ADD COLUMN status            TEXT        NOT NULL DEFAULT 'active',
+    ADD COLUMN status_reason     TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN status_changed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD CONSTRAINT users_status_check CHECK (status IN ('pending', 'active', 'suspended', 'deactivated'));
+
+INSERT INTO permission (name) VALUES ('manageAccountStatus');
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM role r, permission p
+WHERE r.name = 'admin' AND p.name = 'manageAccountStatus';
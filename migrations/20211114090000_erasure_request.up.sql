@@ -0,0 +1,14 @@
+-- Erasure requests don't reference users, as the record of a completed
+-- erasure outlives the user it erased.
+CREATE TABLE erasure_request (
+    id            SERIAL      PRIMARY KEY,
+    request_id    UUID        NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    user_id       UUID        NOT NULL,
+    status        TEXT        NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed')),
+    requested_at  TIMESTAMPTZ NOT NULL,
+    scheduled_for TIMESTAMPTZ NOT NULL,
+    completed_at  TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX erasure_request_pending_user_id_idx ON erasure_request (user_id) WHERE status = 'pending';
+CREATE INDEX erasure_request_pending_scheduled_for_idx ON erasure_request (scheduled_for) WHERE status = 'pending';
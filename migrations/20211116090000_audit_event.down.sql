@@ -0,0 +1,4 @@
+DELETE FROM permission WHERE name = 'viewAuditLog';
+
+DROP TABLE audit_event;
+DROP FUNCTION audit_event_append_only();
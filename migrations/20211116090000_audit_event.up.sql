@@ -0,0 +1,41 @@
+-- Audit events are chained by hash, and the triggers below make the table
+-- append-only. Actor and target IDs aren't foreign keys, as the record of an
+-- action outlives the users it involves.
+CREATE TABLE audit_event (
+    seq         BIGINT      PRIMARY KEY,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    action      TEXT        NOT NULL,
+    actor_id    TEXT        NOT NULL DEFAULT '',
+    actor       TEXT        NOT NULL DEFAULT '',
+    target_id   TEXT        NOT NULL DEFAULT '',
+    ip          TEXT        NOT NULL DEFAULT '',
+    details     JSONB       NOT NULL DEFAULT '{}',
+    prev_hash   TEXT        NOT NULL,
+    hash        TEXT        NOT NULL UNIQUE
+);
+
+CREATE INDEX audit_event_actor_id_idx ON audit_event (actor_id, seq) WHERE actor_id <> '';
+CREATE INDEX audit_event_target_id_idx ON audit_event (target_id, seq) WHERE target_id <> '';
+CREATE INDEX audit_event_action_idx ON audit_event (action, seq);
+CREATE INDEX audit_event_occurred_at_idx ON audit_event (occurred_at);
+
+CREATE FUNCTION audit_event_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_event is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_event_no_update_or_delete
+    BEFORE UPDATE OR DELETE ON audit_event
+    FOR EACH ROW EXECUTE FUNCTION audit_event_append_only();
+
+CREATE TRIGGER audit_event_no_truncate
+    BEFORE TRUNCATE ON audit_event
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_event_append_only();
+
+INSERT INTO permission (name) VALUES ('viewAuditLog');
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM role r, permission p
+WHERE r.name = 'admin' AND p.name = 'viewAuditLog';
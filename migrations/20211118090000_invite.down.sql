@@ -0,0 +1,3 @@
+DELETE FROM permission WHERE name = 'manageInvites';
+
+DROP TABLE invite;
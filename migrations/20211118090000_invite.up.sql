@@ -0,0 +1,23 @@
+-- Invites only store the hash of their code. Deleting a role deletes the
+-- invites for it.
+CREATE TABLE invite (
+    id         SERIAL      PRIMARY KEY,
+    invite_id  UUID        NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    code_hash  TEXT        NOT NULL UNIQUE,
+    email      TEXT        NOT NULL DEFAULT '',
+    role_id    INTEGER     NOT NULL REFERENCES role(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    max_uses   INTEGER     NOT NULL CHECK (max_uses > 0),
+    uses       INTEGER     NOT NULL DEFAULT 0 CHECK (uses >= 0 AND uses <= max_uses),
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_by TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX invite_expires_at_idx ON invite (expires_at);
+
+INSERT INTO permission (name) VALUES ('manageInvites');
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM role r, permission p
+WHERE r.name = 'admin' AND p.name = 'manageInvites';
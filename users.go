@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	app "github.com/rislah/fakes/internal"
+	"github.com/rislah/fakes/internal/audit"
+	"github.com/rislah/fakes/internal/circuitbreaker"
+	"github.com/rislah/fakes/internal/logger"
+)
+
+const usersUsage = `usage:
+  fakes users import [-format csv|jsonl] [-dry-run] [-batch-size n] [file]
+  fakes users export [-format csv|jsonl] [file]
+  fakes users process-erasures
+
+Without a file, import reads stdin and export writes stdout. The format
+defaults to the file extension, or csv. process-erasures erases the users
+whose erasure requests are due and is meant to run periodically.`
+
+// runUsers runs the users subcommand with args and returns the exit code.
+func runUsers(conf config, log *logger.Logger, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, usersUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "import":
+		return runUsersImport(conf, log, args[1:], stdin, stdout, stderr)
+	case "export":
+		return runUsersExport(conf, log, args[1:], stdout, stderr)
+	case "process-erasures":
+		return runUsersProcessErasures(conf, log, stdout, stderr)
+	default:
+		fmt.Fprintln(stderr, usersUsage)
+		return 2
+	}
+}
+
+func runUsersImport(conf config, log *logger.Logger, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("users import", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "", "csv or jsonl")
+	dryRun := flags.Bool("dry-run", false, "validate the file without creating users")
+	batchSize := flags.Int("batch-size", app.DefaultImportBatchSize, "users created per batch")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	in := stdin
+	if path := flags.Arg(0); path != "" && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+
+	opts := app.ImportOptions{
+		Format:    userFormat(*format, flags.Arg(0)),
+		DryRun:    *dryRun,
+		BatchSize: *batchSize,
+	}
+
+	// Imported usernames go into the username filter, which the server
+	// would otherwise only learn of when it next rebuilds the filter.
+	userDB := initStores(conf, log).userDB
+	if conf.UsernameFilter {
+		userDB = app.NewFilteredUserDB(userDB, newUsernameFilter(initRedis(conf, initCircuit("redis_username_filter", log), log)))
+	}
+
+	report, err := app.ImportUsers(context.Background(), userDB, in, opts)
+	for _, rowErr := range report.Errors {
+		fmt.Fprintln(stderr, rowErr.Error())
+	}
+
+	verb := "imported"
+	if opts.DryRun {
+		verb = "would import"
+	}
+	fmt.Fprintf(stdout, "%s %d of %d users\n", verb, report.Imported, report.Records)
+
+	if err != nil {
+		fmt.Fprintln(stderr, "import stopped:", err)
+		return 1
+	}
+
+	if len(report.Errors) > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+func runUsersExport(conf config, log *logger.Logger, args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("users export", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "", "csv or jsonl")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	out := stdout
+	if path := flags.Arg(0); path != "" && path != "-" {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+
+	exported, err := app.ExportUsers(context.Background(), initStores(conf, log).userDB, out, userFormat(*format, flags.Arg(0)))
+	if err != nil {
+		fmt.Fprintln(stderr, "export failed:", err)
+		return 1
+	}
+
+	fmt.Fprintf(stderr, "exported %d users\n", exported)
+	return 0
+}
+
+func runUsersProcessErasures(conf config, log *logger.Logger, stdout io.Writer, stderr io.Writer) int {
+	redisCB, err := circuitbreaker.New("redis_sessions", circuitbreaker.Config{})
+	if err != nil {
+		log.Fatal("error creating sessions redis cb", err)
+	}
+
+	stores := initStores(conf, log)
+	privacy := app.NewPrivacyBackend(stores.userDB, stores.orgDB, stores.groupDB, stores.erasureDB, stores.identifierDB, app.NewRedisSessionStore(initRedis(conf, redisCB, log)), audit.NewLog(stores.auditStore))
+
+	results, err := privacy.ProcessDueErasures(context.Background(), time.Now())
+	var erased, failed int
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			fmt.Fprintf(stderr, "request %s: %s\n", result.Request.RequestID, result.Err)
+			continue
+		}
+		erased++
+	}
+	fmt.Fprintf(stdout, "erased %d users, %d requests failed\n", erased, failed)
+
+	if err != nil {
+		fmt.Fprintln(stderr, "processing stopped:", err)
+		return 1
+	}
+
+	if failed > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// userFormat returns format, or the one named by the extension of path.
+func userFormat(format string, path string) app.UserFormat {
+	if format != "" {
+		return app.UserFormat(format)
+	}
+
+	if ext := strings.TrimPrefix(filepath.Ext(path), "."); ext == string(app.FormatJSONL) {
+		return app.FormatJSONL
+	}
+
+	return app.FormatCSV
+}